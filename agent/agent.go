@@ -14,7 +14,6 @@ import (
 	"google.golang.org/adk/memory"
 	"google.golang.org/adk/model/gemini"
 	"google.golang.org/adk/runner"
-	"google.golang.org/adk/session"
 )
 
 const SYSTEM_INSTRUCTION = `You are a QA Assistant. Your role is to help users with GitLab Issue Management, Test Scenarios (XLSX), and Recorded Automation Tests.
@@ -107,7 +106,7 @@ Your response:
 - /new <title> - Create a new issue
 - /help - Display this help message`
 
-func GetSessionService() session.Service {
+func GetSessionService() *RedisSessionService {
 	return NewRedisSessionService("qa_extension")
 }
 
@@ -148,13 +147,15 @@ func GetQARunner(ctx context.Context) (*runner.Runner, error) {
 	allTools = append(allTools, GetGitLabTools()...)
 	allTools = append(allTools, GetTestTools()...)
 	allTools = append(allTools, GetAutomationTools()...)
+	allTools = append(allTools, GetInteractionTools()...)
 
 	mainAgent, err := llmagent.New(llmagent.Config{
-		Name:        "qa_agent",
-		Model:       llm,
-		Description: "A QA Assistant that helps with GitLab issues, test scenarios, and automation test generation.",
-		Instruction: SYSTEM_INSTRUCTION,
-		Tools:       allTools,
+		Name:                "qa_agent",
+		Model:               llm,
+		Description:         "A QA Assistant that helps with GitLab issues, test scenarios, and automation test generation.",
+		Instruction:         SYSTEM_INSTRUCTION,
+		Tools:               allTools,
+		BeforeToolCallbacks: []llmagent.BeforeToolCallback{approveToolCall},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create main QA agent: %w", err)
@@ -222,8 +222,11 @@ For EACH test case in the scenario below:
 	prompt.WriteString(fmt.Sprintf("- Creator ID: %d\n", scenario.CreatorID))
 	prompt.WriteString(fmt.Sprintf("- Base URL: %s\n", scenario.AuthConfig.BaseURL))
 	prompt.WriteString(fmt.Sprintf("- Login URL: %s\n", scenario.AuthConfig.LoginURL))
-	prompt.WriteString(fmt.Sprintf("- Username: %s\n", scenario.AuthConfig.Username))
-	prompt.WriteString(fmt.Sprintf("- Password: %s\n", scenario.AuthConfig.Password))
+	prompt.WriteString(fmt.Sprintf("- Username: %s\n", scenario.AuthConfig.UsernameValue()))
+	prompt.WriteString(fmt.Sprintf("- Password: %s\n", scenario.AuthConfig.PromptPassword()))
+	if scenario.AuthConfig.CredentialID != "" {
+		prompt.WriteString("- Type the username and password placeholders exactly as written; the runner substitutes the real values.\n")
+	}
 
 	prompt.WriteString("\n## Test Scenario Data\n\n")
 
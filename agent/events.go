@@ -20,6 +20,7 @@ type EventEmitter struct {
 	correlationID string
 	startTime     time.Time
 	totalSteps    int
+	outcome       string
 }
 
 // Event types - these are the only valid values
@@ -34,6 +35,7 @@ const (
 	ResourceTypeScenario  = "scenario"
 	ResourceTypeRecording = "recording"
 	ResourceTypeSession   = "session"
+	ResourceTypeSuiteRun  = "suite_run"
 )
 
 // Stages - standardized across all event types
@@ -42,6 +44,11 @@ const (
 	StageProgress = "progress"
 	StageDone     = "done"
 	StageError    = "error"
+	// StageAwaitingInput marks an interactive run paused on a StreamPrompt
+	StageAwaitingInput = "awaiting_input"
+	// StageToken carries one chunk of streamed model output. Token events are
+	// only sent over the agent WebSocket and are never logged.
+	StageToken = "token"
 )
 
 // NewGenerationEmitter creates an emitter for test generation events.
@@ -54,6 +61,11 @@ func NewExecutionEmitter(ctx context.Context, recordingID string) *EventEmitter
 	return newEmitter(ctx, EventTypeExecution, ResourceTypeRecording, recordingID)
 }
 
+// NewSuiteRunEmitter creates an emitter for the progress of a suite run.
+func NewSuiteRunEmitter(ctx context.Context, suiteRunID string) *EventEmitter {
+	return newEmitter(ctx, EventTypeExecution, ResourceTypeSuiteRun, suiteRunID)
+}
+
 // NewAgentEmitter creates an emitter for agent tool/chat events.
 func NewAgentEmitter(ctx context.Context, sessionID string) *EventEmitter {
 	if sessionID == "" {
@@ -84,6 +96,14 @@ func (e *EventEmitter) SetTotalSteps(total int) *EventEmitter {
 	return e
 }
 
+// WithOutcome returns a copy of the emitter whose events report how the
+// operation ended. Use it for the final event of a run only.
+func (e *EventEmitter) WithOutcome(outcome string) *EventEmitter {
+	c := *e
+	c.outcome = outcome
+	return &c
+}
+
 // Elapsed returns the time since the emitter was created.
 func (e *EventEmitter) Elapsed() time.Duration {
 	return time.Since(e.startTime)
@@ -141,6 +161,20 @@ func (e *EventEmitter) ErrorWithCode(code, message, details string) error {
 	})
 }
 
+// AwaitingInput emits the prompt an interactive run is waiting on.
+func (e *EventEmitter) AwaitingInput(prompt *database.StreamPrompt) error {
+	event := e.event(StageAwaitingInput, prompt.Message, nil, nil)
+	event.Prompt = prompt
+	return database.PublishStreamEvent(e.ctx, event)
+}
+
+// Token builds a token event in the emitter's envelope without publishing it.
+func (e *EventEmitter) Token(text string) database.StreamEvent {
+	event := e.event(StageToken, text, nil, nil)
+	database.ResolveStreamOwner(e.ctx, &event)
+	return event
+}
+
 // ErrorFromErr emits an error event from a Go error.
 func (e *EventEmitter) ErrorFromErr(err error) error {
 	if err == nil {
@@ -151,7 +185,11 @@ func (e *EventEmitter) ErrorFromErr(err error) error {
 
 // emit is the internal method that publishes the event.
 func (e *EventEmitter) emit(stage, message string, stepInfo *database.StreamStepInfo, errorInfo *database.StreamErrorInfo) error {
-	event := database.StreamEvent{
+	return database.PublishStreamEvent(e.ctx, e.event(stage, message, stepInfo, errorInfo))
+}
+
+func (e *EventEmitter) event(stage, message string, stepInfo *database.StreamStepInfo, errorInfo *database.StreamErrorInfo) database.StreamEvent {
+	return database.StreamEvent{
 		Type:          e.eventType,
 		ResourceType:  e.resourceType,
 		ResourceID:    e.resourceID,
@@ -159,10 +197,10 @@ func (e *EventEmitter) emit(stage, message string, stepInfo *database.StreamStep
 		Message:       message,
 		StepInfo:      stepInfo,
 		ErrorInfo:     errorInfo,
+		Outcome:       e.outcome,
 		CorrelationID: e.correlationID,
 		Timestamp:     time.Now().Format(time.RFC3339),
 	}
-	return database.PublishStreamEvent(e.ctx, event)
 }
 
 // ============================================================================
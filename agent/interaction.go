@@ -0,0 +1,291 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"qa-extension-backend/database"
+
+	"github.com/google/uuid"
+	"google.golang.org/adk/tool"
+	"google.golang.org/adk/tool/functiontool"
+)
+
+// Interactive agent runs can be cancelled and can pause for the user: to
+// approve a tool call or to answer a question. Runs and pending prompts live in
+// the process that executes them; cancel and reply messages are relayed over
+// Redis so they reach that process whichever instance the client is connected to.
+
+// Prompt kinds
+const (
+	PromptApproval = "approval"
+	PromptQuestion = "question"
+)
+
+// PromptTimeout is how long a run waits for the user before giving up
+const PromptTimeout = 10 * time.Minute
+
+const agentControlChannel = "agent:control"
+
+var (
+	// ErrPromptTimeout is returned when the user did not answer in time
+	ErrPromptTimeout = errors.New("no answer from the user")
+	// ErrNotInteractive is returned when a run has no client that could answer
+	ErrNotInteractive = errors.New("this agent run is not interactive")
+)
+
+// ClientReply is the user's answer to a prompt
+type ClientReply struct {
+	Approved bool   `json:"approved"`
+	Answer   string `json:"answer,omitempty"`
+}
+
+type controlMessage struct {
+	Action    string      `json:"action"` // "cancel" | "reply"
+	SessionID string      `json:"sessionId,omitempty"`
+	PromptID  string      `json:"promptId,omitempty"`
+	UserID    int         `json:"userId"`
+	Reply     ClientReply `json:"reply"`
+}
+
+type activeRun struct {
+	cancel context.CancelFunc
+	userID int
+}
+
+type pendingPrompt struct {
+	userID int
+	reply  chan ClientReply
+}
+
+var control = struct {
+	sync.Mutex
+	runs    map[string]*activeRun
+	prompts map[string]*pendingPrompt
+}{
+	runs:    make(map[string]*activeRun),
+	prompts: make(map[string]*pendingPrompt),
+}
+
+type interactiveKey struct{}
+
+type interactiveSession struct {
+	sessionID string
+	userID    int
+}
+
+// StartInteractiveRun registers a cancellable run for a session. Tools called
+// with the returned context may ask the user for approval or answers. The
+// returned function must be called when the run ends.
+func StartInteractiveRun(ctx context.Context, sessionID string, userID int) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	ctx = context.WithValue(ctx, interactiveKey{}, interactiveSession{sessionID: sessionID, userID: userID})
+
+	control.Lock()
+	if prev, ok := control.runs[sessionID]; ok {
+		// A new message supersedes a run still going in the same session
+		prev.cancel()
+	}
+	run := &activeRun{cancel: cancel, userID: userID}
+	control.runs[sessionID] = run
+	control.Unlock()
+
+	return ctx, func() {
+		cancel()
+		control.Lock()
+		if control.runs[sessionID] == run {
+			delete(control.runs, sessionID)
+		}
+		control.Unlock()
+	}
+}
+
+// CancelRun asks whichever instance runs the session to stop it
+func CancelRun(ctx context.Context, sessionID string, userID int) error {
+	return publishControl(ctx, controlMessage{Action: "cancel", SessionID: sessionID, UserID: userID})
+}
+
+// ReplyToPrompt delivers the user's answer to a pending prompt
+func ReplyToPrompt(ctx context.Context, promptID string, userID int, reply ClientReply) error {
+	return publishControl(ctx, controlMessage{Action: "reply", PromptID: promptID, UserID: userID, Reply: reply})
+}
+
+func publishControl(ctx context.Context, msg controlMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return database.RedisClient.Publish(ctx, agentControlChannel, data).Err()
+}
+
+// ListenForAgentControl applies cancel and reply messages to the runs of this
+// process until ctx is done
+func ListenForAgentControl(ctx context.Context) {
+	sub := database.RedisClient.Subscribe(ctx, agentControlChannel)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-sub.Channel():
+			if !ok {
+				return
+			}
+			var msg controlMessage
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				continue
+			}
+			applyControl(msg)
+		}
+	}
+}
+
+// applyControl acts on a control message if the run or prompt lives here and
+// belongs to the sender. It reports whether anything was done.
+func applyControl(msg controlMessage) bool {
+	control.Lock()
+	defer control.Unlock()
+
+	switch msg.Action {
+	case "cancel":
+		run, ok := control.runs[msg.SessionID]
+		if !ok || run.userID != msg.UserID {
+			return false
+		}
+		run.cancel()
+		return true
+	case "reply":
+		prompt, ok := control.prompts[msg.PromptID]
+		if !ok || prompt.userID != msg.UserID {
+			return false
+		}
+		delete(control.prompts, msg.PromptID)
+		prompt.reply <- msg.Reply
+		return true
+	}
+	return false
+}
+
+// awaitUser emits a prompt on the session's stream and blocks until the user
+// replies, the run is cancelled or PromptTimeout passes
+func awaitUser(ctx context.Context, prompt *database.StreamPrompt) (ClientReply, error) {
+	session, ok := ctx.Value(interactiveKey{}).(interactiveSession)
+	if !ok {
+		return ClientReply{}, ErrNotInteractive
+	}
+
+	prompt.ID = uuid.NewString()
+	pending := &pendingPrompt{userID: session.userID, reply: make(chan ClientReply, 1)}
+	control.Lock()
+	control.prompts[prompt.ID] = pending
+	control.Unlock()
+	defer func() {
+		control.Lock()
+		delete(control.prompts, prompt.ID)
+		control.Unlock()
+	}()
+
+	events := NewAgentEmitter(ctx, session.sessionID)
+	if err := events.AwaitingInput(prompt); err != nil {
+		return ClientReply{}, fmt.Errorf("failed to send prompt: %w", err)
+	}
+
+	timer := time.NewTimer(PromptTimeout)
+	defer timer.Stop()
+	select {
+	case reply := <-pending.reply:
+		return reply, nil
+	case <-ctx.Done():
+		return ClientReply{}, ctx.Err()
+	case <-timer.C:
+		events.Progressf("No answer received for: %s", prompt.Message)
+		return ClientReply{}, ErrPromptTimeout
+	}
+}
+
+// toolsRequiringApproval change data outside the conversation. In interactive
+// runs the user confirms each call before it happens.
+var toolsRequiringApproval = map[string]string{
+	"createGitLabIssue":    "Create a GitLab issue",
+	"updateGitLabIssue":    "Update a GitLab issue",
+	"createBugFromFailure": "File a GitLab bug from a failed run",
+	"save_automation_test": "Save the generated automation test",
+}
+
+// approveToolCall is a BeforeToolCallback that pauses interactive runs until
+// the user approves a tool from toolsRequiringApproval. Declined calls return a
+// result telling the model so, without running the tool.
+func approveToolCall(ctx tool.Context, t tool.Tool, args map[string]any) (map[string]any, error) {
+	action, gated := toolsRequiringApproval[t.Name()]
+	if !gated {
+		return nil, nil
+	}
+	if _, ok := ctx.Value(interactiveKey{}).(interactiveSession); !ok {
+		return nil, nil
+	}
+
+	reply, err := awaitUser(ctx, &database.StreamPrompt{
+		Kind:     PromptApproval,
+		Message:  fmt.Sprintf("%s?", action),
+		ToolName: t.Name(),
+		Args:     args,
+	})
+	if err != nil {
+		log.Printf("[AgentApproval] %s not approved: %v", t.Name(), err)
+		return map[string]any{"status": "not_approved", "message": fmt.Sprintf("The user did not approve %s: %v", t.Name(), err)}, nil
+	}
+	if !reply.Approved {
+		result := map[string]any{"status": "declined", "message": fmt.Sprintf("The user declined %s. Do not retry it unless they ask.", t.Name())}
+		if reply.Answer != "" {
+			result["userComment"] = reply.Answer
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
+type AskUserArgs struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"`
+}
+
+type AskUserResponse struct {
+	Answer string `json:"answer"`
+}
+
+func askUser(ctx tool.Context, args AskUserArgs) (*AskUserResponse, error) {
+	if args.Question == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	reply, err := awaitUser(ctx, &database.StreamPrompt{
+		Kind:    PromptQuestion,
+		Message: args.Question,
+		Options: args.Options,
+	})
+	if errors.Is(err, ErrNotInteractive) {
+		return nil, fmt.Errorf("the user can't answer mid-run in this chat; ask the question in your reply instead")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AskUserResponse{Answer: reply.Answer}, nil
+}
+
+// GetInteractionTools returns the tools that talk to the user mid-run
+func GetInteractionTools() []tool.Tool {
+	tools := []tool.Tool{}
+
+	t1, _ := functiontool.New(functiontool.Config{
+		Name:        "askUser",
+		Description: "Ask the user a clarifying question and wait for their answer. Only use this when you cannot continue without the answer; optionally suggest answers in options.",
+	}, askUser)
+	tools = append(tools, t1)
+
+	return tools
+}
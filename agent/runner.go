@@ -6,11 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"qa-extension-backend/client"
+	"qa-extension-backend/database"
 	"qa-extension-backend/internal/models"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
@@ -21,6 +26,39 @@ var (
 	globalBrowser playwright.Browser
 )
 
+// routeEnvironmentHeaders adds headers to every request the context sends to
+// one of origins and lets other requests through unchanged
+func routeEnvironmentHeaders(pwCtx playwright.BrowserContext, origins []string, headers map[string]string) error {
+	return pwCtx.Route("**/*", func(route playwright.Route) {
+		req := route.Request()
+		if !slices.Contains(origins, requestOrigin(req.URL())) {
+			if err := route.Continue(); err != nil {
+				log.Printf("[Runner] Failed to continue request %s: %v", req.URL(), err)
+			}
+			return
+		}
+		merged, err := req.AllHeaders()
+		if err != nil {
+			merged = req.Headers()
+		}
+		for name, value := range headers {
+			merged[strings.ToLower(name)] = value
+		}
+		if err := route.Continue(playwright.RouteContinueOptions{Headers: merged}); err != nil {
+			log.Printf("[Runner] Failed to continue request %s: %v", req.URL(), err)
+		}
+	})
+}
+
+// requestOrigin is the scheme://host of a request URL
+func requestOrigin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
 func InitPlaywright() error {
 	log.Printf("[DEBUG] Starting InitPlaywright")
 	log.Printf("[DEBUG] PLAYWRIGHT_NODEJS_PATH: %s", os.Getenv("PLAYWRIGHT_NODEJS_PATH"))
@@ -75,12 +113,32 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 
 	// Create event emitter for consistent event publishing
 	events := NewExecutionEmitter(ctx, run.ID).SetTotalSteps(len(run.Steps))
-	events.Start("Starting test '%s' (%d steps)...", run.Name, len(run.Steps))
+	if run.Environment != nil {
+		events.Start("Starting test '%s' (%d steps) against %s...", run.Name, len(run.Steps), run.Environment.Name)
+	} else {
+		events.Start("Starting test '%s' (%d steps)...", run.Name, len(run.Steps))
+	}
+
+	// Environment headers may reference stored credentials; resolve them here
+	// like step values. They are only added to requests to the environment's
+	// own origins, never to third parties the page loads.
+	var extraHeaders map[string]string
+	if run.Environment != nil && len(run.Environment.Headers) > 0 {
+		extraHeaders = make(map[string]string, len(run.Environment.Headers))
+		for k, v := range run.Environment.Headers {
+			resolved, err := database.ResolveCredentialPlaceholders(ctx, run.ProjectID, v)
+			if err != nil {
+				events.WithOutcome("failed").Error(fmt.Sprintf("Failed to resolve header %s: %v", k, err))
+				return nil, err
+			}
+			extraHeaders[k] = resolved
+		}
+	}
 
 	if globalBrowser == nil {
 		events.Progress("Initializing Playwright browser...")
 		if err := InitPlaywright(); err != nil {
-			events.Error(fmt.Sprintf("Failed to initialize Playwright: %v", err))
+			events.WithOutcome("failed").Error(fmt.Sprintf("Failed to initialize Playwright: %v", err))
 			return nil, err
 		}
 	}
@@ -118,6 +176,13 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 	}
 	defer pwCtx.Close()
 
+	if len(extraHeaders) > 0 {
+		if err := routeEnvironmentHeaders(pwCtx, run.Environment.HeaderOrigins, extraHeaders); err != nil {
+			events.WithOutcome("failed").Error(fmt.Sprintf("Failed to set environment headers: %v", err))
+			return nil, err
+		}
+	}
+
 	page, err := pwCtx.NewPage()
 	if err != nil {
 		return nil, fmt.Errorf("could not create page: %w", err)
@@ -175,6 +240,11 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		log.Printf("[Runner] Stealth scripts injected successfully")
 	}
 
+	// Diagnostics kept on the result for bug reports
+	var diagMu sync.Mutex
+	var consoleErrors []models.ConsoleLogEntry
+	var failedRequests []models.NetworkRequestEntry
+
 	// CRITICAL: Capture browser console logs and page errors
 	page.OnConsole(func(msg playwright.ConsoleMessage) {
 		logType := msg.Type()
@@ -182,6 +252,9 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		switch logType {
 		case "error":
 			log.Printf("[Browser Console ERROR] %s", text)
+			diagMu.Lock()
+			consoleErrors = append(consoleErrors, models.ConsoleLogEntry{Level: "error", Message: text, Timestamp: time.Now().UnixMilli()})
+			diagMu.Unlock()
 		case "warning":
 			log.Printf("[Browser Console WARN] %s", text)
 		default:
@@ -191,6 +264,9 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 
 	page.OnPageError(func(err error) {
 		log.Printf("[Browser Page ERROR] %v", err)
+		diagMu.Lock()
+		consoleErrors = append(consoleErrors, models.ConsoleLogEntry{Level: "error", Message: err.Error(), Source: "pageerror", Timestamp: time.Now().UnixMilli()})
+		diagMu.Unlock()
 	})
 
 	page.OnRequest(func(request playwright.Request) {
@@ -212,6 +288,13 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		}
 		headers, _ := json.Marshal(headersMap)
 		log.Printf("[Browser Network FAILED] %s %s | Cf-Ray: %s | Headers: %s", request.Method(), request.URL(), cfRay, string(headers))
+		entry := models.NetworkRequestEntry{URL: request.URL(), Method: request.Method(), Timestamp: time.Now().UnixMilli()}
+		if failure := request.Failure(); failure != nil {
+			entry.Error = failure.Error()
+		}
+		diagMu.Lock()
+		failedRequests = append(failedRequests, entry)
+		diagMu.Unlock()
 	})
 
 	// Capture responses with error status codes
@@ -227,6 +310,15 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		if status >= 400 {
 			reqHeaders, _ := json.Marshal(response.Request().Headers())
 			log.Printf("[Browser Network ERROR] %d %s %s | Cf-Ray: %s | Req Headers: %s | Res Headers: %s", status, response.Request().Method(), response.URL(), cfRay, string(reqHeaders), string(resHeaders))
+			diagMu.Lock()
+			failedRequests = append(failedRequests, models.NetworkRequestEntry{
+				URL:        response.URL(),
+				Method:     response.Request().Method(),
+				Status:     status,
+				StatusText: response.StatusText(),
+				Timestamp:  time.Now().UnixMilli(),
+			})
+			diagMu.Unlock()
 		}
 	})
 
@@ -235,6 +327,19 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		Status:      "passed",
 		StepResults: make([]models.TestStepResult, 0),
 	}
+	if run.Environment != nil {
+		env := run.Environment.EnvironmentRef
+		result.Environment = &env
+	}
+	defer func() {
+		if result == nil {
+			return
+		}
+		diagMu.Lock()
+		result.ConsoleErrors = consoleErrors
+		result.FailedRequests = failedRequests
+		diagMu.Unlock()
+	}()
 
 	totalSteps := len(run.Steps)
 	for i, step := range run.Steps {
@@ -246,7 +351,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 			stepCancel()
 			result.Status = "timeout"
 			result.Log = "Test execution timed out during step execution"
-			events.Error("Test timed out during step execution")
+			events.WithOutcome(result.Status).Error("Test timed out during step execution")
 			return result, nil
 		default:
 		}
@@ -263,7 +368,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		// Execute the step using a separate goroutine to handle per-step timeout
 		errChan := make(chan error, 1)
 		go func() {
-			errChan <- executeStep(page, step)
+			errChan <- executeStep(page, run.ProjectID, step)
 		}()
 
 		var err error
@@ -297,7 +402,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 
 			// If it's a timeout or serious error, stop immediately
 			if result.Status == "timeout" || result.Status == "failed" {
-				events.Error(fmt.Sprintf("Test failed at Step %d/%d: %s", currentStep, totalSteps, step.Description))
+				events.WithOutcome(result.Status).Error(fmt.Sprintf("Test failed at Step %d/%d: %s", currentStep, totalSteps, step.Description))
 				return result, nil
 			}
 			break
@@ -319,7 +424,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 	case <-ctx.Done():
 		result.Status = "timeout"
 		result.Log = "Test execution timed out after final step"
-		events.Error("Test timed out after final step")
+		events.WithOutcome(result.Status).Error("Test timed out after final step")
 		return result, nil
 	case <-time.After(200 * time.Millisecond):
 	}
@@ -336,7 +441,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 	case <-ctx.Done():
 		result.Status = "timeout"
 		result.Log = "Test execution timed out during video finalization"
-		events.Error("Test timed out during video finalization")
+		events.WithOutcome(result.Status).Error("Test timed out during video finalization")
 		return result, nil
 	case <-time.After(300 * time.Millisecond):
 	}
@@ -367,7 +472,7 @@ func RunTest(ctx context.Context, run *models.TestRun) (result *models.TestResul
 		}
 	}
 
-	events.Done("Test '%s' completed: %s", run.Name, result.Status)
+	events.WithOutcome(result.Status).Done("Test '%s' completed: %s", run.Name, result.Status)
 	return result, nil
 }
 
@@ -596,7 +701,7 @@ func RunTestsChained(ctx context.Context, runs []models.TestRun) []*models.TestR
 		for stepIdx, step := range rec.Steps {
 			events.Progressf("Step %d: %s", stepIdx+1, step.Action)
 
-			if err := executeStep(page, step); err != nil {
+			if err := executeStep(page, rec.ProjectID, step); err != nil {
 				// Mark as failed, take screenshot, but DO NOT abort the whole chain yet (unless you want to)
 				result.Status = "failed"
 				result.Log = fmt.Sprintf("Step %d failed: %v", stepIdx+1, err)
@@ -690,8 +795,42 @@ func RunTestsChained(ctx context.Context, runs []models.TestRun) []*models.TestR
 	return results
 }
 
-func executeStep(page playwright.Page, step models.RecordingStep) error {
-	log.Printf("[Runner] Executing action: %s on selector: %s with value: %s", step.Action, step.Selector, step.Value)
+// sensitiveStepHint flags steps whose literal value must not be logged
+var sensitiveStepHint = regexp.MustCompile(`(?i)pass(word)?|secret|token|otp|pin\b`)
+
+// loggableValue is a step value safe to log: placeholders are shown as-is,
+// literal values typed into secret-looking fields are masked
+func loggableValue(step models.RecordingStep) string {
+	if step.Value != "" && !models.CredentialPlaceholderPattern.MatchString(step.Value) &&
+		sensitiveStepHint.MatchString(step.Selector+" "+step.Description) {
+		return "••••••"
+	}
+	return step.Value
+}
+
+// apiRequestURL resolves a relative api_request endpoint against the page the
+// test is on, the way the page's own fetch calls would
+func apiRequestURL(pageURL, endpoint string) string {
+	ref, err := url.Parse(endpoint)
+	if err != nil || ref.IsAbs() {
+		return endpoint
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil || base.Host == "" {
+		return endpoint
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func executeStep(page playwright.Page, projectID string, step models.RecordingStep) error {
+	log.Printf("[Runner] Executing action: %s on selector: %s with value: %s", step.Action, step.Selector, loggableValue(step))
+
+	// Credential placeholders are resolved here and nowhere else, so the real
+	// values never reach stored steps, logs or events
+	value, err := database.ResolveStepCredentials(context.Background(), projectID, step)
+	if err != nil {
+		return fmt.Errorf("failed to resolve credentials: %w", err)
+	}
 
 	// Helper: Wait for page to settle after navigation (React/Angular apps need time)
 	waitForPageSettled := func() error {
@@ -881,7 +1020,7 @@ func executeStep(page playwright.Page, step models.RecordingStep) error {
 
 	switch step.Action {
 	case "navigate":
-		url := step.Value
+		url := value
 		if url == "" && step.Selector != "" {
 			url = step.Selector
 		}
@@ -924,7 +1063,7 @@ func executeStep(page playwright.Page, step models.RecordingStep) error {
 			return fmt.Errorf("type failed: %w", err)
 		}
 
-		log.Printf("[Runner] Typing '%s' into resolved element (selector: %s)", step.Value, usedSelector)
+		log.Printf("[Runner] Typing '%s' into resolved element (selector: %s)", loggableValue(step), usedSelector)
 
 		// Use .First() to avoid strict mode violation with multiple matches
 		locator := page.Locator(usedSelector).First()
@@ -937,7 +1076,7 @@ func executeStep(page playwright.Page, step models.RecordingStep) error {
 		typeOptions := playwright.LocatorTypeOptions{
 			Delay: &delay,
 		}
-		if err := locator.Type(step.Value, typeOptions); err != nil {
+		if err := locator.Type(value, typeOptions); err != nil {
 			return fmt.Errorf("type action failed: %w", err)
 		}
 
@@ -989,11 +1128,11 @@ func executeStep(page playwright.Page, step models.RecordingStep) error {
 			return fmt.Errorf("press failed: %w", err)
 		}
 
-		log.Printf("[Runner] Pressing '%s' on resolved element (selector: %s)", step.Value, usedSelector)
+		log.Printf("[Runner] Pressing '%s' on resolved element (selector: %s)", loggableValue(step), usedSelector)
 
 		// Use .First() to avoid strict mode violation with multiple matches
 		locator := page.Locator(usedSelector).First()
-		if err := locator.Press(step.Value); err != nil {
+		if err := locator.Press(value); err != nil {
 			return fmt.Errorf("press action failed: %w", err)
 		}
 
@@ -1030,6 +1169,38 @@ func executeStep(page playwright.Page, step models.RecordingStep) error {
 		log.Printf("[Runner] Assert passed for selector: %s", usedSelector)
 		return nil
 
+	case "api_request":
+		// Sent through the page's request context, so it shares the browser's cookies
+		method := strings.ToUpper(step.ApiMethod)
+		if method == "" {
+			method = "GET"
+		}
+		options := playwright.APIRequestContextFetchOptions{
+			Method:  playwright.String(method),
+			Timeout: playwright.Float(30000),
+		}
+		if strings.TrimSpace(step.ApiHeaders) != "" {
+			var headers map[string]string
+			if err := json.Unmarshal([]byte(step.ApiHeaders), &headers); err != nil {
+				return fmt.Errorf("api_request failed: headers are not a JSON object of strings: %w", err)
+			}
+			options.Headers = headers
+		}
+		if step.ApiPayload != "" {
+			options.Data = step.ApiPayload
+		}
+
+		endpoint := apiRequestURL(page.URL(), step.ApiEndpoint)
+		log.Printf("[Runner] Sending %s %s", method, endpoint)
+		resp, err := page.Request().Fetch(endpoint, options)
+		if err != nil {
+			return fmt.Errorf("api_request failed: %w", err)
+		}
+		defer resp.Dispose()
+		if !resp.Ok() {
+			return fmt.Errorf("api_request failed: %s %s returned %d %s", method, endpoint, resp.Status(), resp.StatusText())
+		}
+
 	default:
 		return fmt.Errorf("unknown action: %s", step.Action)
 	}
@@ -3,14 +3,37 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"iter"
+	"os"
 	"qa-extension-backend/database"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"google.golang.org/adk/session"
 )
 
+// Sessions are stored per user at agent:session:<userID>:<sessionID>, so a
+// session ID only reaches its owner's conversation. agent:sessions:<userID>
+// indexes them by last update for listing and retention.
+
+// ErrSessionNotFound is returned for sessions that don't exist, expired or
+// belong to another user
+var ErrSessionNotFound = errors.New("session not found")
+
+const (
+	// defaultSessionRetentionDays is how long an idle session is kept
+	defaultSessionRetentionDays = 30
+	// defaultMaxSessionsPerUser caps the conversations kept per user; the
+	// least recently used are dropped first
+	defaultMaxSessionsPerUser = 100
+	// sessionTitleLength is the length of titles taken from the first message
+	sessionTitleLength = 80
+)
+
 type RedisSessionService struct {
 	AppName string
 }
@@ -23,24 +46,75 @@ type sessionData struct {
 	ID             string           `json:"id"`
 	AppName        string           `json:"appName"`
 	UserID         string           `json:"userId"`
+	Title          string           `json:"title,omitempty"`
 	State          map[string]any   `json:"state"`
 	Events         []*session.Event `json:"events"`
+	CreatedAt      time.Time        `json:"createdAt"`
 	LastUpdateTime time.Time        `json:"lastUpdateTime"`
 }
 
+// Conversation summarises a session for listing
+type Conversation struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	MessageCount int       `json:"messageCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// TranscriptMessage is one message of an exported conversation
+type TranscriptMessage struct {
+	Role      string    `json:"role"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// sessionRetention returns how long idle sessions are kept, from
+// AGENT_SESSION_RETENTION_DAYS
+func sessionRetention() time.Duration {
+	days := defaultSessionRetentionDays
+	if v, err := strconv.Atoi(os.Getenv("AGENT_SESSION_RETENTION_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// maxSessionsPerUser returns the per-user session cap, from
+// AGENT_SESSION_MAX_PER_USER
+func maxSessionsPerUser() int64 {
+	if v, err := strconv.Atoi(os.Getenv("AGENT_SESSION_MAX_PER_USER")); err == nil && v > 0 {
+		return int64(v)
+	}
+	return defaultMaxSessionsPerUser
+}
+
+func sessionKey(userID, sessionID string) string {
+	return fmt.Sprintf("agent:session:%s:%s", userID, sessionID)
+}
+
+func sessionIndexKey(userID string) string {
+	return fmt.Sprintf("agent:sessions:%s", userID)
+}
+
 func (s *RedisSessionService) Create(ctx context.Context, req *session.CreateRequest) (*session.CreateResponse, error) {
 	sessionID := req.SessionID
 	if sessionID == "" {
 		return nil, fmt.Errorf("session_id is required for stateful sessions")
 	}
+	if req.UserID == "" {
+		return nil, fmt.Errorf("user_id is required for stateful sessions")
+	}
 
+	now := time.Now()
 	data := &sessionData{
 		ID:             sessionID,
 		AppName:        req.AppName,
 		UserID:         req.UserID,
 		State:          req.State,
 		Events:         []*session.Event{},
-		LastUpdateTime: time.Now(),
+		CreatedAt:      now,
+		LastUpdateTime: now,
 	}
 	if data.State == nil {
 		data.State = make(map[string]any)
@@ -57,7 +131,7 @@ func (s *RedisSessionService) Create(ctx context.Context, req *session.CreateReq
 }
 
 func (s *RedisSessionService) Get(ctx context.Context, req *session.GetRequest) (*session.GetResponse, error) {
-	data, err := s.load(ctx, req.SessionID)
+	data, err := s.load(ctx, req.UserID, req.SessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -67,13 +141,65 @@ func (s *RedisSessionService) Get(ctx context.Context, req *session.GetRequest)
 	}, nil
 }
 
+// List returns the user's sessions, most recently updated first
 func (s *RedisSessionService) List(ctx context.Context, req *session.ListRequest) (*session.ListResponse, error) {
-	return &session.ListResponse{}, nil
+	sessions, err := s.loadAll(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &session.ListResponse{}
+	for _, data := range sessions {
+		resp.Sessions = append(resp.Sessions, &redisSession{data: data, service: s})
+	}
+	return resp, nil
 }
 
 func (s *RedisSessionService) Delete(ctx context.Context, req *session.DeleteRequest) error {
-	key := fmt.Sprintf("agent:session:%s", req.SessionID)
-	return database.RedisClient.Del(ctx, key).Err()
+	pipe := database.RedisClient.TxPipeline()
+	del := pipe.Del(ctx, sessionKey(req.UserID, req.SessionID))
+	pipe.ZRem(ctx, sessionIndexKey(req.UserID), req.SessionID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if del.Val() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// ListConversations summarises the user's sessions, most recently updated first
+func (s *RedisSessionService) ListConversations(ctx context.Context, userID string) ([]Conversation, error) {
+	sessions, err := s.loadAll(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	conversations := make([]Conversation, 0, len(sessions))
+	for _, data := range sessions {
+		conversations = append(conversations, data.conversation())
+	}
+	return conversations, nil
+}
+
+// GetConversation returns a session's summary and transcript
+func (s *RedisSessionService) GetConversation(ctx context.Context, userID, sessionID string) (Conversation, []TranscriptMessage, error) {
+	data, err := s.load(ctx, userID, sessionID)
+	if err != nil {
+		return Conversation{}, nil, err
+	}
+	return data.conversation(), data.transcript(), nil
+}
+
+// RenameSession sets a session's title
+func (s *RedisSessionService) RenameSession(ctx context.Context, userID, sessionID, title string) (Conversation, error) {
+	data, err := s.load(ctx, userID, sessionID)
+	if err != nil {
+		return Conversation{}, err
+	}
+	data.Title = title
+	if err := s.save(ctx, data); err != nil {
+		return Conversation{}, err
+	}
+	return data.conversation(), nil
 }
 
 func (s *RedisSessionService) AppendEvent(ctx context.Context, sess session.Session, event *session.Event) error {
@@ -84,6 +210,9 @@ func (s *RedisSessionService) AppendEvent(ctx context.Context, sess session.Sess
 
 	rs.data.Events = append(rs.data.Events, event)
 	rs.data.LastUpdateTime = event.Timestamp
+	if rs.data.Title == "" && event.Author == "user" {
+		rs.data.Title = titleFromText(eventText(event))
+	}
 
 	if event.Actions.StateDelta != nil {
 		for k, v := range event.Actions.StateDelta {
@@ -94,18 +223,49 @@ func (s *RedisSessionService) AppendEvent(ctx context.Context, sess session.Sess
 	return s.save(ctx, rs.data)
 }
 
+// save stores the session and applies the retention rules: idle sessions
+// expire after the retention period and users keep at most
+// maxSessionsPerUser sessions
 func (s *RedisSessionService) save(ctx context.Context, data *sessionData) error {
-	key := fmt.Sprintf("agent:session:%s", data.ID)
 	val, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
-	return database.RedisClient.Set(ctx, key, val, 24*time.Hour).Err()
+	retention := sessionRetention()
+	indexKey := sessionIndexKey(data.UserID)
+
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, sessionKey(data.UserID, data.ID), val, retention)
+	pipe.ZAdd(ctx, indexKey, redis.Z{Score: float64(data.LastUpdateTime.Unix()), Member: data.ID})
+	pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(time.Now().Add(-retention).Unix(), 10))
+	pipe.Expire(ctx, indexKey, retention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	// Drop the least recently used sessions over the cap
+	excess, err := database.RedisClient.ZCard(ctx, indexKey).Result()
+	if err != nil || excess <= maxSessionsPerUser() {
+		return err
+	}
+	oldest, err := database.RedisClient.ZRange(ctx, indexKey, 0, excess-maxSessionsPerUser()-1).Result()
+	if err != nil || len(oldest) == 0 {
+		return err
+	}
+	pipe = database.RedisClient.TxPipeline()
+	for _, id := range oldest {
+		pipe.Del(ctx, sessionKey(data.UserID, id))
+		pipe.ZRem(ctx, indexKey, id)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
-func (s *RedisSessionService) load(ctx context.Context, sessionID string) (*sessionData, error) {
-	key := fmt.Sprintf("agent:session:%s", sessionID)
-	val, err := database.RedisClient.Get(ctx, key).Result()
+func (s *RedisSessionService) load(ctx context.Context, userID, sessionID string) (*sessionData, error) {
+	val, err := database.RedisClient.Get(ctx, sessionKey(userID, sessionID)).Result()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +278,89 @@ func (s *RedisSessionService) load(ctx context.Context, sessionID string) (*sess
 	return &data, nil
 }
 
+// loadAll loads the user's indexed sessions, most recently updated first,
+// and drops index entries of sessions that expired
+func (s *RedisSessionService) loadAll(ctx context.Context, userID string) ([]*sessionData, error) {
+	indexKey := sessionIndexKey(userID)
+	ids, err := database.RedisClient.ZRevRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*sessionData
+	for _, id := range ids {
+		data, err := s.load(ctx, userID, id)
+		if errors.Is(err, ErrSessionNotFound) {
+			database.RedisClient.ZRem(ctx, indexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, data)
+	}
+	return sessions, nil
+}
+
+func (d *sessionData) conversation() Conversation {
+	conv := Conversation{
+		ID:        d.ID,
+		Title:     d.Title,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.LastUpdateTime,
+	}
+	for _, event := range d.Events {
+		if eventText(event) != "" {
+			conv.MessageCount++
+		}
+	}
+	if conv.Title == "" {
+		conv.Title = "New conversation"
+	}
+	return conv
+}
+
+// transcript returns the text messages of the session; tool calls and
+// their results are left out
+func (d *sessionData) transcript() []TranscriptMessage {
+	messages := []TranscriptMessage{}
+	for _, event := range d.Events {
+		text := eventText(event)
+		if text == "" {
+			continue
+		}
+		role := "assistant"
+		if event.Author == "user" {
+			role = "user"
+		}
+		messages = append(messages, TranscriptMessage{Role: role, Author: event.Author, Text: text, Timestamp: event.Timestamp})
+	}
+	return messages
+}
+
+// eventText joins the text parts of a complete event, skipping model thoughts
+func eventText(event *session.Event) string {
+	if event == nil || event.Partial || event.Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range event.Content.Parts {
+		if part != nil && part.Text != "" && !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// titleFromText makes a title from the first line of a message
+func titleFromText(text string) string {
+	title, _, _ := strings.Cut(text, "\n")
+	title = strings.TrimSpace(title)
+	if runes := []rune(title); len(runes) > sessionTitleLength {
+		title = strings.TrimSpace(string(runes[:sessionTitleLength])) + "…"
+	}
+	return title
+}
+
 type redisSession struct {
 	data    *sessionData
 	service *RedisSessionService
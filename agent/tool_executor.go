@@ -722,7 +722,7 @@ func runRecordedTestDirect(ctx context.Context, args map[string]any) (*models.Te
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps}
+	run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps, ProjectID: recording.ProjectID}
 	return RunTest(timeoutCtx, run)
 }
 
@@ -771,9 +771,10 @@ func runTestScenarioDirect(ctx context.Context, args map[string]any) (*RunTestSc
 		for _, tc := range section.TestCases {
 			if tc.AutomationTest != nil && len(tc.AutomationTest.Steps) > 0 {
 				runs = append(runs, models.TestRun{
-					ID:    tc.AutomationTest.ID,
-					Name:  tc.AutomationTest.Name,
-					Steps: tc.AutomationTest.Steps,
+					ID:        tc.AutomationTest.ID,
+					Name:      tc.AutomationTest.Name,
+					Steps:     tc.AutomationTest.Steps,
+					ProjectID: scenario.ProjectID,
 				})
 			}
 		}
@@ -871,9 +872,10 @@ func runScenarioTestCaseDirect(ctx context.Context, args map[string]any) (*model
 	}
 
 	run := &models.TestRun{
-		ID:    targetCase.AutomationTest.ID,
-		Name:  targetCase.AutomationTest.Name,
-		Steps: targetCase.AutomationTest.Steps,
+		ID:        targetCase.AutomationTest.ID,
+		Name:      targetCase.AutomationTest.Name,
+		Steps:     targetCase.AutomationTest.Steps,
+		ProjectID: scenario.ProjectID,
 	}
 
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
@@ -138,11 +138,12 @@ func saveAutomation(ctx tool.Context, input SaveAutomationInput) (*SaveAutomatio
 			tc := &scenario.Sections[i].TestCases[j]
 			if tc.ID == input.TestCaseID {
 				tc.AutomationTest = &models.AutomationTest{
-					ID:        fmt.Sprintf("auto-%s", input.TestCaseID),
-					Name:      input.Name,
-					Framework: input.Framework,
-					Status:    models.AutomationStatusIdle,
-					Steps:     steps,
+					ID:         fmt.Sprintf("auto-%s", input.TestCaseID),
+					Name:       input.Name,
+					Framework:  input.Framework,
+					Status:     models.AutomationStatusIdle,
+					Steps:      steps,
+					Parameters: tc.Parameters,
 				}
 				found = true
 				break
@@ -1204,8 +1205,8 @@ func generateAutomationForTestCase(ctx tool.Context, input GenerateAutomationInp
 			input.AuthConfig = AuthConfig{
 				BaseURL:  scenario.AuthConfig.BaseURL,
 				LoginURL: scenario.AuthConfig.LoginURL,
-				Username: scenario.AuthConfig.Username,
-				Password: scenario.AuthConfig.Password,
+				Username: scenario.AuthConfig.UsernameValue(),
+				Password: scenario.AuthConfig.PasswordValue(),
 			}
 		}
 	}
@@ -1994,8 +1995,8 @@ func generateAutomationsForScenario(ctx tool.Context, input GenerateAutomationsI
 		input.AuthConfig = AuthConfig{
 			BaseURL:  scenario.AuthConfig.BaseURL,
 			LoginURL: scenario.AuthConfig.LoginURL,
-			Username: scenario.AuthConfig.Username,
-			Password: scenario.AuthConfig.Password,
+			Username: scenario.AuthConfig.UsernameValue(),
+			Password: scenario.AuthConfig.PasswordValue(),
 		}
 	}
 	if input.ProjectID == "" {
@@ -2053,11 +2054,31 @@ func getScenarioFromRedis(scenarioID string) (*models.TestScenario, error) {
 }
 
 func saveScenarioToRedis(ctx context.Context, scenario *models.TestScenario) error {
+	if err := database.SealScenarioCredentials(ctx, scenario); err != nil {
+		return fmt.Errorf("failed to store scenario credentials: %w", err)
+	}
 	val, err := json.Marshal(scenario)
 	if err != nil {
 		return err
 	}
-	return database.RedisClient.Set(ctx, fmt.Sprintf("scenario:%s", scenario.ID), val, 0).Err()
+	if err := database.RedisClient.Set(ctx, fmt.Sprintf("scenario:%s", scenario.ID), val, 0).Err(); err != nil {
+		return err
+	}
+	database.IndexScenario(ctx, scenario)
+	return nil
+}
+
+// SaveScenarioVersioned stores a scenario and records the change in its
+// version history. The handlers package, which owns version history, sets it.
+var SaveScenarioVersioned func(ctx context.Context, scenario *models.TestScenario, authorID int, action, summary string) error
+
+// saveScenarioWithVersion saves through SaveScenarioVersioned, or without a
+// version when it isn't set
+func saveScenarioWithVersion(ctx context.Context, scenario *models.TestScenario, authorID int, action, summary string) error {
+	if SaveScenarioVersioned == nil {
+		return saveScenarioToRedis(ctx, scenario)
+	}
+	return SaveScenarioVersioned(ctx, scenario, authorID, action, summary)
 }
 
 func getModuleCatalogFromCache(projectID string) (*services.ModuleCatalog, error) {
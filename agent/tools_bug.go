@@ -0,0 +1,391 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"qa-extension-backend/client"
+	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+	"google.golang.org/adk/tool"
+)
+
+// bugSignatureTTL is how long a filed failure signature is remembered for dedup
+const bugSignatureTTL = 90 * 24 * time.Hour
+
+var (
+	// ErrBugSourceNotFound is returned when the scenario, test case or recording to report doesn't exist
+	ErrBugSourceNotFound = errors.New("not found")
+	// ErrBugSourceForbidden is returned when the caller doesn't own the test to report
+	ErrBugSourceForbidden = errors.New("unauthorized: you do not have permission to access this test")
+	// ErrNoFailure is returned when the latest run has nothing to report
+	ErrNoFailure = errors.New("there is no failure to report")
+	// ErrIssueNotCreated is returned when GitLab refuses the new issue
+	ErrIssueNotCreated = errors.New("failed to create issue")
+)
+
+// FileBugRequest identifies the failed run to report. Either ScenarioID and
+// TestCaseID, or RecordingID must be set.
+type FileBugRequest struct {
+	ProjectID   string   `json:"projectId,omitempty"` // defaults to the project of the scenario or recording
+	ScenarioID  string   `json:"scenarioId,omitempty"`
+	TestCaseID  string   `json:"testCaseId,omitempty"`
+	RecordingID string   `json:"recordingId,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	AssigneeIDs []int64  `json:"assigneeIds,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	DryRun      bool     `json:"dryRun,omitempty"` // build the report without filing it
+	ReportedBy  int      `json:"-"`
+}
+
+// FileBugResult describes the issue that was filed or matched
+type FileBugResult struct {
+	Duplicate        bool               `json:"duplicate"`
+	IssueIID         int64              `json:"issueIid,omitempty"`
+	IssueURL         string             `json:"issueUrl,omitempty"`
+	IssueTitle       string             `json:"issueTitle,omitempty"`
+	ProjectID        string             `json:"projectId"`
+	Signature        string             `json:"signature"`
+	Report           services.BugReport `json:"report"`
+	LinkedToTestCase bool               `json:"linkedToTestCase"`
+}
+
+// FileBugFromFailure builds a bug report from the latest failed run of a test
+// case or recording and files it as a GitLab issue. When an open issue already
+// carries the same failure signature, a new occurrence note is added to it
+// instead. ownerID, when non-zero, must match the creator of the source.
+func FileBugFromFailure(ctx context.Context, gitlabClient *gitlab.Client, req FileBugRequest, ownerID int) (*FileBugResult, error) {
+	input, scenario, err := loadBugSource(ctx, req, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Result.Status == "passed" || input.Result.Status == string(models.AutomationStatusPass) {
+		return nil, fmt.Errorf("the latest run of %s passed; %w", input.Name, ErrNoFailure)
+	}
+
+	projectID := req.ProjectID
+	if projectID == "" && scenario != nil {
+		projectID = scenario.ProjectID
+	}
+	if projectID == "" {
+		projectID = input.projectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("projectId is required when the test has no project")
+	}
+
+	report := services.BuildBugReport(input.BugReportInput)
+	result := &FileBugResult{ProjectID: projectID, Signature: report.Signature, Report: report}
+	if req.DryRun {
+		return result, nil
+	}
+
+	if existing := findOpenBugBySignature(ctx, gitlabClient, projectID, report.Signature); existing != nil {
+		evidence := fmt.Sprintf("Failure `%s` occurred again on %s.\n\n```\n%s\n```",
+			report.Signature, time.Now().Format("2006-01-02 15:04"), strings.TrimSpace(report.Error))
+		if input.Result.VideoURL != "" {
+			evidence += fmt.Sprintf("\n\nVideo: %s", input.Result.VideoURL)
+		}
+		if _, err := client.CreateEvidenceNote(gitlabClient, projectID, existing.IID, evidence, ""); err != nil {
+			log.Printf("[BugReport] Failed to add occurrence note to #%d: %v", existing.IID, err)
+		}
+		result.Duplicate = true
+		result.IssueIID, result.IssueURL, result.IssueTitle = existing.IID, existing.WebURL, existing.Title
+	} else {
+		description := report.Description
+		if report.Screenshot != "" {
+			if md := uploadFailureScreenshot(gitlabClient, projectID, report); md != "" {
+				description = strings.Replace(description, "\n---\nFailure signature",
+					"\n## Screenshot\n\n"+md+"\n\n---\nFailure signature", 1)
+			}
+		}
+
+		labels := gitlab.LabelOptions(append([]string{"bug"}, req.Labels...))
+		opt := &gitlab.CreateIssueOptions{
+			Title:       gitlab.Ptr(report.Title),
+			Description: gitlab.Ptr(description),
+			Labels:      &labels,
+		}
+		if len(req.AssigneeIDs) > 0 {
+			opt.AssigneeIDs = &req.AssigneeIDs
+		}
+
+		issue, _, err := gitlabClient.Issues.CreateIssue(projectID, opt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIssueNotCreated, err)
+		}
+		database.RedisClient.Set(ctx, bugSignatureKey(projectID, report.Signature), issue.IID, bugSignatureTTL)
+		result.IssueIID, result.IssueURL, result.IssueTitle = issue.IID, issue.WebURL, issue.Title
+	}
+
+	if scenario != nil {
+		result.LinkedToTestCase = linkBugToTestCase(ctx, scenario, req.TestCaseID, models.IssueLink{
+			Type:      models.IssueLinkIssue,
+			ProjectID: projectID,
+			IID:       result.IssueIID,
+			Title:     result.IssueTitle,
+			State:     "opened",
+			WebURL:    result.IssueURL,
+			LinkedBy:  req.ReportedBy,
+			LinkedAt:  time.Now(),
+		})
+	}
+
+	return result, nil
+}
+
+type bugSource struct {
+	services.BugReportInput
+	projectID string
+}
+
+// loadBugSource resolves the steps and latest result for the failed test. The
+// scenario is returned for test cases so the new issue can be linked back.
+func loadBugSource(ctx context.Context, req FileBugRequest, ownerID int) (*bugSource, *models.TestScenario, error) {
+	src := &bugSource{BugReportInput: services.BugReportInput{Environment: req.Environment}}
+
+	switch {
+	case req.ScenarioID != "" && req.TestCaseID != "":
+		scenario, err := getScenarioFromRedis(req.ScenarioID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scenario %s %w", req.ScenarioID, ErrBugSourceNotFound)
+		}
+		if ownerID != 0 && scenario.CreatorID != ownerID {
+			return nil, nil, ErrBugSourceForbidden
+		}
+
+		var tc *models.TestCase
+		var sectionTitle string
+		for si := range scenario.Sections {
+			for ti := range scenario.Sections[si].TestCases {
+				if scenario.Sections[si].TestCases[ti].ID == req.TestCaseID {
+					tc = &scenario.Sections[si].TestCases[ti]
+					sectionTitle = scenario.Sections[si].Title
+				}
+			}
+		}
+		if tc == nil {
+			return nil, nil, fmt.Errorf("test case %s %w", req.TestCaseID, ErrBugSourceNotFound)
+		}
+		if tc.AutomationTest == nil || tc.AutomationTest.LastRunAt == "" {
+			return nil, nil, fmt.Errorf("test case %s has no automation run; %w", tc.Code, ErrNoFailure)
+		}
+
+		auto := tc.AutomationTest
+		src.SourceID = tc.ID
+		src.Name = fmt.Sprintf("%s %s", tc.Code, tc.Title)
+		src.Context = fmt.Sprintf("%s / %s", scenario.Title, sectionTitle)
+		src.Steps = auto.Steps
+		src.ManualSteps = tc.Steps
+
+		if latest, err := database.GetLatestTestResult(ctx, auto.ID); err == nil {
+			src.Result = *latest
+		} else {
+			// Runs stored before results were persisted only live on the test case
+			src.Result = models.TestResult{
+				TestID:        auto.ID,
+				Status:        string(auto.Status),
+				StepResults:   auto.StepResults,
+				Log:           auto.ErrorMessage,
+				VideoURL:      auto.VideoURL,
+				RunDurationMs: auto.RunDurationMs,
+			}
+		}
+		return src, scenario, nil
+
+	case req.RecordingID != "":
+		val, err := database.RedisClient.Get(ctx, fmt.Sprintf("recording:%s", req.RecordingID)).Result()
+		if err != nil {
+			return nil, nil, fmt.Errorf("recording %s %w", req.RecordingID, ErrBugSourceNotFound)
+		}
+		var recording models.ManualRecording
+		if err := json.Unmarshal([]byte(val), &recording); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse recording: %w", err)
+		}
+		if ownerID != 0 && recording.CreatorID != ownerID {
+			return nil, nil, ErrBugSourceForbidden
+		}
+
+		latest, err := database.GetLatestTestResult(ctx, recording.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("recording %s has no stored runs; %w", recording.Name, ErrNoFailure)
+		}
+
+		src.SourceID = recording.ID
+		src.Name = recording.Name
+		src.Context = recording.Description
+		src.Steps = recording.Steps
+		src.Result = *latest
+		src.projectID = recording.ProjectID
+		return src, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("either scenarioId and testCaseId, or recordingId is required")
+}
+
+func bugSignatureKey(projectID, signature string) string {
+	return fmt.Sprintf("bug_signature:%s:%s", projectID, signature)
+}
+
+// findOpenBugBySignature looks for an open issue already filed for the same
+// failure, first via the signature index and then by searching descriptions.
+func findOpenBugBySignature(ctx context.Context, gitlabClient *gitlab.Client, projectID, signature string) *gitlab.Issue {
+	if iid, err := database.RedisClient.Get(ctx, bugSignatureKey(projectID, signature)).Int64(); err == nil {
+		issue, _, err := gitlabClient.Issues.GetIssue(projectID, iid)
+		if err == nil && issue.State == "opened" {
+			return issue
+		}
+	}
+
+	issues, _, err := gitlabClient.Issues.ListProjectIssues(projectID, &gitlab.ListProjectIssuesOptions{
+		State:  gitlab.Ptr("opened"),
+		Search: gitlab.Ptr(signature),
+		In:     gitlab.Ptr("description"),
+	})
+	if err != nil {
+		log.Printf("[BugReport] Signature search failed in project %s: %v", projectID, err)
+		return nil
+	}
+	for _, issue := range issues {
+		if strings.Contains(issue.Description, signature) {
+			database.RedisClient.Set(ctx, bugSignatureKey(projectID, signature), issue.IID, bugSignatureTTL)
+			return issue
+		}
+	}
+	return nil
+}
+
+// uploadFailureScreenshot attaches the failing step's screenshot to the
+// project and returns its markdown, or "" when it cannot be uploaded.
+func uploadFailureScreenshot(gitlabClient *gitlab.Client, projectID string, report services.BugReport) string {
+	if strings.HasPrefix(report.Screenshot, "http") {
+		return fmt.Sprintf("![failure](%s)", report.Screenshot)
+	}
+
+	data := report.Screenshot
+	if i := strings.Index(data, ","); strings.HasPrefix(data, "data:") && i >= 0 {
+		data = data[i+1:]
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		log.Printf("[BugReport] Screenshot is not valid base64: %v", err)
+		return ""
+	}
+
+	filename := fmt.Sprintf("failure-%s.png", report.Signature)
+	upload, _, err := gitlabClient.ProjectMarkdownUploads.UploadProjectMarkdown(projectID, bytes.NewReader(raw), filename)
+	if err != nil {
+		log.Printf("[BugReport] Failed to upload screenshot: %v", err)
+		return ""
+	}
+	return upload.Markdown
+}
+
+// linkBugToTestCase records the issue on the test case and in the traceability index
+func linkBugToTestCase(ctx context.Context, scenario *models.TestScenario, tcID string, link models.IssueLink) bool {
+	for si := range scenario.Sections {
+		for ti := range scenario.Sections[si].TestCases {
+			tc := &scenario.Sections[si].TestCases[ti]
+			if tc.ID != tcID {
+				continue
+			}
+			for _, l := range tc.Issues {
+				if l.Matches(link.Type, link.ProjectID, link.IID) {
+					return true
+				}
+			}
+			tc.Issues = append(tc.Issues, link)
+			tc.UpdatedAt = time.Now().Format(time.RFC3339)
+			scenario.UpdatedAt = time.Now()
+			summary := fmt.Sprintf("Linked %s to #%d", tc.Code, link.IID)
+			if err := saveScenarioWithVersion(ctx, scenario, link.LinkedBy, models.VersionActionUpdateTestCase, summary); err != nil {
+				log.Printf("[BugReport] Failed to link issue #%d to test case %s: %v", link.IID, tcID, err)
+				return false
+			}
+			database.RedisClient.SAdd(ctx, database.TraceabilityKey(link.Type, link.ProjectID, link.IID), database.TraceabilityMember(scenario.ID, tcID))
+			return true
+		}
+	}
+	return false
+}
+
+// =============================================================================
+// AGENT TOOL: CREATE BUG FROM FAILURE
+// =============================================================================
+
+type CreateBugFromFailureArgs struct {
+	ProjectID   int      `json:"projectId,omitempty"`
+	ScenarioID  string   `json:"scenarioId,omitempty"`
+	TestCaseID  string   `json:"testCaseId,omitempty"`
+	RecordingID string   `json:"recordingId,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	DryRun      bool     `json:"dryRun,omitempty"`
+}
+
+func createBugFromFailure(ctx tool.Context, args CreateBugFromFailureArgs) (*FileBugResult, error) {
+	log.Printf("[AgentTool] createBugFromFailure called with args: %+v", args)
+
+	events := NewAgentToolEmitter(ctx)
+	events.Start("Filing bug from failed run...")
+
+	token, _ := ctx.Value("token").(*oauth2.Token)
+	sessionID, _ := ctx.Value("session_id").(string)
+	if token == nil || sessionID == "" {
+		events.Error("Missing authentication context")
+		return nil, fmt.Errorf("unauthorized: missing authentication context")
+	}
+	userID, err := identity.GetCurrentUserIDFromCtx(ctx, token, sessionID)
+	if err != nil {
+		events.Error("Failed to verify user identity")
+		return nil, fmt.Errorf("unauthorized: failed to verify user identity: %w", err)
+	}
+
+	gitlabClient, err := getGitLabClient(ctx)
+	if err != nil {
+		events.Error("Failed to get GitLab client: " + err.Error())
+		return nil, err
+	}
+
+	req := FileBugRequest{
+		ScenarioID:  args.ScenarioID,
+		TestCaseID:  args.TestCaseID,
+		RecordingID: args.RecordingID,
+		Labels:      args.Labels,
+		Environment: args.Environment,
+		DryRun:      args.DryRun,
+		ReportedBy:  userID,
+	}
+	if args.ProjectID > 0 {
+		req.ProjectID = strconv.Itoa(args.ProjectID)
+	}
+
+	result, err := FileBugFromFailure(ctx, gitlabClient, req, userID)
+	if err != nil {
+		log.Printf("[AgentTool] createBugFromFailure error: %v", err)
+		events.Error(err.Error())
+		return nil, err
+	}
+
+	switch {
+	case args.DryRun:
+		events.Done("Drafted bug report: %s", result.Report.Title)
+	case result.Duplicate:
+		events.Done("Failure already reported in #%d, added a new occurrence", result.IssueIID)
+	default:
+		events.Done("Created issue #%d: %s", result.IssueIID, result.IssueTitle)
+	}
+	return result, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
+
 	"qa-extension-backend/client"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
@@ -143,3 +145,85 @@ func GetCurrentUser(ctx context.Context) (*GitUser, error) {
 		Email: user.Email,
 	}, nil
 }
+
+// SetCommitStatus sets an external commit status, such as a QA suite's
+// result, on a commit in a GitLab project
+func SetCommitStatus(ctx context.Context, projectID interface{}, sha string, opts *gitlab.SetCommitStatusOptions) (*gitlab.CommitStatus, error) {
+	log.Printf("[GitLabWrite] Setting commit status %s on %s in project %v", opts.State, sha, projectID)
+
+	token, ok := ctx.Value("token").(*oauth2.Token)
+	if !ok {
+		return nil, fmt.Errorf("no GitLab token in context")
+	}
+
+	gitlabClient, err := client.GetClient(ctx, token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitLab client: %w", err)
+	}
+
+	status, _, err := gitlabClient.Commits.SetCommitStatus(projectID, sha, opts)
+	if err != nil {
+		log.Printf("[GitLabWrite] Failed to set commit status: %v", err)
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// UpsertMergeRequestNote keeps a single note on a merge request: it updates
+// the current user's note containing marker, or posts a new one when there is
+// none. marker is typically an HTML comment embedded in body.
+func UpsertMergeRequestNote(ctx context.Context, projectID interface{}, mrIID int64, marker, body string) (*gitlab.Note, error) {
+	log.Printf("[GitLabWrite] Posting note on MR !%d in project %v", mrIID, projectID)
+
+	token, ok := ctx.Value("token").(*oauth2.Token)
+	if !ok {
+		return nil, fmt.Errorf("no GitLab token in context")
+	}
+
+	gitlabClient, err := client.GetClient(ctx, token, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GitLab client: %w", err)
+	}
+
+	user, _, err := gitlabClient.Users.CurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	opts := &gitlab.ListMergeRequestNotesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+		Sort:        gitlab.Ptr("desc"),
+	}
+	for {
+		notes, resp, err := gitlabClient.Notes.ListMergeRequestNotes(projectID, mrIID, opts)
+		if err != nil {
+			log.Printf("[GitLabWrite] Failed to list MR notes: %v", err)
+			return nil, err
+		}
+		for _, n := range notes {
+			if n.System || n.Author.ID != user.ID || !strings.Contains(n.Body, marker) {
+				continue
+			}
+			note, _, err := gitlabClient.Notes.UpdateMergeRequestNote(projectID, mrIID, n.ID, &gitlab.UpdateMergeRequestNoteOptions{Body: gitlab.Ptr(body)})
+			if err != nil {
+				log.Printf("[GitLabWrite] Failed to update MR note: %v", err)
+				return nil, err
+			}
+			return note, nil
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	note, _, err := gitlabClient.Notes.CreateMergeRequestNote(projectID, mrIID, &gitlab.CreateMergeRequestNoteOptions{Body: gitlab.Ptr(body)})
+	if err != nil {
+		log.Printf("[GitLabWrite] Failed to create MR note: %v", err)
+		return nil, err
+	}
+
+	log.Printf("[GitLabWrite] MR note created: %d", note.ID)
+	return note, nil
+}
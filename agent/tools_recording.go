@@ -50,6 +50,12 @@ func GetTestTools() []tool.Tool {
 	}, runScenarioTestCase)
 	tools = append(tools, tt5)
 
+	tt6, _ := functiontool.New(functiontool.Config{
+		Name:        "createBugFromFailure",
+		Description: "File a GitLab bug from the latest failed run of a scenario test case (scenarioId + testCaseId) or a recording (recordingId). The issue includes the failing step, expected vs actual, repro steps, console errors and failed network calls. If an open issue already has the same failure signature, a new occurrence is noted there instead. Use dryRun to preview the report.",
+	}, createBugFromFailure)
+	tools = append(tools, tt6)
+
 	return tools
 }
 
@@ -139,9 +145,10 @@ func runTestScenario(ctx tool.Context, args RunTestScenarioArgs) (*RunTestScenar
 		for _, tc := range section.TestCases {
 			if tc.AutomationTest != nil && len(tc.AutomationTest.Steps) > 0 {
 				runs = append(runs, models.TestRun{
-					ID:    tc.AutomationTest.ID,
-					Name:  tc.AutomationTest.Name,
-					Steps: tc.AutomationTest.Steps,
+					ID:        tc.AutomationTest.ID,
+					Name:      tc.AutomationTest.Name,
+					Steps:     tc.AutomationTest.Steps,
+					ProjectID: scenario.ProjectID,
 				})
 			}
 		}
@@ -269,9 +276,10 @@ func runScenarioTestCase(ctx tool.Context, args RunScenarioTestCaseArgs) (*model
 	}
 
 	run := &models.TestRun{
-		ID:    targetCase.AutomationTest.ID,
-		Name:  targetCase.AutomationTest.Name,
-		Steps: targetCase.AutomationTest.Steps,
+		ID:        targetCase.AutomationTest.ID,
+		Name:      targetCase.AutomationTest.Name,
+		Steps:     targetCase.AutomationTest.Steps,
+		ProjectID: scenario.ProjectID,
 	}
 
 	// Run the test
@@ -458,7 +466,7 @@ func runRecordedTest(ctx tool.Context, args RunRecordedTestArgs) (*models.TestRe
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps}
+	run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps, ProjectID: recording.ProjectID}
 	result, err := RunTest(timeoutCtx, run)
 	if err != nil {
 		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
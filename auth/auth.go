@@ -3,9 +3,12 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"qa-extension-backend/config"
 	"qa-extension-backend/database"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -56,6 +59,15 @@ func GetSession(ctx context.Context, sessionID string) (*oauth2.Token, error) {
 		return nil, err
 	}
 
+	if userID, ok := database.GitLabGrantSessionUser(ctx, sessionID); ok {
+		token, err := GrantToken(ctx, userID)
+		if !errors.Is(err, database.ErrGitLabGrantNotFound) {
+			return token, err
+		}
+		// The grant is gone; fall back to the session's own token
+		database.UnlinkGitLabGrantSession(ctx, sessionID)
+	}
+
 	var token *oauth2.Token
 	if err := json.Unmarshal([]byte(sessionData), &token); err != nil {
 		return nil, err
@@ -79,7 +91,103 @@ func GetSession(ctx context.Context, sessionID string) (*oauth2.Token, error) {
 	return token, nil
 }
 
+// grantSessionPrefix marks the session ID of API token requests that act with
+// the token creator's stored GitLab authorization. Tokens refreshed during
+// those requests are saved back to the grant rather than to a browser session.
+const grantSessionPrefix = "grant:"
+
+// GrantSessionID is the session ID of requests acting with a user's grant
+func GrantSessionID(userID int) string {
+	return grantSessionPrefix + strconv.Itoa(userID)
+}
+
+const (
+	grantRefreshLockTTL = 30 * time.Second
+	grantRefreshWait    = 10 * time.Second
+)
+
+// GrantToken returns a user's stored GitLab authorization, refreshing it when
+// it has expired. GitLab rotates the refresh token on use, so only one caller
+// refreshes at a time and the others wait for its result.
+func GrantToken(ctx context.Context, userID int) (*oauth2.Token, error) {
+	token, err := database.GitLabGrantToken(ctx, userID)
+	if err != nil || token.Valid() {
+		return token, err
+	}
+
+	deadline := time.Now().Add(grantRefreshWait)
+	for {
+		release, ok, err := database.LockGitLabGrantRefresh(ctx, userID, grantRefreshLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			defer release()
+			// Another caller may have refreshed it before we got the lock
+			token, err := database.GitLabGrantToken(ctx, userID)
+			if err != nil || token.Valid() {
+				return token, err
+			}
+			newToken, err := getOAuthConfig().TokenSource(ctx, token).Token()
+			if err != nil {
+				return nil, fmt.Errorf("failed to refresh token: %w", err)
+			}
+			if err := database.SaveGitLabGrant(ctx, userID, newToken); err != nil {
+				return nil, fmt.Errorf("failed to save refreshed token: %w", err)
+			}
+			return newToken, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the GitLab authorization to be refreshed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+		if token, err := database.GitLabGrantToken(ctx, userID); err == nil && token.Valid() {
+			return token, nil
+		}
+	}
+}
+
+// SaveSessionGrant stores a session's token as the user's grant and links the
+// session to it, so the session, the user's API tokens and their automations
+// all refresh the same token
+func SaveSessionGrant(ctx context.Context, sessionID string, userID int, token *oauth2.Token) error {
+	if err := database.SaveGitLabGrant(ctx, userID, token); err != nil {
+		return err
+	}
+	if sessionID == "" || strings.HasPrefix(sessionID, grantSessionPrefix) {
+		return nil
+	}
+	ttl, err := database.RedisClient.TTL(ctx, "session:"+sessionID).Result()
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return database.LinkGitLabGrantSession(ctx, sessionID, userID, ttl)
+}
+
 func UpdateSession(ctx context.Context, sessionID string, token *oauth2.Token) error {
+	if sessionID == "" {
+		// API token requests with a GitLab access token have no session
+		return nil
+	}
+	if uid, ok := strings.CutPrefix(sessionID, grantSessionPrefix); ok {
+		userID, err := strconv.Atoi(uid)
+		if err != nil {
+			return err
+		}
+		return database.SaveGitLabGrant(ctx, userID, token)
+	}
+	if userID, ok := database.GitLabGrantSessionUser(ctx, sessionID); ok {
+		if err := database.SaveGitLabGrant(ctx, userID, token); err != nil {
+			return err
+		}
+		database.RedisClient.Expire(ctx, "session:"+sessionID, 24*time.Hour)
+		return database.LinkGitLabGrantSession(ctx, sessionID, userID, 24*time.Hour)
+	}
 	tokenBytes, err := json.Marshal(token)
 	if err != nil {
 		return err
@@ -88,5 +196,8 @@ func UpdateSession(ctx context.Context, sessionID string, token *oauth2.Token) e
 }
 
 func DeleteSession(ctx context.Context, sessionID string) error {
+	if err := database.UnlinkGitLabGrantSession(ctx, sessionID); err != nil {
+		return err
+	}
 	return database.RedisClient.Del(ctx, "session:"+sessionID).Err()
 }
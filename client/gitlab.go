@@ -261,6 +261,25 @@ func FetchRecentIssueNotes(client *gitlab.Client, projectID int, issueID int, li
 	return notes, nil
 }
 
+// FormatEvidenceNote builds the markdown body used for evidence notes on issues.
+func FormatEvidenceNote(evidence, comment string) string {
+	var body strings.Builder
+	body.WriteString("**Evidence:**\n")
+	body.WriteString(evidence)
+	body.WriteString("\n\n")
+	body.WriteString(comment)
+	return body.String()
+}
+
+// CreateEvidenceNote posts an evidence note on an issue.
+func CreateEvidenceNote(client *gitlab.Client, projectID interface{}, issueIID int64, evidence, comment string) (*gitlab.Note, error) {
+	opt := &gitlab.CreateIssueNoteOptions{
+		Body: gitlab.Ptr(FormatEvidenceNote(evidence, comment)),
+	}
+	note, _, err := client.Notes.CreateIssueNote(projectID, issueIID, opt)
+	return note, err
+}
+
 // FetchUnifiedActivities fetches the last activity note for multiple issues concurrently.
 func FetchUnifiedActivities(gitlabClient *gitlab.Client, issues []*gitlab.Issue) []models.ActivityFeedItem {
 	var wg sync.WaitGroup
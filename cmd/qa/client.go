@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// apiClient calls the QA backend with an API token
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	// stream has no timeout; event streams stay open for the whole run
+	stream *http.Client
+}
+
+func newAPIClient(server, token string) *apiClient {
+	return &apiClient{
+		baseURL: strings.TrimRight(server, "/") + "/api",
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+		stream:  &http.Client{},
+	}
+}
+
+// apiError is a non-2xx response from the backend
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+func (c *apiClient) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	req, err := c.newRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) != nil || body.Error == "" {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	return &apiError{Status: resp.StatusCode, Message: body.Error}
+}
+
+func (c *apiClient) startSuiteRun(ctx context.Context, projectID string, req models.StartSuiteRunRequest) (models.SuiteRun, error) {
+	var run models.SuiteRun
+	err := c.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(projectID)+"/suite-runs", req, &run)
+	return run, err
+}
+
+func (c *apiClient) getSuiteRun(ctx context.Context, id string) (models.SuiteRun, error) {
+	var run models.SuiteRun
+	err := c.do(ctx, http.MethodGet, "/suite-runs/"+url.PathEscape(id), nil, &run)
+	return run, err
+}
+
+// exportSuiteRun downloads a suite run's report in one of the server's
+// export formats: junit, allure or ctrf
+func (c *apiClient) exportSuiteRun(ctx context.Context, id, format string) ([]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/suite-runs/"+url.PathEscape(id)+"/export?format="+url.QueryEscape(format), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// streamEvent is the part of a backend stream event the CLI prints
+type streamEvent struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	ResourceID string `json:"resourceId"`
+	Stage      string `json:"stage"`
+	Message    string `json:"message"`
+	StepInfo   *struct {
+		CurrentStep int    `json:"currentStep"`
+		TotalSteps  int    `json:"totalSteps"`
+		StepName    string `json:"stepName"`
+	} `json:"stepInfo"`
+}
+
+// followEvents reads the event stream of a resource, resuming after lastID,
+// and calls fn for each event until the stream ends. It returns the ID of the
+// last event seen so the caller can resume after a disconnect.
+func (c *apiClient) followEvents(ctx context.Context, resourceID, lastID string, fn func(streamEvent)) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/stream?resourceId="+url.QueryEscape(resourceID), nil)
+	if err != nil {
+		return lastID, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := c.stream.Do(req)
+	if err != nil {
+		return lastID, err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return lastID, err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line ends an event
+			if data.Len() > 0 {
+				var ev streamEvent
+				if json.Unmarshal([]byte(data.String()), &ev) == nil && ev.ResourceID == resourceID {
+					if ev.ID != "" {
+						lastID = ev.ID
+					}
+					fn(ev)
+				}
+				data.Reset()
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return lastID, scanner.Err()
+}
@@ -0,0 +1,275 @@
+// Command qa runs QA suites from CI pipelines. It starts a suite run on the
+// backend, streams its progress, downloads the run's JUnit XML (and CTRF JSON
+// when asked) and writes a JSON summary, and exits non-zero when a test case
+// fails, so pipelines can gate merges on it.
+//
+// Usage:
+//
+//	qa run -project <id> [-tag smoke] [-scenario <id>] [-env staging] [-junit qa-report.xml] [-ctrf qa-ctrf.json] [-summary qa-summary.json]
+//
+// The backend and API token come from -server and -token, or QA_SERVER_URL
+// and QA_API_TOKEN. A project API token with the read and run scopes is
+// enough; create one with POST /api/projects/<id>/api-tokens. Inside GitLab
+// CI the project, commit, branch, merge request and pipeline default to the
+// predefined CI_* variables, so the results also show up as a commit status
+// and merge request note.
+//
+// Exit codes: 0 when every test case passed, 1 when any failed, 2 when the
+// run could not be started or did not finish within -timeout.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+const (
+	exitPassed = 0
+	exitFailed = 1
+	exitError  = 2
+)
+
+// listFlag collects a flag given several times or as a comma-separated list
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "run" {
+		fmt.Fprintln(os.Stderr, "usage: qa run -project <id> [-tag <tag>] [-scenario <id>] [-env <environment>] [flags]")
+		fmt.Fprintln(os.Stderr, "run 'qa run -h' for all flags")
+		os.Exit(exitError)
+	}
+	os.Exit(runCommand(os.Args[2:]))
+}
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("qa run", flag.ExitOnError)
+	server := fs.String("server", os.Getenv("QA_SERVER_URL"), "backend URL, e.g. https://qa.example.com (QA_SERVER_URL)")
+	token := fs.String("token", os.Getenv("QA_API_TOKEN"), "API token (QA_API_TOKEN)")
+	projectID := fs.String("project", os.Getenv("CI_PROJECT_ID"), "GitLab project ID (CI_PROJECT_ID)")
+	var scenarios, tags listFlag
+	fs.Var(&scenarios, "scenario", "run the automated test cases of this scenario; repeatable")
+	fs.Var(&tags, "tag", "only run test cases with this tag; repeatable")
+	env := fs.String("env", "", "environment ID or name; the project default when empty")
+	name := fs.String("name", "", "name of the run")
+	noRef := fs.Bool("no-ref", false, "do not tie the run to the CI commit and merge request")
+	junitPath := fs.String("junit", "qa-report.xml", "write JUnit XML here; empty to skip")
+	ctrfPath := fs.String("ctrf", "", "write CTRF JSON here; empty to skip")
+	summaryPath := fs.String("summary", "qa-summary.json", "write the JSON summary here; empty to skip")
+	timeout := fs.Duration("timeout", 30*time.Minute, "give up when the run takes longer")
+	fs.Parse(args)
+
+	if *server == "" || *token == "" || *projectID == "" {
+		fmt.Fprintln(os.Stderr, "qa: -server, -token and -project are required")
+		return exitError
+	}
+	if len(scenarios) == 0 && len(tags) == 0 {
+		fmt.Fprintln(os.Stderr, "qa: choose the test cases with -scenario or -tag")
+		return exitError
+	}
+
+	req := models.StartSuiteRunRequest{
+		Name:          *name,
+		ScenarioIDs:   scenarios,
+		Tags:          tags,
+		EnvironmentID: *env,
+	}
+	if req.Name == "" {
+		req.Name = defaultRunName(tags)
+	}
+	if !*noRef {
+		req.Ref = ciRef()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	api := newAPIClient(*server, *token)
+	run, err := api.startSuiteRun(ctx, *projectID, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "qa: could not start the run: %v\n", err)
+		return exitError
+	}
+	logf("Started %s: %d test case%s", run.ID, run.Total, plural(run.Total))
+
+	run, err = waitForRun(ctx, api, run)
+	timedOut := err != nil
+	if timedOut {
+		fmt.Fprintf(os.Stderr, "qa: run %s did not finish: %v\n", run.ID, err)
+		// Report whatever finished before giving up
+		refreshCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if latest, err := api.getSuiteRun(refreshCtx, run.ID); err == nil {
+			run = latest
+		}
+		cancel()
+	}
+
+	printResults(run)
+	reportCtx, cancelReports := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelReports()
+	for _, report := range []struct{ path, format, name string }{
+		{*junitPath, "junit", "JUnit report"},
+		{*ctrfPath, "ctrf", "CTRF report"},
+	} {
+		if report.path == "" {
+			continue
+		}
+		if err := writeReport(reportCtx, api, report.path, run, report.format); err != nil {
+			fmt.Fprintf(os.Stderr, "qa: could not write %s: %v\n", report.name, err)
+		}
+	}
+	if *summaryPath != "" {
+		if err := writeSummary(*summaryPath, run, timedOut); err != nil {
+			fmt.Fprintf(os.Stderr, "qa: could not write summary: %v\n", err)
+		}
+	}
+
+	switch {
+	case timedOut:
+		return exitError
+	case run.Status == models.SuiteRunPassed:
+		return exitPassed
+	}
+	return exitFailed
+}
+
+// ciRef ties the run to the commit and merge request of a GitLab CI job
+func ciRef() *models.SuiteRunRef {
+	ref := &models.SuiteRunRef{
+		Branch:    os.Getenv("CI_COMMIT_REF_NAME"),
+		CommitSHA: os.Getenv("CI_COMMIT_SHA"),
+	}
+	if mr := os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"); mr != "" {
+		ref.Branch = mr
+	}
+	ref.MergeRequestIID, _ = strconv.ParseInt(os.Getenv("CI_MERGE_REQUEST_IID"), 10, 64)
+	ref.PipelineID, _ = strconv.ParseInt(os.Getenv("CI_PIPELINE_ID"), 10, 64)
+	if ref.CommitSHA == "" && ref.MergeRequestIID == 0 {
+		return nil
+	}
+	return ref
+}
+
+func defaultRunName(tags []string) string {
+	if len(tags) > 0 {
+		return "CI suite: " + strings.Join(tags, ", ")
+	}
+	return "CI suite"
+}
+
+// waitForRun prints the run's progress events until it finishes. The event
+// stream is resumed after disconnects, and the run is also polled in case
+// the final event is missed.
+func waitForRun(ctx context.Context, api *apiClient, run models.SuiteRun) (models.SuiteRun, error) {
+	events := make(chan streamEvent, 64)
+	go func() {
+		lastID := ""
+		for ctx.Err() == nil {
+			var err error
+			lastID, err = api.followEvents(ctx, run.ID, lastID, func(ev streamEvent) {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && (apiErr.Status == 401 || apiErr.Status == 403) {
+				fmt.Fprintf(os.Stderr, "qa: event stream unavailable: %v\n", err)
+				return
+			}
+			time.Sleep(3 * time.Second)
+		}
+	}()
+
+	poll := time.NewTicker(15 * time.Second)
+	defer poll.Stop()
+	for {
+		check := false
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		case ev := <-events:
+			printEvent(ev)
+			check = ev.Stage == "done" || ev.Stage == "error"
+		case <-poll.C:
+			check = true
+		}
+		if !check {
+			continue
+		}
+		latest, err := api.getSuiteRun(ctx, run.ID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "qa: could not fetch run %s: %v\n", run.ID, err)
+			continue
+		}
+		run = latest
+		if run.FinishedAt != nil {
+			return run, nil
+		}
+	}
+}
+
+func printEvent(ev streamEvent) {
+	switch {
+	case ev.StepInfo != nil:
+		logf("[%d/%d] %s", ev.StepInfo.CurrentStep, ev.StepInfo.TotalSteps, ev.StepInfo.StepName)
+	case ev.Message != "":
+		logf("%s", ev.Message)
+	}
+}
+
+func printResults(run models.SuiteRun) {
+	fmt.Println()
+	for _, res := range run.Results {
+		fmt.Printf("  %-7s %s %s (%s)\n", strings.ToUpper(string(res.Status)), res.Code, res.Title, res.ScenarioTitle)
+		if res.Status != models.SuiteRunFailed {
+			continue
+		}
+		if res.FailedStepIndex != nil {
+			fmt.Println(strings.TrimRight(fmt.Sprintf("          step %d %s", *res.FailedStepIndex+1, res.FailedStep), " "))
+		}
+		if res.Error != "" {
+			fmt.Printf("          %s\n", firstLine(res.Error))
+		}
+		if res.VideoURL != "" {
+			fmt.Printf("          video: %s\n", res.VideoURL)
+		}
+	}
+	fmt.Printf("\n%s: %d passed, %d failed of %d\n", strings.ToUpper(string(run.Status)), run.Passed, run.Failed, run.Total)
+}
+
+func logf(format string, args ...any) {
+	fmt.Printf("%s  %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// runSummary is the JSON summary written for the pipeline
+type runSummary struct {
+	RunID      string          `json:"runId"`
+	Status     string          `json:"status"`
+	Total      int             `json:"total"`
+	Passed     int             `json:"passed"`
+	Failed     int             `json:"failed"`
+	DurationMs int64           `json:"durationMs"`
+	TimedOut   bool            `json:"timedOut,omitempty"`
+	Run        models.SuiteRun `json:"run"`
+}
+
+func writeSummary(path string, run models.SuiteRun, timedOut bool) error {
+	end := time.Now()
+	if run.FinishedAt != nil {
+		end = *run.FinishedAt
+	}
+	summary := runSummary{
+		RunID:      run.ID,
+		Status:     string(run.Status),
+		Total:      run.Total,
+		Passed:     run.Passed,
+		Failed:     run.Failed,
+		DurationMs: end.Sub(run.CreatedAt).Milliseconds(),
+		TimedOut:   timedOut,
+		Run:        run,
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// writeReport downloads a suite run's report in a server export format
+func writeReport(ctx context.Context, api *apiClient, path string, run models.SuiteRun, format string) error {
+	data, err := api.exportSuiteRun(ctx, run.ID, format)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,75 @@
+// Command redact-recordings scrubs recording telemetry that was stored before
+// redaction was enabled, or before a project's policy was tightened.
+//
+// Usage:
+//
+//	go run ./cmd/redact-recordings [-project <id>] [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+)
+
+func main() {
+	projectID := flag.String("project", "", "only scrub recordings of this GitLab project ID")
+	dryRun := flag.Bool("dry-run", false, "report what would be redacted without saving")
+	verbose := flag.Bool("v", false, "print every recording that has values redacted")
+	flag.Parse()
+
+	if err := database.InitRedis(); err != nil {
+		log.Fatalf("Could not connect to Redis: %v", err)
+	}
+
+	opts := services.RedactionBackfillOptions{ProjectID: *projectID, DryRun: *dryRun}
+	if *verbose {
+		opts.Progress = func(rec *models.ManualRecording, report models.RedactionReport) {
+			fmt.Printf("%s  %-40.40s  %d values%s\n", rec.ID, rec.Name, report.Total(), patternSummary(report))
+		}
+	}
+
+	result, err := services.BackfillRedaction(context.Background(), opts)
+	if err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+
+	verb := "Redacted"
+	if *dryRun {
+		verb = "Would redact"
+	}
+	fmt.Printf("Scanned %d recordings. %s %d values in %d recordings.", result.Scanned, verb, result.Values, result.Redacted)
+	if result.Failed > 0 {
+		fmt.Printf(" %d failed.", result.Failed)
+	}
+	fmt.Println()
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func patternSummary(report models.RedactionReport) string {
+	if len(report.Patterns) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(report.Patterns))
+	for name := range report.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s := " ("
+	for i, name := range names {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%s: %d", name, report.Patterns[name])
+	}
+	return s + ")"
+}
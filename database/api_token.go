@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// APITokenPrefix starts every API token secret, so the auth middleware can
+// tell tokens from session IDs
+const APITokenPrefix = "qat_"
+
+// apiTokenTouchInterval throttles the last-used writes of busy tokens
+const apiTokenTouchInterval = time.Minute
+
+// ErrAPITokenNotFound is returned for unknown, revoked or mistyped tokens
+var ErrAPITokenNotFound = errors.New("API token not found")
+
+// storedAPIToken is the Redis record. Only the hash of the secret is kept;
+// a GitLab access token given at creation is sealed with the credential key.
+type storedAPIToken struct {
+	models.APIToken
+	Hash   string       `json:"hash"`
+	GitLab *sealedToken `json:"gitlab,omitempty"`
+}
+
+type apiTokenUsage struct {
+	At time.Time `json:"at"`
+	IP string    `json:"ip,omitempty"`
+}
+
+func apiTokenKey(id string) string {
+	return fmt.Sprintf("api_token:%s", id)
+}
+
+func apiTokenHashKey(hash string) string {
+	return fmt.Sprintf("api_token:hash:%s", hash)
+}
+
+func apiTokenUsageKey(id string) string {
+	return fmt.Sprintf("api_token:used:%s", id)
+}
+
+func userAPITokensKey(userID int) string {
+	return fmt.Sprintf("api_tokens:user:%d", userID)
+}
+
+func projectAPITokensKey(projectID string) string {
+	return fmt.Sprintf("api_tokens:project:%s", projectID)
+}
+
+// hashAPIToken hashes a secret for lookup. Secrets are random, so a plain
+// SHA-256 is enough.
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPITokenSecret generates a token secret
+func NewAPITokenSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + hex.EncodeToString(b), nil
+}
+
+// CreateAPIToken stores a new token under the hash of its secret. gitlabToken
+// is sealed with the token when it acts with a GitLab access token.
+func CreateAPIToken(ctx context.Context, tok *models.APIToken, secret string, gitlabToken *oauth2.Token) error {
+	if !strings.HasPrefix(secret, APITokenPrefix) {
+		return fmt.Errorf("API token secrets must start with %s", APITokenPrefix)
+	}
+	if tok.ID == "" {
+		tok.ID = uuid.NewString()
+	}
+	if tok.CreatedAt.IsZero() {
+		tok.CreatedAt = time.Now()
+	}
+	if tok.ProjectIDs == nil {
+		tok.ProjectIDs = []string{}
+	}
+	tok.Prefix = secret[:len(APITokenPrefix)+6]
+
+	record := storedAPIToken{APIToken: *tok, Hash: hashAPIToken(secret)}
+	if gitlabToken != nil {
+		// The token ID is bound as additional data so the GitLab token can't be
+		// moved to another API token
+		sealed, err := sealToken(gitlabToken, tok.ID)
+		if err != nil {
+			return err
+		}
+		record.GitLab = &sealed
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	pipe := RedisClient.TxPipeline()
+	pipe.Set(ctx, apiTokenKey(tok.ID), data, 0)
+	pipe.Set(ctx, apiTokenHashKey(record.Hash), tok.ID, 0)
+	if tok.Kind == models.APITokenProject {
+		for _, pid := range tok.ProjectIDs {
+			pipe.SAdd(ctx, projectAPITokensKey(pid), tok.ID)
+		}
+	} else {
+		pipe.SAdd(ctx, userAPITokensKey(tok.CreatedBy), tok.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func getStoredAPIToken(ctx context.Context, id string) (storedAPIToken, error) {
+	var record storedAPIToken
+	val, err := RedisClient.Get(ctx, apiTokenKey(id)).Result()
+	if err != nil {
+		return record, ErrAPITokenNotFound
+	}
+	if err := json.Unmarshal([]byte(val), &record); err != nil {
+		return record, err
+	}
+	if usage, err := RedisClient.Get(ctx, apiTokenUsageKey(id)).Result(); err == nil {
+		var used apiTokenUsage
+		if json.Unmarshal([]byte(usage), &used) == nil {
+			record.LastUsedAt = &used.At
+			record.LastUsedIP = used.IP
+		}
+	}
+	return record, nil
+}
+
+// GetAPIToken returns a token's metadata
+func GetAPIToken(ctx context.Context, id string) (models.APIToken, error) {
+	record, err := getStoredAPIToken(ctx, id)
+	return record.APIToken, err
+}
+
+// LookupAPIToken finds the token a secret belongs to
+func LookupAPIToken(ctx context.Context, secret string) (models.APIToken, error) {
+	id, err := RedisClient.Get(ctx, apiTokenHashKey(hashAPIToken(secret))).Result()
+	if err != nil {
+		return models.APIToken{}, ErrAPITokenNotFound
+	}
+	return GetAPIToken(ctx, id)
+}
+
+// APITokenGitLabToken returns the GitLab token an API token acts with. A
+// token using the creator's OAuth grant may have expired; callers refresh it
+// and save it back with SaveGitLabGrant.
+func APITokenGitLabToken(ctx context.Context, id string) (*oauth2.Token, error) {
+	record, err := getStoredAPIToken(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.GitLab == nil {
+		return GitLabGrantToken(ctx, record.UserID)
+	}
+	token, err := openToken(*record.GitLab, id)
+	if err != nil {
+		return nil, fmt.Errorf("API token %s: %w", id, err)
+	}
+	return token, nil
+}
+
+func listAPITokens(ctx context.Context, setKey string) ([]models.APIToken, error) {
+	ids, err := RedisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]models.APIToken, 0, len(ids))
+	for _, id := range ids {
+		if tok, err := GetAPIToken(ctx, id); err == nil {
+			tokens = append(tokens, tok)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.After(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// ListUserAPITokens returns the personal tokens a user created
+func ListUserAPITokens(ctx context.Context, userID int) ([]models.APIToken, error) {
+	return listAPITokens(ctx, userAPITokensKey(userID))
+}
+
+// ListProjectAPITokens returns the tokens of a project
+func ListProjectAPITokens(ctx context.Context, projectID string) ([]models.APIToken, error) {
+	return listAPITokens(ctx, projectAPITokensKey(projectID))
+}
+
+// RevokeAPIToken deletes a token; requests using it fail from then on
+func RevokeAPIToken(ctx context.Context, id string) error {
+	record, err := getStoredAPIToken(ctx, id)
+	if err != nil {
+		return err
+	}
+	pipe := RedisClient.TxPipeline()
+	pipe.Del(ctx, apiTokenKey(id), apiTokenHashKey(record.Hash), apiTokenUsageKey(id))
+	pipe.SRem(ctx, userAPITokensKey(record.CreatedBy), id)
+	for _, pid := range record.ProjectIDs {
+		pipe.SRem(ctx, projectAPITokensKey(pid), id)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// TouchAPIToken records that a token was used. Writes are throttled, so the
+// last-used time is accurate to about a minute.
+func TouchAPIToken(ctx context.Context, tok models.APIToken, ip string) error {
+	now := time.Now()
+	if tok.LastUsedAt != nil && now.Sub(*tok.LastUsedAt) < apiTokenTouchInterval && tok.LastUsedIP == ip {
+		return nil
+	}
+	data, err := json.Marshal(apiTokenUsage{At: now, IP: ip})
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, apiTokenUsageKey(tok.ID), data, 0).Err()
+}
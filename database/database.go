@@ -33,12 +33,16 @@ func InitRedis() error {
 }
 
 func SaveTestResult(ctx context.Context, result *models.TestResult) error {
+	now := time.Now()
+	if result.RanAt == nil {
+		result.RanAt = &now
+	}
 	data, err := json.Marshal(result)
 	if err != nil {
 		return err
 	}
 	
-	resultID := fmt.Sprintf("result:%s:%d", result.TestID, time.Now().Unix())
+	resultID := fmt.Sprintf("result:%s:%d", result.TestID, now.Unix())
 	if err := RedisClient.Set(ctx, resultID, data, 0).Err(); err != nil {
 		return err
 	}
@@ -47,6 +51,62 @@ func SaveTestResult(ctx context.Context, result *models.TestResult) error {
 	return RedisClient.LPush(ctx, fmt.Sprintf("results:%s", result.TestID), resultID).Err()
 }
 
+// GetLatestTestResult returns the most recent stored result for a test.
+func GetLatestTestResult(ctx context.Context, testID string) (*models.TestResult, error) {
+	resultID, err := RedisClient.LIndex(ctx, fmt.Sprintf("results:%s", testID), 0).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := RedisClient.Get(ctx, resultID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.TestResult
+	if err := json.Unmarshal([]byte(val), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRecentTestResults returns up to n stored results for a test, newest first.
+func GetRecentTestResults(ctx context.Context, testID string, n int64) ([]models.TestResult, error) {
+	resultIDs, err := RedisClient.LRange(ctx, fmt.Sprintf("results:%s", testID), 0, n-1).Result()
+	if err != nil || len(resultIDs) == 0 {
+		return nil, err
+	}
+
+	vals, err := RedisClient.MGet(ctx, resultIDs...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.TestResult, 0, len(vals))
+	for _, v := range vals {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var result models.TestResult
+		if json.Unmarshal([]byte(str), &result) == nil {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// TraceabilityKey is the reverse index from a GitLab issue or epic to the test
+// cases linked to it. Members are built with TraceabilityMember.
+func TraceabilityKey(linkType, parentID string, iid int64) string {
+	return fmt.Sprintf("traceability:%s:%s:%d", linkType, parentID, iid)
+}
+
+// TraceabilityMember identifies a test case inside a traceability index.
+func TraceabilityMember(scenarioID, testCaseID string) string {
+	return scenarioID + "|" + testCaseID
+}
+
 // Project name cache helpers with TTL
 var projectCacheTTL = 30 * time.Minute
 
@@ -227,13 +287,18 @@ func InvalidateBoardCache(ctx context.Context, projectID string) {
 // StreamEvent represents a unified SSE event for all long-running operations.
 // Follows AG-UI-inspired event patterns for agent-to-frontend real-time communication.
 type StreamEvent struct {
+	ID           string          `json:"id,omitempty"`            // Redis Stream entry ID, used for SSE resume
 	Type         string          `json:"type"`                    // "generation" | "execution" | "agent"
+	UserID       int             `json:"userId,omitempty"`        // GitLab user who started the operation
+	ProjectID    string          `json:"projectId,omitempty"`     // GitLab project the resource belongs to
 	ResourceType string          `json:"resourceType,omitempty"`  // "scenario" | "recording" | "session"
 	ResourceID   string          `json:"resourceId,omitempty"`    // ID of the resource being operated on
 	Stage        string          `json:"stage"`                   // "start", "progress", "done", "error"
 	Message      string          `json:"message"`                 // Human-readable contextual message
 	StepInfo     *StreamStepInfo `json:"stepInfo,omitempty"`      // For execution step progress
 	ErrorInfo    *StreamErrorInfo `json:"errorInfo,omitempty"`    // Structured error details
+	Prompt       *StreamPrompt   `json:"prompt,omitempty"`        // Set when the agent waits for the user (stage "awaiting_input")
+	Outcome      string          `json:"outcome,omitempty"`       // How a run or fix ended ("passed", "failed", "timeout", "done"); final event only
 	CorrelationID string         `json:"correlationId,omitempty"` // Links all events in a single operation
 	Timestamp    string          `json:"timestamp"`               // RFC3339 timestamp
 }
@@ -247,6 +312,17 @@ type StreamStepInfo struct {
 	Progress    int    `json:"progress,omitempty"`     // 0-100 percentage
 }
 
+// StreamPrompt is a question or tool approval an interactive agent run is
+// waiting on. Clients answer it over the agent WebSocket using its ID.
+type StreamPrompt struct {
+	ID       string         `json:"id"`
+	Kind     string         `json:"kind"`               // "approval" | "question"
+	Message  string         `json:"message"`            // What the user is asked
+	ToolName string         `json:"toolName,omitempty"` // For approvals: the tool about to run
+	Args     map[string]any `json:"args,omitempty"`     // For approvals: the arguments it would run with
+	Options  []string       `json:"options,omitempty"`  // For questions: suggested answers
+}
+
 // StreamErrorInfo provides structured error details
 type StreamErrorInfo struct {
 	Code    string `json:"code,omitempty"`    // Machine-readable error code
@@ -256,10 +332,17 @@ type StreamErrorInfo struct {
 // Unified Redis channel for all stream events
 const StreamChannel = "stream:events"
 
-// PublishStreamEvent publishes a unified event to the shared Redis pub/sub channel.
-// All SSE subscribers receive all events; frontend filters by resourceId.
+// PublishStreamEvent attributes a unified event to its owner, appends it to the
+// event log (see AppendStreamEvent) and publishes it, with its ID, on the
+// shared pub/sub channel.
 func PublishStreamEvent(ctx context.Context, event StreamEvent) error {
 	event.Timestamp = time.Now().Format(time.RFC3339)
+	ResolveStreamOwner(ctx, &event)
+	id, err := AppendStreamEvent(ctx, event)
+	if err != nil {
+		log.Printf("[Stream] Failed to append event for %s: %v", event.ResourceID, err)
+	}
+	event.ID = id
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
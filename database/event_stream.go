@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Every event is written twice: to the global log, which SSE clients tail and
+// resume from, and to a log per resource, which is kept long enough to
+// rebuild the progress of a finished generation or run. Both entries share one
+// ID, so a Last-Event-ID from either can resume the other.
+const (
+	StreamLogKey = "stream:log"
+
+	streamLogMaxLen      = 10000
+	resourceLogMaxLen    = 2000
+	resourceLogRetention = 24 * time.Hour
+)
+
+// noResourceLog holds events that are not tied to a resource
+const noResourceLog = "_"
+
+func resourceLogKey(resourceID string) string {
+	if resourceID == "" {
+		resourceID = noResourceLog
+	}
+	return fmt.Sprintf("stream:log:%s", resourceID)
+}
+
+// appendEventScript adds the event to the global log and copies it, under the
+// same ID, into the resource log
+var appendEventScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', 'event', ARGV[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[3], id, 'event', ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return id
+`)
+
+// AppendStreamEvent writes an event to the event logs and returns its ID
+func AppendStreamEvent(ctx context.Context, event StreamEvent) (string, error) {
+	event.ID = ""
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	return appendEventScript.Run(ctx, RedisClient,
+		[]string{StreamLogKey, resourceLogKey(event.ResourceID)},
+		string(data), streamLogMaxLen, resourceLogMaxLen, int(resourceLogRetention.Seconds()),
+	).Text()
+}
+
+func decodeStreamEntries(msgs []redis.XMessage) []StreamEvent {
+	events := make([]StreamEvent, 0, len(msgs))
+	for _, msg := range msgs {
+		raw, ok := msg.Values["event"].(string)
+		if !ok {
+			continue
+		}
+		var ev StreamEvent
+		if json.Unmarshal([]byte(raw), &ev) != nil {
+			continue
+		}
+		ev.ID = msg.ID
+		events = append(events, ev)
+	}
+	return events
+}
+
+// ResourceStreamEvents returns the logged events of a resource after the given
+// ID, oldest first. An empty after returns the whole log.
+func ResourceStreamEvents(ctx context.Context, resourceID, after string) ([]StreamEvent, error) {
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+	msgs, err := RedisClient.XRange(ctx, resourceLogKey(resourceID), start, "+").Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeStreamEntries(msgs), nil
+}
+
+// LatestStreamEventID is the ID of the newest logged event, or "0-0" when the
+// log is empty. Tailing from it delivers only events appended afterwards.
+func LatestStreamEventID(ctx context.Context) (string, error) {
+	msgs, err := RedisClient.XRevRangeN(ctx, StreamLogKey, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0-0", nil
+	}
+	return msgs[0].ID, nil
+}
+
+// ReadStreamEvents blocks for up to block waiting for events after the given
+// ID in the global log. It returns no events and no error on timeout.
+func ReadStreamEvents(ctx context.Context, after string, block time.Duration) ([]StreamEvent, error) {
+	res, err := RedisClient.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{StreamLogKey, after},
+		Count:   100,
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []StreamEvent
+	for _, stream := range res {
+		events = append(events, decodeStreamEntries(stream.Messages)...)
+	}
+	return events, nil
+}
+
+// EnsureStreamGroup creates a consumer group on the global log that starts
+// with events appended from now on. An existing group is left as it is.
+func EnsureStreamGroup(ctx context.Context, group string) error {
+	err := RedisClient.XGroupCreateMkStream(ctx, StreamLogKey, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// ReadStreamGroup reads events of the global log for a consumer group, so each
+// event is handed to one consumer across all instances. With pending set it
+// returns the events this consumer was given but never acknowledged, e.g.
+// before a restart, instead of new ones.
+func ReadStreamGroup(ctx context.Context, group, consumer string, pending bool, block time.Duration) ([]StreamEvent, error) {
+	start := ">"
+	if pending {
+		start = "0"
+		block = -1 // pending entries are returned immediately; don't block
+	}
+	res, err := RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{StreamLogKey, start},
+		Count:    100,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var events []StreamEvent
+	for _, stream := range res {
+		events = append(events, decodeStreamEntries(stream.Messages)...)
+	}
+	return events, nil
+}
+
+// AckStreamEvents marks events read through a consumer group as handled
+func AckStreamEvents(ctx context.Context, group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return RedisClient.XAck(ctx, StreamLogKey, group, ids...).Err()
+}
+
+// ValidStreamEventID reports whether id has the <ms>-<seq> form of a stream entry ID
+func ValidStreamEventID(id string) bool {
+	_, _, ok := parseStreamEventID(id)
+	return ok
+}
+
+// CompareStreamEventIDs orders two stream entry IDs like strings.Compare.
+// Malformed IDs sort first.
+func CompareStreamEventIDs(a, b string) int {
+	ams, aseq, aok := parseStreamEventID(a)
+	bms, bseq, bok := parseStreamEventID(b)
+	switch {
+	case !aok || !bok:
+		if aok == bok {
+			return 0
+		}
+		if !aok {
+			return -1
+		}
+		return 1
+	case ams != bms:
+		if ams < bms {
+			return -1
+		}
+		return 1
+	case aseq != bseq:
+		if aseq < bseq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func parseStreamEventID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
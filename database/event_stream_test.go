@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareStreamEventIDs(t *testing.T) {
+	assert.Equal(t, -1, CompareStreamEventIDs("1700000000000-0", "1700000000000-1"))
+	assert.Equal(t, 1, CompareStreamEventIDs("1700000000001-0", "1700000000000-9"))
+	assert.Equal(t, 1, CompareStreamEventIDs("10-0", "9-0"), "IDs compare numerically, not as strings")
+	assert.Equal(t, 0, CompareStreamEventIDs("5-2", "5-2"))
+	assert.Equal(t, -1, CompareStreamEventIDs("garbage", "0-0"))
+
+	assert.True(t, ValidStreamEventID("1700000000000-3"))
+	assert.False(t, ValidStreamEventID("1700000000000"))
+	assert.False(t, ValidStreamEventID("abc-1"))
+}
+
+func TestStreamOwnerContext(t *testing.T) {
+	_, ok := StreamOwnerFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = StreamOwnerFromContext(WithStreamOwner(context.Background(), StreamOwner{}))
+	assert.False(t, ok, "an empty owner attributes nothing")
+
+	owner, ok := StreamOwnerFromContext(WithStreamOwner(context.Background(), StreamOwner{UserID: 7, ProjectID: "42"}))
+	assert.True(t, ok)
+	assert.Equal(t, StreamOwner{UserID: 7, ProjectID: "42"}, owner)
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const gitlabAutomationRunsKept = 100
+
+// ErrGitLabAutomationNotFound is returned when a project has no GitLab
+// automation configured
+var ErrGitLabAutomationNotFound = errors.New("GitLab automation is not configured for this project")
+
+// storedGitLabAutomation is the Redis record; the secret token is only
+// returned when it is generated and compared by the webhook receiver
+type storedGitLabAutomation struct {
+	models.GitLabAutomation
+	Secret string `json:"secret"`
+}
+
+func gitlabAutomationKey(projectID string) string {
+	return fmt.Sprintf("gitlab_automation:%s", projectID)
+}
+
+func gitlabAutomationRunsKey(projectID string) string {
+	return fmt.Sprintf("gitlab_automation:runs:%s", projectID)
+}
+
+// NewGitLabWebhookToken generates the secret token GitLab sends in X-Gitlab-Token
+func NewGitLabWebhookToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "glwh_" + hex.EncodeToString(b), nil
+}
+
+// SaveGitLabAutomation stores a project's automation configuration. An empty
+// secret keeps the current one.
+func SaveGitLabAutomation(ctx context.Context, cfg *models.GitLabAutomation, secret string) error {
+	now := time.Now()
+	if cfg.CreatedAt.IsZero() {
+		cfg.CreatedAt = now
+	}
+	cfg.UpdatedAt = now
+	if cfg.Rules == nil {
+		cfg.Rules = []models.GitLabAutomationRule{}
+	}
+	for i := range cfg.Rules {
+		if cfg.Rules[i].ID == "" {
+			cfg.Rules[i].ID = uuid.NewString()
+		}
+	}
+
+	if secret == "" {
+		existing, err := getStoredGitLabAutomation(ctx, cfg.ProjectID)
+		if err != nil {
+			return err
+		}
+		secret = existing.Secret
+	}
+
+	data, err := json.Marshal(storedGitLabAutomation{GitLabAutomation: *cfg, Secret: secret})
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, gitlabAutomationKey(cfg.ProjectID), data, 0).Err()
+}
+
+func getStoredGitLabAutomation(ctx context.Context, projectID string) (storedGitLabAutomation, error) {
+	var record storedGitLabAutomation
+	val, err := RedisClient.Get(ctx, gitlabAutomationKey(projectID)).Result()
+	if err != nil {
+		return record, ErrGitLabAutomationNotFound
+	}
+	err = json.Unmarshal([]byte(val), &record)
+	return record, err
+}
+
+// GetGitLabAutomation returns a project's automation configuration without its secret
+func GetGitLabAutomation(ctx context.Context, projectID string) (models.GitLabAutomation, error) {
+	record, err := getStoredGitLabAutomation(ctx, projectID)
+	return record.GitLabAutomation, err
+}
+
+// GetGitLabAutomationSecret returns the token a project's GitLab webhook must present
+func GetGitLabAutomationSecret(ctx context.Context, projectID string) (string, error) {
+	record, err := getStoredGitLabAutomation(ctx, projectID)
+	return record.Secret, err
+}
+
+// AddGitLabAutomationRun records what a webhook event triggered, keeping the
+// newest gitlabAutomationRunsKept entries per project
+func AddGitLabAutomationRun(ctx context.Context, run *models.GitLabAutomationRun) error {
+	if run.ID == "" {
+		run.ID = uuid.NewString()
+	}
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	key := gitlabAutomationRunsKey(run.ProjectID)
+	pipe := RedisClient.TxPipeline()
+	pipe.LPush(ctx, key, data)
+	pipe.LTrim(ctx, key, 0, gitlabAutomationRunsKept-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ListGitLabAutomationRuns returns a project's automation log, newest first
+func ListGitLabAutomationRuns(ctx context.Context, projectID string, limit int) ([]models.GitLabAutomationRun, error) {
+	vals, err := RedisClient.LRange(ctx, gitlabAutomationRunsKey(projectID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]models.GitLabAutomationRun, 0, len(vals))
+	for _, val := range vals {
+		var run models.GitLabAutomationRun
+		if err := json.Unmarshal([]byte(val), &run); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
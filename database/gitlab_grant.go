@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+// A GitLab grant is a user's OAuth token kept so that work they configured,
+// such as actions triggered by GitLab webhooks, can act as them after their
+// session has expired. It is sealed with the credential store key; the
+// refresh token keeps it usable.
+//
+// GitLab rotates refresh tokens, so a browser session whose token was stored
+// as a grant is linked to it and reads and refreshes the grant instead of its
+// own copy; two copies would invalidate each other on the first refresh.
+
+// ErrGitLabGrantNotFound is returned when a user has not stored a grant
+var ErrGitLabGrantNotFound = errors.New("no stored GitLab authorization for this user")
+
+// sealedToken is an OAuth token encrypted with the credential store key
+type sealedToken struct {
+	KeyID      string `json:"keyId"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type storedGrant struct {
+	UserID int `json:"userId"`
+	sealedToken
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func gitlabGrantKey(userID int) string {
+	return fmt.Sprintf("gitlab_grant:%d", userID)
+}
+
+func gitlabGrantSessionKey(sessionID string) string {
+	return "gitlab_grant_session:" + sessionID
+}
+
+func gitlabGrantRefreshKey(userID int) string {
+	return fmt.Sprintf("gitlab_grant_refresh:%d", userID)
+}
+
+// releaseGrantRefreshLock deletes the lock only while the caller still holds
+// it, so a refresh that outlived its lock can't release the next one's
+var releaseGrantRefreshLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// sealToken encrypts a token, binding it to aad so it cannot be moved to
+// another record
+func sealToken(token *oauth2.Token, aad string) (sealedToken, error) {
+	aead, keyID, err := credentialCipher()
+	if err != nil {
+		return sealedToken{}, err
+	}
+	plaintext, err := json.Marshal(token)
+	if err != nil {
+		return sealedToken{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return sealedToken{}, err
+	}
+	return sealedToken{
+		KeyID:      keyID,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(aad))),
+	}, nil
+}
+
+// openToken decrypts a token sealed with sealToken
+func openToken(sealed sealedToken, aad string) (*oauth2.Token, error) {
+	aead, keyID, err := credentialCipher()
+	if err != nil {
+		return nil, err
+	}
+	if sealed.KeyID != keyID {
+		return nil, fmt.Errorf("GitLab authorization was encrypted with a different key")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(sealed.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return nil, fmt.Errorf("GitLab authorization could not be decrypted")
+	}
+	var token oauth2.Token
+	err = json.Unmarshal(plaintext, &token)
+	return &token, err
+}
+
+// SaveGitLabGrant seals and stores a user's OAuth token, replacing any
+// previous one
+func SaveGitLabGrant(ctx context.Context, userID int, token *oauth2.Token) error {
+	if userID == 0 || token == nil {
+		return fmt.Errorf("a user and token are required")
+	}
+	sealed, err := sealToken(token, strconv.Itoa(userID))
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(storedGrant{UserID: userID, sealedToken: sealed, UpdatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, gitlabGrantKey(userID), data, 0).Err()
+}
+
+// GitLabGrantToken opens a user's stored OAuth token. The token may have
+// expired; GitLab clients built from it refresh it and should save the result
+// back with SaveGitLabGrant.
+func GitLabGrantToken(ctx context.Context, userID int) (*oauth2.Token, error) {
+	val, err := RedisClient.Get(ctx, gitlabGrantKey(userID)).Result()
+	if err != nil {
+		return nil, ErrGitLabGrantNotFound
+	}
+	var record storedGrant
+	if err := json.Unmarshal([]byte(val), &record); err != nil {
+		return nil, err
+	}
+	token, err := openToken(record.sealedToken, strconv.Itoa(userID))
+	if err != nil {
+		return nil, fmt.Errorf("user %d: %w", userID, err)
+	}
+	return token, nil
+}
+
+// DeleteGitLabGrant removes a user's stored token
+func DeleteGitLabGrant(ctx context.Context, userID int) error {
+	return RedisClient.Del(ctx, gitlabGrantKey(userID)).Err()
+}
+
+// LinkGitLabGrantSession makes a browser session use the user's grant as its
+// token until ttl passes
+func LinkGitLabGrantSession(ctx context.Context, sessionID string, userID int, ttl time.Duration) error {
+	return RedisClient.Set(ctx, gitlabGrantSessionKey(sessionID), userID, ttl).Err()
+}
+
+// GitLabGrantSessionUser returns the user whose grant a session is linked to
+func GitLabGrantSessionUser(ctx context.Context, sessionID string) (int, bool) {
+	userID, err := RedisClient.Get(ctx, gitlabGrantSessionKey(sessionID)).Int()
+	if err != nil || userID == 0 {
+		return 0, false
+	}
+	return userID, true
+}
+
+// UnlinkGitLabGrantSession makes a session use its own token again
+func UnlinkGitLabGrantSession(ctx context.Context, sessionID string) error {
+	return RedisClient.Del(ctx, gitlabGrantSessionKey(sessionID)).Err()
+}
+
+// LockGitLabGrantRefresh takes the lock that lets one caller at a time
+// refresh a user's grant. ok is false when another caller holds it; release
+// must be called once the refreshed token is saved.
+func LockGitLabGrantRefresh(ctx context.Context, userID int, ttl time.Duration) (release func(), ok bool, err error) {
+	key := gitlabGrantRefreshKey(userID)
+	holder := uuid.NewString()
+	ok, err = RedisClient.SetNX(ctx, key, holder, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	release = func() {
+		releaseGrantRefreshLock.Run(context.Background(), RedisClient, []string{key}, holder)
+	}
+	return release, true, nil
+}
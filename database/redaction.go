@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func redactionPolicyKey(projectID string) string {
+	return fmt.Sprintf("redaction_policy:%s", projectID)
+}
+
+// GetRedactionPolicy returns the project's redaction policy, or the default
+// policy when the project has none. custom reports which one was returned.
+func GetRedactionPolicy(ctx context.Context, projectID string) (policy models.RedactionPolicy, custom bool, err error) {
+	if projectID != "" {
+		val, err := RedisClient.Get(ctx, redactionPolicyKey(projectID)).Result()
+		if err == nil {
+			if err := json.Unmarshal([]byte(val), &policy); err != nil {
+				return policy, false, err
+			}
+			return policy, true, nil
+		}
+		if err != redis.Nil {
+			return policy, false, err
+		}
+	}
+
+	policy = models.DefaultRedactionPolicy()
+	policy.ProjectID = projectID
+	return policy, false, nil
+}
+
+// SaveRedactionPolicy stores a project's redaction policy
+func SaveRedactionPolicy(ctx context.Context, policy *models.RedactionPolicy) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return RedisClient.Set(ctx, redactionPolicyKey(policy.ProjectID), data, 0).Err()
+}
+
+// DeleteRedactionPolicy drops a project's policy so the default applies again
+func DeleteRedactionPolicy(ctx context.Context, projectID string) error {
+	return RedisClient.Del(ctx, redactionPolicyKey(projectID)).Err()
+}
@@ -0,0 +1,574 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"qa-extension-backend/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Secondary indexes for listing resources without loading every document.
+//
+// For a resource index named N:
+//   idx:N:created, idx:N:updated   sorted sets scored by unix milliseconds
+//   idx:N:<facet>:<value>          sets per facet value (status, project, tag, owner...)
+//   idx:N:term:<token>             sets per full-text token
+//   idx:N:terms                    lexicographic set of all tokens, for prefix search
+//   idx:N:doc:<id>                 the set keys a document is in, for clean re-indexing
+
+// Facets shared by the scenario and recording indexes
+const (
+	FacetStatus     = "status"
+	FacetProject    = "project"
+	FacetTag        = "tag"
+	FacetOwner      = "owner"
+	FacetIssue      = "issue"
+	FacetAutomation = "automation" // automation outcomes present in a scenario
+	FacetRun        = "run"        // outcome of the latest run of a recording
+)
+
+// SortCreated and SortUpdated are the orderings an index supports
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+)
+
+// searchIndexVersion is bumped whenever the indexed fields change, which
+// triggers a rebuild on startup
+const searchIndexVersion = "1"
+
+const (
+	maxIndexTerms      = 2000
+	maxPrefixExpansion = 50
+	tempIndexTTL       = 30 * time.Second
+)
+
+// ErrInvalidCursor is returned by Query for a cursor it did not issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ResourceIndex maintains the secondary indexes of one resource type
+type ResourceIndex struct {
+	Name string
+}
+
+var (
+	ScenarioIndex  = ResourceIndex{Name: "scenarios"}
+	RecordingIndex = ResourceIndex{Name: "recordings"}
+)
+
+// IndexDoc is what gets indexed for one resource
+type IndexDoc struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Facets    map[string][]string
+	Text      string
+}
+
+// IndexQuery selects and orders documents. Facets are ANDed together; the
+// values of one facet are ORed. Search tokens must all match, the last one as
+// a prefix.
+type IndexQuery struct {
+	Filters      map[string][]string
+	Search       string
+	SortBy       string // SortCreated (default) or SortUpdated
+	Asc          bool
+	CreatedSince time.Time
+	CreatedUntil time.Time
+	UpdatedSince time.Time
+	UpdatedUntil time.Time
+	Cursor       string // from a previous page; takes precedence over Offset
+	Offset       int
+	Limit        int // 0 returns every match
+}
+
+// IndexPage is one page of query results
+type IndexPage struct {
+	IDs        []string
+	Total      int64
+	NextCursor string
+}
+
+func (ix ResourceIndex) key(parts ...string) string {
+	return "idx:" + ix.Name + ":" + strings.Join(parts, ":")
+}
+
+// Put indexes a document, dropping memberships left over from its previous version
+func (ix ResourceIndex) Put(ctx context.Context, doc IndexDoc) error {
+	newKeys := make(map[string]bool)
+	for facet, values := range doc.Facets {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				newKeys[ix.key(facet, strings.ToLower(v))] = true
+			}
+		}
+	}
+	terms := IndexTokens(doc.Text)
+	for _, t := range terms {
+		newKeys[ix.key("term", t)] = true
+	}
+
+	docKey := ix.key("doc", doc.ID)
+	var oldKeys []string
+	if val, err := RedisClient.Get(ctx, docKey).Result(); err == nil {
+		json.Unmarshal([]byte(val), &oldKeys)
+	}
+
+	keys := make([]string, 0, len(newKeys))
+	for k := range newKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	keysJSON, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	updated := doc.UpdatedAt
+	if updated.IsZero() {
+		updated = doc.CreatedAt
+	}
+
+	pipe := RedisClient.TxPipeline()
+	for _, k := range oldKeys {
+		if !newKeys[k] {
+			pipe.SRem(ctx, k, doc.ID)
+		}
+	}
+	for _, k := range keys {
+		pipe.SAdd(ctx, k, doc.ID)
+	}
+	if len(terms) > 0 {
+		members := make([]redis.Z, len(terms))
+		for i, t := range terms {
+			members[i] = redis.Z{Score: 0, Member: t}
+		}
+		pipe.ZAdd(ctx, ix.key("terms"), members...)
+	}
+	pipe.ZAdd(ctx, ix.key(SortCreated), redis.Z{Score: indexScore(doc.CreatedAt), Member: doc.ID})
+	pipe.ZAdd(ctx, ix.key(SortUpdated), redis.Z{Score: indexScore(updated), Member: doc.ID})
+	pipe.Set(ctx, docKey, keysJSON, 0)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Remove drops a document from every index
+func (ix ResourceIndex) Remove(ctx context.Context, id string) error {
+	docKey := ix.key("doc", id)
+	var oldKeys []string
+	if val, err := RedisClient.Get(ctx, docKey).Result(); err == nil {
+		json.Unmarshal([]byte(val), &oldKeys)
+	}
+
+	pipe := RedisClient.TxPipeline()
+	for _, k := range oldKeys {
+		pipe.SRem(ctx, k, id)
+	}
+	pipe.ZRem(ctx, ix.key(SortCreated), id)
+	pipe.ZRem(ctx, ix.key(SortUpdated), id)
+	pipe.Del(ctx, docKey)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Query runs a filtered, ordered query. Filters are intersected server-side
+// into a short-lived sorted set, so only the requested page is returned.
+func (ix ResourceIndex) Query(ctx context.Context, q IndexQuery) (*IndexPage, error) {
+	sortBy := q.SortBy
+	if sortBy != SortUpdated {
+		sortBy = SortCreated
+	}
+	sortKey := ix.key(sortBy)
+
+	var temps []string
+	defer func() {
+		if len(temps) > 0 {
+			RedisClient.Del(context.Background(), temps...)
+		}
+	}()
+	tempKey := func() string {
+		k := ix.key("tmp", uuid.NewString())
+		temps = append(temps, k)
+		return k
+	}
+
+	// Every component is intersected with the sort set, which carries the score
+	var components []string
+
+	facets := make([]string, 0, len(q.Filters))
+	for facet := range q.Filters {
+		facets = append(facets, facet)
+	}
+	sort.Strings(facets)
+	for _, facet := range facets {
+		var keys []string
+		for _, v := range q.Filters[facet] {
+			if v = strings.TrimSpace(v); v != "" {
+				keys = append(keys, ix.key(facet, strings.ToLower(v)))
+			}
+		}
+		switch len(keys) {
+		case 0:
+		case 1:
+			components = append(components, keys[0])
+		default:
+			k := tempKey()
+			if err := RedisClient.SUnionStore(ctx, k, keys...).Err(); err != nil {
+				return nil, err
+			}
+			components = append(components, k)
+		}
+	}
+
+	tokens := IndexTokens(q.Search)
+	for i, t := range tokens {
+		if i < len(tokens)-1 {
+			components = append(components, ix.key("term", t))
+			continue
+		}
+		// Last token matches as a prefix so results update while typing
+		matches, err := RedisClient.ZRangeByLex(ctx, ix.key("terms"), &redis.ZRangeBy{
+			Min: "[" + t, Max: "[" + t + "\xff", Count: maxPrefixExpansion,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return &IndexPage{}, nil
+		}
+		keys := make([]string, len(matches))
+		for j, m := range matches {
+			keys[j] = ix.key("term", m)
+		}
+		k := tempKey()
+		if err := RedisClient.SUnionStore(ctx, k, keys...).Err(); err != nil {
+			return nil, err
+		}
+		components = append(components, k)
+	}
+
+	// A range on the other timestamp becomes one more component
+	otherKey, since, until := ix.key(SortUpdated), q.UpdatedSince, q.UpdatedUntil
+	if sortBy == SortUpdated {
+		otherKey, since, until = ix.key(SortCreated), q.CreatedSince, q.CreatedUntil
+	}
+	if !since.IsZero() || !until.IsZero() {
+		k := tempKey()
+		min, max := scoreRange(since, until)
+		if err := RedisClient.ZRangeStore(ctx, k, redis.ZRangeArgs{Key: otherKey, Start: min, Stop: max, ByScore: true}).Err(); err != nil {
+			return nil, err
+		}
+		components = append(components, k)
+	}
+
+	resultKey := sortKey
+	if len(components) > 0 {
+		resultKey = tempKey()
+		weights := make([]float64, len(components)+1)
+		weights[0] = 1
+		if err := RedisClient.ZInterStore(ctx, resultKey, &redis.ZStore{
+			Keys:      append([]string{sortKey}, components...),
+			Weights:   weights,
+			Aggregate: "SUM",
+		}).Err(); err != nil {
+			return nil, err
+		}
+		RedisClient.Expire(ctx, resultKey, tempIndexTTL)
+	}
+
+	since, until = q.CreatedSince, q.CreatedUntil
+	if sortBy == SortUpdated {
+		since, until = q.UpdatedSince, q.UpdatedUntil
+	}
+	min, max := scoreRange(since, until)
+
+	total, err := RedisClient.ZCount(ctx, resultKey, min, max).Result()
+	if err != nil {
+		return nil, err
+	}
+	page := &IndexPage{Total: total}
+
+	offset := int64(q.Offset)
+	var cursorScore float64
+	var cursorID string
+	hasCursor := false
+	if q.Cursor != "" {
+		cursorScore, cursorID, err = decodeIndexCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		hasCursor = true
+		offset = 0
+		if q.Asc {
+			min = strconv.FormatFloat(cursorScore, 'f', -1, 64)
+		} else {
+			max = strconv.FormatFloat(cursorScore, 'f', -1, 64)
+		}
+	}
+
+	count := int64(-1)
+	if q.Limit > 0 {
+		count = int64(q.Limit) + 1
+		if hasCursor {
+			// Members sharing the cursor's score are returned again and skipped below
+			count += 100
+		}
+	}
+	rangeBy := &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}
+	var entries []redis.Z
+	if q.Asc {
+		entries, err = RedisClient.ZRangeByScoreWithScores(ctx, resultKey, rangeBy).Result()
+	} else {
+		entries, err = RedisClient.ZRevRangeByScoreWithScores(ctx, resultKey, rangeBy).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		id, _ := e.Member.(string)
+		if hasCursor && e.Score == cursorScore {
+			// Equal scores come back in lexical order (reversed when descending)
+			if (q.Asc && id <= cursorID) || (!q.Asc && id >= cursorID) {
+				continue
+			}
+		}
+		if q.Limit > 0 && len(page.IDs) == q.Limit {
+			last := page.IDs[len(page.IDs)-1]
+			page.NextCursor = encodeIndexCursor(lastScore(entries, last), last)
+			break
+		}
+		page.IDs = append(page.IDs, id)
+	}
+	return page, nil
+}
+
+func lastScore(entries []redis.Z, id string) float64 {
+	for _, e := range entries {
+		if e.Member == id {
+			return e.Score
+		}
+	}
+	return 0
+}
+
+func indexScore(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixMilli())
+}
+
+func scoreRange(since, until time.Time) (string, string) {
+	min, max := "-inf", "+inf"
+	if !since.IsZero() {
+		min = strconv.FormatInt(since.UnixMilli(), 10)
+	}
+	if !until.IsZero() {
+		max = strconv.FormatInt(until.UnixMilli(), 10)
+	}
+	return min, max
+}
+
+func encodeIndexCursor(score float64, id string) string {
+	raw := strconv.FormatFloat(score, 'f', -1, 64) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeIndexCursor(cursor string) (float64, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, "", ErrInvalidCursor
+	}
+	scoreStr, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return 0, "", ErrInvalidCursor
+	}
+	score, err := strconv.ParseFloat(scoreStr, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, "", ErrInvalidCursor
+	}
+	return score, id, nil
+}
+
+// IndexTokens splits text into the lowercase tokens used by the full-text index
+func IndexTokens(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, f := range fields {
+		if len(f) < 2 || seen[f] {
+			continue
+		}
+		seen[f] = true
+		tokens = append(tokens, f)
+		if len(tokens) == maxIndexTerms {
+			break
+		}
+	}
+	return tokens
+}
+
+// ─────────────────────────────────────────────
+// Resource documents
+// ─────────────────────────────────────────────
+
+func ownerFacet(creatorID int) []string {
+	return []string{strconv.Itoa(creatorID)}
+}
+
+// ScenarioIndexDoc describes a scenario for the index: test case tags and
+// automation outcomes are facets, and test case content is searchable.
+func ScenarioIndexDoc(s *models.TestScenario) IndexDoc {
+	var text strings.Builder
+	text.WriteString(s.Title + " " + s.Description + " " + s.ProjectName)
+
+	tags := make(map[string]bool)
+	outcomes := make(map[string]bool)
+	for _, sec := range s.Sections {
+		text.WriteString(" " + sec.Title)
+		for _, tc := range sec.TestCases {
+			text.WriteString(" " + tc.Code + " " + tc.Title + " " + tc.Description + " " + tc.PreCondition)
+			for _, step := range tc.Steps {
+				text.WriteString(" " + step.Action + " " + step.Expected)
+			}
+			for _, t := range tc.Tags {
+				tags[t] = true
+			}
+			if tc.AutomationTest != nil {
+				outcomes[string(tc.AutomationTest.Status)] = true
+			}
+		}
+	}
+
+	doc := IndexDoc{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		Text:      text.String(),
+		Facets: map[string][]string{
+			FacetStatus:     {string(s.Status)},
+			FacetProject:    {s.ProjectID},
+			FacetOwner:      ownerFacet(s.CreatorID),
+			FacetTag:        sortedKeys(tags),
+			FacetAutomation: sortedKeys(outcomes),
+		},
+	}
+	return doc
+}
+
+// RecordingIndexDoc describes a recording for the index. latestRun is the
+// status of its most recent run, if any.
+func RecordingIndexDoc(r *models.ManualRecording, latestRun string) IndexDoc {
+	var text strings.Builder
+	text.WriteString(r.Name + " " + r.Description + " " + r.ProjectName)
+	for _, step := range r.Steps {
+		text.WriteString(" " + step.Description)
+	}
+
+	updated := r.UpdatedAt
+	if updated.IsZero() {
+		updated = r.CreatedAt
+	}
+
+	return IndexDoc{
+		ID:        r.ID,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: updated,
+		Text:      text.String(),
+		Facets: map[string][]string{
+			FacetStatus:  {r.Status},
+			FacetProject: {r.ProjectID},
+			FacetIssue:   {r.IssueID},
+			FacetOwner:   ownerFacet(r.CreatorID),
+			FacetRun:     {latestRun},
+		},
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IndexScenario updates the secondary indexes of a scenario
+func IndexScenario(ctx context.Context, s *models.TestScenario) {
+	if err := ScenarioIndex.Put(ctx, ScenarioIndexDoc(s)); err != nil {
+		log.Printf("[SearchIndex] Failed to index scenario %s: %v", s.ID, err)
+	}
+}
+
+// IndexRecording updates the secondary indexes of a recording
+func IndexRecording(ctx context.Context, r *models.ManualRecording) {
+	latestRun := ""
+	if result, err := GetLatestTestResult(ctx, r.ID); err == nil {
+		latestRun = result.Status
+	}
+	if err := RecordingIndex.Put(ctx, RecordingIndexDoc(r, latestRun)); err != nil {
+		log.Printf("[SearchIndex] Failed to index recording %s: %v", r.ID, err)
+	}
+}
+
+// EnsureSearchIndexes rebuilds the indexes when they are missing or were built
+// by an older version of the indexer.
+func EnsureSearchIndexes(ctx context.Context) {
+	version, _ := RedisClient.Get(ctx, "idx:version").Result()
+	if version == searchIndexVersion {
+		return
+	}
+
+	start := time.Now()
+	scenarios, recordings := ReindexAll(ctx)
+	RedisClient.Set(ctx, "idx:version", searchIndexVersion, 0)
+	log.Printf("[SearchIndex] Indexed %d scenarios and %d recordings in %v", scenarios, recordings, time.Since(start))
+}
+
+// ReindexAll indexes every stored scenario and recording
+func ReindexAll(ctx context.Context) (int, int) {
+	scenarios := 0
+	ids, _ := RedisClient.SMembers(ctx, "scenarios").Result()
+	for _, id := range ids {
+		val, err := RedisClient.Get(ctx, fmt.Sprintf("scenario:%s", id)).Result()
+		if err != nil {
+			continue
+		}
+		var s models.TestScenario
+		if json.Unmarshal([]byte(val), &s) == nil {
+			IndexScenario(ctx, &s)
+			scenarios++
+		}
+	}
+
+	recordings := 0
+	ids, _ = RedisClient.SMembers(ctx, "recordings").Result()
+	for _, id := range ids {
+		val, err := RedisClient.Get(ctx, fmt.Sprintf("recording:%s", id)).Result()
+		if err != nil {
+			continue
+		}
+		var r models.ManualRecording
+		if json.Unmarshal([]byte(val), &r) == nil {
+			IndexRecording(ctx, &r)
+			recordings++
+		}
+	}
+	return scenarios, recordings
+}
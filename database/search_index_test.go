@@ -0,0 +1,19 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexCursorAndTokens(t *testing.T) {
+	score, id, err := decodeIndexCursor(encodeIndexCursor(1760000000123, "rec|1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, float64(1760000000123), score)
+		assert.Equal(t, "rec|1", id)
+	}
+	_, _, err = decodeIndexCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	assert.Equal(t, []string{"login", "with", "sso", "tc01"}, IndexTokens("Login with SSO - login (TC01) a"))
+}
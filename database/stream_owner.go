@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// StreamOwner is who a stream event belongs to. Events are delivered to the
+// owning user and to members of the owning GitLab project.
+type StreamOwner struct {
+	UserID    int    `json:"userId,omitempty"`
+	ProjectID string `json:"projectId,omitempty"`
+}
+
+func (o StreamOwner) known() bool {
+	return o.UserID != 0 || o.ProjectID != ""
+}
+
+type streamOwnerKey struct{}
+
+// WithStreamOwner returns a context whose stream events are attributed to owner.
+// Background work started from a request should carry it, since events
+// published without an owner are not delivered to anyone.
+func WithStreamOwner(ctx context.Context, owner StreamOwner) context.Context {
+	return context.WithValue(ctx, streamOwnerKey{}, owner)
+}
+
+// StreamOwnerFromContext returns the owner set by WithStreamOwner
+func StreamOwnerFromContext(ctx context.Context) (StreamOwner, bool) {
+	owner, ok := ctx.Value(streamOwnerKey{}).(StreamOwner)
+	return owner, ok && owner.known()
+}
+
+func streamOwnerKeyFor(resourceID string) string {
+	return fmt.Sprintf("stream:owner:%s", resourceID)
+}
+
+// RegisterStreamOwner records the owner of a resource's events, so events
+// published for it from contexts without an owner are still attributed
+func RegisterStreamOwner(ctx context.Context, resourceID string, owner StreamOwner) {
+	if resourceID == "" || !owner.known() {
+		return
+	}
+	if data, err := json.Marshal(owner); err == nil {
+		RedisClient.Set(ctx, streamOwnerKeyFor(resourceID), data, resourceLogRetention)
+	}
+}
+
+// ResolveStreamOwner fills in the owner of an event from, in order: the event
+// itself, the context, the resource's registered owner and finally the stored
+// scenario or recording
+func ResolveStreamOwner(ctx context.Context, event *StreamEvent) {
+	if event.UserID != 0 || event.ProjectID != "" {
+		return
+	}
+
+	owner, ok := StreamOwnerFromContext(ctx)
+	if !ok && event.ResourceID != "" {
+		if val, err := RedisClient.Get(ctx, streamOwnerKeyFor(event.ResourceID)).Result(); err == nil {
+			if json.Unmarshal([]byte(val), &owner) == nil && owner.known() {
+				event.UserID, event.ProjectID = owner.UserID, owner.ProjectID
+				return
+			}
+		}
+		owner, ok = resourceOwner(ctx, event.ResourceType, event.ResourceID)
+	}
+	if !ok {
+		return
+	}
+
+	event.UserID, event.ProjectID = owner.UserID, owner.ProjectID
+	RegisterStreamOwner(ctx, event.ResourceID, owner)
+}
+
+func resourceOwner(ctx context.Context, resourceType, resourceID string) (StreamOwner, bool) {
+	var key string
+	switch resourceType {
+	case "scenario":
+		key = fmt.Sprintf("scenario:%s", resourceID)
+	case "recording":
+		key = fmt.Sprintf("recording:%s", resourceID)
+	default:
+		return StreamOwner{}, false
+	}
+	val, err := RedisClient.Get(ctx, key).Result()
+	if err != nil {
+		return StreamOwner{}, false
+	}
+
+	var owner StreamOwner
+	if resourceType == "scenario" {
+		var s models.TestScenario
+		if json.Unmarshal([]byte(val), &s) != nil {
+			return owner, false
+		}
+		owner = StreamOwner{UserID: s.CreatorID, ProjectID: s.ProjectID}
+	} else {
+		var r models.ManualRecording
+		if json.Unmarshal([]byte(val), &r) != nil {
+			return owner, false
+		}
+		owner = StreamOwner{UserID: r.CreatorID, ProjectID: r.ProjectID}
+	}
+	return owner, owner.known()
+}
+
+// ProjectMembershipCacheTTL bounds how long a GitLab membership answer is reused
+// when filtering stream events or checking access to project settings
+const ProjectMembershipCacheTTL = 10 * time.Minute
+
+func projectMembershipKey(userID int, projectID string) string {
+	return fmt.Sprintf("stream:member:%d:%s", userID, projectID)
+}
+
+// GetCachedProjectAccess returns a cached GitLab access level of a user on a
+// project; 0 means the user is not a member
+func GetCachedProjectAccess(ctx context.Context, userID int, projectID string) (level int, ok bool) {
+	val, err := RedisClient.Get(ctx, projectMembershipKey(userID, projectID)).Result()
+	if err != nil {
+		return 0, false
+	}
+	level, err = strconv.Atoi(val)
+	return level, err == nil
+}
+
+// SetCachedProjectAccess caches a GitLab access level answer
+func SetCachedProjectAccess(ctx context.Context, userID int, projectID string, level int) {
+	RedisClient.Set(ctx, projectMembershipKey(userID, projectID), strconv.Itoa(level), ProjectMembershipCacheTTL)
+}
@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// WebhookRetryKey schedules deliveries by the unix time of their next attempt
+	WebhookRetryKey = "webhook:retry"
+
+	webhookDeliveryRetention = 7 * 24 * time.Hour
+	webhookDeliveriesKept    = 200
+)
+
+// ErrWebhookNotFound is returned for unknown webhook or delivery IDs
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// storedWebhook is the Redis record; the secret never leaves this package
+// except to sign a delivery
+type storedWebhook struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+func webhookKey(id string) string {
+	return fmt.Sprintf("webhook:%s", id)
+}
+
+func projectWebhooksKey(projectID string) string {
+	return fmt.Sprintf("webhooks:project:%s", projectID)
+}
+
+func webhookDeliveryKey(id string) string {
+	return fmt.Sprintf("webhook:delivery:%s", id)
+}
+
+func webhookDeliveriesKey(webhookID string) string {
+	return fmt.Sprintf("webhook:deliveries:%s", webhookID)
+}
+
+// NewWebhookSecret generates a signing secret
+func NewWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// SaveWebhook stores a webhook. An empty secret keeps the current one.
+func SaveWebhook(ctx context.Context, hook *models.Webhook, secret string) error {
+	now := time.Now()
+	if hook.ID == "" {
+		hook.ID = uuid.NewString()
+	}
+	if hook.CreatedAt.IsZero() {
+		hook.CreatedAt = now
+	}
+	hook.UpdatedAt = now
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+
+	if secret == "" {
+		existing, err := getStoredWebhook(ctx, hook.ID)
+		if err != nil {
+			return err
+		}
+		secret = existing.Secret
+	}
+
+	data, err := json.Marshal(storedWebhook{Webhook: *hook, Secret: secret})
+	if err != nil {
+		return err
+	}
+	pipe := RedisClient.TxPipeline()
+	pipe.Set(ctx, webhookKey(hook.ID), data, 0)
+	pipe.SAdd(ctx, projectWebhooksKey(hook.ProjectID), hook.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func getStoredWebhook(ctx context.Context, id string) (storedWebhook, error) {
+	var record storedWebhook
+	val, err := RedisClient.Get(ctx, webhookKey(id)).Result()
+	if err != nil {
+		return record, ErrWebhookNotFound
+	}
+	err = json.Unmarshal([]byte(val), &record)
+	return record, err
+}
+
+// GetWebhook returns a webhook without its secret
+func GetWebhook(ctx context.Context, id string) (models.Webhook, error) {
+	record, err := getStoredWebhook(ctx, id)
+	return record.Webhook, err
+}
+
+// GetWebhookSecret returns the secret deliveries to a webhook are signed with
+func GetWebhookSecret(ctx context.Context, id string) (string, error) {
+	record, err := getStoredWebhook(ctx, id)
+	return record.Secret, err
+}
+
+// ListProjectWebhooks returns a project's webhooks, oldest first
+func ListProjectWebhooks(ctx context.Context, projectID string) ([]models.Webhook, error) {
+	ids, err := RedisClient.SMembers(ctx, projectWebhooksKey(projectID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]models.Webhook, 0, len(ids))
+	for _, id := range ids {
+		if hook, err := GetWebhook(ctx, id); err == nil {
+			hooks = append(hooks, hook)
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(ctx context.Context, id string) error {
+	hook, err := GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	pipe := RedisClient.TxPipeline()
+	pipe.Del(ctx, webhookKey(id), webhookDeliveriesKey(id))
+	pipe.SRem(ctx, projectWebhooksKey(hook.ProjectID), id)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// SaveWebhookDelivery records a delivery in its webhook's log, which keeps
+// the newest webhookDeliveriesKept entries for a week
+func SaveWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	now := time.Now()
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	logKey := webhookDeliveriesKey(d.WebhookID)
+	pipe := RedisClient.TxPipeline()
+	pipe.Set(ctx, webhookDeliveryKey(d.ID), data, webhookDeliveryRetention)
+	pipe.ZAdd(ctx, logKey, redis.Z{Score: float64(d.CreatedAt.UnixMilli()), Member: d.ID})
+	pipe.ZRemRangeByRank(ctx, logKey, 0, -webhookDeliveriesKept-1)
+	pipe.Expire(ctx, logKey, webhookDeliveryRetention)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// GetWebhookDelivery returns a logged delivery
+func GetWebhookDelivery(ctx context.Context, id string) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	val, err := RedisClient.Get(ctx, webhookDeliveryKey(id)).Result()
+	if err != nil {
+		return d, ErrWebhookNotFound
+	}
+	err = json.Unmarshal([]byte(val), &d)
+	return d, err
+}
+
+// ListWebhookDeliveries returns a webhook's deliveries, newest first
+func ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	ids, err := RedisClient.ZRevRange(ctx, webhookDeliveriesKey(webhookID), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]models.WebhookDelivery, 0, len(ids))
+	for _, id := range ids {
+		if d, err := GetWebhookDelivery(ctx, id); err == nil {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// ScheduleWebhookDelivery queues a delivery attempt for the given time
+func ScheduleWebhookDelivery(ctx context.Context, deliveryID string, at time.Time) error {
+	return RedisClient.ZAdd(ctx, WebhookRetryKey, redis.Z{Score: float64(at.Unix()), Member: deliveryID}).Err()
+}
+
+// UnscheduleWebhookDelivery removes a queued attempt. It reports whether the
+// attempt was still queued, which makes it the claim between instances.
+func UnscheduleWebhookDelivery(ctx context.Context, deliveryID string) (bool, error) {
+	n, err := RedisClient.ZRem(ctx, WebhookRetryKey, deliveryID).Result()
+	return n > 0, err
+}
+
+// DueWebhookDeliveries returns the IDs of queued deliveries whose attempt is due
+func DueWebhookDeliveries(ctx context.Context, now time.Time) ([]string, error) {
+	return RedisClient.ZRangeByScore(ctx, WebhookRetryKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: 50,
+	}).Result()
+}
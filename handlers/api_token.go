@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+// apiTokenWithSecret is returned when a token is created; the secret is not
+// shown again afterwards
+type apiTokenWithSecret struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// apiTokenError maps API token store errors to responses
+func apiTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrAPITokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrCredentialKeyMissing):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// tokenAllowsProject reports whether the request may see a project's data.
+// Only API tokens limited to other projects are refused; routes outside
+// /projects/:id use it to filter what they return.
+func tokenAllowsProject(c *gin.Context, projectID string) bool {
+	if v, ok := c.Get("api_token"); ok {
+		tok := v.(models.APIToken)
+		return tok.AllowsProject(projectID)
+	}
+	return true
+}
+
+// apiTokenProjectIDs returns the projects a request's API token is limited
+// to, or nil for browser sessions and unlimited tokens
+func apiTokenProjectIDs(c *gin.Context) []string {
+	if v, ok := c.Get("api_token"); ok {
+		return v.(models.APIToken).ProjectIDs
+	}
+	return nil
+}
+
+// CreateAPIToken handles POST /api-tokens
+// Creates a personal token, optionally limited to projectIds.
+func CreateAPIToken(c *gin.Context) {
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	createAPIToken(c, req, models.APITokenPersonal, req.ProjectIDs)
+}
+
+// CreateProjectAPIToken handles POST /projects/:id/api-tokens
+// Creates a token that only reaches the project.
+func CreateProjectAPIToken(c *gin.Context) {
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	createAPIToken(c, req, models.APITokenProject, []string{c.Param("id")})
+}
+
+// createAPIToken stores a token acting with the GitLab access token in the
+// request, or with the creator's own GitLab authorization when none is given.
+// A GitLab access token is preferred for long-lived tokens: the creator's
+// authorization stops working when GitLab revokes it, e.g. on sign-out.
+// Project tokens need Maintainer access to the project; personal tokens can
+// only be limited to projects the creator is a member of.
+func createAPIToken(c *gin.Context, req models.CreateAPITokenRequest, kind string, projectIDs []string) {
+	if err := services.ValidateAPITokenRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	minAccess := gitlab.GuestPermissions
+	if kind == models.APITokenProject {
+		minAccess = gitlab.MaintainerPermissions
+	}
+	for _, pid := range projectIDs {
+		if !RequireProjectAccess(c, pid, minAccess) {
+			return
+		}
+	}
+	creatorID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	tok := models.APIToken{
+		Name:       strings.TrimSpace(req.Name),
+		Kind:       kind,
+		ProjectIDs: projectIDs,
+		Scopes:     req.Scopes,
+		CreatedBy:  creatorID,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		tok.ExpiresAt = &expires
+	}
+
+	var gitlabToken *oauth2.Token
+	if req.GitLabToken != "" {
+		gitlabToken = &oauth2.Token{AccessToken: strings.TrimSpace(req.GitLabToken), TokenType: "Bearer"}
+		gl, err := client.GetClient(ctx, gitlabToken, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		user, _, err := gl.Users.CurrentUser()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "GitLab rejected the access token: " + err.Error()})
+			return
+		}
+		for _, pid := range projectIDs {
+			if _, _, err := gl.Projects.GetProject(pid, nil); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the GitLab access token can't access project " + pid})
+				return
+			}
+		}
+		tok.UserID = int(user.ID)
+		tok.GitLabCredentials = models.APITokenGitLabAccessToken
+	} else {
+		sessionToken := c.MustGet("token").(*oauth2.Token)
+		if err := auth.SaveSessionGrant(ctx, c.GetString("session_id"), creatorID, sessionToken); err != nil {
+			apiTokenError(c, err)
+			return
+		}
+		tok.UserID = creatorID
+		tok.GitLabCredentials = models.APITokenGitLabGrant
+	}
+
+	secret, err := database.NewAPITokenSecret()
+	if err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	if err := database.CreateAPIToken(ctx, &tok, secret, gitlabToken); err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, apiTokenWithSecret{APIToken: tok, Token: secret})
+}
+
+// ListAPITokens handles GET /api-tokens
+// Returns the caller's personal tokens without their secrets.
+func ListAPITokens(c *gin.Context) {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return
+	}
+	tokens, err := database.ListUserAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// ListProjectAPITokens handles GET /projects/:id/api-tokens
+// Managing project tokens needs Maintainer access to the project.
+func ListProjectAPITokens(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	tokens, err := database.ListProjectAPITokens(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// RevokeAPIToken handles DELETE /api-tokens/:tokenId
+// Only the creator can revoke a personal token.
+func RevokeAPIToken(c *gin.Context) {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	tok, err := database.GetAPIToken(ctx, c.Param("tokenId"))
+	if err == nil && (tok.Kind != models.APITokenPersonal || tok.CreatedBy != userID) {
+		err = database.ErrAPITokenNotFound
+	}
+	if err == nil {
+		err = database.RevokeAPIToken(ctx, tok.ID)
+	}
+	if err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
+
+// RevokeProjectAPIToken handles DELETE /projects/:id/api-tokens/:tokenId
+func RevokeProjectAPIToken(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	ctx := c.Request.Context()
+	tok, err := database.GetAPIToken(ctx, c.Param("tokenId"))
+	if err == nil && (tok.Kind != models.APITokenProject || !tok.AllowsProject(c.Param("id"))) {
+		err = database.ErrAPITokenNotFound
+	}
+	if err == nil {
+		err = database.RevokeAPIToken(ctx, tok.ID)
+	}
+	if err != nil {
+		apiTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
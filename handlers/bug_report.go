@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"qa-extension-backend/agent"
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/identity"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// CreateBugFromFailure files a GitLab issue from the latest failed run of a
+// scenario test case or recording, or notes a new occurrence on an open issue
+// with the same failure signature.
+func CreateBugFromFailure(c *gin.Context) {
+	var req agent.FileBugRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if projectID := c.Param("id"); projectID != "" {
+		req.ProjectID = projectID
+	}
+	if req.RecordingID == "" && (req.ScenarioID == "" || req.TestCaseID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "either scenarioId and testCaseId, or recordingId is required"})
+		return
+	}
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return
+	}
+	req.ReportedBy = userID
+
+	token := c.MustGet("token").(*oauth2.Token)
+	sessionID := c.MustGet("session_id").(string)
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	gitlabClient, err := client.GetClient(c, token, tokenSaver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitLab client: " + err.Error()})
+		return
+	}
+
+	result, err := agent.FileBugFromFailure(c.Request.Context(), gitlabClient, req, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, agent.ErrBugSourceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, agent.ErrBugSourceForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, agent.ErrIssueNotCreated):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if result.Duplicate || req.DryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, result)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// credentialError maps credential store errors to responses
+func credentialError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrCredentialNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrCredentialKeyMissing):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// projectCredential loads the :credentialId credential if the caller is a
+// maintainer of its project, and writes the error response otherwise
+func projectCredential(c *gin.Context) (models.Credential, bool) {
+	cred, err := database.GetCredential(c.Request.Context(), c.Param("credentialId"))
+	if err != nil {
+		credentialError(c, err)
+		return cred, false
+	}
+	return cred, RequireProjectAccess(c, cred.ProjectID, gitlab.MaintainerPermissions)
+}
+
+// ListProjectCredentials lists the stored test accounts of a project. Only
+// metadata and masked usernames are returned, to developers and above.
+func ListProjectCredentials(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.DeveloperPermissions) {
+		return
+	}
+	creds, err := database.ListProjectCredentials(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		credentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": creds})
+}
+
+// CreateProjectCredential stores a new encrypted test account for a project.
+// Only project maintainers can add accounts.
+func CreateProjectCredential(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	var req models.SaveCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cred := models.Credential{
+		ProjectID: c.Param("id"),
+		Name:      req.Name,
+		CreatedBy: currentAuthorID(c),
+	}
+	if cred.Name == "" {
+		cred.Name = "Test account"
+	}
+	if err := database.SaveCredential(c.Request.Context(), &cred, models.CredentialSecret{Username: req.Username, Password: req.Password}); err != nil {
+		credentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cred)
+}
+
+// UpdateCredential rotates a stored test account. Scenarios and steps keep
+// referencing the same ID. Only project maintainers can rotate accounts.
+func UpdateCredential(c *gin.Context) {
+	cred, ok := projectCredential(c)
+	if !ok {
+		return
+	}
+	var req models.SaveCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if req.Name != "" {
+		cred.Name = req.Name
+	}
+	if err := database.SaveCredential(ctx, &cred, models.CredentialSecret{Username: req.Username, Password: req.Password}); err != nil {
+		credentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cred)
+}
+
+// DeleteCredential removes a stored test account. Only project maintainers
+// can delete accounts.
+func DeleteCredential(c *gin.Context) {
+	cred, ok := projectCredential(c)
+	if !ok {
+		return
+	}
+	if err := database.DeleteCredential(c.Request.Context(), cred.ID); err != nil {
+		credentialError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "credential deleted"})
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// duplicateRunWindow is how many recent runs rank the automation of a duplicate
+const duplicateRunWindow = 20
+
+// projectScenarios loads every scenario that belongs to a GitLab project
+func projectScenarios(ctx context.Context, projectID string) []models.TestScenario {
+	ids, err := database.RedisClient.SMembers(ctx, "scenarios").Result()
+	if err != nil {
+		return nil
+	}
+
+	var scenarios []models.TestScenario
+	for _, id := range ids {
+		val, err := database.RedisClient.Get(ctx, fmt.Sprintf("scenario:%s", id)).Result()
+		if err != nil {
+			continue
+		}
+		var s models.TestScenario
+		if json.Unmarshal([]byte(val), &s) == nil && s.ProjectID == projectID {
+			scenarios = append(scenarios, s)
+		}
+	}
+	sort.Slice(scenarios, func(i, j int) bool { return scenarios[i].CreatedAt.Before(scenarios[j].CreatedAt) })
+	return scenarios
+}
+
+func duplicateKey(scenarioID, tcID string) string {
+	return scenarioID + "|" + tcID
+}
+
+// duplicateMember describes a test case together with its automation track record
+func duplicateMember(ctx context.Context, scenario *models.TestScenario, sec models.TestSection, tc models.TestCase) models.DuplicateMember {
+	m := models.DuplicateMember{
+		ScenarioID:    scenario.ID,
+		ScenarioTitle: scenario.Title,
+		SectionID:     sec.ID,
+		SectionTitle:  sec.Title,
+		TestCaseID:    tc.ID,
+		Code:          tc.Code,
+		Title:         tc.Title,
+		StepCount:     len(tc.Steps),
+	}
+	auto := tc.AutomationTest
+	if auto == nil || len(auto.Steps) == 0 {
+		return m
+	}
+
+	m.Automated = true
+	m.AutomationStatus = auto.Status
+	m.LastRunAt = auto.LastRunAt
+
+	results, _ := database.GetRecentTestResults(ctx, auto.ID, duplicateRunWindow)
+	passed := 0
+	for _, r := range results {
+		if r.Status == "passed" {
+			passed++
+		}
+	}
+	m.Runs = len(results)
+	if m.Runs == 0 && (auto.Status == models.AutomationStatusPass || auto.Status == models.AutomationStatusFail) {
+		m.Runs = 1
+		if auto.Status == models.AutomationStatusPass {
+			passed = 1
+		}
+	}
+	if m.Runs > 0 {
+		m.PassRate = float64(int(float64(passed)/float64(m.Runs)*1000+0.5)) / 10
+	}
+	return m
+}
+
+// betterPerformer ranks duplicates: automated beats manual, then pass rate,
+// then number of runs, then the most recent run
+func betterPerformer(a, b models.DuplicateMember) bool {
+	if a.Automated != b.Automated {
+		return a.Automated
+	}
+	if a.PassRate != b.PassRate {
+		return a.PassRate > b.PassRate
+	}
+	if a.Runs != b.Runs {
+		return a.Runs > b.Runs
+	}
+	return a.LastRunAt > b.LastRunAt
+}
+
+// GetProjectDuplicates lists clusters of likely duplicate test cases across the
+// scenarios of a project. ?threshold= sets the cosine similarity cut-off (default 0.8).
+func GetProjectDuplicates(c *gin.Context) {
+	projectID := c.Param("id")
+
+	threshold := 0.8
+	if t := c.Query("threshold"); t != "" {
+		parsed, err := strconv.ParseFloat(t, 64)
+		if err != nil || parsed < 0.3 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be a number between 0.3 and 1"})
+			return
+		}
+		threshold = parsed
+	}
+	scenarioFilter := c.Query("scenarioId")
+
+	ctx := c.Request.Context()
+	scenarios := projectScenarios(ctx, projectID)
+
+	var docs []services.SimilarityDoc
+	type location struct {
+		scenario *models.TestScenario
+		section  models.TestSection
+		tc       models.TestCase
+	}
+	locations := make(map[string]location)
+	for i := range scenarios {
+		s := &scenarios[i]
+		for _, sec := range s.Sections {
+			for _, tc := range sec.TestCases {
+				if tc.Status == models.TCStatusDeprecated {
+					continue
+				}
+				key := duplicateKey(s.ID, tc.ID)
+				locations[key] = location{scenario: s, section: sec, tc: tc}
+				docs = append(docs, services.TestCaseDoc(key, tc))
+			}
+		}
+	}
+
+	pairs := services.NewSimilarityIndex(docs).Pairs(threshold)
+	pairsByKey := make(map[string][]models.DuplicatePair)
+	for _, p := range pairs {
+		pair := models.DuplicatePair{
+			A:          locations[p.A].tc.ID,
+			B:          locations[p.B].tc.ID,
+			Similarity: float64(int(p.Similarity*1000+0.5)) / 1000,
+		}
+		pairsByKey[p.A] = append(pairsByKey[p.A], pair)
+	}
+
+	clusters := []models.DuplicateCluster{}
+	for i, keys := range services.ClusterPairs(pairs) {
+		if scenarioFilter != "" {
+			touches := false
+			for _, k := range keys {
+				if strings.HasPrefix(k, scenarioFilter+"|") {
+					touches = true
+				}
+			}
+			if !touches {
+				continue
+			}
+		}
+
+		cluster := models.DuplicateCluster{ID: fmt.Sprintf("dup-%d", i+1), MinSimilarity: 1}
+		for _, k := range keys {
+			loc := locations[k]
+			cluster.Members = append(cluster.Members, duplicateMember(ctx, loc.scenario, loc.section, loc.tc))
+			for _, p := range pairsByKey[k] {
+				cluster.Pairs = append(cluster.Pairs, p)
+				if p.Similarity > cluster.MaxSimilarity {
+					cluster.MaxSimilarity = p.Similarity
+				}
+				if p.Similarity < cluster.MinSimilarity {
+					cluster.MinSimilarity = p.Similarity
+				}
+			}
+		}
+		sort.SliceStable(cluster.Members, func(a, b int) bool {
+			return betterPerformer(cluster.Members[a], cluster.Members[b])
+		})
+		cluster.SuggestedKeepID = cluster.Members[0].TestCaseID
+		clusters = append(clusters, cluster)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clusters":  clusters,
+		"total":     len(clusters),
+		"threshold": threshold,
+		"scanned":   len(docs),
+	})
+}
+
+// MergeDuplicates collapses a duplicate cluster into one test case. Tags and
+// issue links are combined, the automation of the best-performing member is
+// kept, and the other test cases are removed from their scenarios.
+func MergeDuplicates(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var req models.MergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Members) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least two members are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenarios := make(map[string]*models.TestScenario)
+	var members []models.DuplicateMember
+	seen := make(map[string]bool)
+	for _, ref := range req.Members {
+		if seen[ref.TestCaseID] {
+			continue
+		}
+		seen[ref.TestCaseID] = true
+
+		scenario, ok := scenarios[ref.ScenarioID]
+		if !ok {
+			s, err := getScenario(ctx, ref.ScenarioID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("scenario %s not found", ref.ScenarioID)})
+				return
+			}
+			if s.ProjectID != projectID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("scenario %s does not belong to this project", ref.ScenarioID)})
+				return
+			}
+			if s.Status == models.ScenarioStatusGenerating {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("scenario %s is generating tests", s.Title)})
+				return
+			}
+			scenario = &s
+			scenarios[ref.ScenarioID] = scenario
+		}
+
+		si, ti := findTestCaseLocation(scenario, ref.TestCaseID)
+		if si < 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("test case %s not found in scenario %s", ref.TestCaseID, ref.ScenarioID)})
+			return
+		}
+		sec := scenario.Sections[si]
+		members = append(members, duplicateMember(ctx, scenario, sec, sec.TestCases[ti]))
+	}
+	if len(members) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least two distinct test cases are required"})
+		return
+	}
+
+	best := members[0]
+	for _, m := range members[1:] {
+		if betterPerformer(m, best) {
+			best = m
+		}
+	}
+	keep := best
+	if req.KeepTestCaseID != "" {
+		found := false
+		for _, m := range members {
+			if m.TestCaseID == req.KeepTestCaseID {
+				keep, found = m, true
+			}
+		}
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "keepTestCaseId must be one of the members"})
+			return
+		}
+	}
+
+	keepScenario := scenarios[keep.ScenarioID]
+	si, ti := findTestCaseLocation(keepScenario, keep.TestCaseID)
+	keeper := &keepScenario.Sections[si].TestCases[ti]
+
+	result := models.MergeDuplicatesResult{Kept: models.DuplicateRef{ScenarioID: keep.ScenarioID, TestCaseID: keep.TestCaseID}}
+	if keeper.AutomationTest != nil {
+		result.AutomationFrom = keeper.ID
+	}
+
+	// Collect what the removed copies bring along before taking them out
+	var mergedCodes []string
+	removeByScenario := make(map[string][]string)
+	for _, m := range members {
+		if m.TestCaseID == keep.TestCaseID {
+			continue
+		}
+		s := scenarios[m.ScenarioID]
+		msi, mti := findTestCaseLocation(s, m.TestCaseID)
+		dup := s.Sections[msi].TestCases[mti]
+
+		keeper.Tags = applyTagUpdate(keeper.Tags, models.TagUpdate{Add: dup.Tags})
+		for _, l := range dup.Issues {
+			linked := false
+			for _, existing := range keeper.Issues {
+				if existing.Matches(l.Type, linkParentID(l), l.IID) {
+					linked = true
+				}
+			}
+			if !linked {
+				keeper.Issues = append(keeper.Issues, l)
+			}
+			database.RedisClient.SRem(ctx, database.TraceabilityKey(l.Type, linkParentID(l), l.IID), database.TraceabilityMember(s.ID, dup.ID))
+		}
+		if m.TestCaseID == best.TestCaseID && !req.KeepOwnAutomation && dup.AutomationTest != nil {
+			keeper.AutomationTest = dup.AutomationTest
+			result.AutomationFrom = dup.ID
+		}
+
+		label := dup.Code
+		if s.ID != keep.ScenarioID {
+			label = fmt.Sprintf("%s (%s)", dup.Code, s.Title)
+		}
+		mergedCodes = append(mergedCodes, label)
+		removeByScenario[s.ID] = append(removeByScenario[s.ID], dup.ID)
+		result.Removed = append(result.Removed, models.DuplicateRef{ScenarioID: s.ID, TestCaseID: dup.ID})
+	}
+
+	note := "Merged duplicates: " + strings.Join(mergedCodes, ", ")
+	if keeper.Note != "" {
+		keeper.Note += "\n"
+	}
+	keeper.Note += note
+	keeper.UpdatedAt = time.Now().Format(time.RFC3339)
+	keeperCopy := *keeper
+
+	authorID := currentAuthorID(c)
+	for id, s := range scenarios {
+		takeTestCases(s, removeByScenario[id])
+		s.UpdatedAt = time.Now()
+		s.ComputeStats()
+
+		summary := fmt.Sprintf("Removed %d duplicates merged into %s", len(removeByScenario[id]), keep.Code)
+		if id == keep.ScenarioID {
+			summary = fmt.Sprintf("Merged %s into %s", strings.Join(mergedCodes, ", "), keep.Code)
+		}
+		if err := saveScenarioVersioned(ctx, s, authorID, models.VersionActionMergeDuplicates, summary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		result.UpdatedScenarioIDs = append(result.UpdatedScenarioIDs, id)
+	}
+	sort.Strings(result.UpdatedScenarioIDs)
+	indexTestCaseLinks(ctx, keep.ScenarioID, keeperCopy)
+
+	c.JSON(http.StatusOK, gin.H{"result": result, "testCase": keeperCopy})
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+var (
+	errEnvironmentNotFound = errors.New("environment not found")
+	errSecretValueMissing  = errors.New("secret has no stored value")
+)
+
+func getEnvironment(ctx context.Context, id string) (models.Environment, error) {
+	var env models.Environment
+	val, err := database.RedisClient.Get(ctx, fmt.Sprintf("environment:%s", id)).Result()
+	if err != nil {
+		return env, errEnvironmentNotFound
+	}
+	err = json.Unmarshal([]byte(val), &env)
+	return env, err
+}
+
+func saveEnvironment(ctx context.Context, env *models.Environment) error {
+	val, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("environment:%s", env.ID), val, 0)
+	pipe.SAdd(ctx, fmt.Sprintf("environments:project:%s", env.ProjectID), env.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// listEnvironments returns a project's environments, default first, then by name
+func listEnvironments(ctx context.Context, projectID string) ([]models.Environment, error) {
+	ids, err := database.RedisClient.SMembers(ctx, fmt.Sprintf("environments:project:%s", projectID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	envs := make([]models.Environment, 0, len(ids))
+	for _, id := range ids {
+		if env, err := getEnvironment(ctx, id); err == nil {
+			envs = append(envs, env)
+		}
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		if envs[i].IsDefault != envs[j].IsDefault {
+			return envs[i].IsDefault
+		}
+		return strings.ToLower(envs[i].Name) < strings.ToLower(envs[j].Name)
+	})
+	return envs, nil
+}
+
+// findEnvironment looks an environment up by ID or, case-insensitively, by name
+func findEnvironment(ctx context.Context, projectID, selector string) (*models.Environment, error) {
+	envs, err := listEnvironments(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range envs {
+		if envs[i].ID == selector || strings.EqualFold(envs[i].Name, selector) {
+			return &envs[i], nil
+		}
+	}
+	return nil, errEnvironmentNotFound
+}
+
+// resolveRunEnvironment picks the environment for a run: the one asked for,
+// else the project's default. A nil environment runs the steps as stored.
+func resolveRunEnvironment(ctx context.Context, projectID, selector string) (*models.Environment, error) {
+	if projectID == "" {
+		if selector != "" {
+			return nil, fmt.Errorf("environments require a project")
+		}
+		return nil, nil
+	}
+	if selector != "" {
+		env, err := findEnvironment(ctx, projectID, selector)
+		if err != nil {
+			return nil, fmt.Errorf("environment %q not found in this project", selector)
+		}
+		return env, nil
+	}
+	envs, err := listEnvironments(ctx, projectID)
+	if err != nil {
+		return nil, nil
+	}
+	for i := range envs {
+		if envs[i].IsDefault {
+			return &envs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// validateEnvironment checks the URLs, variable names and referenced credential
+// of an environment request
+func validateEnvironment(ctx context.Context, projectID string, req *models.SaveEnvironmentRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for field, raw := range map[string]string{"baseUrl": req.BaseURL, "apiBaseUrl": req.ApiBaseURL, "loginUrl": req.LoginURL} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an absolute http(s) URL", field)
+		}
+	}
+	for name := range req.Variables {
+		if !models.EnvVariableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+	}
+	for name := range req.Secrets {
+		if !models.EnvVariableNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+		if _, ok := req.Variables[name]; ok {
+			return fmt.Errorf("%s can't be both a variable and a secret", name)
+		}
+	}
+	for name, value := range req.Headers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") || strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid header %q", name)
+		}
+	}
+	if req.CredentialID != "" {
+		cred, err := database.GetCredential(ctx, req.CredentialID)
+		if err != nil || cred.ProjectID != projectID {
+			return fmt.Errorf("credential not found in this project")
+		}
+	}
+	return nil
+}
+
+// applyEnvironmentRequest copies a validated request onto an environment and
+// keeps names unique and a single default per project
+func applyEnvironmentRequest(ctx context.Context, env *models.Environment, req models.SaveEnvironmentRequest) error {
+	if other, err := findEnvironment(ctx, env.ProjectID, req.Name); err == nil && other.ID != env.ID {
+		return fmt.Errorf("an environment named %q already exists", req.Name)
+	}
+
+	env.Name = req.Name
+	env.Description = req.Description
+	env.BaseURL = req.BaseURL
+	env.ApiBaseURL = req.ApiBaseURL
+	env.LoginURL = req.LoginURL
+	env.CredentialID = req.CredentialID
+	env.Variables = req.Variables
+	env.Headers = req.Headers
+	env.IsDefault = req.IsDefault
+	env.UpdatedAt = time.Now()
+
+	if !env.IsDefault {
+		return nil
+	}
+	envs, err := listEnvironments(ctx, env.ProjectID)
+	if err != nil {
+		return err
+	}
+	for i := range envs {
+		if envs[i].ID != env.ID && envs[i].IsDefault {
+			envs[i].IsDefault = false
+			if err := saveEnvironment(ctx, &envs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveEnvironmentSecrets keeps secret variables in the credential store, one
+// credential per secret, and deletes the ones no longer listed. An empty value
+// keeps the stored secret.
+func saveEnvironmentSecrets(ctx context.Context, env *models.Environment, secrets map[string]string) error {
+	kept := make(map[string]string, len(secrets))
+	for name, value := range secrets {
+		id := env.SecretVariables[name]
+		if value == "" {
+			if id == "" {
+				return fmt.Errorf("%w: %s", errSecretValueMissing, name)
+			}
+			kept[name] = id
+			continue
+		}
+		cred := models.Credential{ID: id, CreatedBy: env.CreatedBy}
+		if id != "" {
+			if existing, err := database.GetCredential(ctx, id); err == nil {
+				cred = existing
+			}
+		}
+		cred.ProjectID = env.ProjectID
+		cred.Name = fmt.Sprintf("%s environment: %s", env.Name, name)
+		if err := database.SaveCredential(ctx, &cred, models.CredentialSecret{Password: value}); err != nil {
+			return err
+		}
+		kept[name] = cred.ID
+	}
+	for name, id := range env.SecretVariables {
+		if _, ok := kept[name]; !ok {
+			deleteEnvironmentSecret(ctx, id)
+		}
+	}
+	env.SecretVariables = nil
+	if len(kept) > 0 {
+		env.SecretVariables = kept
+	}
+	return nil
+}
+
+func deleteEnvironmentSecret(ctx context.Context, credentialID string) {
+	if err := database.DeleteCredential(ctx, credentialID); err != nil && !errors.Is(err, database.ErrCredentialNotFound) {
+		log.Printf("[Environments] Failed to delete secret %s: %v", credentialID, err)
+	}
+}
+
+// environmentSecretError maps secret storage errors to responses
+func environmentSecretError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errSecretValueMissing):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrCredentialKeyMissing):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save environment secrets"})
+	}
+}
+
+// projectEnvironment loads the environment of the route and checks the caller
+// is a member of its project
+func projectEnvironment(c *gin.Context) (models.Environment, bool) {
+	env, err := getEnvironment(c.Request.Context(), c.Param("envId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return env, false
+	}
+	return env, RequireProjectAccess(c, env.ProjectID, gitlab.GuestPermissions)
+}
+
+// ListEnvironments handles GET /projects/:id/environments
+func ListEnvironments(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.GuestPermissions) {
+		return
+	}
+	envs, err := listEnvironments(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list environments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": envs})
+}
+
+// CreateEnvironment handles POST /projects/:id/environments
+func CreateEnvironment(c *gin.Context) {
+	var req models.SaveEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	projectID := c.Param("id")
+	if !RequireProjectAccess(c, projectID, gitlab.GuestPermissions) {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := validateEnvironment(ctx, projectID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	env := models.Environment{
+		ID:        models.NewEnvironmentID(),
+		ProjectID: projectID,
+		CreatedBy: currentAuthorID(c),
+		CreatedAt: time.Now(),
+	}
+	if err := applyEnvironmentRequest(ctx, &env, req); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err := saveEnvironmentSecrets(ctx, &env, req.Secrets); err != nil {
+		environmentSecretError(c, err)
+		return
+	}
+	if err := saveEnvironment(ctx, &env); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save environment"})
+		return
+	}
+	c.JSON(http.StatusCreated, env)
+}
+
+// GetEnvironment handles GET /environments/:envId
+func GetEnvironment(c *gin.Context) {
+	env, ok := projectEnvironment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, env)
+}
+
+// UpdateEnvironment handles PUT /environments/:envId
+func UpdateEnvironment(c *gin.Context) {
+	var req models.SaveEnvironmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	env, ok := projectEnvironment(c)
+	if !ok {
+		return
+	}
+	if err := validateEnvironment(ctx, env.ProjectID, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyEnvironmentRequest(ctx, &env, req); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err := saveEnvironmentSecrets(ctx, &env, req.Secrets); err != nil {
+		environmentSecretError(c, err)
+		return
+	}
+	if err := saveEnvironment(ctx, &env); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save environment"})
+		return
+	}
+	c.JSON(http.StatusOK, env)
+}
+
+// DeleteEnvironment handles DELETE /environments/:envId
+func DeleteEnvironment(c *gin.Context) {
+	ctx := c.Request.Context()
+	env, ok := projectEnvironment(c)
+	if !ok {
+		return
+	}
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Del(ctx, fmt.Sprintf("environment:%s", env.ID))
+	pipe.SRem(ctx, fmt.Sprintf("environments:project:%s", env.ProjectID), env.ID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete environment"})
+		return
+	}
+	for _, id := range env.SecretVariables {
+		deleteEnvironmentSecret(ctx, id)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "environment deleted"})
+}
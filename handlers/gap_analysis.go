@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"qa-extension-backend/agent"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// gapAnalysisLockTTL bounds how long a crashed job keeps others from starting
+const gapAnalysisLockTTL = 30 * time.Minute
+
+func gapAnalysisKey(id string) string {
+	return fmt.Sprintf("gap_analysis:%s", id)
+}
+
+// gapAnalysisLockKey is held by the running job of a scenario
+func gapAnalysisLockKey(scenarioID string) string {
+	return fmt.Sprintf("gap_analysis:running:%s", scenarioID)
+}
+
+// releaseGapAnalysisLock deletes the lock only while the job still holds it,
+// so a job that outlived its lock can't release the next job's
+var releaseGapAnalysisLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func getGapAnalysisJob(ctx context.Context, id string) (models.GapAnalysisJob, error) {
+	var job models.GapAnalysisJob
+	val, err := database.RedisClient.Get(ctx, gapAnalysisKey(id)).Result()
+	if err != nil {
+		return job, err
+	}
+	err = json.Unmarshal([]byte(val), &job)
+	return job, err
+}
+
+// saveGapAnalysisJob stores the job and marks it as the scenario's latest run
+func saveGapAnalysisJob(ctx context.Context, job *models.GapAnalysisJob) error {
+	val, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, gapAnalysisKey(job.ID), val, 0)
+	pipe.Set(ctx, fmt.Sprintf("gap_analysis:scenario:%s", job.ScenarioID), job.ID, 0)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func getProposal(ctx context.Context, id string) (models.TestCaseProposal, error) {
+	var p models.TestCaseProposal
+	val, err := database.RedisClient.Get(ctx, fmt.Sprintf("proposal:%s", id)).Result()
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal([]byte(val), &p)
+	return p, err
+}
+
+func saveProposal(ctx context.Context, p *models.TestCaseProposal) error {
+	val, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("proposal:%s", p.ID), val, 0)
+	pipe.SAdd(ctx, fmt.Sprintf("proposals:scenario:%s", p.ScenarioID), p.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// StartGapAnalysis starts a background job that proposes missing test cases for
+// a scenario. Proposals go to the review queue; nothing is written to the scenario.
+func StartGapAnalysis(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.StartGapAnalysisRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, cat := range req.Categories {
+		if !cat.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown category %q", cat)})
+			return
+		}
+	}
+	if len(req.Categories) == 0 {
+		req.Categories = models.AllGapCategories
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	jobID := models.NewGapAnalysisJobID()
+	locked, err := database.RedisClient.SetNX(ctx, gapAnalysisLockKey(id), jobID, gapAnalysisLockTTL).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !locked {
+		resp := gin.H{"error": "a gap analysis is already running for this scenario"}
+		if runningID, err := database.RedisClient.Get(ctx, gapAnalysisLockKey(id)).Result(); err == nil {
+			if running, err := getGapAnalysisJob(ctx, runningID); err == nil {
+				resp["job"] = running
+			}
+		}
+		c.JSON(http.StatusConflict, resp)
+		return
+	}
+
+	job := models.GapAnalysisJob{
+		ID:          jobID,
+		ScenarioID:  id,
+		Status:      models.GapAnalysisRunning,
+		Categories:  req.Categories,
+		Branch:      req.Branch,
+		RequestedBy: currentAuthorID(c),
+		StartedAt:   time.Now(),
+	}
+	if err := saveGapAnalysisJob(ctx, &job); err != nil {
+		releaseGapAnalysisLock.Run(ctx, database.RedisClient, []string{gapAnalysisLockKey(id)}, jobID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	gitlabClient := backgroundGitLabClient(c)
+
+	c.JSON(http.StatusAccepted, job)
+
+	go runGapAnalysis(scenario, job, req, gitlabClient)
+}
+
+func runGapAnalysis(scenario models.TestScenario, job models.GapAnalysisJob, req models.StartGapAnalysisRequest, gitlabClient *gitlab.Client) {
+	bgCtx := database.WithStreamOwner(context.Background(), database.StreamOwner{UserID: job.RequestedBy, ProjectID: scenario.ProjectID})
+	events := agent.NewGenerationEmitter(bgCtx, scenario.ID)
+	events.Start("Analysing %s for missing test cases...", scenario.Title)
+
+	finish := func(err error) {
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = models.GapAnalysisFailed
+			job.Error = err.Error()
+			events.Error("Gap analysis failed: " + err.Error())
+		} else {
+			job.Status = models.GapAnalysisCompleted
+			events.Done("Proposed %d test cases for review", job.ProposalCount)
+		}
+		if err := saveGapAnalysisJob(bgCtx, &job); err != nil {
+			log.Printf("[GapAnalysis] Failed to save job %s: %v", job.ID, err)
+		}
+		releaseGapAnalysisLock.Run(bgCtx, database.RedisClient, []string{gapAnalysisLockKey(scenario.ID)}, job.ID)
+	}
+
+	var catalog *services.ModuleCatalog
+	if scenario.ProjectID != "" && gitlabClient != nil {
+		branch := job.Branch
+		if branch == "" {
+			if project, _, err := gitlabClient.Projects.GetProject(scenario.ProjectID, nil); err == nil {
+				branch = project.DefaultBranch
+			}
+		}
+		if branch != "" {
+			events.Progress("Loading knowledge graph...")
+			graphMapper := services.NewGraphMapper()
+			catalog, _ = graphMapper.GetCachedCatalog(bgCtx, scenario.ProjectID, branch)
+			if catalog == nil {
+				var err error
+				catalog, err = graphMapper.FetchAndEnrichCatalog(bgCtx, gitlabClient, scenario.ProjectID, branch)
+				if err != nil {
+					log.Printf("[GapAnalysis] Knowledge graph unavailable for project %s: %v", scenario.ProjectID, err)
+					catalog = nil
+				}
+			}
+			job.Branch = branch
+		}
+	}
+	job.UsedKnowledge = catalog != nil
+
+	input := services.GapAnalysisInput{
+		Scenario:     &scenario,
+		SectionIDs:   req.SectionIDs,
+		Catalog:      catalog,
+		Categories:   job.Categories,
+		MaxProposals: req.MaxProposals,
+	}
+	if catalog != nil {
+		events.Progress("Reading forms and API calls...")
+		input.Graph = services.LoadGapKnowledge(bgCtx, gitlabClient, scenario.ProjectID, job.Branch, catalog, services.GapAnalysisRoutes(input))
+	}
+
+	events.Progress("Looking for validation, permission, empty state and boundary gaps...")
+	proposals, err := services.ProposeMissingTestCases(bgCtx, input)
+	if err != nil {
+		finish(err)
+		return
+	}
+
+	// Don't re-propose what is already waiting in the queue
+	pending := make(map[string]bool)
+	for _, p := range listProposals(bgCtx, scenario.ID, models.ProposalPending) {
+		pending[normalizeProposalTitle(p.Title)] = true
+	}
+
+	now := time.Now()
+	for i := range proposals {
+		p := &proposals[i]
+		if pending[normalizeProposalTitle(p.Title)] {
+			continue
+		}
+		p.ID = models.NewProposalID(job.ID, i+1)
+		p.ScenarioID = scenario.ID
+		p.JobID = job.ID
+		p.Status = models.ProposalPending
+		p.CreatedAt = now
+		if err := saveProposal(bgCtx, p); err != nil {
+			log.Printf("[GapAnalysis] Failed to save proposal %s: %v", p.ID, err)
+			continue
+		}
+		job.ProposalCount++
+	}
+
+	finish(nil)
+}
+
+func normalizeProposalTitle(title string) string {
+	return strings.Join(strings.Fields(strings.ToLower(title)), " ")
+}
+
+// listProposals loads the scenario's proposals, newest first. An empty status lists all.
+func listProposals(ctx context.Context, scenarioID string, status models.ProposalStatus) []models.TestCaseProposal {
+	key := fmt.Sprintf("proposals:scenario:%s", scenarioID)
+	ids, err := database.RedisClient.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil
+	}
+
+	proposals := []models.TestCaseProposal{}
+	for _, pid := range ids {
+		p, err := getProposal(ctx, pid)
+		if err != nil {
+			database.RedisClient.SRem(ctx, key, pid)
+			continue
+		}
+		if status != "" && p.Status != status {
+			continue
+		}
+		proposals = append(proposals, p)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if !proposals[i].CreatedAt.Equal(proposals[j].CreatedAt) {
+			return proposals[i].CreatedAt.After(proposals[j].CreatedAt)
+		}
+		return proposals[i].ID < proposals[j].ID
+	})
+	return proposals
+}
+
+// GetGapAnalysis returns the latest gap analysis job of a scenario
+func GetGapAnalysis(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	jobID, err := database.RedisClient.Get(ctx, fmt.Sprintf("gap_analysis:scenario:%s", id)).Result()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no gap analysis has been run for this scenario"})
+		return
+	}
+	job, err := getGapAnalysisJob(ctx, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "gap analysis not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// ListProposals returns the review queue of a scenario. Defaults to pending proposals;
+// pass ?status=all for the full history.
+func ListProposals(c *gin.Context) {
+	id := c.Param("id")
+	status := models.ProposalStatus(c.DefaultQuery("status", string(models.ProposalPending)))
+	if status == "all" {
+		status = ""
+	}
+
+	proposals := listProposals(c.Request.Context(), id, status)
+	c.JSON(http.StatusOK, gin.H{"proposals": proposals, "total": len(proposals)})
+}
+
+// AcceptProposal turns a pending proposal into a test case in the chosen section
+func AcceptProposal(c *gin.Context) {
+	id := c.Param("id")
+	proposalID := c.Param("proposalId")
+
+	var req models.AcceptProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil || proposal.ScenarioID != id {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proposal not found"})
+		return
+	}
+	if proposal.Status != models.ProposalPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("proposal is already %s", proposal.Status)})
+		return
+	}
+
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	sectionID := req.SectionID
+	if sectionID == "" {
+		sectionID = proposal.SuggestedSectionID
+	}
+	si := findSectionIndex(&scenario, sectionID)
+	if si < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sectionId is required and must be a section of this scenario"})
+		return
+	}
+
+	if req.Title != nil {
+		proposal.Title = *req.Title
+	}
+	if req.Description != nil {
+		proposal.Description = *req.Description
+	}
+	if req.PreCondition != nil {
+		proposal.PreCondition = *req.PreCondition
+	}
+	if req.Steps != nil {
+		proposal.Steps = *req.Steps
+	}
+	if req.Priority != nil {
+		proposal.Priority = *req.Priority
+	}
+	if req.Tags != nil {
+		proposal.Tags = *req.Tags
+	}
+
+	for k := range proposal.Steps {
+		proposal.Steps[k].Order = k + 1
+		if proposal.Steps[k].ID == "" {
+			proposal.Steps[k].ID = models.NewTestStepID()
+		}
+	}
+
+	now := time.Now()
+	nowStr := now.Format(time.RFC3339)
+	newTC := models.TestCase{
+		ID:           models.NewTestCaseID(),
+		Order:        len(scenario.Sections[si].TestCases) + 1,
+		Code:         fmt.Sprintf("TC-%03d", nextTestCaseNumber(&scenario)),
+		Title:        proposal.Title,
+		Description:  proposal.Description,
+		PreCondition: proposal.PreCondition,
+		Steps:        proposal.Steps,
+		Tags:         proposal.Tags,
+		Priority:     proposal.Priority,
+		Type:         proposal.Type,
+		Status:       models.TCStatusDraft,
+		Note:         proposal.Rationale,
+		CreatedAt:    nowStr,
+		UpdatedAt:    nowStr,
+	}
+	scenario.Sections[si].TestCases = append(scenario.Sections[si].TestCases, newTC)
+
+	authorID := currentAuthorID(c)
+	scenario.UpdatedAt = now
+	scenario.ComputeStats()
+	summary := fmt.Sprintf("Accepted %s proposal as %s", proposal.Category, newTC.Code)
+	if err := saveScenarioVersioned(ctx, &scenario, authorID, models.VersionActionAcceptProposal, summary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	proposal.Status = models.ProposalAccepted
+	proposal.TestCaseID = newTC.ID
+	proposal.SuggestedSectionID = sectionID
+	proposal.ReviewedBy = authorID
+	proposal.ReviewedAt = &now
+	if err := saveProposal(ctx, &proposal); err != nil {
+		log.Printf("[GapAnalysis] Failed to mark proposal %s accepted: %v", proposal.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"proposal": proposal, "testCase": newTC})
+}
+
+// RejectProposal removes a proposal from the review queue
+func RejectProposal(c *gin.Context) {
+	id := c.Param("id")
+	proposalID := c.Param("proposalId")
+
+	var req models.RejectProposalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil || proposal.ScenarioID != id {
+		c.JSON(http.StatusNotFound, gin.H{"error": "proposal not found"})
+		return
+	}
+	if proposal.Status != models.ProposalPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("proposal is already %s", proposal.Status)})
+		return
+	}
+
+	now := time.Now()
+	proposal.Status = models.ProposalRejected
+	proposal.RejectReason = req.Reason
+	proposal.ReviewedBy = currentAuthorID(c)
+	proposal.ReviewedAt = &now
+	if err := saveProposal(ctx, &proposal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, proposal)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"qa-extension-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listParams are the paging, sorting and filtering options shared by the
+// scenario and recording list endpoints
+type listParams struct {
+	Query     database.IndexQuery
+	Page      int
+	Limit     int
+	FieldSort string // non-time field sorted in memory; empty when the index orders results
+	Asc       bool
+}
+
+// parseListParams reads page/limit/cursor, sort_by/order, search and the
+// created_/updated_ since/until range from the query string. fieldSorts lists
+// the sort_by values that are not timestamps; those are sorted after loading.
+func parseListParams(c *gin.Context, fieldSorts ...string) (listParams, error) {
+	p := listParams{Page: 1, Limit: 20}
+
+	if v := c.Query("page"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			p.Page = parsed
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= 100 {
+			p.Limit = parsed
+		}
+	}
+	p.Asc = c.Query("order") == "asc"
+
+	p.Query = database.IndexQuery{
+		Filters: make(map[string][]string),
+		Search:  c.Query("search"),
+		SortBy:  database.SortCreated,
+		Asc:     p.Asc,
+		Cursor:  c.Query("cursor"),
+		Offset:  (p.Page - 1) * p.Limit,
+		Limit:   p.Limit,
+	}
+
+	switch sortBy := c.Query("sort_by"); sortBy {
+	case "", "created_at":
+	case "updated_at":
+		p.Query.SortBy = database.SortUpdated
+	default:
+		for _, f := range fieldSorts {
+			if sortBy == f {
+				p.FieldSort = sortBy
+			}
+		}
+		if p.FieldSort == "" {
+			return p, fmt.Errorf("unsupported sort_by: %s", sortBy)
+		}
+		if p.Query.Cursor != "" {
+			return p, fmt.Errorf("cursor pagination is only supported when sorting by created_at or updated_at")
+		}
+		// Field sorts need every match; the page is cut after sorting
+		p.Query.Offset = 0
+		p.Query.Limit = 0
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"created_since": &p.Query.CreatedSince,
+		"created_until": &p.Query.CreatedUntil,
+		"updated_since": &p.Query.UpdatedSince,
+		"updated_until": &p.Query.UpdatedUntil,
+	} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return p, fmt.Errorf("%s must be an RFC3339 timestamp", param)
+			}
+			*dst = t
+		}
+	}
+	return p, nil
+}
+
+// filter adds a facet filter from a query parameter. Repeated parameters and
+// comma-separated values are ORed.
+func (p *listParams) filter(c *gin.Context, param, facet string) {
+	for _, v := range c.QueryArray(param) {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				p.Query.Filters[facet] = append(p.Query.Filters[facet], part)
+			}
+		}
+	}
+}
+
+// pageBounds returns the slice of a fully loaded, field-sorted result that
+// makes up the requested page
+func (p listParams) pageBounds(total int) (int, int) {
+	start := (p.Page - 1) * p.Limit
+	if start > total {
+		start = total
+	}
+	end := start + p.Limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func (p listParams) pagination(total int64, nextCursor string) gin.H {
+	totalPages := (int(total) + p.Limit - 1) / p.Limit
+	return gin.H{"page": p.Page, "limit": p.Limit, "total": total, "totalPages": totalPages, "nextCursor": nextCursor}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+// defaultPlaywrightDir is where exported specs are committed when no directory is given
+const defaultPlaywrightDir = "tests/e2e"
+
+// CommitPlaywrightExportRequest is the body for committing an exported spec to GitLab
+type CommitPlaywrightExportRequest struct {
+	Branch        string `json:"branch"`
+	Directory     string `json:"directory,omitempty"`
+	FileName      string `json:"fileName,omitempty"`
+	CommitMessage string `json:"commitMessage,omitempty"`
+	ProjectID     string `json:"projectId,omitempty"` // defaults to the scenario/recording project
+	SectionID     string `json:"sectionId,omitempty"`
+	TestCaseID    string `json:"testCaseId,omitempty"`
+}
+
+// ExportRecordingPlaywright handles GET /recordings/:id/export/playwright
+// Query params: branch (knowledge graph branch), download (bool)
+func ExportRecordingPlaywright(c *gin.Context) {
+	ctx := c.Request.Context()
+	recording, err := getRecording(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return
+	}
+
+	exporter := services.NewPlaywrightExporter("", loadExportCatalog(ctx, recording.ProjectID, c.Query("branch")))
+	writePlaywrightExport(c, services.SpecFileName(recording.Name), exporter.ExportRecording(&recording))
+}
+
+// ExportScenarioPlaywright handles GET /test-scenarios/:id/export/playwright
+// Query params: sectionId, testCaseId (narrow the export), branch, download (bool)
+func ExportScenarioPlaywright(c *gin.Context) {
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	fileName, content, err := renderScenarioExport(ctx, &scenario, c.Query("sectionId"), c.Query("testCaseId"), c.Query("branch"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	writePlaywrightExport(c, fileName, content)
+}
+
+// CommitScenarioPlaywright handles POST /test-scenarios/:id/export/playwright/commit
+func CommitScenarioPlaywright(c *gin.Context) {
+	var req CommitPlaywrightExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	fileName, content, err := renderScenarioExport(ctx, &scenario, req.SectionID, req.TestCaseID, req.Branch)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ProjectID == "" {
+		req.ProjectID = scenario.ProjectID
+	}
+	commitPlaywrightExport(c, req, fileName, content, fmt.Sprintf("Export Playwright spec for %q", scenario.Title))
+}
+
+// CommitRecordingPlaywright handles POST /recordings/:id/export/playwright/commit
+func CommitRecordingPlaywright(c *gin.Context) {
+	var req CommitPlaywrightExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	recording, err := getRecording(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return
+	}
+
+	if req.ProjectID == "" {
+		req.ProjectID = recording.ProjectID
+	}
+	exporter := services.NewPlaywrightExporter("", loadExportCatalog(ctx, req.ProjectID, req.Branch))
+	content := exporter.ExportRecording(&recording)
+	commitPlaywrightExport(c, req, services.SpecFileName(recording.Name), content, fmt.Sprintf("Export Playwright spec for %q", recording.Name))
+}
+
+// renderScenarioExport exports the whole scenario, a single section or a single test case
+func renderScenarioExport(ctx context.Context, scenario *models.TestScenario, sectionID, testCaseID, branch string) (string, string, error) {
+	exporter := services.NewPlaywrightExporter(scenario.AuthConfig.BaseURL, loadExportCatalog(ctx, scenario.ProjectID, branch))
+
+	if testCaseID != "" {
+		for si := range scenario.Sections {
+			if sectionID != "" && scenario.Sections[si].ID != sectionID {
+				continue
+			}
+			for ti := range scenario.Sections[si].TestCases {
+				tc := &scenario.Sections[si].TestCases[ti]
+				if tc.ID == testCaseID {
+					return services.SpecFileName(tc.Code + " " + tc.Title), exporter.ExportTestCase(tc), nil
+				}
+			}
+		}
+		return "", "", fmt.Errorf("test case not found")
+	}
+
+	if sectionID != "" {
+		for _, sec := range scenario.Sections {
+			if sec.ID == sectionID {
+				narrowed := *scenario
+				narrowed.Sections = []models.TestSection{sec}
+				return services.SpecFileName(scenario.Title + " " + sec.Title), exporter.ExportScenario(&narrowed), nil
+			}
+		}
+		return "", "", fmt.Errorf("section not found")
+	}
+
+	return services.SpecFileName(scenario.Title), exporter.ExportScenario(scenario), nil
+}
+
+// loadExportCatalog returns the cached knowledge graph for locator preferences.
+// Without a branch, the first cached catalog for the project is used. A missing
+// catalog is not an error: the exporter falls back to recorded selectors.
+func loadExportCatalog(ctx context.Context, projectID, branch string) *services.ModuleCatalog {
+	if projectID == "" {
+		return nil
+	}
+	mapper := services.NewGraphMapper()
+	if branch != "" {
+		catalog, err := mapper.GetCachedCatalog(ctx, projectID, branch)
+		if err == nil && catalog != nil {
+			return catalog
+		}
+	}
+	catalogs, err := mapper.ListCachedCatalogs(ctx, projectID)
+	if err != nil || len(catalogs) == 0 {
+		return nil
+	}
+	return &catalogs[0]
+}
+
+func writePlaywrightExport(c *gin.Context, fileName, content string) {
+	if c.Query("download") == "true" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		c.Data(http.StatusOK, "text/typescript; charset=utf-8", []byte(content))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"fileName": fileName, "content": content})
+}
+
+func commitPlaywrightExport(c *gin.Context, req CommitPlaywrightExportRequest, fileName, content, defaultMessage string) {
+	if req.ProjectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "projectId is required"})
+		return
+	}
+	if strings.TrimSpace(req.Branch) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "branch is required"})
+		return
+	}
+
+	token := c.MustGet("token").(*oauth2.Token)
+	sessionID := c.MustGet("session_id").(string)
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	glClient, err := client.GetClient(c, token, tokenSaver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitLab client: " + err.Error()})
+		return
+	}
+
+	if req.FileName != "" {
+		fileName = req.FileName
+		if !strings.HasSuffix(fileName, ".ts") {
+			fileName += ".spec.ts"
+		}
+	}
+	dir := req.Directory
+	if dir == "" {
+		dir = defaultPlaywrightDir
+	}
+	filePath := path.Join(strings.Trim(dir, "/"), fileName)
+
+	action := "create"
+	if _, err := specsService.GetFile(glClient, req.ProjectID, filePath, req.Branch); err == nil {
+		action = "update"
+	}
+
+	message := req.CommitMessage
+	if message == "" {
+		message = defaultMessage
+	}
+
+	commit, err := specsService.CommitFiles(glClient, req.ProjectID, req.Branch, message, []services.FileAction{
+		{Action: action, FilePath: filePath, Content: content},
+	}, "", "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "path": filePath, "action": action, "commit": commit})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// projectAccessLevel returns a user's GitLab access level on a project,
+// gitlab.NoPermissions when they are not a member. Answers are cached for
+// database.ProjectMembershipCacheTTL; lookup failures are not.
+func projectAccessLevel(ctx context.Context, gl *gitlab.Client, userID int, projectID string) (gitlab.AccessLevelValue, error) {
+	if level, ok := database.GetCachedProjectAccess(ctx, userID, projectID); ok {
+		return gitlab.AccessLevelValue(level), nil
+	}
+	if gl == nil {
+		return gitlab.NoPermissions, errors.New("no GitLab client")
+	}
+	level := gitlab.NoPermissions
+	member, resp, err := gl.ProjectMembers.GetInheritedProjectMember(projectID, int64(userID))
+	switch {
+	case err == nil:
+		level = member.AccessLevel
+	case resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden):
+	default:
+		return gitlab.NoPermissions, err
+	}
+	database.SetCachedProjectAccess(ctx, userID, projectID, int(level))
+	return level, nil
+}
+
+// RequireProjectAccess checks that the caller has at least the given GitLab
+// access level on a project, e.g. gitlab.GuestPermissions for any member. It
+// writes the error response and returns false otherwise. API tokens limited
+// to other projects are refused as well.
+func RequireProjectAccess(c *gin.Context, projectID string, min gitlab.AccessLevelValue) bool {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return false
+	}
+	if !tokenAllowsProject(c, projectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("the API token can't access project %s", projectID)})
+		return false
+	}
+	level, err := projectAccessLevel(c.Request.Context(), backgroundGitLabClient(c), userID, projectID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to check project membership: " + err.Error()})
+		return false
+	}
+	if level < min || level == gitlab.NoPermissions {
+		msg := fmt.Sprintf("you are not a member of project %s", projectID)
+		if level > gitlab.NoPermissions {
+			msg = fmt.Sprintf("this needs %s access to project %s", accessLevelName(min), projectID)
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return false
+	}
+	return true
+}
+
+// accessLevelName names GitLab access levels in error messages
+func accessLevelName(level gitlab.AccessLevelValue) string {
+	switch {
+	case level >= gitlab.OwnerPermissions:
+		return "Owner"
+	case level >= gitlab.MaintainerPermissions:
+		return "Maintainer"
+	case level >= gitlab.DeveloperPermissions:
+		return "Developer"
+	case level >= gitlab.ReporterPermissions:
+		return "Reporter"
+	}
+	return "Guest"
+}
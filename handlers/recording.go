@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"qa-extension-backend/agent"
@@ -10,9 +11,9 @@ import (
 	"qa-extension-backend/database"
 	"qa-extension-backend/identity"
 	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
 	"sort"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -55,10 +56,61 @@ func deleteRecordingByID(ctx context.Context, id string) (bool, error) {
 	if recording.IssueID != "" {
 		database.RedisClient.SRem(ctx, fmt.Sprintf("recordings:issue:%s", recording.IssueID), id)
 	}
+	database.RecordingIndex.Remove(ctx, id)
 
 	return true, nil
 }
 
+// getRecording loads a single recording from Redis
+func getRecording(ctx context.Context, id string) (models.ManualRecording, error) {
+	var recording models.ManualRecording
+	val, err := database.RedisClient.Get(ctx, fmt.Sprintf("recording:%s", id)).Result()
+	if err != nil {
+		return recording, err
+	}
+	err = json.Unmarshal([]byte(val), &recording)
+	return recording, err
+}
+
+// storeRecording saves a recording and adds it to the listing index sets
+func storeRecording(ctx context.Context, recording *models.ManualRecording) error {
+	if _, err := services.RedactRecordingForProject(ctx, recording); err != nil {
+		return fmt.Errorf("failed to redact recording telemetry: %w", err)
+	}
+	if err := database.SealRecordingCredentials(ctx, recording); err != nil {
+		return fmt.Errorf("failed to apply recording credential: %w", err)
+	}
+	recording.UpdatedAt = time.Now()
+	val, err := json.Marshal(recording)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording")
+	}
+
+	if err := database.RedisClient.Set(ctx, fmt.Sprintf("recording:%s", recording.ID), val, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save to redis")
+	}
+
+	// Also add to a set of all recording IDs for easy listing
+	if err := database.RedisClient.SAdd(ctx, "recordings", recording.ID).Err(); err != nil {
+		return fmt.Errorf("failed to index recording")
+	}
+
+	if recording.CreatorID != 0 {
+		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:user:%d", recording.CreatorID), recording.ID)
+	} else {
+		database.RedisClient.SAdd(ctx, "recordings:legacy", recording.ID)
+	}
+
+	if recording.ProjectID != "" {
+		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:project:%s", recording.ProjectID), recording.ID)
+	}
+	if recording.IssueID != "" {
+		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:issue:%s", recording.IssueID), recording.ID)
+	}
+	database.IndexRecording(ctx, recording)
+	return nil
+}
+
 // getProjectName fetches the project name from GitLab API
 func getProjectName(c *gin.Context, projectID string) string {
 	if projectID == "" {
@@ -184,41 +236,11 @@ func SaveRecording(c *gin.Context) {
 	}
 
 	// Save to Redis
-	ctx := context.Background()
-	key := fmt.Sprintf("recording:%s", recording.ID)
-
-	val, err := json.Marshal(recording)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal recording"})
+	if err := storeRecording(context.Background(), &recording); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = database.RedisClient.Set(ctx, key, val, 0).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save to redis"})
-		return
-	}
-
-	// Also add to a set of all recording IDs for easy listing
-	err = database.RedisClient.SAdd(ctx, "recordings", recording.ID).Err()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to index recording"})
-		return
-	}
-
-	if recording.CreatorID != 0 {
-		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:user:%d", recording.CreatorID), recording.ID)
-	} else {
-		database.RedisClient.SAdd(ctx, "recordings:legacy", recording.ID)
-	}
-
-	if recording.ProjectID != "" {
-		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:project:%s", recording.ProjectID), recording.ID)
-	}
-	if recording.IssueID != "" {
-		database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:issue:%s", recording.IssueID), recording.ID)
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message": "recording saved successfully",
 		"id":      recording.ID,
@@ -227,60 +249,44 @@ func SaveRecording(c *gin.Context) {
 
 func ListRecordings(c *gin.Context) {
 	ctx := context.Background()
-	projectID := c.Query("project_id")
-	issueID := c.Query("issue_id")
-	search := c.Query("search")
-	status := c.Query("status")
-	sortBy := c.Query("sort_by") // "created_at", "name", "status"
-	order := c.Query("order")            // "asc", "desc"
-	page := 1
-	limit := 20
-
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
-		}
-	}
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
-	}
 
+	params, err := parseListParams(c, "name", "status")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	userID, _ := identity.GetCurrentUserID(c)
-
-	// Step 1: Get recording IDs from Redis sets
-	var ids []string
-	var err error
-
-	if issueID != "" {
-		ids, err = database.RedisClient.SMembers(ctx, fmt.Sprintf("recordings:issue:%s", issueID)).Result()
-	} else if projectID != "" {
-		ids, err = database.RedisClient.SMembers(ctx, fmt.Sprintf("recordings:project:%s", projectID)).Result()
-	} else if userID != 0 {
-		userKey := fmt.Sprintf("recordings:user:%d", userID)
-		ids, err = database.RedisClient.SUnion(ctx, "recordings:legacy", userKey).Result()
-	} else {
-		ids, err = database.RedisClient.SMembers(ctx, "recordings").Result()
+	if userID != 0 {
+		// Own recordings plus legacy ones without a creator
+		params.Query.Filters[database.FacetOwner] = []string{strconv.Itoa(userID), "0"}
+	}
+	params.filter(c, "project_id", database.FacetProject)
+	params.filter(c, "issue_id", database.FacetIssue)
+	params.filter(c, "status", database.FacetStatus)
+	params.filter(c, "run_status", database.FacetRun)
+
+	// Step 1: Resolve the matching IDs from the secondary indexes
+	result, err := database.RecordingIndex.Query(ctx, params.Query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recordings"})
 		return
 	}
 
-	// Step 2: OPTIMIZATION - Batch fetch recordings with MGet instead of N+1 GETs
-	// This reduces Redis round-trips from N calls to 1 call
-	if len(ids) == 0 {
+	if len(result.IDs) == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"data":       []models.ManualRecording{},
-			"pagination": gin.H{"page": page, "limit": limit, "total": 0, "totalPages": 0},
+			"pagination": params.pagination(result.Total, ""),
 		})
 		return
 	}
 
-	keys := make([]string, len(ids))
-	for i, id := range ids {
+	// Step 2: Batch fetch the recordings with MGet, keeping index order
+	keys := make([]string, len(result.IDs))
+	for i, id := range result.IDs {
 		keys[i] = fmt.Sprintf("recording:%s", id)
 	}
 
@@ -290,109 +296,37 @@ func ListRecordings(c *gin.Context) {
 		return
 	}
 
-	// Step 3: Parse and filter recordings
-	var recordings []models.ManualRecording
-	processedIDs := make(map[string]bool)
-
-	for i, val := range vals {
-		if val == nil {
+	recordings := make([]models.ManualRecording, 0, len(vals))
+	for _, val := range vals {
+		str, ok := val.(string)
+		if !ok {
 			continue
 		}
-
-		id := ids[i]
-		if processedIDs[id] {
-			continue
-		}
-
 		var r models.ManualRecording
-		if err := json.Unmarshal([]byte(val.(string)), &r); err != nil {
+		if err := json.Unmarshal([]byte(str), &r); err != nil {
 			continue
 		}
-
-		// Filter for current user or legacy
-		if userID == 0 || r.CreatorID == 0 || r.CreatorID == userID {
-			recordings = append(recordings, r)
-			processedIDs[id] = true
-		}
-	}
-
-	// Step 4: Apply status filter
-	if status != "" {
-		filtered := make([]models.ManualRecording, 0, len(recordings))
-		for _, r := range recordings {
-			if r.Status == status {
-				filtered = append(filtered, r)
-			}
-		}
-		recordings = filtered
-	}
-
-	// Step 5: Apply search filter
-	if search != "" {
-		searchLower := strings.ToLower(search)
-		filtered := make([]models.ManualRecording, 0, len(recordings))
-		for _, r := range recordings {
-			if strings.Contains(strings.ToLower(r.Name), searchLower) ||
-				strings.Contains(strings.ToLower(r.Description), searchLower) {
-				filtered = append(filtered, r)
-			}
-		}
-		recordings = filtered
+		recordings = append(recordings, r)
 	}
 
-	// Step 6: Sort recordings
-	if sortBy == "" {
-		sortBy = "created_at"
-	}
-	if order == "" {
-		order = "desc"
-	}
-
-	sort.Slice(recordings, func(i, j int) bool {
-		switch sortBy {
-		case "name":
-			if order == "asc" {
-				return recordings[i].Name < recordings[j].Name
-			}
-			return recordings[i].Name > recordings[j].Name
-		case "status":
-			if order == "asc" {
-				return recordings[i].Status < recordings[j].Status
+	// Step 3: Sort by name/status in memory; time sorts come ordered from the index
+	paginatedRecordings := recordings
+	if params.FieldSort != "" {
+		sort.SliceStable(recordings, func(i, j int) bool {
+			a, b := recordings[i].Status, recordings[j].Status
+			if params.FieldSort == "name" {
+				a, b = recordings[i].Name, recordings[j].Name
 			}
-			return recordings[i].Status > recordings[j].Status
-		case "created_at":
-			fallthrough
-		default:
-			if order == "asc" {
-				return recordings[i].CreatedAt.Before(recordings[j].CreatedAt)
+			if params.Asc {
+				return a < b
 			}
-			return recordings[i].CreatedAt.After(recordings[j].CreatedAt)
-		}
-	})
-
-	// Step 7: Paginate
-	total := len(recordings)
-	totalPages := 0
-	if total > 0 {
-		totalPages = (total + limit - 1) / limit
-	}
-	start := (page - 1) * limit
-	end := start + limit
-
-	if start >= total {
-		c.JSON(http.StatusOK, gin.H{
-			"data":       []models.ManualRecording{},
-			"pagination": gin.H{"page": page, "limit": limit, "total": total, "totalPages": totalPages},
+			return a > b
 		})
-		return
-	}
-	if end > total {
-		end = total
+		start, end := params.pageBounds(len(recordings))
+		paginatedRecordings = recordings[start:end]
 	}
 
-	paginatedRecordings := recordings[start:end]
-
-	// Step 8: OPTIMIZATION - Parallel fetch of project details
+	// Step 4: OPTIMIZATION - Parallel fetch of project details
 	// Collect unique project IDs that need fetching
 	projectIDs := make(map[string]int) // projectID -> index in paginatedRecordings
 	for i, r := range paginatedRecordings {
@@ -440,7 +374,7 @@ func ListRecordings(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"data":       paginatedRecordings,
-		"pagination": gin.H{"page": page, "limit": limit, "total": total, "totalPages": totalPages},
+		"pagination": params.pagination(result.Total, result.NextCursor),
 	})
 }
 
@@ -527,6 +461,7 @@ func UpdateRecording(c *gin.Context) {
 	}
 
 	// Save back
+	existing.UpdatedAt = time.Now()
 	newVal, err := json.Marshal(existing)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal updated recording"})
@@ -556,6 +491,7 @@ func UpdateRecording(c *gin.Context) {
 			database.RedisClient.SAdd(ctx, fmt.Sprintf("recordings:issue:%s", existing.IssueID), existing.ID)
 		}
 	}
+	database.IndexRecording(ctx, &existing)
 
 	c.JSON(http.StatusOK, existing)
 }
@@ -598,6 +534,7 @@ func DeleteRecording(c *gin.Context) {
 	if recording.IssueID != "" {
 		database.RedisClient.SRem(ctx, fmt.Sprintf("recordings:issue:%s", recording.IssueID), id)
 	}
+	database.RecordingIndex.Remove(ctx, id)
 
 	c.JSON(http.StatusOK, gin.H{"message": "recording deleted successfully", "id": id})
 }
@@ -682,6 +619,7 @@ func BulkDeleteRecordings(c *gin.Context) {
 		if recording.IssueID != "" {
 			database.RedisClient.SRem(ctx, fmt.Sprintf("recordings:issue:%s", recording.IssueID), id)
 		}
+		database.RecordingIndex.Remove(ctx, id)
 
 		deletedCount++
 	}
@@ -703,7 +641,8 @@ func RunRecording(c *gin.Context) {
 	}
 
 	var req struct {
-		Overrides []map[string]any `json:"overrides,omitempty"`
+		Overrides     []map[string]any `json:"overrides,omitempty"`
+		EnvironmentID string           `json:"environmentId,omitempty"`
 	}
 	// Optional body - ignore errors as body may be empty
 	c.ShouldBindJSON(&req)
@@ -723,24 +662,43 @@ func RunRecording(c *gin.Context) {
 		return
 	}
 
+	run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps, ProjectID: recording.ProjectID}
+	env, err := resolveRunEnvironment(ctx, recording.ProjectID, req.EnvironmentID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if env != nil {
+		if err := services.ApplyEnvironment(run, models.AuthConfig{CredentialID: recording.CredentialID}, env); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("cannot run against %s: %v", env.Name, err)})
+			return
+		}
+	}
+
 	// Publish start event and execute in goroutine
-	events := agent.NewExecutionEmitter(ctx, id)
+	owner := database.StreamOwner{UserID: currentAuthorID(c), ProjectID: recording.ProjectID}
+	events := agent.NewExecutionEmitter(database.WithStreamOwner(ctx, owner), id)
 	events.Start("Starting recording '%s' (%d steps)...", recording.Name, len(recording.Steps))
 
 	// Execute in goroutine to not block HTTP
 	go func() {
-		bgCtx := context.Background()
-		run := &models.TestRun{ID: recording.ID, Name: recording.Name, Steps: recording.Steps}
-		result, err := agent.RunTest(bgCtx, run)
+		bgCtx := database.WithStreamOwner(context.Background(), owner)
+		result, err := services.RunWithParameters(bgCtx, run, recording.Parameters, agent.RunTest)
 		if err != nil {
 			events.Error(fmt.Sprintf("Recording '%s' failed: %v", recording.Name, err))
 		} else {
+			_ = database.SaveTestResult(bgCtx, result)
+			database.IndexRecording(bgCtx, &recording)
 			events.Done("Recording '%s' completed: %s", recording.Name, result.Status)
 		}
 	}()
 
-	c.JSON(http.StatusAccepted, gin.H{
+	resp := gin.H{
 		"message": "execution started",
 		"id":      id,
-	})
+	}
+	if env != nil {
+		resp["environment"] = env.Ref()
+	}
+	c.JSON(http.StatusAccepted, resp)
 }
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ConvertRecordingToTestCase documents a manual recording as a test case in a
+// scenario section and attaches the recording as its automation
+func ConvertRecordingToTestCase(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.RecordingToTestCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	recording, err := getRecording(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return
+	}
+	if len(recording.Steps) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "recording has no steps"})
+		return
+	}
+
+	scenario, err := getScenario(ctx, req.ScenarioID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+	si := findSectionIndex(&scenario, req.SectionID)
+	if si < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+	if recording.TestCaseID != "" && hasTestCaseID(&scenario, recording.TestCaseID) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("recording is already attached to test case %s", recording.TestCaseID)})
+		return
+	}
+
+	generatedBy := "rules"
+	draft := services.DescribeRecording(&recording)
+	if !req.SkipAI {
+		if generated, err := services.GenerateTestCaseFromRecording(ctx, &recording); err != nil {
+			log.Printf("[RecordingConversion] Falling back to rule-based steps for %s: %v", id, err)
+		} else {
+			draft = generated
+			generatedBy = "ai"
+		}
+	}
+
+	if req.Title != "" {
+		draft.Title = req.Title
+	}
+	priority := req.Priority
+	if priority == "" {
+		priority = models.PriorityMedium
+	}
+	tags := req.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	now := time.Now()
+	nowStr := now.Format(time.RFC3339)
+	authorID := currentAuthorID(c)
+	newTC := models.TestCase{
+		ID:           models.NewTestCaseID(),
+		Order:        len(scenario.Sections[si].TestCases) + 1,
+		Code:         fmt.Sprintf("TC-%03d", nextTestCaseNumber(&scenario)),
+		Title:        draft.Title,
+		Description:  draft.Description,
+		PreCondition: draft.PreCondition,
+		Steps:        draft.Steps,
+		Tags:         tags,
+		Priority:     priority,
+		Type:         "positive",
+		Status:       models.TCStatusDraft,
+		CreatedAt:    nowStr,
+		UpdatedAt:    nowStr,
+	}
+	if len(recording.Parameters) > 0 {
+		newTC.Parameters = recording.Parameters
+	}
+	newTC.AutomationTest = &models.AutomationTest{
+		ID:         fmt.Sprintf("auto-%s", newTC.ID),
+		Name:       recording.Name,
+		Status:     models.AutomationStatusIdle,
+		Steps:      append([]models.RecordingStep(nil), recording.Steps...),
+		Parameters: newTC.Parameters,
+		VideoURL:   recording.VideoURL,
+	}
+	if iid, err := strconv.ParseInt(recording.IssueID, 10, 64); err == nil && recording.ProjectID != "" {
+		newTC.Issues = []models.IssueLink{{
+			Type:      models.IssueLinkIssue,
+			ProjectID: recording.ProjectID,
+			IID:       iid,
+			LinkedBy:  authorID,
+			LinkedAt:  now,
+		}}
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"testCase": newTC, "generatedBy": generatedBy})
+		return
+	}
+
+	scenario.Sections[si].TestCases = append(scenario.Sections[si].TestCases, newTC)
+	scenario.UpdatedAt = now
+	scenario.ComputeStats()
+	summary := fmt.Sprintf("Added %s from recording '%s'", newTC.Code, recording.Name)
+	if err := saveScenarioVersioned(ctx, &scenario, authorID, models.VersionActionFromRecording, summary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	indexTestCaseLinks(ctx, scenario.ID, newTC)
+
+	recording.TestCaseID = newTC.ID
+	if err := storeRecording(ctx, &recording); err != nil {
+		log.Printf("[RecordingConversion] Failed to link recording %s to %s: %v", id, newTC.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"scenarioId":  scenario.ID,
+		"sectionId":   req.SectionID,
+		"testCase":    newTC,
+		"generatedBy": generatedBy,
+	})
+}
+
+// CreateRecordingFromAutomation creates an editable manual recording from a
+// test case's automation steps
+func CreateRecordingFromAutomation(c *gin.Context) {
+	id := c.Param("id")
+	sectionID := c.Param("sectionId")
+	tcID := c.Param("tcId")
+
+	var req models.AutomationToRecordingRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+	si := findSectionIndex(&scenario, sectionID)
+	if si < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+
+	var tc *models.TestCase
+	for i := range scenario.Sections[si].TestCases {
+		if scenario.Sections[si].TestCases[i].ID == tcID {
+			tc = &scenario.Sections[si].TestCases[i]
+			break
+		}
+	}
+	if tc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test case not found"})
+		return
+	}
+	if tc.AutomationTest == nil || len(tc.AutomationTest.Steps) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "test case has no automation steps"})
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = tc.AutomationTest.Name
+	}
+	if name == "" {
+		name = tc.Title
+	}
+	description := req.Description
+	if description == "" {
+		description = fmt.Sprintf("Created from %s %s", tc.Code, tc.Title)
+	}
+	issueID := req.IssueID
+	if issueID == "" {
+		for _, l := range tc.Issues {
+			if l.Type == models.IssueLinkIssue && l.ProjectID == scenario.ProjectID {
+				issueID = strconv.FormatInt(l.IID, 10)
+				break
+			}
+		}
+	}
+
+	recording := models.ManualRecording{
+		ID:          uuid.NewString(),
+		Name:        name,
+		Description: description,
+		Status:      "draft",
+		ProjectID:   scenario.ProjectID,
+		ProjectName: scenario.ProjectName,
+		IssueID:     issueID,
+		TestCaseID:  tc.ID,
+		VideoURL:    tc.AutomationTest.VideoURL,
+		Steps:       append([]models.RecordingStep(nil), tc.AutomationTest.Steps...),
+		Parameters:  make([]any, 0),
+		CreatedAt:   time.Now(),
+	}
+	if len(tc.Parameters) > 0 {
+		recording.Parameters = tc.Parameters
+	}
+	if userID, err := identity.GetCurrentUserID(c); err == nil {
+		recording.CreatorID = userID
+	}
+
+	if err := storeRecording(ctx, &recording); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"recording": recording})
+}
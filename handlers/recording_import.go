@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImportRecording handles POST /recordings/import
+// Multipart form: file (codegen .js/.ts or .har), format ("codegen" | "har", inferred
+// from the extension when omitted), name, description, projectId, issueId,
+// harMode ("telemetry" | "steps"), dryRun (bool)
+func ImportRecording(c *gin.Context) {
+	err := c.Request.ParseMultipartForm(20 << 20) // HAR files can be large
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse multipart form"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+
+	format := strings.ToLower(c.Request.FormValue("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".har":
+			format = "har"
+		case ".js", ".ts", ".mjs", ".cjs":
+			format = "codegen"
+		case ".json":
+			format = "har"
+		}
+	}
+
+	var imported *services.RecordingImport
+	switch format {
+	case "codegen":
+		imported = services.ImportPlaywrightCodegen(string(data))
+	case "har":
+		imported, err = services.ImportHAR(data, c.Request.FormValue("harMode") == "steps")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, expected codegen (.js/.ts) or har"})
+		return
+	}
+
+	if len(imported.Steps) == 0 && len(imported.NetworkRequests) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":    "nothing could be imported from this file",
+			"warnings": imported.Warnings,
+		})
+		return
+	}
+
+	name := c.Request.FormValue("name")
+	if name == "" {
+		name = strings.TrimSuffix(header.Filename, filepath.Ext(header.Filename))
+	}
+
+	recording := models.ManualRecording{
+		ID:          uuid.NewString(),
+		Name:        name,
+		Description: c.Request.FormValue("description"),
+		Status:      "draft",
+		ProjectID:   c.Request.FormValue("projectId"),
+		IssueID:     c.Request.FormValue("issueId"),
+		Steps:       imported.Steps,
+		Parameters:  make([]any, 0),
+		CreatedAt:   time.Now(),
+	}
+	if recording.Description == "" {
+		recording.Description = fmt.Sprintf("Imported from %s", header.Filename)
+	}
+
+	if len(imported.NetworkRequests) > 0 || imported.StartURL != "" {
+		recording.Telemetry = &models.SessionTelemetry{
+			RecordingID:     recording.ID,
+			StartUrl:        imported.StartURL,
+			NetworkRequests: imported.NetworkRequests,
+		}
+		if n := len(imported.NetworkRequests); n > 0 {
+			recording.Telemetry.StartTime = imported.NetworkRequests[0].Timestamp
+			recording.Telemetry.EndTime = imported.NetworkRequests[n-1].Timestamp
+		}
+	}
+
+	if c.Request.FormValue("dryRun") == "true" {
+		c.JSON(http.StatusOK, gin.H{"recording": recording, "warnings": imported.Warnings})
+		return
+	}
+
+	if userID, err := identity.GetCurrentUserID(c); err == nil {
+		recording.CreatorID = userID
+	}
+	if recording.ProjectID != "" {
+		recording.ProjectName = getProjectName(c, recording.ProjectID)
+	}
+
+	if err := storeRecording(context.Background(), &recording); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "recording imported successfully",
+		"id":              recording.ID,
+		"format":          format,
+		"stepCount":       len(recording.Steps),
+		"networkRequests": len(imported.NetworkRequests),
+		"warnings":        imported.Warnings,
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// GetRedactionPolicy returns the redaction policy applied to the project's
+// recordings, which is the default policy until the project saves its own
+func GetRedactionPolicy(c *gin.Context) {
+	projectID := c.Param("id")
+	if !RequireProjectAccess(c, projectID, gitlab.GuestPermissions) {
+		return
+	}
+
+	policy, custom, err := database.GetRedactionPolicy(c.Request.Context(), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load redaction policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "custom": custom})
+}
+
+// UpdateRedactionPolicy replaces the project's redaction policy. It applies to
+// recordings saved from now on; stored ones are scrubbed by the backfill command.
+// Only project maintainers can change it.
+func UpdateRedactionPolicy(c *gin.Context) {
+	projectID := c.Param("id")
+	if !RequireProjectAccess(c, projectID, gitlab.MaintainerPermissions) {
+		return
+	}
+
+	var policy models.RedactionPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := services.NewRedactor(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy.ProjectID = projectID
+	policy.UpdatedBy = currentAuthorID(c)
+	policy.UpdatedAt = time.Now()
+	if err := database.SaveRedactionPolicy(c.Request.Context(), &policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save redaction policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "custom": true})
+}
+
+// ResetRedactionPolicy removes the project's policy so the default applies.
+// Only project maintainers can reset it.
+func ResetRedactionPolicy(c *gin.Context) {
+	projectID := c.Param("id")
+	if !RequireProjectAccess(c, projectID, gitlab.MaintainerPermissions) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := database.DeleteRedactionPolicy(ctx, projectID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset redaction policy"})
+		return
+	}
+	policy, _, _ := database.GetRedactionPolicy(ctx, projectID)
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "custom": false})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+var specsService = services.NewSpecsService()
+
+// maxGherkinFiles caps how many .feature files a single repo import may pull
+const maxGherkinFiles = 200
+
+// ImportGherkinScenario handles uploading one or more .feature files and
+// converting them into a single TestScenario
+func ImportGherkinScenario(c *gin.Context) {
+	err := c.Request.ParseMultipartForm(10 << 20) // 10 MB limit
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse multipart form"})
+		return
+	}
+
+	files := c.Request.MultipartForm.File["files"]
+	files = append(files, c.Request.MultipartForm.File["file"]...)
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one .feature file is required"})
+		return
+	}
+
+	projectID := c.Request.FormValue("projectId")
+
+	var authConfig models.AuthConfig
+	authConfigStr := c.Request.FormValue("authConfig")
+	if authConfigStr != "" {
+		if err := json.Unmarshal([]byte(authConfigStr), &authConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authConfig format"})
+			return
+		}
+	}
+
+	var features []models.GherkinFeature
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to open %s", fh.Filename)})
+			return
+		}
+		feature, err := services.ParseGherkin(f)
+		f.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to parse %s: %v", fh.Filename, err)})
+			return
+		}
+		feature.SourcePath = fh.Filename
+		features = append(features, *feature)
+	}
+
+	title := c.Request.FormValue("title")
+	if title == "" {
+		title = files[0].Filename
+		if len(files) > 1 {
+			title = features[0].Name
+		}
+	}
+
+	saveGherkinScenario(c, title, features, projectID, getProjectName(c, projectID), authConfig)
+}
+
+// ImportGherkinFromRepoRequest describes a .feature file or folder in a GitLab repository
+type ImportGherkinFromRepoRequest struct {
+	ProjectID  string            `json:"projectId"`
+	Path       string            `json:"path"`
+	Ref        string            `json:"ref,omitempty"`
+	Title      string            `json:"title,omitempty"`
+	AuthConfig models.AuthConfig `json:"authConfig"`
+}
+
+// ImportGherkinFromRepo imports a single .feature file, or every .feature file
+// under a folder, from the project repository
+func ImportGherkinFromRepo(c *gin.Context) {
+	var req ImportGherkinFromRepoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.ProjectID == "" || req.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "projectId and path are required"})
+		return
+	}
+
+	token := c.MustGet("token").(*oauth2.Token)
+	sessionID := c.MustGet("session_id").(string)
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	glClient, err := client.GetClient(c, token, tokenSaver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitLab client: " + err.Error()})
+		return
+	}
+
+	repoPath := strings.Trim(req.Path, "/")
+	var paths []string
+	if strings.HasSuffix(strings.ToLower(repoPath), ".feature") {
+		paths = []string{repoPath}
+	} else {
+		tree, err := specsService.GetFileTree(glClient, req.ProjectID, repoPath, req.Ref, true)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		paths = collectFeaturePaths(tree)
+	}
+
+	if len(paths) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("no .feature files found under %s", repoPath)})
+		return
+	}
+	if len(paths) > maxGherkinFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many .feature files (%d), maximum is %d", len(paths), maxGherkinFiles)})
+		return
+	}
+
+	var features []models.GherkinFeature
+	for _, p := range paths {
+		file, err := specsService.GetFile(glClient, req.ProjectID, p, req.Ref)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("failed to read %s: %v", p, err)})
+			return
+		}
+		feature, err := services.ParseGherkin(strings.NewReader(file.Content))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to parse %s: %v", p, err)})
+			return
+		}
+		feature.SourcePath = p
+		features = append(features, *feature)
+	}
+
+	title := req.Title
+	if title == "" {
+		title = path.Base(repoPath)
+	}
+
+	saveGherkinScenario(c, title, features, req.ProjectID, getProjectName(c, req.ProjectID), req.AuthConfig)
+}
+
+func saveGherkinScenario(c *gin.Context, title string, features []models.GherkinFeature, projectID, projectName string, authConfig models.AuthConfig) {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		userID = 0
+	}
+
+	scenario := services.BuildScenarioFromGherkin(title, features, projectID, projectName, authConfig, userID)
+	scenario.ID = uuid.NewString()
+
+	if err := createScenario(c.Request.Context(), &scenario); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save to redis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "feature files imported successfully",
+		"id":        scenario.ID,
+		"features":  len(features),
+		"sections":  len(scenario.Sections),
+		"testCases": scenario.Stats.TotalTestCases,
+	})
+}
+
+// collectFeaturePaths walks a file tree and returns every .feature blob path
+func collectFeaturePaths(nodes []*services.FileTreeNode) []string {
+	var paths []string
+	for _, n := range nodes {
+		if n.Type == "tree" {
+			paths = append(paths, collectFeaturePaths(n.Children)...)
+			continue
+		}
+		if strings.HasSuffix(strings.ToLower(n.Path), ".feature") {
+			paths = append(paths, n.Path)
+		}
+	}
+	return paths
+}
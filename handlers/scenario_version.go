@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"qa-extension-backend/agent"
+	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxScenarioVersions is how many snapshots are kept per scenario; older ones are pruned
+const maxScenarioVersions = 100
+
+func init() {
+	// Scenario changes made by agent tools are recorded in version history too
+	agent.SaveScenarioVersioned = saveScenarioVersioned
+}
+
+func scenarioVersionsKey(scenarioID string) string {
+	return fmt.Sprintf("scenario_versions:%s", scenarioID)
+}
+
+func scenarioVersionKey(scenarioID string, version int) string {
+	return fmt.Sprintf("scenario_version:%s:%d", scenarioID, version)
+}
+
+func versionAuthorName(authorID int) string {
+	if authorID == 0 {
+		return "System"
+	}
+	return fmt.Sprintf("User %d", authorID)
+}
+
+// currentAuthorID returns the GitLab user making the request, or 0 if unknown
+func currentAuthorID(c *gin.Context) int {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		return 0
+	}
+	return userID
+}
+
+// saveScenarioVersioned persists the scenario and records the new state as a version.
+// Scenarios created before history existed get their stored state snapshotted as a
+// baseline first, so the very first tracked change can still be reverted.
+func saveScenarioVersioned(ctx context.Context, scenario *models.TestScenario, authorID int, action, summary string) error {
+	count, err := database.RedisClient.ZCard(ctx, scenarioVersionsKey(scenario.ID)).Result()
+	if err == nil && count == 0 {
+		if previous, err := getScenario(ctx, scenario.ID); err == nil {
+			if err := recordScenarioVersion(ctx, &previous, previous.CreatorID, models.VersionActionBaseline, "State before version history was enabled"); err != nil {
+				log.Printf("[ScenarioVersion] failed to record baseline for %s: %v", scenario.ID, err)
+			}
+		}
+	}
+
+	if err := saveScenario(ctx, scenario); err != nil {
+		return err
+	}
+
+	if err := recordScenarioVersion(ctx, scenario, authorID, action, summary); err != nil {
+		log.Printf("[ScenarioVersion] failed to record version for %s: %v", scenario.ID, err)
+	}
+	return nil
+}
+
+// recordScenarioVersion appends a snapshot to the scenario's history
+func recordScenarioVersion(ctx context.Context, scenario *models.TestScenario, authorID int, action, summary string) error {
+	seq, err := database.RedisClient.Incr(ctx, fmt.Sprintf("scenario_version_seq:%s", scenario.ID)).Result()
+	if err != nil {
+		return err
+	}
+
+	snapshot := *scenario
+	snapshot.ComputeStats()
+	// Snapshots never keep plain credentials, even of scenarios saved before the credential store
+	snapshot.AuthConfig.Username, snapshot.AuthConfig.Password = "", ""
+
+	version := models.ScenarioVersion{
+		Version:    int(seq),
+		ScenarioID: scenario.ID,
+		Action:     action,
+		Summary:    summary,
+		AuthorID:   authorID,
+		Author:     versionAuthorName(authorID),
+		CreatedAt:  time.Now(),
+		Stats:      snapshot.Stats,
+		Snapshot:   &snapshot,
+	}
+
+	val, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, scenarioVersionKey(scenario.ID, version.Version), val, 0)
+	pipe.ZAdd(ctx, scenarioVersionsKey(scenario.ID), redis.Z{Score: float64(version.Version), Member: version.Version})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	pruneScenarioVersions(ctx, scenario.ID)
+	return nil
+}
+
+func pruneScenarioVersions(ctx context.Context, scenarioID string) {
+	key := scenarioVersionsKey(scenarioID)
+	stale, err := database.RedisClient.ZRange(ctx, key, 0, -maxScenarioVersions-1).Result()
+	if err != nil || len(stale) == 0 {
+		return
+	}
+	for _, v := range stale {
+		n, _ := strconv.Atoi(v)
+		database.RedisClient.Del(ctx, scenarioVersionKey(scenarioID, n))
+	}
+	database.RedisClient.ZRemRangeByRank(ctx, key, 0, int64(-maxScenarioVersions-1))
+}
+
+func getScenarioVersion(ctx context.Context, scenarioID string, version int) (models.ScenarioVersion, error) {
+	var v models.ScenarioVersion
+	val, err := database.RedisClient.Get(ctx, scenarioVersionKey(scenarioID, version)).Result()
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal([]byte(val), &v)
+	return v, err
+}
+
+// deleteScenarioVersions removes the whole history of a scenario
+func deleteScenarioVersions(ctx context.Context, scenarioID string) {
+	versions, _ := database.RedisClient.ZRange(ctx, scenarioVersionsKey(scenarioID), 0, -1).Result()
+	for _, v := range versions {
+		n, _ := strconv.Atoi(v)
+		database.RedisClient.Del(ctx, scenarioVersionKey(scenarioID, n))
+	}
+	database.RedisClient.Del(ctx, scenarioVersionsKey(scenarioID), fmt.Sprintf("scenario_version_seq:%s", scenarioID))
+}
+
+// ListScenarioVersions handles GET /test-scenarios/:id/versions
+// Query params: limit (default 50)
+func ListScenarioVersions(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	if _, err := getScenario(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= maxScenarioVersions {
+			limit = parsed
+		}
+	}
+
+	members, err := database.RedisClient.ZRevRange(ctx, scenarioVersionsKey(id), 0, int64(limit-1)).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list versions"})
+		return
+	}
+
+	versions := make([]models.ScenarioVersion, 0, len(members))
+	for _, m := range members {
+		n, _ := strconv.Atoi(m)
+		v, err := getScenarioVersion(ctx, id, n)
+		if err != nil {
+			continue
+		}
+		v.Snapshot = nil
+		versions = append(versions, v)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// GetScenarioVersion handles GET /test-scenarios/:id/versions/:version
+func GetScenarioVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+		return
+	}
+
+	v, err := getScenarioVersion(c.Request.Context(), c.Param("id"), version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+	if v.Snapshot != nil {
+		v.Snapshot.Sheets = nil
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+// DiffScenarioVersions handles GET /test-scenarios/:id/versions/diff?from=<n>&to=<n|current>
+// When "to" is omitted the current scenario is used.
+func DiffScenarioVersions(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	fromVersion, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a version number"})
+		return
+	}
+	from, err := getScenarioVersion(ctx, id, fromVersion)
+	if err != nil || from.Snapshot == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("version %d not found", fromVersion)})
+		return
+	}
+
+	var to *models.TestScenario
+	toVersion := 0
+	if toParam := c.Query("to"); toParam != "" && toParam != "current" {
+		toVersion, err = strconv.Atoi(toParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a version number or 'current'"})
+			return
+		}
+		v, err := getScenarioVersion(ctx, id, toVersion)
+		if err != nil || v.Snapshot == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("version %d not found", toVersion)})
+			return
+		}
+		to = v.Snapshot
+	} else {
+		current, err := getScenario(ctx, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+			return
+		}
+		to = &current
+	}
+
+	diff := services.DiffScenarios(from.Snapshot, to)
+	diff.From = fromVersion
+	diff.To = toVersion // 0 means the current state
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreScenarioVersion handles POST /test-scenarios/:id/versions/:version/restore
+// The restore itself is recorded as a new version, so it can be undone too.
+func RestoreScenarioVersion(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+		return
+	}
+
+	current, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+	if current.Status == models.ScenarioStatusGenerating {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot restore while generation is running"})
+		return
+	}
+
+	v, err := getScenarioVersion(ctx, id, version)
+	if err != nil || v.Snapshot == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "version not found"})
+		return
+	}
+
+	restored := *v.Snapshot
+	restored.ID = current.ID
+	restored.CreatorID = current.CreatorID
+	restored.CreatedAt = current.CreatedAt
+	restored.UpdatedAt = time.Now()
+	restored.ComputeStats()
+
+	if err := saveScenarioVersioned(ctx, &restored, currentAuthorID(c), models.VersionActionRestore, fmt.Sprintf("Restored version %d", version)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save scenario"})
+		return
+	}
+	reindexScenarioLinks(ctx, &current, &restored)
+
+	restored.Sheets = nil
+	c.JSON(http.StatusOK, restored)
+}
@@ -1,52 +1,200 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
 
 	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
+// streamPollInterval is how long one read of the event log blocks; a keep-alive
+// comment is sent after each empty read so proxies don't drop the connection
+const streamPollInterval = 15 * time.Second
+
+// StreamAccess decides which events a connected user may receive: their own,
+// and those of GitLab projects they are a member of
+type StreamAccess struct {
+	ctx     context.Context
+	userID  int
+	gitlab  *gitlab.Client
+	members map[string]bool
+	// projects limits an API token's events to its projects
+	projects []string
+}
+
+func NewStreamAccess(c *gin.Context) (*StreamAccess, error) {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamAccess{
+		ctx:      c.Request.Context(),
+		userID:   userID,
+		gitlab:   backgroundGitLabClient(c),
+		members:  make(map[string]bool),
+		projects: apiTokenProjectIDs(c),
+	}, nil
+}
+
+// UserID is the GitLab user the access was resolved for
+func (a *StreamAccess) UserID() int {
+	return a.userID
+}
+
+// Allows reports whether the user may receive an event
+func (a *StreamAccess) Allows(ev database.StreamEvent) bool {
+	if len(a.projects) > 0 && !slices.Contains(a.projects, ev.ProjectID) {
+		return false
+	}
+	if ev.UserID != 0 && ev.UserID == a.userID {
+		return true
+	}
+	return ev.ProjectID != "" && a.isMember(ev.ProjectID)
+}
+
+func (a *StreamAccess) isMember(projectID string) bool {
+	if member, ok := a.members[projectID]; ok {
+		return member
+	}
+	level, err := projectAccessLevel(a.ctx, a.gitlab, a.userID, projectID)
+	if err != nil {
+		// Don't remember transient failures
+		log.Printf("[Stream] Failed to check membership of user %d in project %s: %v", a.userID, projectID, err)
+		return false
+	}
+	member := level > gitlab.NoPermissions
+	a.members[projectID] = member
+	return member
+}
+
+// setStreamCORSHeaders allows credentialed requests from the origins listed in
+// STREAM_ALLOWED_ORIGINS (comma-separated), or from browser extensions when
+// it is unset. The session cookie is what authorises the stream, so arbitrary
+// origins must not be echoed back.
+func setStreamCORSHeaders(c *gin.Context) {
+	c.Header("Vary", "Origin")
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return
+	}
+
+	if !StreamOriginAllowed(origin) {
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", origin)
+	c.Header("Access-Control-Allow-Credentials", "true")
+	c.Header("Access-Control-Expose-Headers", "*")
+}
+
+// StreamOriginAllowed reports whether a browser origin may open the event
+// stream or agent WebSocket with the user's session
+func StreamOriginAllowed(origin string) bool {
+	if list := os.Getenv("STREAM_ALLOWED_ORIGINS"); list != "" {
+		for _, o := range strings.Split(list, ",") {
+			if strings.TrimSpace(o) == origin {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.HasPrefix(origin, "chrome-extension://") || strings.HasPrefix(origin, "moz-extension://")
+}
+
 // StreamEvents SSE endpoint — single unified stream for all long-running operations.
-// Frontend subscribes here and filters events client-side by type/resourceId.
+// The caller is authenticated by the session cookie or X-Session-ID header and
+// only receives events they own or that belong to their GitLab projects.
+// Every event carries an SSE id, and the stream stays open until the client
+// disconnects, across any number of resources finishing.
 //
 // Query params (all optional):
-//   - resourceId: only receive events for a specific resource (e.g. scenario-123, rec-456)
+//   - resourceId: only receive events for these resources (comma-separated,
+//     e.g. scenario-123,rec-456). Their logged history is replayed first, so
+//     events published before the client connected are not lost.
 //   - type: only receive events of a specific type (e.g. "generation", "execution")
+//   - lastEventId: resume after this event; the Last-Event-ID header sent by
+//     EventSource on reconnect takes precedence
 func StreamEvents(c *gin.Context) {
 	// Handle preflight CORS requests
+	setStreamCORSHeaders(c)
 	if c.Request.Method == "OPTIONS" {
-		c.Header("Vary", "Origin")
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, X-Session-ID")
 		c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
 		c.Header("Access-Control-Max-Age", "86400")
 		c.AbortWithStatus(204)
 		return
 	}
 
-	// Set SSE headers
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering if behind proxy
-	c.Header("Vary", "Origin")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Expose-Headers", "*")
+	access, err := NewStreamAccess(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: could not identify user"})
+		return
+	}
 
 	// Optional filters from query params
-	filterResourceID := c.Query("resourceId")
+	filterResourceIDs := make(map[string]bool)
+	for _, id := range strings.Split(c.DefaultQuery("resourceId", c.Param("id")), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			filterResourceIDs[id] = true
+		}
+	}
 	filterType := c.Query("type")
 
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+	if lastEventID != "" && !database.ValidStreamEventID(lastEventID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Last-Event-ID"})
+		return
+	}
+
 	ctx := c.Request.Context()
 
-	// Subscribe to the unified Redis channel
-	sub := database.SubscribeAllStreamEvents(ctx)
-	defer sub.Close()
+	// Remember where the log ends before replaying, so nothing appended while
+	// replaying is missed
+	tip, err := database.LatestStreamEventID(ctx)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "event stream unavailable"})
+		return
+	}
 
-	ch := sub.Channel()
+	// Set SSE headers
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering if behind proxy
+
+	matches := func(ev database.StreamEvent) bool {
+		if len(filterResourceIDs) > 0 && !filterResourceIDs[ev.ResourceID] {
+			return false
+		}
+		if filterType != "" && ev.Type != filterType {
+			return false
+		}
+		return access.Allows(ev)
+	}
+	lastSent := lastEventID
+	send := func(ev database.StreamEvent) {
+		eventJSON, err := json.Marshal(ev)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(c.Writer, "id: %s\ndata: %s\n\n", ev.ID, eventJSON)
+		c.Writer.Flush()
+		lastSent = ev.ID
+	}
 
 	// Send initial connected event so the frontend knows the stream is alive
 	connectedEvent := map[string]string{
@@ -54,56 +202,130 @@ func StreamEvents(c *gin.Context) {
 		"stage":   "connected",
 		"message": "Connected to unified event stream",
 	}
-	if filterResourceID != "" {
-		connectedEvent["filteredResourceId"] = filterResourceID
+	if len(filterResourceIDs) > 0 {
+		connectedEvent["filteredResourceId"] = strings.Join(sortedKeys(filterResourceIDs), ",")
 	}
 	if filterType != "" {
 		connectedEvent["filteredType"] = filterType
 	}
+	if lastEventID != "" {
+		connectedEvent["resumedAfter"] = lastEventID
+	}
 	connectedJSON, _ := json.Marshal(connectedEvent)
-	fmt.Fprintf(c.Writer, "data: %s\n\n", string(connectedJSON))
+	fmt.Fprintf(c.Writer, "retry: 3000\ndata: %s\n\n", string(connectedJSON))
 	c.Writer.Flush()
 
-	// Stream events as they arrive
-	for {
-		select {
-		case msg := <-ch:
-			eventJSON := msg.Payload
-
-			// Parse event to check filters
-			var ev database.StreamEvent
-			if err := json.Unmarshal([]byte(eventJSON), &ev); err != nil {
-				// Invalid JSON, skip
+	// Replay the history of the watched resources
+	if len(filterResourceIDs) > 0 {
+		var history []database.StreamEvent
+		for id := range filterResourceIDs {
+			events, err := database.ResourceStreamEvents(ctx, id, lastEventID)
+			if err != nil {
+				log.Printf("[Stream] Failed to replay events for %s: %v", id, err)
 				continue
 			}
+			history = append(history, events...)
+		}
+		sort.Slice(history, func(i, j int) bool {
+			return database.CompareStreamEventIDs(history[i].ID, history[j].ID) < 0
+		})
+		for _, ev := range history {
+			if matches(ev) {
+				send(ev)
+			}
+		}
+	}
+
+	// Tail the global log. Without a resource filter a Last-Event-ID resumes
+	// straight from the log.
+	cursor := tip
+	if lastEventID != "" && len(filterResourceIDs) == 0 {
+		cursor = lastEventID
+	}
+	if lastSent != "" && database.CompareStreamEventIDs(lastSent, cursor) > 0 {
+		cursor = lastSent
+	}
 
-			// Apply filters
-			if filterResourceID != "" && ev.ResourceID != filterResourceID {
+	for {
+		events, err := database.ReadStreamEvents(ctx, cursor, streamPollInterval)
+		if ctx.Err() != nil {
+			// Client disconnected
+			return
+		}
+		if err != nil {
+			log.Printf("[Stream] Failed to read event log: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if len(events) == 0 {
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+			continue
+		}
+		for _, ev := range events {
+			cursor = ev.ID
+			if lastSent != "" && database.CompareStreamEventIDs(ev.ID, lastSent) <= 0 {
 				continue
 			}
-			if filterType != "" && ev.Type != filterType {
-				continue
+			if matches(ev) {
+				send(ev)
 			}
+		}
+	}
+}
 
-			// Forward event to client
-			fmt.Fprintf(c.Writer, "data: %s\n\n", eventJSON)
-			c.Writer.Flush()
-
-			// If it's a terminal event, close the stream
-			if ev.Stage == "done" || ev.Stage == "error" {
-				closedJSON, _ := json.Marshal(map[string]string{
-					"type":    "system",
-					"stage":   "closed",
-					"message": "Stream ended for " + ev.ResourceID,
-				})
-				fmt.Fprintf(c.Writer, "data: %s\n\n", closedJSON)
-				c.Writer.Flush()
-				return
-			}
+// GetStreamHistory handles GET /stream/history/:resourceId
+// Returns the logged events of a generation or run so a client can rebuild
+// its progress after a reload. Pass ?after=<event id> to fetch only newer events.
+func GetStreamHistory(c *gin.Context) {
+	resourceID := c.Param("resourceId")
+	after := c.Query("after")
+	if after != "" && !database.ValidStreamEventID(after) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after event id"})
+		return
+	}
 
-		case <-ctx.Done():
-			// Client disconnected
-			return
+	access, err := NewStreamAccess(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: could not identify user"})
+		return
+	}
+	logged, err := database.ResourceStreamEvents(c.Request.Context(), resourceID, after)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load event history"})
+		return
+	}
+	events := make([]database.StreamEvent, 0, len(logged))
+	for _, ev := range logged {
+		if access.Allows(ev) {
+			events = append(events, ev)
 		}
 	}
-}
\ No newline at end of file
+	if len(logged) > 0 && len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no event history for this resource"})
+		return
+	}
+
+	resp := gin.H{
+		"resourceId":  resourceID,
+		"events":      events,
+		"lastEventId": after,
+		"finished":    false,
+	}
+	if n := len(events); n > 0 {
+		last := events[n-1]
+		resp["lastEventId"] = last.ID
+		resp["stage"] = last.Stage
+		resp["finished"] = last.Stage == "done" || last.Stage == "error"
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
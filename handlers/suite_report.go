@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"qa-extension-backend/agent"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+// resolveSuiteRef fills in the commit of a run that names only a branch or a
+// merge request, so its results can be reported on the commit
+func resolveSuiteRef(gl *gitlab.Client, run *models.SuiteRun) {
+	ref := run.Ref
+	if ref == nil || ref.CommitSHA != "" || gl == nil {
+		return
+	}
+	switch {
+	case ref.MergeRequestIID > 0:
+		mr, _, err := gl.MergeRequests.GetMergeRequest(run.ProjectID, ref.MergeRequestIID, nil)
+		if err != nil {
+			log.Printf("[SuiteRun] Failed to get MR !%d of project %s: %v", ref.MergeRequestIID, run.ProjectID, err)
+			return
+		}
+		ref.CommitSHA = mr.SHA
+		if ref.Branch == "" {
+			ref.Branch = mr.SourceBranch
+		}
+	case ref.Branch != "":
+		branch, _, err := gl.Branches.GetBranch(run.ProjectID, ref.Branch)
+		if err != nil {
+			log.Printf("[SuiteRun] Failed to get branch %s of project %s: %v", ref.Branch, run.ProjectID, err)
+			return
+		}
+		if branch.Commit != nil {
+			ref.CommitSHA = branch.Commit.ID
+		}
+	}
+}
+
+// reportSuiteRun shows a suite run's state in GitLab when the run is tied to
+// a commit or merge request: the commit status follows every change of state,
+// and the merge request note is posted, or updated, once the run finishes.
+// ctx must carry the GitLab token of the user who started the run.
+func reportSuiteRun(ctx context.Context, run models.SuiteRun) {
+	if run.Ref == nil {
+		return
+	}
+	if _, ok := ctx.Value("token").(*oauth2.Token); !ok {
+		return
+	}
+
+	if sha := run.Ref.CommitSHA; sha != "" {
+		opts := &gitlab.SetCommitStatusOptions{
+			State:       services.SuiteCommitState(run.Status),
+			Name:        gitlab.Ptr(services.SuiteStatusName(run)),
+			Description: gitlab.Ptr(services.SuiteStatusDescription(run)),
+		}
+		if run.Ref.Branch != "" {
+			opts.Ref = gitlab.Ptr(run.Ref.Branch)
+		}
+		if run.Ref.PipelineID > 0 {
+			opts.PipelineID = gitlab.Ptr(run.Ref.PipelineID)
+		}
+		if _, err := agent.SetCommitStatus(ctx, run.ProjectID, sha, opts); err != nil {
+			// GitLab rejects a status equal to the current one; nothing to report
+			if !strings.Contains(err.Error(), "Cannot transition status") {
+				log.Printf("[SuiteRun] Failed to set commit status of %s: %v", run.ID, err)
+			}
+		}
+	}
+
+	if run.Ref.MergeRequestIID > 0 && run.FinishedAt != nil {
+		note := services.BuildSuiteRunNote(run)
+		if _, err := agent.UpsertMergeRequestNote(ctx, run.ProjectID, run.Ref.MergeRequestIID, services.SuiteNoteMarker(run), note); err != nil {
+			log.Printf("[SuiteRun] Failed to post results of %s on MR !%d: %v", run.ID, run.Ref.MergeRequestIID, err)
+		}
+	}
+}
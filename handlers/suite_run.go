@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"qa-extension-backend/agent"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+var (
+	errSuiteRunNotFound = errors.New("suite run not found")
+	// ErrNoSuiteCases is returned when a suite selection matches no automated test case
+	ErrNoSuiteCases = errors.New("no automated test cases match the suite")
+)
+
+func suiteRunKey(id string) string {
+	return fmt.Sprintf("suite_run:%s", id)
+}
+
+func projectSuiteRunsKey(projectID string) string {
+	return fmt.Sprintf("suite_runs:project:%s", projectID)
+}
+
+func getSuiteRun(ctx context.Context, id string) (models.SuiteRun, error) {
+	var run models.SuiteRun
+	val, err := database.RedisClient.Get(ctx, suiteRunKey(id)).Result()
+	if err != nil {
+		return run, errSuiteRunNotFound
+	}
+	err = json.Unmarshal([]byte(val), &run)
+	return run, err
+}
+
+func saveSuiteRun(ctx context.Context, run *models.SuiteRun) error {
+	val, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, suiteRunKey(run.ID), val, 0)
+	pipe.ZAdd(ctx, projectSuiteRunsKey(run.ProjectID), redis.Z{Score: float64(run.CreatedAt.UnixMilli()), Member: run.ID})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// suiteCase is one test case queued in a suite run
+type suiteCase struct {
+	scenario   *models.TestScenario
+	sectionID  string
+	automation models.AutomationTest
+}
+
+// selectSuiteCases finds the automated test cases of a suite: those of the
+// listed scenarios, or of every scenario in the project, narrowed to the
+// suite's tags
+func selectSuiteCases(ctx context.Context, run *models.SuiteRun) ([]suiteCase, error) {
+	scenarioIDs := run.ScenarioIDs
+	if len(scenarioIDs) == 0 {
+		q := database.IndexQuery{Filters: map[string][]string{database.FacetProject: {run.ProjectID}}}
+		if len(run.Tags) > 0 {
+			q.Filters[database.FacetTag] = run.Tags
+		}
+		page, err := database.ScenarioIndex.Query(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		scenarioIDs = page.IDs
+	}
+
+	wanted := make(map[string]bool, len(run.Tags))
+	for _, t := range run.Tags {
+		wanted[t] = true
+	}
+	hasTag := func(tc *models.TestCase) bool {
+		if len(wanted) == 0 {
+			return true
+		}
+		for _, t := range tc.Tags {
+			if wanted[t] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var cases []suiteCase
+	for _, id := range scenarioIDs {
+		scenario, err := getScenario(ctx, id)
+		if err != nil || scenario.ProjectID != run.ProjectID {
+			continue
+		}
+		for _, sec := range scenario.Sections {
+			for ti := range sec.TestCases {
+				tc := &sec.TestCases[ti]
+				if tc.AutomationTest == nil || len(tc.AutomationTest.Steps) == 0 || !hasTag(tc) {
+					continue
+				}
+				cases = append(cases, suiteCase{scenario: &scenario, sectionID: sec.ID, automation: runnableAutomation(tc)})
+				run.Results = append(run.Results, models.SuiteCaseResult{
+					ScenarioID:    scenario.ID,
+					ScenarioTitle: scenario.Title,
+					SectionID:     sec.ID,
+					SectionTitle:  sec.Title,
+					TestCaseID:    tc.ID,
+					Code:          tc.Code,
+					Title:         tc.Title,
+					Status:        models.SuiteRunQueued,
+				})
+			}
+		}
+	}
+	return cases, nil
+}
+
+// StartSuiteRun selects the suite's test cases, checks they can run against
+// the environment and runs them in the background. environmentID may be an
+// environment ID or name; the project default is used when it is empty. gl,
+// when set, is used to report failures on linked issues. When ctx carries the
+// caller's GitLab token and the run has a Ref, progress and results are also
+// reported on the commit and merge request.
+func StartSuiteRun(ctx context.Context, run *models.SuiteRun, environmentID string, gl *gitlab.Client) error {
+	env, err := resolveRunEnvironment(ctx, run.ProjectID, environmentID)
+	if err != nil {
+		return err
+	}
+	if env != nil {
+		run.Environment = env.Ref()
+	}
+
+	if run.ID == "" {
+		run.ID = models.NewSuiteRunID()
+	}
+	if run.Trigger == "" {
+		run.Trigger = models.SuiteTriggerManual
+	}
+	run.CreatedAt = time.Now()
+	run.Results = []models.SuiteCaseResult{}
+	cases, err := selectSuiteCases(ctx, run)
+	if err != nil {
+		return err
+	}
+	if len(cases) == 0 {
+		return ErrNoSuiteCases
+	}
+
+	owner := database.StreamOwner{UserID: run.StartedBy, ProjectID: run.ProjectID}
+	opts := make([]automationRunOptions, len(cases))
+	for i, sc := range cases {
+		opts[i] = automationRunOptions{
+			GitLab:      gl,
+			Environment: env,
+			Auth:        sc.scenario.AuthConfig,
+			ProjectID:   sc.scenario.ProjectID,
+			Owner:       owner,
+		}
+		if _, err := opts[i].prepareRun(sc.automation); err != nil {
+			return fmt.Errorf("%s: %w", run.Results[i].Code, err)
+		}
+	}
+
+	resolveSuiteRef(gl, run)
+	run.Status = models.SuiteRunQueued
+	run.Tally()
+	if err := saveSuiteRun(ctx, run); err != nil {
+		return err
+	}
+	database.RegisterStreamOwner(ctx, run.ID, owner)
+
+	bgCtx := database.WithStreamOwner(context.Background(), owner)
+	if token, ok := ctx.Value("token").(*oauth2.Token); ok {
+		bgCtx = context.WithValue(bgCtx, "token", token)
+	}
+	go executeSuiteRun(bgCtx, *run, cases, opts)
+	return nil
+}
+
+// executeSuiteRun runs the queued cases one at a time, saving the run after each
+func executeSuiteRun(ctx context.Context, run models.SuiteRun, cases []suiteCase, opts []automationRunOptions) {
+	reportSuiteRun(ctx, run)
+	events := agent.NewSuiteRunEmitter(ctx, run.ID).SetTotalSteps(len(cases))
+	events.Start("Running suite '%s' (%d test case%s)", run.Name, len(cases), pluralize(len(cases)))
+
+	run.Status = models.SuiteRunRunning
+	reportSuiteRun(ctx, run)
+	for i, sc := range cases {
+		res := &run.Results[i]
+		res.Status = models.SuiteRunRunning
+		_ = saveSuiteRun(ctx, &run)
+		events.Step(i+1, fmt.Sprintf("%s %s", res.Code, res.Title))
+
+		setTestCasesAutomationStatus(ctx, sc.scenario.ID, []string{res.TestCaseID}, models.AutomationStatusRunning)
+		updated := runScenarioAutomation(sc.scenario.ID, sc.sectionID, res.TestCaseID, sc.automation, opts[i])
+
+		res.Status = models.SuiteRunFailed
+		switch {
+		case updated == nil:
+			res.Error = "test case was removed during the run"
+		case updated.Status == models.AutomationStatusPass:
+			res.Status = models.SuiteRunPassed
+		}
+		if updated != nil {
+			res.DurationMs = updated.RunDurationMs
+			res.Error = updated.ErrorMessage
+			res.FailedStepIndex = updated.FailedStepIndex
+			res.VideoURL = updated.VideoURL
+			if idx := updated.FailedStepIndex; idx != nil {
+				if *idx >= 0 && *idx < len(sc.automation.Steps) {
+					step := sc.automation.Steps[*idx]
+					res.FailedStep = step.Description
+					if res.FailedStep == "" {
+						res.FailedStep = step.Action
+					}
+				}
+				// Screenshots only kept inline are left out of the suite run
+				for _, sr := range updated.StepResults {
+					if sr.StepIndex == *idx && strings.HasPrefix(sr.Screenshot, "http") {
+						res.ScreenshotURL = sr.Screenshot
+					}
+				}
+			}
+		}
+		run.Tally()
+		events.Progressf("%s %s (%d/%d)", res.Code, res.Status, i+1, len(cases))
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Tally()
+	if err := saveSuiteRun(ctx, &run); err != nil {
+		log.Printf("[SuiteRun] Failed to save suite run %s: %v", run.ID, err)
+	}
+	reportSuiteRun(ctx, run)
+	events.Done("Suite '%s' finished: %d passed, %d failed", run.Name, run.Passed, run.Failed)
+}
+
+// StartProjectSuiteRun handles POST /projects/:id/suite-runs
+// Runs the automated test cases of the chosen scenarios, or of the whole
+// project, that carry one of the given tags.
+func StartProjectSuiteRun(c *gin.Context) {
+	var req models.StartSuiteRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run := models.SuiteRun{
+		ProjectID:   c.Param("id"),
+		Name:        req.Name,
+		ScenarioIDs: req.ScenarioIDs,
+		Tags:        req.Tags,
+		Ref:         req.Ref,
+		StartedBy:   currentAuthorID(c),
+	}
+	if run.Name == "" {
+		run.Name = "Suite run"
+	}
+	ctx := context.WithValue(c.Request.Context(), "token", c.MustGet("token"))
+	if err := StartSuiteRun(ctx, &run, req.EnvironmentID, backgroundGitLabClient(c)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrNoSuiteCases) {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListProjectSuiteRuns handles GET /projects/:id/suite-runs
+// Returns the project's suite runs, newest first. ?limit= defaults to 20.
+func ListProjectSuiteRuns(c *gin.Context) {
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	ctx := c.Request.Context()
+	ids, err := database.RedisClient.ZRevRange(ctx, projectSuiteRunsKey(c.Param("id")), 0, int64(limit-1)).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	runs := make([]models.SuiteRun, 0, len(ids))
+	for _, id := range ids {
+		if run, err := getSuiteRun(ctx, id); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": runs})
+}
+
+// GetSuiteRun handles GET /suite-runs/:runId
+func GetSuiteRun(c *gin.Context) {
+	run, err := getSuiteRun(c.Request.Context(), c.Param("runId"))
+	if err == nil && !tokenAllowsProject(c, run.ProjectID) {
+		err = errSuiteRunNotFound
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
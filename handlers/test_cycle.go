@@ -0,0 +1,723 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func getTestCycle(ctx context.Context, id string) (models.TestCycle, error) {
+	var cycle models.TestCycle
+	val, err := database.RedisClient.Get(ctx, fmt.Sprintf("test_cycle:%s", id)).Result()
+	if err != nil {
+		return cycle, err
+	}
+	err = json.Unmarshal([]byte(val), &cycle)
+	return cycle, err
+}
+
+func saveTestCycle(ctx context.Context, cycle *models.TestCycle) error {
+	cycle.ComputeProgress()
+	val, err := json.Marshal(cycle)
+	if err != nil {
+		return err
+	}
+
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf("test_cycle:%s", cycle.ID), val, 0)
+	pipe.SAdd(ctx, "test_cycles", cycle.ID)
+	pipe.SAdd(ctx, fmt.Sprintf("test_cycles:scenario:%s", cycle.ScenarioID), cycle.ID)
+	if cycle.ProjectID != "" {
+		pipe.SAdd(ctx, fmt.Sprintf("test_cycles:project:%s", cycle.ProjectID), cycle.ID)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func findExecution(cycle *models.TestCycle, tcID string) *models.TestExecution {
+	for i := range cycle.Executions {
+		if cycle.Executions[i].TestCaseID == tcID {
+			return &cycle.Executions[i]
+		}
+	}
+	return nil
+}
+
+// applyExecutionResult sets the current result of an execution and appends it to its history
+func applyExecutionResult(e *models.TestExecution, record models.ExecutionRecord) {
+	e.Status = record.Status
+	e.Source = record.Source
+	e.Comment = record.Comment
+	e.Evidence = record.Evidence
+	e.ExecutedBy = record.ExecutedBy
+	executedAt := record.ExecutedAt
+	e.ExecutedAt = &executedAt
+	e.History = append(e.History, record)
+}
+
+// recordAutomationExecution copies an automation result onto the executions that
+// track the test case. With a cycle ID only that cycle is updated; otherwise every
+// active cycle of the scenario is, except where a tester already recorded a
+// manual verdict.
+func recordAutomationExecution(ctx context.Context, scenarioID, tcID, cycleID string, automation *models.AutomationTest) {
+	var status models.ExecutionStatus
+	switch automation.Status {
+	case models.AutomationStatusPass:
+		status = models.ExecutionPassed
+	case models.AutomationStatusFail:
+		status = models.ExecutionFailed
+	default:
+		return
+	}
+
+	cycleIDs := []string{cycleID}
+	if cycleID == "" {
+		ids, err := database.RedisClient.SMembers(ctx, fmt.Sprintf("test_cycles:scenario:%s", scenarioID)).Result()
+		if err != nil {
+			return
+		}
+		cycleIDs = ids
+	}
+
+	for _, id := range cycleIDs {
+		cycle, err := getTestCycle(ctx, id)
+		if err != nil || cycle.ScenarioID != scenarioID || cycle.Status != models.CycleStatusActive {
+			continue
+		}
+		e := findExecution(&cycle, tcID)
+		if e == nil {
+			continue
+		}
+		manualVerdict := e.Source == models.ExecutionSourceManual && e.Status != models.ExecutionNotRun && e.Status != models.ExecutionInProgress
+		if cycleID == "" && manualVerdict {
+			continue
+		}
+
+		record := models.ExecutionRecord{
+			Status:     status,
+			Source:     models.ExecutionSourceAutomation,
+			Comment:    automation.ErrorMessage,
+			ExecutedAt: time.Now(),
+		}
+		if automation.Environment != nil {
+			record.Environment = automation.Environment.Name
+		}
+		if automation.VideoURL != "" {
+			record.Evidence = append(record.Evidence, models.ExecutionEvidence{Type: "video", URL: automation.VideoURL, Name: automation.Name})
+		}
+		if automation.ScreenshotURL != "" {
+			record.Evidence = append(record.Evidence, models.ExecutionEvidence{Type: "screenshot", URL: automation.ScreenshotURL, Name: automation.Name})
+		}
+		applyExecutionResult(e, record)
+
+		cycle.UpdatedAt = time.Now()
+		if err := saveTestCycle(ctx, &cycle); err != nil {
+			log.Printf("[TestCycle] failed to record automation result for %s in %s: %v", tcID, cycle.ID, err)
+		}
+	}
+}
+
+// finalizeCycleExecution settles an execution a cycle run left in progress,
+// which happens when the scenario can't be re-read after the run, the run ends
+// without a pass or fail, or the cycle is completed while it runs. The
+// execution is marked blocked with the reason so it never stays in progress.
+func finalizeCycleExecution(ctx context.Context, cycleID, tcID string, automation *models.AutomationTest) {
+	cycle, err := getTestCycle(ctx, cycleID)
+	if err != nil {
+		log.Printf("[TestCycle] failed to load %s to finalize %s: %v", cycleID, tcID, err)
+		return
+	}
+	e := findExecution(&cycle, tcID)
+	if e == nil || e.Status != models.ExecutionInProgress {
+		return
+	}
+
+	var reason string
+	switch {
+	case automation == nil:
+		reason = "The automation result could not be loaded after the run"
+	case cycle.Status != models.CycleStatusActive:
+		reason = "The cycle was completed before the automation run finished"
+	case automation.ErrorMessage != "":
+		reason = automation.ErrorMessage
+	default:
+		reason = fmt.Sprintf("The automation run finished without a result (status %q)", automation.Status)
+	}
+	applyExecutionResult(e, models.ExecutionRecord{
+		Status:     models.ExecutionBlocked,
+		Source:     models.ExecutionSourceAutomation,
+		Comment:    reason,
+		ExecutedAt: time.Now(),
+	})
+
+	cycle.UpdatedAt = time.Now()
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		log.Printf("[TestCycle] failed to finalize %s in %s: %v", tcID, cycle.ID, err)
+	}
+}
+
+// CreateTestCycle handles POST /test-cycles
+// Includes every non-deprecated test case of the scenario unless sectionIds or
+// testCaseIds narrow it down.
+func CreateTestCycle(c *gin.Context) {
+	var req models.CreateTestCycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" || req.ScenarioID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and scenarioId are required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, req.ScenarioID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	sectionFilter := make(map[string]bool)
+	for _, id := range req.SectionIDs {
+		sectionFilter[id] = true
+	}
+	caseFilter := make(map[string]bool)
+	for _, id := range req.TestCaseIDs {
+		caseFilter[id] = true
+	}
+
+	var executions []models.TestExecution
+	for _, sec := range scenario.Sections {
+		for _, tc := range sec.TestCases {
+			if len(caseFilter) > 0 || len(sectionFilter) > 0 {
+				if !caseFilter[tc.ID] && !sectionFilter[sec.ID] {
+					continue
+				}
+			} else if tc.Status == models.TCStatusDeprecated {
+				continue
+			}
+			executions = append(executions, models.TestExecution{
+				TestCaseID:   tc.ID,
+				Code:         tc.Code,
+				Title:        tc.Title,
+				SectionID:    sec.ID,
+				SectionTitle: sec.Title,
+				Priority:     tc.Priority,
+				Automated:    tc.AutomationTest != nil && len(tc.AutomationTest.Steps) > 0,
+				AssigneeID:   req.AssigneeID,
+				AssigneeName: req.AssigneeName,
+				Status:       models.ExecutionNotRun,
+			})
+		}
+	}
+
+	if len(executions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no test cases selected for this cycle"})
+		return
+	}
+
+	now := time.Now()
+	cycle := models.TestCycle{
+		ID:            models.NewTestCycleID(),
+		Name:          strings.TrimSpace(req.Name),
+		Description:   req.Description,
+		ScenarioID:    scenario.ID,
+		ScenarioTitle: scenario.Title,
+		ProjectID:     scenario.ProjectID,
+		Build:         req.Build,
+		Environment:   req.Environment,
+		Status:        models.CycleStatusActive,
+		Executions:    executions,
+		CreatorID:     currentAuthorID(c),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save test cycle"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, cycle)
+}
+
+// ListTestCycles handles GET /test-cycles
+// Query params: scenarioId, projectId, status
+func ListTestCycles(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	key := "test_cycles"
+	if scenarioID := c.Query("scenarioId"); scenarioID != "" {
+		key = fmt.Sprintf("test_cycles:scenario:%s", scenarioID)
+	} else if projectID := c.Query("projectId"); projectID != "" {
+		key = fmt.Sprintf("test_cycles:project:%s", projectID)
+	}
+	status := c.Query("status")
+
+	ids, err := database.RedisClient.SMembers(ctx, key).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list test cycles"})
+		return
+	}
+
+	cycles := make([]models.TestCycle, 0, len(ids))
+	for _, id := range ids {
+		cycle, err := getTestCycle(ctx, id)
+		if err != nil {
+			continue
+		}
+		if status != "" && string(cycle.Status) != status {
+			continue
+		}
+		cycle.ComputeProgress()
+		cycle.Executions = nil
+		cycles = append(cycles, cycle)
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].CreatedAt.After(cycles[j].CreatedAt)
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": cycles})
+}
+
+// GetTestCycle handles GET /test-cycles/:id
+// Query params: assigneeId, status (filter executions)
+func GetTestCycle(c *gin.Context) {
+	cycle, err := getTestCycle(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+	cycle.ComputeProgress()
+
+	assignee := c.Query("assigneeId")
+	status := c.Query("status")
+	if assignee != "" || status != "" {
+		filtered := make([]models.TestExecution, 0)
+		for _, e := range cycle.Executions {
+			if assignee != "" && strconv.Itoa(e.AssigneeID) != assignee {
+				continue
+			}
+			if status != "" && string(e.Status) != status {
+				continue
+			}
+			filtered = append(filtered, e)
+		}
+		cycle.Executions = filtered
+	}
+
+	c.JSON(http.StatusOK, cycle)
+}
+
+// UpdateTestCycle handles PATCH /test-cycles/:id
+func UpdateTestCycle(c *gin.Context) {
+	var req models.UpdateTestCycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	cycle, err := getTestCycle(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+
+	if req.Name != nil {
+		cycle.Name = *req.Name
+	}
+	if req.Description != nil {
+		cycle.Description = *req.Description
+	}
+	if req.Build != nil {
+		cycle.Build = *req.Build
+	}
+	if req.Environment != nil {
+		cycle.Environment = *req.Environment
+	}
+	if req.Status != nil {
+		if *req.Status != models.CycleStatusActive && *req.Status != models.CycleStatusCompleted {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'active' or 'completed'"})
+			return
+		}
+		cycle.Status = *req.Status
+	}
+
+	cycle.UpdatedAt = time.Now()
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save test cycle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, cycle)
+}
+
+// DeleteTestCycle handles DELETE /test-cycles/:id
+func DeleteTestCycle(c *gin.Context) {
+	id := c.Param("id")
+	ctx := c.Request.Context()
+
+	cycle, err := getTestCycle(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+
+	database.RedisClient.Del(ctx, fmt.Sprintf("test_cycle:%s", id))
+	database.RedisClient.SRem(ctx, "test_cycles", id)
+	database.RedisClient.SRem(ctx, fmt.Sprintf("test_cycles:scenario:%s", cycle.ScenarioID), id)
+	if cycle.ProjectID != "" {
+		database.RedisClient.SRem(ctx, fmt.Sprintf("test_cycles:project:%s", cycle.ProjectID), id)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "test cycle deleted successfully",
+		"id":      id,
+	})
+}
+
+// UpdateExecution handles PATCH /test-cycles/:id/executions/:tcId
+// Records a manual result (status, comment, evidence) and/or changes the assignee.
+func UpdateExecution(c *gin.Context) {
+	var req models.UpdateExecutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Status != nil && !req.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid execution status %q", *req.Status)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	cycle, err := getTestCycle(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+	if cycle.Status == models.CycleStatusCompleted && req.Status != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "test cycle is completed; reopen it to record results"})
+		return
+	}
+
+	e := findExecution(&cycle, c.Param("tcId"))
+	if e == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test case is not part of this cycle"})
+		return
+	}
+
+	if req.AssigneeID != nil {
+		e.AssigneeID = *req.AssigneeID
+	}
+	if req.AssigneeName != nil {
+		e.AssigneeName = *req.AssigneeName
+	}
+
+	if req.Status != nil {
+		record := models.ExecutionRecord{
+			Status:     *req.Status,
+			Source:     models.ExecutionSourceManual,
+			Evidence:   req.Evidence,
+			ExecutedBy: currentAuthorID(c),
+			ExecutedAt: time.Now(),
+		}
+		if req.Comment != nil {
+			record.Comment = *req.Comment
+		}
+		applyExecutionResult(e, record)
+	} else {
+		// Annotating the current result without changing it
+		if req.Comment != nil {
+			e.Comment = *req.Comment
+		}
+		if len(req.Evidence) > 0 {
+			e.Evidence = append(e.Evidence, req.Evidence...)
+		}
+	}
+
+	cycle.UpdatedAt = time.Now()
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save test cycle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+// AssignExecutions handles POST /test-cycles/:id/assign
+func AssignExecutions(c *gin.Context) {
+	var req models.AssignExecutionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.TestCaseIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "testCaseIds array is required and cannot be empty"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	cycle, err := getTestCycle(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+
+	assigned := 0
+	for _, tcID := range req.TestCaseIDs {
+		if e := findExecution(&cycle, tcID); e != nil {
+			e.AssigneeID = req.AssigneeID
+			e.AssigneeName = req.AssigneeName
+			assigned++
+		}
+	}
+
+	cycle.UpdatedAt = time.Now()
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save test cycle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("assigned %d test case%s", assigned, pluralize(assigned)),
+		"assigned": assigned,
+	})
+}
+
+// RunTestCycleAutomation handles POST /test-cycles/:id/run
+// Runs the automation of every automated test case in the cycle, one at a time,
+// and records each result against the cycle.
+func RunTestCycleAutomation(c *gin.Context) {
+	var req struct {
+		TestCaseIDs   []string `json:"testCaseIds"`
+		EnvironmentID string   `json:"environmentId"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	ctx := c.Request.Context()
+	cycle, err := getTestCycle(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+	if cycle.Status != models.CycleStatusActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "test cycle is not active"})
+		return
+	}
+
+	scenario, err := getScenario(ctx, cycle.ScenarioID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, id := range req.TestCaseIDs {
+		wanted[id] = true
+	}
+
+	type queued struct {
+		sectionID  string
+		tcID       string
+		automation models.AutomationTest
+	}
+	var queue []queued
+	for _, sec := range scenario.Sections {
+		for _, tc := range sec.TestCases {
+			if len(wanted) > 0 && !wanted[tc.ID] {
+				continue
+			}
+			if findExecution(&cycle, tc.ID) == nil || tc.AutomationTest == nil || len(tc.AutomationTest.Steps) == 0 {
+				continue
+			}
+			queue = append(queue, queued{sectionID: sec.ID, tcID: tc.ID, automation: runnableAutomation(&tc)})
+		}
+	}
+
+	if len(queue) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no automated test cases to run in this cycle"})
+		return
+	}
+
+	// The cycle's free-text environment is used when it names a project environment
+	var env *models.Environment
+	if req.EnvironmentID == "" && cycle.Environment != "" {
+		env, _ = findEnvironment(ctx, scenario.ProjectID, cycle.Environment)
+	}
+	if env == nil {
+		if env, err = resolveRunEnvironment(ctx, scenario.ProjectID, req.EnvironmentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	opts := automationRunOptions{
+		CycleID:     cycle.ID,
+		GitLab:      backgroundGitLabClient(c),
+		Environment: env,
+		Auth:        scenario.AuthConfig,
+		ProjectID:   scenario.ProjectID,
+		Owner:       database.StreamOwner{UserID: currentAuthorID(c), ProjectID: scenario.ProjectID},
+	}
+	for _, q := range queue {
+		if _, err := opts.prepareRun(q.automation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	for _, q := range queue {
+		e := findExecution(&cycle, q.tcID)
+		e.Status = models.ExecutionInProgress
+		e.Source = models.ExecutionSourceAutomation
+	}
+	cycle.UpdatedAt = time.Now()
+	_ = saveTestCycle(ctx, &cycle)
+
+	tcIDs := make([]string, len(queue))
+	for i, q := range queue {
+		tcIDs[i] = q.tcID
+	}
+	setTestCasesAutomationStatus(ctx, scenario.ID, tcIDs, models.AutomationStatusRunning)
+
+	go func() {
+		for _, q := range queue {
+			updated := runScenarioAutomation(scenario.ID, q.sectionID, q.tcID, q.automation, opts)
+			finalizeCycleExecution(context.Background(), cycle.ID, q.tcID, updated)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":     fmt.Sprintf("running %d automated test case%s", len(queue), pluralize(len(queue))),
+		"testCaseIds": tcIDs,
+	})
+}
+
+// buildCycleSummary groups progress by assignee and section and lists open failures
+func buildCycleSummary(cycle *models.TestCycle) models.CycleSummary {
+	summary := models.CycleSummary{
+		CycleID:     cycle.ID,
+		Name:        cycle.Name,
+		Build:       cycle.Build,
+		Environment: cycle.Environment,
+		Status:      cycle.Status,
+		Progress:    models.ComputeCycleProgress(cycle.Executions),
+		ByAssignee:  []models.CycleGroupProgress{},
+		BySection:   []models.CycleGroupProgress{},
+		Failed:      []models.TestExecution{},
+		Blocked:     []models.TestExecution{},
+		SignOff:     cycle.SignOff,
+	}
+
+	group := func(executions []models.TestExecution, keyOf func(models.TestExecution) (string, string)) []models.CycleGroupProgress {
+		var order []string
+		labels := make(map[string]string)
+		buckets := make(map[string][]models.TestExecution)
+		for _, e := range executions {
+			key, label := keyOf(e)
+			if _, ok := buckets[key]; !ok {
+				order = append(order, key)
+				labels[key] = label
+			}
+			buckets[key] = append(buckets[key], e)
+		}
+		groups := make([]models.CycleGroupProgress, 0, len(order))
+		for _, key := range order {
+			groups = append(groups, models.CycleGroupProgress{Key: key, Label: labels[key], Progress: models.ComputeCycleProgress(buckets[key])})
+		}
+		return groups
+	}
+
+	summary.ByAssignee = group(cycle.Executions, func(e models.TestExecution) (string, string) {
+		if e.AssigneeID == 0 {
+			return "unassigned", "Unassigned"
+		}
+		label := e.AssigneeName
+		if label == "" {
+			label = versionAuthorName(e.AssigneeID)
+		}
+		return strconv.Itoa(e.AssigneeID), label
+	})
+	summary.BySection = group(cycle.Executions, func(e models.TestExecution) (string, string) {
+		return e.SectionID, e.SectionTitle
+	})
+
+	for _, e := range cycle.Executions {
+		e.History = nil
+		switch e.Status {
+		case models.ExecutionFailed:
+			summary.Failed = append(summary.Failed, e)
+		case models.ExecutionBlocked:
+			summary.Blocked = append(summary.Blocked, e)
+		}
+	}
+
+	summary.ReadyToSign = summary.Progress.NotRun == 0 && summary.Progress.InProgress == 0
+	return summary
+}
+
+// GetTestCycleSummary handles GET /test-cycles/:id/summary
+func GetTestCycleSummary(c *gin.Context) {
+	cycle, err := getTestCycle(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildCycleSummary(&cycle))
+}
+
+// SignOffTestCycle handles POST /test-cycles/:id/sign-off
+// Records the approve/reject decision with a snapshot of progress and completes the cycle.
+func SignOffTestCycle(c *gin.Context) {
+	var req models.SignOffTestCycleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Decision != models.SignOffApproved && req.Decision != models.SignOffRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision must be 'approved' or 'rejected'"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	cycle, err := getTestCycle(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+
+	progress := models.ComputeCycleProgress(cycle.Executions)
+	if req.Decision == models.SignOffApproved && progress.InProgress > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot approve while executions are still in progress"})
+		return
+	}
+
+	cycle.SignOff = &models.CycleSignOff{
+		Decision: req.Decision,
+		Comment:  req.Comment,
+		SignedBy: currentAuthorID(c),
+		SignedAt: time.Now(),
+		Progress: progress,
+	}
+	cycle.Status = models.CycleStatusCompleted
+	cycle.UpdatedAt = time.Now()
+
+	if err := saveTestCycle(ctx, &cycle); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save test cycle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildCycleSummary(&cycle))
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReportRuns caps how many stored runs per test case a scenario export includes
+const maxReportRuns = 50
+
+// writeTestReport sends a report as a download in the ?format= query
+// parameter: junit (default), allure or ctrf
+func writeTestReport(c *gin.Context, report services.TestReport) {
+	data, fileName, contentType, err := services.RenderTestReport(report, c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ExportSuiteRun handles GET /suite-runs/:runId/export
+// Query params: format (junit, allure or ctrf)
+func ExportSuiteRun(c *gin.Context) {
+	run, err := getSuiteRun(c.Request.Context(), c.Param("runId"))
+	if err == nil && !tokenAllowsProject(c, run.ProjectID) {
+		err = errSuiteRunNotFound
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	writeTestReport(c, services.SuiteRunReport(run))
+}
+
+// ExportScenarioResults handles GET /test-scenarios/:id/export/results
+// Query params: format (junit, allure or ctrf), runs (how many of each test
+// case's most recent runs to include; defaults to the last run only)
+func ExportScenarioResults(c *gin.Context) {
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	runs := 1
+	if v := c.Query("runs"); v != "" {
+		runs, err = strconv.Atoi(v)
+		if err != nil || runs < 1 || runs > maxReportRuns {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("runs must be between 1 and %d", maxReportRuns)})
+			return
+		}
+	}
+
+	// The last run is kept on each automation; older ones only in the result history
+	var history map[string][]models.TestResult
+	if runs > 1 {
+		history = make(map[string][]models.TestResult)
+		for _, sec := range scenario.Sections {
+			for _, tc := range sec.TestCases {
+				if tc.AutomationTest == nil {
+					continue
+				}
+				results, err := database.GetRecentTestResults(ctx, tc.AutomationTest.ID, int64(runs))
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				history[tc.AutomationTest.ID] = results
+			}
+		}
+	}
+	writeTestReport(c, services.ScenarioReport(scenario, history))
+}
+
+// ExportTestCycle handles GET /test-cycles/:id/export
+// Query params: format (junit, allure or ctrf)
+func ExportTestCycle(c *gin.Context) {
+	cycle, err := getTestCycle(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test cycle not found"})
+		return
+	}
+	writeTestReport(c, services.TestCycleReport(cycle))
+}
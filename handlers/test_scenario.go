@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,12 +11,11 @@ import (
 	"qa-extension-backend/auth"
 	"qa-extension-backend/client"
 	"qa-extension-backend/database"
-	"qa-extension-backend/internal/models"
 	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
 	"qa-extension-backend/services"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -91,32 +91,14 @@ func UploadScenario(c *gin.Context) {
 	scenario.ID = uuid.NewString()
 
 	// Save to Redis
-	ctx := c.Request.Context()
-	key := fmt.Sprintf("scenario:%s", scenario.ID)
-
-	val, err := json.Marshal(scenario)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal scenario"})
-		return
-	}
-
-	err = database.RedisClient.Set(ctx, key, val, 0).Err()
-	if err != nil {
+	if err := createScenario(c.Request.Context(), &scenario); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save to redis"})
 		return
 	}
 
-	// Add to set of scenarios
-	database.RedisClient.SAdd(ctx, "scenarios", scenario.ID)
-	if scenario.CreatorID != 0 {
-		database.RedisClient.SAdd(ctx, fmt.Sprintf("scenarios:user:%d", scenario.CreatorID), scenario.ID)
-	} else {
-		database.RedisClient.SAdd(ctx, "scenarios:legacy", scenario.ID)
-	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"message": "scenario uploaded and parsed successfully",
-		"id":      scenario.ID,
+		"message":  "scenario uploaded and parsed successfully",
+		"id":       scenario.ID,
 		"sections": len(scenario.Sections),
 	})
 }
@@ -125,141 +107,78 @@ func UploadScenario(c *gin.Context) {
 func ListScenarios(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID, _ := identity.GetCurrentUserID(c)
-	search := c.Query("search")
-	status := c.Query("status")
-	sortBy := c.Query("sort_by") // "created_at", "title", "status"
-	order := c.Query("order")    // "asc", "desc"
-	page := 1
-	limit := 20
-
-	if p := c.Query("page"); p != "" {
-		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
-			page = parsed
-		}
-	}
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
-	}
-
-	var ids []string
-	var err error
 
+	params, err := parseListParams(c, "title", "file_name", "status")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if userID != 0 {
-		userKey := fmt.Sprintf("scenarios:user:%d", userID)
-		ids, err = database.RedisClient.SUnion(ctx, "scenarios:legacy", userKey).Result()
-	} else {
-		ids, err = database.RedisClient.SMembers(ctx, "scenarios").Result()
+		// Own scenarios plus legacy ones without a creator
+		params.Query.Filters[database.FacetOwner] = []string{strconv.Itoa(userID), "0"}
 	}
+	params.filter(c, "status", database.FacetStatus)
+	params.filter(c, "project_id", database.FacetProject)
+	params.filter(c, "tag", database.FacetTag)
+	params.filter(c, "automation_status", database.FacetAutomation)
 
+	result, err := database.ScenarioIndex.Query(ctx, params.Query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list scenarios"})
 		return
 	}
 
-	var scenarios []models.TestScenario
-	processedIDs := make(map[string]bool)
-
-	for _, id := range ids {
-		if processedIDs[id] {
-			continue
+	scenarios := make([]models.TestScenario, 0, len(result.IDs))
+	if len(result.IDs) > 0 {
+		keys := make([]string, len(result.IDs))
+		for i, id := range result.IDs {
+			keys[i] = fmt.Sprintf("scenario:%s", id)
 		}
-		val, err := database.RedisClient.Get(ctx, fmt.Sprintf("scenario:%s", id)).Result()
-		if err == nil {
-			var s models.TestScenario
-			if json.Unmarshal([]byte(val), &s) == nil {
-				if userID == 0 || s.CreatorID == 0 || s.CreatorID == userID {
-					// For lists, we don't need the full parsed sheets or massive test cases payload
-					// We can just compute stats and clear out the heavy parts
-					s.ComputeStats()
-					s.Sections = nil
-					s.Sheets = nil
-					scenarios = append(scenarios, s)
-					processedIDs[id] = true
-				}
-			}
+		vals, err := database.RedisClient.MGet(ctx, keys...).Result()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list scenarios"})
+			return
 		}
-	}
-
-	// Apply filters
-	if status != "" {
-		filtered := make([]models.TestScenario, 0)
-		for _, s := range scenarios {
-			if string(s.Status) == status {
-				filtered = append(filtered, s)
+		for _, val := range vals {
+			str, ok := val.(string)
+			if !ok {
+				continue
 			}
-		}
-		scenarios = filtered
-	}
-
-	// Apply search
-	if search != "" {
-		searchLower := strings.ToLower(search)
-		filtered := make([]models.TestScenario, 0)
-		for _, s := range scenarios {
-			if strings.Contains(strings.ToLower(s.Title), searchLower) ||
-				strings.Contains(strings.ToLower(s.ProjectName), searchLower) {
-				filtered = append(filtered, s)
+			var s models.TestScenario
+			if json.Unmarshal([]byte(str), &s) == nil {
+				// For lists, we don't need the full parsed sheets or massive test cases payload
+				// We can just compute stats and clear out the heavy parts
+				s.ComputeStats()
+				s.Sections = nil
+				s.Sheets = nil
+				maskAuthConfig(&s.AuthConfig)
+				scenarios = append(scenarios, s)
 			}
 		}
-		scenarios = filtered
 	}
 
-	// Default sort
-	if sortBy == "" {
-		sortBy = "created_at"
-	}
-	if order == "" {
-		order = "desc"
-	}
-
-	sort.Slice(scenarios, func(i, j int) bool {
-		var condition bool
-		switch sortBy {
-		case "title", "file_name":
-			if order == "asc" {
-				condition = scenarios[i].Title < scenarios[j].Title
-			} else {
-				condition = scenarios[i].Title > scenarios[j].Title
-			}
-		case "status":
-			if order == "asc" {
-				condition = scenarios[i].Status < scenarios[j].Status
-			} else {
-				condition = scenarios[i].Status > scenarios[j].Status
+	if params.FieldSort != "" {
+		sort.SliceStable(scenarios, func(i, j int) bool {
+			a, b := string(scenarios[i].Status), string(scenarios[j].Status)
+			if params.FieldSort != "status" {
+				a, b = scenarios[i].Title, scenarios[j].Title
 			}
-		case "created_at":
-			fallthrough
-		default:
-			if order == "asc" {
-				condition = scenarios[i].CreatedAt.Before(scenarios[j].CreatedAt)
-			} else {
-				condition = scenarios[i].CreatedAt.After(scenarios[j].CreatedAt)
+			if params.Asc {
+				return a < b
 			}
-		}
-		return condition
-	})
-
-	total := len(scenarios)
-	totalPages := (total + limit - 1) / limit
-	start := (page - 1) * limit
-	end := start + limit
-
-	if start >= total {
-		c.JSON(http.StatusOK, gin.H{
-			"data":        []models.TestScenario{},
-			"pagination": gin.H{"page": page, "limit": limit, "total": total, "totalPages": totalPages},
+			return a > b
 		})
-		return
-	}
-	if end > total {
-		end = total
+		start, end := params.pageBounds(len(scenarios))
+		scenarios = scenarios[start:end]
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data":        scenarios[start:end],
-		"pagination": gin.H{"page": page, "limit": limit, "total": total, "totalPages": totalPages},
+		"data":       scenarios,
+		"pagination": params.pagination(result.Total, result.NextCursor),
 	})
 }
 
@@ -279,9 +198,10 @@ func GetScenario(c *gin.Context) {
 
 	// Ensure stats are computed
 	scenario.ComputeStats()
-	
+
 	// Exclude sheets from response to save bandwidth
 	scenario.Sheets = nil
+	maskAuthConfig(&scenario.AuthConfig)
 
 	c.JSON(http.StatusOK, scenario)
 }
@@ -308,11 +228,26 @@ func UpdateScenario(c *gin.Context) {
 	if req.Description != nil {
 		scenario.Description = *req.Description
 	}
+	if req.AuthConfig != nil {
+		auth := *req.AuthConfig
+		if auth.CredentialID == "" {
+			auth.CredentialID = scenario.AuthConfig.CredentialID
+		} else if cred, err := database.GetCredential(ctx, auth.CredentialID); err != nil || cred.ProjectID != scenario.ProjectID {
+			// Credentials of other projects are reported as missing
+			c.JSON(http.StatusBadRequest, gin.H{"error": "credential not found in this project"})
+			return
+		}
+		scenario.AuthConfig = auth
+	}
 
 	scenario.UpdatedAt = time.Now()
-	saveScenario(ctx, &scenario)
+	if err := saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionUpdateScenario, "Updated scenario details"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	scenario.Sheets = nil
+	maskAuthConfig(&scenario.AuthConfig)
 	c.JSON(http.StatusOK, scenario)
 }
 
@@ -336,28 +271,46 @@ func UpdateTestCase(c *gin.Context) {
 	}
 
 	updated := false
+	summary := ""
 	for i := range scenario.Sections {
 		if scenario.Sections[i].ID == sectionID {
 			for j := range scenario.Sections[i].TestCases {
 				if scenario.Sections[i].TestCases[j].ID == tcID {
 					tc := &scenario.Sections[i].TestCases[j]
-					
-					if req.Title != nil { tc.Title = *req.Title }
-					if req.Description != nil { tc.Description = *req.Description }
-					if req.PreCondition != nil { tc.PreCondition = *req.PreCondition }
-					if req.Tags != nil { tc.Tags = *req.Tags }
-					if req.Priority != nil { tc.Priority = *req.Priority }
-					if req.Type != nil { tc.Type = *req.Type }
-					if req.Status != nil { tc.Status = *req.Status }
-					if req.Note != nil { tc.Note = *req.Note }
-					if req.Steps != nil { 
+					summary = fmt.Sprintf("Updated %s", tc.Code)
+
+					if req.Title != nil {
+						tc.Title = *req.Title
+					}
+					if req.Description != nil {
+						tc.Description = *req.Description
+					}
+					if req.PreCondition != nil {
+						tc.PreCondition = *req.PreCondition
+					}
+					if req.Tags != nil {
+						tc.Tags = *req.Tags
+					}
+					if req.Priority != nil {
+						tc.Priority = *req.Priority
+					}
+					if req.Type != nil {
+						tc.Type = *req.Type
+					}
+					if req.Status != nil {
+						tc.Status = *req.Status
+					}
+					if req.Note != nil {
+						tc.Note = *req.Note
+					}
+					if req.Steps != nil {
 						tc.Steps = *req.Steps
 						// Enforce order
 						for k := range tc.Steps {
 							tc.Steps[k].Order = k + 1
 						}
 					}
-					
+
 					tc.UpdatedAt = time.Now().Format(time.RFC3339)
 					updated = true
 					break
@@ -374,7 +327,7 @@ func UpdateTestCase(c *gin.Context) {
 
 	scenario.UpdatedAt = time.Now()
 	scenario.ComputeStats()
-	saveScenario(ctx, &scenario)
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionUpdateTestCase, summary)
 
 	scenario.Sheets = nil
 	c.JSON(http.StatusOK, scenario)
@@ -437,7 +390,7 @@ func ReorderTestCases(c *gin.Context) {
 	}
 
 	scenario.UpdatedAt = time.Now()
-	saveScenario(ctx, &scenario)
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionReorderTestCases, "Reordered test cases")
 
 	scenario.Sheets = nil
 	c.JSON(http.StatusOK, scenario)
@@ -462,10 +415,11 @@ func AddTestCase(c *gin.Context) {
 	}
 
 	updated := false
+	summary := ""
 	for i := range scenario.Sections {
 		if scenario.Sections[i].ID == sectionID {
 			now := time.Now().Format(time.RFC3339)
-			
+
 			// Enforce step orders
 			for k := range req.Steps {
 				req.Steps[k].Order = k + 1
@@ -475,19 +429,7 @@ func AddTestCase(c *gin.Context) {
 			}
 
 			// Generate TC-XXX code
-			maxCode := 0
-			for _, s := range scenario.Sections {
-				for _, tc := range s.TestCases {
-					if strings.HasPrefix(tc.Code, "TC-") {
-						var num int
-						fmt.Sscanf(tc.Code, "TC-%d", &num)
-						if num > maxCode {
-							maxCode = num
-						}
-					}
-				}
-			}
-			code := fmt.Sprintf("TC-%03d", maxCode+1)
+			code := fmt.Sprintf("TC-%03d", nextTestCaseNumber(&scenario))
 
 			newTC := models.TestCase{
 				ID:           models.NewTestCaseID(),
@@ -505,11 +447,18 @@ func AddTestCase(c *gin.Context) {
 				UpdatedAt:    now,
 			}
 
-			if newTC.Priority == "" { newTC.Priority = models.PriorityMedium }
-			if newTC.Type == "" { newTC.Type = "positive" }
-			if newTC.Status == "" { newTC.Status = models.TCStatusDraft }
+			if newTC.Priority == "" {
+				newTC.Priority = models.PriorityMedium
+			}
+			if newTC.Type == "" {
+				newTC.Type = "positive"
+			}
+			if newTC.Status == "" {
+				newTC.Status = models.TCStatusDraft
+			}
 
 			scenario.Sections[i].TestCases = append(scenario.Sections[i].TestCases, newTC)
+			summary = fmt.Sprintf("Added %s", newTC.Code)
 			updated = true
 			break
 		}
@@ -522,7 +471,7 @@ func AddTestCase(c *gin.Context) {
 
 	scenario.UpdatedAt = time.Now()
 	scenario.ComputeStats()
-	saveScenario(ctx, &scenario)
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionAddTestCase, summary)
 
 	scenario.Sheets = nil
 	c.JSON(http.StatusOK, scenario)
@@ -542,12 +491,14 @@ func DeleteTestCase(c *gin.Context) {
 	}
 
 	updated := false
+	summary := ""
 	for i := range scenario.Sections {
 		if scenario.Sections[i].ID == sectionID {
 			var newCases []models.TestCase
 			for j := range scenario.Sections[i].TestCases {
 				tc := &scenario.Sections[i].TestCases[j]
 				if tc.ID == tcID {
+					summary = fmt.Sprintf("Deleted %s", tc.Code)
 					updated = true
 				} else {
 					tc.Order = len(newCases) + 1
@@ -566,7 +517,7 @@ func DeleteTestCase(c *gin.Context) {
 
 	scenario.UpdatedAt = time.Now()
 	scenario.ComputeStats()
-	saveScenario(ctx, &scenario)
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionDeleteTestCase, summary)
 
 	scenario.Sheets = nil
 	c.JSON(http.StatusOK, scenario)
@@ -589,10 +540,12 @@ func DeleteScenario(c *gin.Context) {
 	}
 
 	database.RedisClient.SRem(ctx, "scenarios", id)
+	database.ScenarioIndex.Remove(ctx, id)
+	deleteScenarioVersions(ctx, id)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "scenario deleted successfully",
-		"id":    id,
+		"id":      id,
 	})
 }
 
@@ -630,6 +583,8 @@ func BulkDeleteScenarios(c *gin.Context) {
 		}
 
 		database.RedisClient.SRem(ctx, "scenarios", id)
+		database.ScenarioIndex.Remove(ctx, id)
+		deleteScenarioVersions(ctx, id)
 		deletedCount++
 	}
 
@@ -659,7 +614,7 @@ func GenerateTests(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	
+
 	if len(req.SheetNames) == 0 && len(req.SectionIDs) == 0 && len(req.TestCaseIDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "must provide sheetNames, sectionIds, or testCaseIds"})
 		return
@@ -674,7 +629,7 @@ func GenerateTests(c *gin.Context) {
 
 	// Resolve the list of target test case IDs based on the request
 	var targetTestCaseIDs []string
-	
+
 	if len(req.TestCaseIDs) > 0 {
 		targetTestCaseIDs = req.TestCaseIDs
 	} else if len(req.SectionIDs) > 0 {
@@ -726,12 +681,14 @@ func GenerateTests(c *gin.Context) {
 		return
 	}
 
+	authorID := currentAuthorID(c)
+
 	c.JSON(http.StatusAccepted, gin.H{"message": "generation started", "id": id})
 
 	go func(scenario *models.TestScenario, targetIDs []string, gitlabClient interface{}) {
-		bgCtx := context.Background()
+		bgCtx := database.WithStreamOwner(context.Background(), database.StreamOwner{UserID: authorID, ProjectID: scenario.ProjectID})
 		events := agent.NewGenerationEmitter(bgCtx, id)
-		
+
 		clientObj, _ := client.GetClient(bgCtx, token, nil)
 		if clientObj == nil {
 			clientObj = gitlabClient.(*gitlab.Client)
@@ -823,7 +780,8 @@ func GenerateTests(c *gin.Context) {
 		s.Status = models.ScenarioStatusReady
 		s.Error = ""
 		s.ComputeStats()
-		saveScenario(bgCtx, &s)
+		saveScenarioVersioned(bgCtx, &s, authorID, models.VersionActionGeneration,
+			fmt.Sprintf("Generated %d automation test%s", len(allAutomations), pluralize(len(allAutomations))))
 
 		events.Done("Successfully generated %d automation test%s for '%s'",
 			len(allAutomations), pluralize(len(allAutomations)), projectName)
@@ -834,6 +792,17 @@ func GenerateTests(c *gin.Context) {
 // Helpers
 // ─────────────────────────────────────────────
 
+// maskAuthConfig hides inline credentials of scenarios that could not be moved
+// into the credential store yet
+func maskAuthConfig(auth *models.AuthConfig) {
+	if auth.Password != "" {
+		auth.Password = "********"
+	}
+	if auth.Username != "" {
+		auth.Username = models.MaskUsername(auth.Username)
+	}
+}
+
 func getScenario(ctx context.Context, id string) (models.TestScenario, error) {
 	var scenario models.TestScenario
 	val, err := database.RedisClient.Get(ctx, fmt.Sprintf("scenario:%s", id)).Result()
@@ -845,11 +814,35 @@ func getScenario(ctx context.Context, id string) (models.TestScenario, error) {
 }
 
 func saveScenario(ctx context.Context, scenario *models.TestScenario) error {
+	if err := database.SealScenarioCredentials(ctx, scenario); err != nil {
+		return fmt.Errorf("failed to store scenario credentials: %w", err)
+	}
 	val, err := json.Marshal(scenario)
 	if err != nil {
 		return err
 	}
-	return database.RedisClient.Set(ctx, fmt.Sprintf("scenario:%s", scenario.ID), val, 0).Err()
+	if err := database.RedisClient.Set(ctx, fmt.Sprintf("scenario:%s", scenario.ID), val, 0).Err(); err != nil {
+		return err
+	}
+	database.IndexScenario(ctx, scenario)
+	return nil
+}
+
+// createScenario stores a new scenario and registers it in the scenario index sets
+func createScenario(ctx context.Context, scenario *models.TestScenario) error {
+	if err := saveScenario(ctx, scenario); err != nil {
+		return err
+	}
+	if err := recordScenarioVersion(ctx, scenario, scenario.CreatorID, models.VersionActionCreated, scenario.Description); err != nil {
+		log.Printf("[ScenarioVersion] failed to record initial version for %s: %v", scenario.ID, err)
+	}
+	database.RedisClient.SAdd(ctx, "scenarios", scenario.ID)
+	if scenario.CreatorID != 0 {
+		database.RedisClient.SAdd(ctx, fmt.Sprintf("scenarios:user:%d", scenario.CreatorID), scenario.ID)
+	} else {
+		database.RedisClient.SAdd(ctx, "scenarios:legacy", scenario.ID)
+	}
+	return nil
 }
 
 func setTestCasesAutomationStatus(ctx context.Context, scenarioID string, targetTestCaseIDs []string, status models.AutomationRunStatus) {
@@ -885,6 +878,7 @@ func setTestCasesAutomationStatus(ctx context.Context, scenarioID string, target
 }
 
 // RunScenarioTestCase runs a single test case's automation from a scenario.
+// Query params: cycleId (record the result against this test cycle only)
 func RunScenarioTestCase(c *gin.Context) {
 	scenarioID := c.Param("id")
 	sectionID := c.Param("sectionId")
@@ -932,6 +926,25 @@ func RunScenarioTestCase(c *gin.Context) {
 		return
 	}
 
+	env, err := resolveRunEnvironment(ctx, scenario.ProjectID, c.Query("environment"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := automationRunOptions{
+		CycleID:     c.Query("cycleId"),
+		GitLab:      backgroundGitLabClient(c),
+		Environment: env,
+		Auth:        scenario.AuthConfig,
+		ProjectID:   scenario.ProjectID,
+		Owner:       database.StreamOwner{UserID: currentAuthorID(c), ProjectID: scenario.ProjectID},
+	}
+	automation := runnableAutomation(targetCase)
+	if _, err := opts.prepareRun(automation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Mark as running and save
 	scenario.Sections[targetSectionIdx].TestCases[targetCaseIdx].AutomationTest.Status = models.AutomationStatusRunning
 	scenario.Sections[targetSectionIdx].TestCases[targetCaseIdx].AutomationTest.LastRunAt = time.Now().Format(time.RFC3339)
@@ -939,68 +952,128 @@ func RunScenarioTestCase(c *gin.Context) {
 	_ = saveScenario(ctx, &scenario)
 
 	// Run in goroutine so HTTP doesn't block
-	go func() {
-		bgCtx := context.Background()
-		run := &models.TestRun{
-			ID:    targetCase.AutomationTest.ID,
-			Name:  targetCase.AutomationTest.Name,
-			Steps: targetCase.AutomationTest.Steps,
-		}
+	go runScenarioAutomation(scenarioID, sectionID, tcID, automation, opts)
 
-		timeoutCtx, cancel := context.WithTimeout(bgCtx, 5*time.Minute)
-		defer cancel()
+	resp := gin.H{
+		"message": "test execution started",
+		"id":      tcID,
+	}
+	if env != nil {
+		resp["environment"] = env.Ref()
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
 
-		result, err := agent.RunTest(timeoutCtx, run)
+// automationRunOptions controls where the result of an automation run is reported
+type automationRunOptions struct {
+	CycleID     string               // record against this test cycle only
+	GitLab      *gitlab.Client       // when set, failures are posted to linked issues
+	Environment *models.Environment  // when set, steps are rewritten for this environment
+	Auth        models.AuthConfig    // the scenario's own URLs and account, rewritten from
+	ProjectID   string               // the scenario's project, whose credentials the steps may use
+	Owner       database.StreamOwner // who the run's stream events are delivered to
+}
 
-		// Re-fetch scenario to avoid overwriting concurrent changes
-		scenario, fetchErr := getScenario(bgCtx, scenarioID)
-		if fetchErr != nil {
-			log.Printf("[RunScenarioTestCase] failed to re-fetch scenario after run: %v", fetchErr)
-			return
+// prepareRun builds the runner input for an automation, rewritten for the
+// chosen environment
+func (o automationRunOptions) prepareRun(automation models.AutomationTest) (*models.TestRun, error) {
+	run := &models.TestRun{
+		ID:        automation.ID,
+		Name:      automation.Name,
+		Steps:     automation.Steps,
+		ProjectID: o.ProjectID,
+	}
+	if o.Environment != nil {
+		if err := services.ApplyEnvironment(run, o.Auth, o.Environment); err != nil {
+			return nil, fmt.Errorf("cannot run against %s: %w", o.Environment.Name, err)
 		}
+	}
+	return run, nil
+}
 
-		// Find the test case again in the refreshed scenario
-		for si := range scenario.Sections {
-			if scenario.Sections[si].ID != sectionID {
-				continue
-			}
-			for ti := range scenario.Sections[si].TestCases {
-				at := scenario.Sections[si].TestCases[ti].AutomationTest
-				if at != nil && at.ID == targetCase.AutomationTest.ID {
-					if err != nil {
-						scenario.Sections[si].TestCases[ti].AutomationTest.Status = models.AutomationStatusFail
-						scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = err.Error()
-					} else {
-						scenario.Sections[si].TestCases[ti].AutomationTest.Status = mapResultStatus(result.Status)
-						scenario.Sections[si].TestCases[ti].AutomationTest.RunDurationMs = result.RunDurationMs
-						scenario.Sections[si].TestCases[ti].AutomationTest.VideoURL = result.VideoURL
-						scenario.Sections[si].TestCases[ti].AutomationTest.StepResults = result.StepResults
-						scenario.Sections[si].TestCases[ti].AutomationTest.Log = result.Log
-						scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = ""
-						scenario.Sections[si].TestCases[ti].AutomationTest.FailedStepIndex = nil
-						if result.Status == "failed" && len(result.StepResults) > 0 {
-							for _, sr := range result.StepResults {
-								if sr.Status == "failure" {
-									scenario.Sections[si].TestCases[ti].AutomationTest.FailedStepIndex = &sr.StepIndex
-									scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = sr.Error
-									break
-								}
+// runnableAutomation returns a copy of the test case's automation to run. When
+// the automation carries no data-driven rows of its own, the test case's
+// Examples rows are used, so automations linked before the rows were copied
+// still run once per row.
+func runnableAutomation(tc *models.TestCase) models.AutomationTest {
+	automation := *tc.AutomationTest
+	if len(automation.Parameters) == 0 {
+		automation.Parameters = tc.Parameters
+	}
+	return automation
+}
+
+// runScenarioAutomation executes a test case's automation and stores the result on
+// the scenario, on any test cycle tracking the case and on linked GitLab issues.
+// It returns the updated automation, or nil when the test case is gone.
+func runScenarioAutomation(scenarioID, sectionID, tcID string, automation models.AutomationTest, opts automationRunOptions) *models.AutomationTest {
+	bgCtx := database.WithStreamOwner(context.Background(), opts.Owner)
+
+	var result *models.TestResult
+	run, err := opts.prepareRun(automation)
+	if err == nil {
+		result, err = services.RunWithParameters(bgCtx, run, automation.Parameters, func(ctx context.Context, run *models.TestRun) (*models.TestResult, error) {
+			timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			defer cancel()
+			return agent.RunTest(timeoutCtx, run)
+		})
+	}
+	if err == nil {
+		_ = database.SaveTestResult(bgCtx, result)
+	}
+
+	// Re-fetch scenario to avoid overwriting concurrent changes
+	scenario, fetchErr := getScenario(bgCtx, scenarioID)
+	if fetchErr != nil {
+		log.Printf("[RunScenarioTestCase] failed to re-fetch scenario after run: %v", fetchErr)
+		return nil
+	}
+
+	// Find the test case again in the refreshed scenario
+	var updated *models.AutomationTest
+	for si := range scenario.Sections {
+		if scenario.Sections[si].ID != sectionID {
+			continue
+		}
+		for ti := range scenario.Sections[si].TestCases {
+			at := scenario.Sections[si].TestCases[ti].AutomationTest
+			if at != nil && at.ID == automation.ID {
+				if err != nil {
+					scenario.Sections[si].TestCases[ti].AutomationTest.Status = models.AutomationStatusFail
+					scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = err.Error()
+				} else {
+					scenario.Sections[si].TestCases[ti].AutomationTest.Status = mapResultStatus(result.Status)
+					scenario.Sections[si].TestCases[ti].AutomationTest.RunDurationMs = result.RunDurationMs
+					scenario.Sections[si].TestCases[ti].AutomationTest.VideoURL = result.VideoURL
+					scenario.Sections[si].TestCases[ti].AutomationTest.StepResults = result.StepResults
+					scenario.Sections[si].TestCases[ti].AutomationTest.Log = result.Log
+					scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = ""
+					scenario.Sections[si].TestCases[ti].AutomationTest.FailedStepIndex = nil
+					scenario.Sections[si].TestCases[ti].AutomationTest.Environment = result.Environment
+					if result.Status == "failed" && len(result.StepResults) > 0 {
+						for _, sr := range result.StepResults {
+							if sr.Status == "failure" {
+								scenario.Sections[si].TestCases[ti].AutomationTest.FailedStepIndex = &sr.StepIndex
+								scenario.Sections[si].TestCases[ti].AutomationTest.ErrorMessage = sr.Error
+								break
 							}
 						}
 					}
-					break
 				}
+				updated = scenario.Sections[si].TestCases[ti].AutomationTest
+				break
 			}
 		}
+	}
 
-		scenario.ComputeStats()
-		_ = saveScenario(bgCtx, &scenario)
-	}()
+	scenario.ComputeStats()
+	_ = saveScenario(bgCtx, &scenario)
 
-	c.JSON(http.StatusAccepted, gin.H{
-		"message": "test execution started",
-		"id":      tcID,
-	})
+	if updated != nil {
+		recordAutomationExecution(bgCtx, scenarioID, tcID, opts.CycleID, updated)
+		notifyLinkedIssues(opts.GitLab, &scenario, tcID, updated)
+	}
+	return updated
 }
 
 func mapResultStatus(s string) models.AutomationRunStatus {
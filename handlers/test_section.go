@@ -0,0 +1,627 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"qa-extension-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ─────────────────────────────────────────────
+// Section helpers
+// ─────────────────────────────────────────────
+
+func findSectionIndex(scenario *models.TestScenario, sectionID string) int {
+	for i := range scenario.Sections {
+		if scenario.Sections[i].ID == sectionID {
+			return i
+		}
+	}
+	return -1
+}
+
+func renumberSections(scenario *models.TestScenario) {
+	for i := range scenario.Sections {
+		scenario.Sections[i].Order = i + 1
+	}
+}
+
+func renumberTestCases(section *models.TestSection) {
+	for i := range section.TestCases {
+		section.TestCases[i].Order = i + 1
+	}
+}
+
+// nextTestCaseNumber returns the number for the next TC-XXX code in a scenario
+func nextTestCaseNumber(scenario *models.TestScenario) int {
+	maxCode := 0
+	for _, s := range scenario.Sections {
+		for _, tc := range s.TestCases {
+			if strings.HasPrefix(tc.Code, "TC-") {
+				var num int
+				fmt.Sscanf(tc.Code, "TC-%d", &num)
+				if num > maxCode {
+					maxCode = num
+				}
+			}
+		}
+	}
+	return maxCode + 1
+}
+
+func hasTestCaseID(scenario *models.TestScenario, tcID string) bool {
+	for _, sec := range scenario.Sections {
+		for _, tc := range sec.TestCases {
+			if tc.ID == tcID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// takeTestCases removes the requested test cases from a scenario, in request order
+func takeTestCases(scenario *models.TestScenario, ids []string) []models.TestCase {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	found := make(map[string]models.TestCase)
+	for i := range scenario.Sections {
+		kept := []models.TestCase{}
+		for _, tc := range scenario.Sections[i].TestCases {
+			if wanted[tc.ID] {
+				found[tc.ID] = tc
+				continue
+			}
+			kept = append(kept, tc)
+		}
+		scenario.Sections[i].TestCases = kept
+		renumberTestCases(&scenario.Sections[i])
+	}
+
+	var taken []models.TestCase
+	for _, id := range ids {
+		if tc, ok := found[id]; ok {
+			taken = append(taken, tc)
+			delete(found, id)
+		}
+	}
+	return taken
+}
+
+// copyTestCases returns copies of the requested test cases without touching the scenario
+func copyTestCases(scenario *models.TestScenario, ids []string) []models.TestCase {
+	byID := make(map[string]models.TestCase)
+	for _, sec := range scenario.Sections {
+		for _, tc := range sec.TestCases {
+			byID[tc.ID] = tc
+		}
+	}
+
+	var copies []models.TestCase
+	for _, id := range ids {
+		if tc, ok := byID[id]; ok {
+			copies = append(copies, tc)
+		}
+	}
+	return copies
+}
+
+// insertTestCases places test cases into a section at a 1-based position (0 appends)
+func insertTestCases(section *models.TestSection, cases []models.TestCase, position int) {
+	idx := len(section.TestCases)
+	if position > 0 && position-1 < idx {
+		idx = position - 1
+	}
+	merged := make([]models.TestCase, 0, len(section.TestCases)+len(cases))
+	merged = append(merged, section.TestCases[:idx]...)
+	merged = append(merged, cases...)
+	merged = append(merged, section.TestCases[idx:]...)
+	section.TestCases = merged
+	renumberTestCases(section)
+}
+
+// cloneTestCase gives a test case a fresh identity. Automation steps are kept but
+// run results are reset, since they belong to the original.
+func cloneTestCase(tc models.TestCase, code string) models.TestCase {
+	now := time.Now().Format(time.RFC3339)
+	clone := tc
+	clone.ID = models.NewTestCaseID()
+	clone.Code = code
+	clone.Tags = append([]string(nil), tc.Tags...)
+	clone.CreatedAt = now
+	clone.UpdatedAt = now
+
+	clone.Steps = make([]models.TestStepV2, len(tc.Steps))
+	for i, s := range tc.Steps {
+		s.ID = fmt.Sprintf("%s-%d", models.NewTestStepID(), i)
+		clone.Steps[i] = s
+	}
+
+	if tc.AutomationTest != nil {
+		clone.AutomationTest = &models.AutomationTest{
+			ID:         fmt.Sprintf("%s-auto", clone.ID),
+			Name:       tc.AutomationTest.Name,
+			Framework:  tc.AutomationTest.Framework,
+			Status:     models.AutomationStatusIdle,
+			Steps:      append([]models.RecordingStep(nil), tc.AutomationTest.Steps...),
+			Parameters: append([]any(nil), tc.AutomationTest.Parameters...),
+		}
+	}
+	return clone
+}
+
+// findSheetCase returns the parsed source row behind a test case, if any
+func findSheetCase(scenario *models.TestScenario, tcID string) (models.ParsedTestCase, bool) {
+	for _, sheet := range scenario.Sheets {
+		for _, ptc := range sheet.TestCases {
+			if ptc.ID == tcID {
+				return ptc, true
+			}
+		}
+	}
+	return models.ParsedTestCase{}, false
+}
+
+// carrySheetCase copies the parsed source row of a test case into the target
+// scenario so that generation can still find it after a move or copy.
+func carrySheetCase(source, target *models.TestScenario, oldID, newID, sheetName string) {
+	ptc, ok := findSheetCase(source, oldID)
+	if !ok {
+		return
+	}
+	ptc.ID = newID
+	for i := range target.Sheets {
+		if target.Sheets[i].Name == sheetName {
+			target.Sheets[i].TestCases = append(target.Sheets[i].TestCases, ptc)
+			return
+		}
+	}
+	target.Sheets = append(target.Sheets, models.TestScenarioSheet{Name: sheetName, TestCases: []models.ParsedTestCase{ptc}})
+}
+
+// ─────────────────────────────────────────────
+// Section handlers
+// ─────────────────────────────────────────────
+
+// CreateSection handles POST /test-scenarios/:id/sections
+func CreateSection(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.CreateSectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	section := models.TestSection{
+		ID:          models.NewSectionID(),
+		Order:       len(scenario.Sections) + 1,
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		TestCases:   []models.TestCase{},
+	}
+	scenario.Sections = append(scenario.Sections, section)
+
+	scenario.UpdatedAt = time.Now()
+	scenario.ComputeStats()
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionCreateSection, fmt.Sprintf("Added section %q", section.Title))
+
+	scenario.Sheets = nil
+	c.JSON(http.StatusOK, scenario)
+}
+
+// UpdateSection handles PATCH /test-scenarios/:id/sections/:sectionId
+func UpdateSection(c *gin.Context) {
+	id := c.Param("id")
+	sectionID := c.Param("sectionId")
+
+	var req models.UpdateSectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title cannot be empty"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	idx := findSectionIndex(&scenario, sectionID)
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+
+	section := &scenario.Sections[idx]
+	summary := fmt.Sprintf("Updated section %q", section.Title)
+	if req.Title != nil {
+		newTitle := strings.TrimSpace(*req.Title)
+		if newTitle != section.Title {
+			summary = fmt.Sprintf("Renamed section %q to %q", section.Title, newTitle)
+		}
+		section.Title = newTitle
+	}
+	if req.Description != nil {
+		section.Description = *req.Description
+	}
+
+	scenario.UpdatedAt = time.Now()
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionUpdateSection, summary)
+
+	scenario.Sheets = nil
+	c.JSON(http.StatusOK, scenario)
+}
+
+// ReorderSections handles PATCH /test-scenarios/:id/sections/reorder
+func ReorderSections(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.ReorderSectionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	secMap := make(map[string]models.TestSection, len(scenario.Sections))
+	for _, sec := range scenario.Sections {
+		secMap[sec.ID] = sec
+	}
+
+	var newSections []models.TestSection
+	for _, secID := range req.OrderedIDs {
+		if sec, ok := secMap[secID]; ok {
+			newSections = append(newSections, sec)
+			delete(secMap, secID)
+		}
+	}
+
+	// Append any remaining sections in their existing order
+	for _, sec := range scenario.Sections {
+		if _, ok := secMap[sec.ID]; ok {
+			newSections = append(newSections, sec)
+		}
+	}
+
+	scenario.Sections = newSections
+	renumberSections(&scenario)
+
+	scenario.UpdatedAt = time.Now()
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionReorderSections, "Reordered sections")
+
+	scenario.Sheets = nil
+	c.JSON(http.StatusOK, scenario)
+}
+
+// DeleteSection handles DELETE /test-scenarios/:id/sections/:sectionId
+// Query params: moveTo (section ID that receives the test cases; otherwise they are deleted)
+func DeleteSection(c *gin.Context) {
+	id := c.Param("id")
+	sectionID := c.Param("sectionId")
+	moveTo := c.Query("moveTo")
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+	if scenario.Status == models.ScenarioStatusGenerating {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot delete a section while generation is running"})
+		return
+	}
+
+	idx := findSectionIndex(&scenario, sectionID)
+	if idx < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+	if moveTo == sectionID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "moveTo must be a different section"})
+		return
+	}
+
+	removed := scenario.Sections[idx]
+	summary := fmt.Sprintf("Deleted section %q with %d test case%s", removed.Title, len(removed.TestCases), pluralize(len(removed.TestCases)))
+
+	if moveTo != "" {
+		targetIdx := findSectionIndex(&scenario, moveTo)
+		if targetIdx < 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "moveTo section not found"})
+			return
+		}
+		insertTestCases(&scenario.Sections[targetIdx], removed.TestCases, 0)
+		summary = fmt.Sprintf("Deleted section %q, moved %d test case%s to %q", removed.Title, len(removed.TestCases), pluralize(len(removed.TestCases)), scenario.Sections[targetIdx].Title)
+	}
+
+	scenario.Sections = append(scenario.Sections[:idx], scenario.Sections[idx+1:]...)
+	renumberSections(&scenario)
+
+	scenario.UpdatedAt = time.Now()
+	scenario.ComputeStats()
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionDeleteSection, summary)
+
+	scenario.Sheets = nil
+	c.JSON(http.StatusOK, scenario)
+}
+
+// ─────────────────────────────────────────────
+// Moving and bulk-editing test cases
+// ─────────────────────────────────────────────
+
+// MoveTestCases handles POST /test-scenarios/:id/test-cases/move
+// Moves or copies test cases into a section of this or another scenario. Copies
+// get a new ID and code; cross-scenario moves keep their ID unless it collides.
+func MoveTestCases(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.MoveTestCasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.TestCaseIDs) == 0 || req.TargetSectionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "testCaseIds and targetSectionId are required"})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = "move"
+	}
+	if req.Mode != "move" && req.Mode != "copy" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be 'move' or 'copy'"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	source, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	crossScenario := req.TargetScenarioID != "" && req.TargetScenarioID != source.ID
+	target := &source
+	if crossScenario {
+		other, err := getScenario(ctx, req.TargetScenarioID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "target scenario not found"})
+			return
+		}
+		target = &other
+	}
+	if source.Status == models.ScenarioStatusGenerating || target.Status == models.ScenarioStatusGenerating {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot move test cases while generation is running"})
+		return
+	}
+
+	if findSectionIndex(target, req.TargetSectionID) < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "target section not found"})
+		return
+	}
+
+	var cases []models.TestCase
+	if req.Mode == "copy" {
+		cases = copyTestCases(&source, req.TestCaseIDs)
+	} else {
+		cases = takeTestCases(&source, req.TestCaseIDs)
+	}
+	if len(cases) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "none of the test cases were found"})
+		return
+	}
+
+	// Section lookup happens after taking, since a same-scenario move rewrites the slices
+	targetIdx := findSectionIndex(target, req.TargetSectionID)
+	sheetName := target.Sections[targetIdx].Title
+
+	nextNum := nextTestCaseNumber(target)
+	for i, tc := range cases {
+		switch {
+		case req.Mode == "copy":
+			cases[i] = cloneTestCase(tc, fmt.Sprintf("TC-%03d", nextNum))
+			nextNum++
+		case crossScenario:
+			if hasTestCaseID(target, tc.ID) {
+				cases[i].ID = models.NewTestCaseID()
+			}
+			cases[i].Code = fmt.Sprintf("TC-%03d", nextNum)
+			cases[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			nextNum++
+		default:
+			cases[i].UpdatedAt = time.Now().Format(time.RFC3339)
+		}
+		if cases[i].ID != tc.ID || crossScenario {
+			carrySheetCase(&source, target, tc.ID, cases[i].ID, sheetName)
+		}
+	}
+	insertTestCases(&target.Sections[targetIdx], cases, req.Position)
+
+	action := models.VersionActionMoveTestCases
+	verb := "Moved"
+	if req.Mode == "copy" {
+		action = models.VersionActionCopyTestCases
+		verb = "Copied"
+	}
+	summary := fmt.Sprintf("%s %d test case%s to %q", verb, len(cases), pluralize(len(cases)), sheetName)
+
+	authorID := currentAuthorID(c)
+	now := time.Now()
+	target.UpdatedAt = now
+	target.ComputeStats()
+	if err := saveScenarioVersioned(ctx, target, authorID, action, summary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save scenario"})
+		return
+	}
+	if crossScenario && req.Mode == "move" {
+		source.UpdatedAt = now
+		source.ComputeStats()
+		sourceSummary := fmt.Sprintf("Moved %d test case%s to scenario %q", len(cases), pluralize(len(cases)), target.Title)
+		if err := saveScenarioVersioned(ctx, &source, authorID, action, sourceSummary); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save source scenario"})
+			return
+		}
+	}
+
+	ids := make([]string, len(cases))
+	for i, tc := range cases {
+		ids[i] = tc.ID
+		indexTestCaseLinks(ctx, target.ID, tc)
+	}
+
+	target.Sheets = nil
+	c.JSON(http.StatusOK, gin.H{
+		"message":     fmt.Sprintf("%s %d test case%s", strings.ToLower(verb), len(cases), pluralize(len(cases))),
+		"testCaseIds": ids,
+		"scenario":    target,
+	})
+}
+
+// BulkTestCases handles POST /test-scenarios/:id/test-cases/bulk
+// action "delete" removes the test cases; action "update" applies tags, priority,
+// status and type changes to all of them. The whole batch is one version.
+func BulkTestCases(c *gin.Context) {
+	id := c.Param("id")
+
+	var req models.BulkTestCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.TestCaseIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "testCaseIds array is required and cannot be empty"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	var affected int
+	var summary string
+	switch req.Action {
+	case "delete":
+		if scenario.Status == models.ScenarioStatusGenerating {
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot delete test cases while generation is running"})
+			return
+		}
+		affected = len(takeTestCases(&scenario, req.TestCaseIDs))
+		summary = fmt.Sprintf("Deleted %d test case%s", affected, pluralize(affected))
+
+	case "update":
+		if req.Tags == nil && req.Priority == nil && req.Status == nil && req.Type == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to update: provide tags, priority, status or type"})
+			return
+		}
+		if req.Priority != nil && !req.Priority.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid priority %q: must be low, medium, high or critical", *req.Priority)})
+			return
+		}
+		if req.Status != nil && !req.Status.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid status %q: must be draft, ready, blocked or deprecated", *req.Status)})
+			return
+		}
+		wanted := make(map[string]bool, len(req.TestCaseIDs))
+		for _, tcID := range req.TestCaseIDs {
+			wanted[tcID] = true
+		}
+		now := time.Now().Format(time.RFC3339)
+		for i := range scenario.Sections {
+			for j := range scenario.Sections[i].TestCases {
+				tc := &scenario.Sections[i].TestCases[j]
+				if !wanted[tc.ID] {
+					continue
+				}
+				if req.Tags != nil {
+					tc.Tags = applyTagUpdate(tc.Tags, *req.Tags)
+				}
+				if req.Priority != nil {
+					tc.Priority = *req.Priority
+				}
+				if req.Status != nil {
+					tc.Status = *req.Status
+				}
+				if req.Type != nil {
+					tc.Type = *req.Type
+				}
+				tc.UpdatedAt = now
+				affected++
+			}
+		}
+		summary = fmt.Sprintf("Bulk updated %d test case%s", affected, pluralize(affected))
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be 'delete' or 'update'"})
+		return
+	}
+
+	if affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "none of the test cases were found"})
+		return
+	}
+
+	scenario.UpdatedAt = time.Now()
+	scenario.ComputeStats()
+	saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionBulkUpdate, summary)
+
+	scenario.Sheets = nil
+	c.JSON(http.StatusOK, gin.H{
+		"message":  summary,
+		"affected": affected,
+		"scenario": scenario,
+	})
+}
+
+func applyTagUpdate(tags []string, update models.TagUpdate) []string {
+	if update.Set != nil {
+		tags = append([]string(nil), (*update.Set)...)
+	}
+
+	remove := make(map[string]bool, len(update.Remove))
+	for _, t := range update.Remove {
+		remove[strings.ToLower(t)] = true
+	}
+
+	result := make([]string, 0, len(tags)+len(update.Add))
+	seen := make(map[string]bool)
+	for _, t := range append(tags, update.Add...) {
+		key := strings.ToLower(strings.TrimSpace(t))
+		if key == "" || remove[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, strings.TrimSpace(t))
+	}
+	return result
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+func linkParentID(link models.IssueLink) string {
+	if link.Type == models.IssueLinkEpic {
+		return link.GroupID
+	}
+	return link.ProjectID
+}
+
+// indexTestCaseLinks adds a test case to the reverse index of every issue it links
+func indexTestCaseLinks(ctx context.Context, scenarioID string, tc models.TestCase) {
+	for _, l := range tc.Issues {
+		database.RedisClient.SAdd(ctx, database.TraceabilityKey(l.Type, linkParentID(l), l.IID), database.TraceabilityMember(scenarioID, tc.ID))
+	}
+}
+
+// reindexScenarioLinks brings the reverse index in line with a scenario whose
+// test cases were replaced wholesale, e.g. by a version restore. Links that
+// only the previous scenario had are removed and every current link is added.
+func reindexScenarioLinks(ctx context.Context, previous, current *models.TestScenario) {
+	kept := make(map[string]bool)
+	for _, sec := range current.Sections {
+		for _, tc := range sec.TestCases {
+			for _, l := range tc.Issues {
+				kept[database.TraceabilityKey(l.Type, linkParentID(l), l.IID)+" "+tc.ID] = true
+			}
+			indexTestCaseLinks(ctx, current.ID, tc)
+		}
+	}
+	for _, sec := range previous.Sections {
+		for _, tc := range sec.TestCases {
+			for _, l := range tc.Issues {
+				key := database.TraceabilityKey(l.Type, linkParentID(l), l.IID)
+				if !kept[key+" "+tc.ID] {
+					database.RedisClient.SRem(ctx, key, database.TraceabilityMember(previous.ID, tc.ID))
+				}
+			}
+		}
+	}
+}
+
+// backgroundGitLabClient builds a GitLab client for the current session that stays
+// usable after the request has finished. Returns nil when there is no session token.
+func backgroundGitLabClient(c *gin.Context) *gitlab.Client {
+	token, ok := c.Get("token")
+	if !ok {
+		return nil
+	}
+	oauthToken, ok := token.(*oauth2.Token)
+	if !ok {
+		return nil
+	}
+	sessionID := c.GetString("session_id")
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		if sessionID == "" {
+			return nil
+		}
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	gitlabClient, err := client.GetClient(context.Background(), oauthToken, tokenSaver)
+	if err != nil {
+		return nil
+	}
+	return gitlabClient
+}
+
+// findTestCaseLocation returns the section and test case indexes of a test case
+func findTestCaseLocation(scenario *models.TestScenario, tcID string) (int, int) {
+	for si := range scenario.Sections {
+		for ti := range scenario.Sections[si].TestCases {
+			if scenario.Sections[si].TestCases[ti].ID == tcID {
+				return si, ti
+			}
+		}
+	}
+	return -1, -1
+}
+
+// linkedTestCases resolves the reverse index of an issue or epic into test cases.
+// Stale members are dropped lazily as they are read.
+func linkedTestCases(ctx context.Context, linkType, parentID string, iid int64) []models.LinkedTestCase {
+	key := database.TraceabilityKey(linkType, parentID, iid)
+	members, err := database.RedisClient.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil
+	}
+
+	scenarios := make(map[string]*models.TestScenario)
+	var linked []models.LinkedTestCase
+	for _, m := range members {
+		scenarioID, tcID, ok := strings.Cut(m, "|")
+		if !ok {
+			continue
+		}
+
+		scenario, cached := scenarios[scenarioID]
+		if !cached {
+			if s, err := getScenario(ctx, scenarioID); err == nil {
+				scenario = &s
+			}
+			scenarios[scenarioID] = scenario
+		}
+
+		si, ti := -1, -1
+		if scenario != nil {
+			si, ti = findTestCaseLocation(scenario, tcID)
+		}
+		if si < 0 {
+			database.RedisClient.SRem(ctx, key, m)
+			continue
+		}
+
+		sec := scenario.Sections[si]
+		tc := sec.TestCases[ti]
+		stillLinked := false
+		for _, l := range tc.Issues {
+			if l.Matches(linkType, parentID, iid) {
+				stillLinked = true
+				break
+			}
+		}
+		if !stillLinked {
+			database.RedisClient.SRem(ctx, key, m)
+			continue
+		}
+
+		item := models.LinkedTestCase{
+			ScenarioID:    scenario.ID,
+			ScenarioTitle: scenario.Title,
+			SectionID:     sec.ID,
+			SectionTitle:  sec.Title,
+			TestCaseID:    tc.ID,
+			Code:          tc.Code,
+			Title:         tc.Title,
+			Status:        tc.Status,
+		}
+		if tc.AutomationTest != nil {
+			item.AutomationStatus = tc.AutomationTest.Status
+			item.LastRunAt = tc.AutomationTest.LastRunAt
+		}
+		linked = append(linked, item)
+	}
+	return linked
+}
+
+func buildIssueCoverage(ctx context.Context, linkType, parentID string, iid int64) models.IssueCoverage {
+	coverage := models.IssueCoverage{
+		Type:      linkType,
+		ParentID:  parentID,
+		IID:       iid,
+		TestCases: linkedTestCases(ctx, linkType, parentID, iid),
+	}
+	if coverage.TestCases == nil {
+		coverage.TestCases = []models.LinkedTestCase{}
+	}
+
+	if linkType == models.IssueLinkIssue {
+		recordings, _ := database.RedisClient.SInter(ctx,
+			fmt.Sprintf("recordings:issue:%d", iid),
+			fmt.Sprintf("recordings:project:%s", parentID),
+		).Result()
+		coverage.Recordings = recordings
+	}
+
+	for _, tc := range coverage.TestCases {
+		switch tc.AutomationStatus {
+		case models.AutomationStatusPass:
+			coverage.Passing++
+		case models.AutomationStatusFail:
+			coverage.Failing++
+		}
+	}
+	coverage.Covered = len(coverage.TestCases) > 0 || len(coverage.Recordings) > 0
+	return coverage
+}
+
+// LinkTestCaseIssue handles POST /test-scenarios/:id/sections/:sectionId/test-cases/:tcId/issues
+// Body: { type: "issue" | "epic", projectId, groupId, iid }
+func LinkTestCaseIssue(c *gin.Context) {
+	id := c.Param("id")
+	sectionID := c.Param("sectionId")
+	tcID := c.Param("tcId")
+
+	var req models.LinkIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type == "" {
+		req.Type = models.IssueLinkIssue
+	}
+	if req.IID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "iid is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+
+	link := models.IssueLink{
+		Type:     req.Type,
+		IID:      req.IID,
+		LinkedBy: currentAuthorID(c),
+		LinkedAt: time.Now(),
+	}
+
+	switch req.Type {
+	case models.IssueLinkIssue:
+		link.ProjectID = req.ProjectID
+		if link.ProjectID == "" {
+			link.ProjectID = scenario.ProjectID
+		}
+		if link.ProjectID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "projectId is required for scenarios without a project"})
+			return
+		}
+	case models.IssueLinkEpic:
+		if req.GroupID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "groupId is required for epics"})
+			return
+		}
+		link.GroupID = req.GroupID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'issue' or 'epic'"})
+		return
+	}
+
+	token := c.MustGet("token").(*oauth2.Token)
+	sessionID := c.MustGet("session_id").(string)
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	gitlabClient, err := client.GetClient(c, token, tokenSaver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitLab client: " + err.Error()})
+		return
+	}
+
+	if link.Type == models.IssueLinkEpic {
+		epic, _, err := gitlabClient.Epics.GetEpic(link.GroupID, link.IID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("epic &%d not found: %v", link.IID, err)})
+			return
+		}
+		link.Title, link.State, link.WebURL = epic.Title, epic.State, epic.WebURL
+	} else {
+		issue, _, err := gitlabClient.Issues.GetIssue(link.ProjectID, link.IID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("issue #%d not found: %v", link.IID, err)})
+			return
+		}
+		link.Title, link.State, link.WebURL = issue.Title, issue.State, issue.WebURL
+	}
+
+	si := findSectionIndex(&scenario, sectionID)
+	if si < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+	var tc *models.TestCase
+	for ti := range scenario.Sections[si].TestCases {
+		if scenario.Sections[si].TestCases[ti].ID == tcID {
+			tc = &scenario.Sections[si].TestCases[ti]
+			break
+		}
+	}
+	if tc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test case not found"})
+		return
+	}
+
+	replaced := false
+	for i, l := range tc.Issues {
+		if l.Matches(link.Type, linkParentID(link), link.IID) {
+			tc.Issues[i] = link
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		tc.Issues = append(tc.Issues, link)
+	}
+
+	ref := fmt.Sprintf("#%d", link.IID)
+	if link.Type == models.IssueLinkEpic {
+		ref = fmt.Sprintf("&%d", link.IID)
+	}
+	tc.UpdatedAt = time.Now().Format(time.RFC3339)
+	scenario.UpdatedAt = time.Now()
+	if err := saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionUpdateTestCase, fmt.Sprintf("Linked %s to %s", tc.Code, ref)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save scenario"})
+		return
+	}
+	database.RedisClient.SAdd(ctx, database.TraceabilityKey(link.Type, linkParentID(link), link.IID), database.TraceabilityMember(scenario.ID, tc.ID))
+
+	c.JSON(http.StatusOK, gin.H{"testCaseId": tc.ID, "issues": tc.Issues})
+}
+
+// UnlinkTestCaseIssue handles DELETE /test-scenarios/:id/sections/:sectionId/test-cases/:tcId/issues
+// Query params: type ("issue" | "epic"), projectId or groupId, iid
+func UnlinkTestCaseIssue(c *gin.Context) {
+	id := c.Param("id")
+	sectionID := c.Param("sectionId")
+	tcID := c.Param("tcId")
+
+	linkType := c.DefaultQuery("type", models.IssueLinkIssue)
+	parentID := c.Query("projectId")
+	if linkType == models.IssueLinkEpic {
+		parentID = c.Query("groupId")
+	}
+	iid, err := strconv.ParseInt(c.Query("iid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "iid must be a number"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	scenario, err := getScenario(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "scenario not found"})
+		return
+	}
+	if parentID == "" && linkType == models.IssueLinkIssue {
+		parentID = scenario.ProjectID
+	}
+
+	si := findSectionIndex(&scenario, sectionID)
+	if si < 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "section not found"})
+		return
+	}
+
+	var tc *models.TestCase
+	for ti := range scenario.Sections[si].TestCases {
+		if scenario.Sections[si].TestCases[ti].ID == tcID {
+			tc = &scenario.Sections[si].TestCases[ti]
+			break
+		}
+	}
+	if tc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "test case not found"})
+		return
+	}
+
+	var kept []models.IssueLink
+	for _, l := range tc.Issues {
+		if !l.Matches(linkType, parentID, iid) {
+			kept = append(kept, l)
+		}
+	}
+	if len(kept) == len(tc.Issues) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+		return
+	}
+	tc.Issues = kept
+
+	tc.UpdatedAt = time.Now().Format(time.RFC3339)
+	scenario.UpdatedAt = time.Now()
+	if err := saveScenarioVersioned(ctx, &scenario, currentAuthorID(c), models.VersionActionUpdateTestCase, fmt.Sprintf("Unlinked %s from %s %d", tc.Code, linkType, iid)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save scenario"})
+		return
+	}
+	database.RedisClient.SRem(ctx, database.TraceabilityKey(linkType, parentID, iid), database.TraceabilityMember(scenario.ID, tc.ID))
+
+	c.JSON(http.StatusOK, gin.H{"testCaseId": tc.ID, "issues": tc.Issues})
+}
+
+// GetIssueTestCases handles GET /projects/:id/issues/:issue_id/test-cases
+// Lists the test cases (and recordings) that verify an issue.
+func GetIssueTestCases(c *gin.Context) {
+	iid, err := strconv.ParseInt(c.Param("issue_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildIssueCoverage(c.Request.Context(), models.IssueLinkIssue, c.Param("id"), iid))
+}
+
+// GetEpicTestCases handles GET /groups/:id/epics/:epic_iid/test-cases
+func GetEpicTestCases(c *gin.Context) {
+	iid, err := strconv.ParseInt(c.Param("epic_iid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid epic ID"})
+		return
+	}
+
+	c.JSON(http.StatusOK, buildIssueCoverage(c.Request.Context(), models.IssueLinkEpic, c.Param("id"), iid))
+}
+
+// GetProjectTraceability handles GET /projects/:id/traceability
+// Query params: state (default "opened"), labels (comma separated), uncovered (bool), limit (default 100)
+// Returns the project's issues with the test cases linked to each one.
+func GetProjectTraceability(c *gin.Context) {
+	projectID := c.Param("id")
+
+	token := c.MustGet("token").(*oauth2.Token)
+	sessionID := c.MustGet("session_id").(string)
+
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, sessionID, t)
+	}
+
+	gitlabClient, err := client.GetClient(c, token, tokenSaver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create GitLab client: " + err.Error()})
+		return
+	}
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	opt := &gitlab.ListProjectIssuesOptions{
+		State:       gitlab.Ptr(c.DefaultQuery("state", "opened")),
+		ListOptions: gitlab.ListOptions{PerPage: 100},
+	}
+	if labels := c.Query("labels"); labels != "" {
+		labelOpts := gitlab.LabelOptions(strings.Split(labels, ","))
+		opt.Labels = &labelOpts
+	}
+
+	var issues []*gitlab.Issue
+	for page := int64(1); len(issues) < limit; page++ {
+		opt.Page = page
+		batch, resp, err := gitlabClient.Issues.ListProjectIssues(projectID, opt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		issues = append(issues, batch...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+	}
+	if len(issues) > limit {
+		issues = issues[:limit]
+	}
+
+	ctx := c.Request.Context()
+	uncoveredOnly := c.Query("uncovered") == "true"
+	covered := 0
+	items := make([]models.IssueCoverage, 0, len(issues))
+	for _, issue := range issues {
+		coverage := buildIssueCoverage(ctx, models.IssueLinkIssue, projectID, issue.IID)
+		coverage.Title = issue.Title
+		coverage.State = issue.State
+		coverage.WebURL = issue.WebURL
+		coverage.Labels = issue.Labels
+		if coverage.Covered {
+			covered++
+			if uncoveredOnly {
+				continue
+			}
+		}
+		items = append(items, coverage)
+	}
+
+	coveragePercent := 0.0
+	if len(issues) > 0 {
+		coveragePercent = float64(covered*1000/len(issues)) / 10
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": items,
+		"summary": gin.H{
+			"totalIssues":     len(issues),
+			"coveredIssues":   covered,
+			"uncoveredIssues": len(issues) - covered,
+			"coveragePercent": coveragePercent,
+		},
+	})
+}
+
+// notifyLinkedIssues posts a failed automation result as an evidence note on every
+// GitLab issue linked to the test case.
+func notifyLinkedIssues(gitlabClient *gitlab.Client, scenario *models.TestScenario, tcID string, automation *models.AutomationTest) {
+	if gitlabClient == nil || automation.Status != models.AutomationStatusFail {
+		return
+	}
+	si, ti := findTestCaseLocation(scenario, tcID)
+	if si < 0 {
+		return
+	}
+	tc := scenario.Sections[si].TestCases[ti]
+
+	var evidence strings.Builder
+	fmt.Fprintf(&evidence, "- Test case: **%s %s** (%s / %s)\n", tc.Code, tc.Title, scenario.Title, scenario.Sections[si].Title)
+	fmt.Fprintf(&evidence, "- Result: **failed** at %s\n", time.Now().Format(time.RFC1123))
+	if automation.FailedStepIndex != nil {
+		step := *automation.FailedStepIndex
+		desc := ""
+		if step >= 0 && step < len(automation.Steps) {
+			desc = automation.Steps[step].Description
+		}
+		fmt.Fprintf(&evidence, "- Failed step: %d %s\n", step+1, desc)
+	}
+	if automation.VideoURL != "" {
+		fmt.Fprintf(&evidence, "- Video: %s\n", automation.VideoURL)
+	}
+	if automation.ScreenshotURL != "" {
+		fmt.Fprintf(&evidence, "- Screenshot: %s\n", automation.ScreenshotURL)
+	}
+
+	comment := "Automated test run failed."
+	if automation.ErrorMessage != "" {
+		comment = fmt.Sprintf("Automated test run failed:\n\n```\n%s\n```", automation.ErrorMessage)
+	}
+
+	for _, link := range tc.Issues {
+		if link.Type != models.IssueLinkIssue {
+			continue
+		}
+		if _, err := client.CreateEvidenceNote(gitlabClient, link.ProjectID, link.IID, evidence.String(), comment); err != nil {
+			log.Printf("[Traceability] failed to post failure of %s to issue %s#%d: %v", tc.Code, link.ProjectID, link.IID, err)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// webhookWithSecret is returned when a webhook is created or its secret
+// rotated; the secret is not shown again afterwards
+type webhookWithSecret struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// webhookError maps webhook store errors to responses
+func webhookError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// projectWebhook loads the :webhookId webhook if the caller has at least the
+// given access level on its project, and writes the error response otherwise.
+// Any member can see a project's webhooks; only maintainers can change,
+// test or inspect their deliveries.
+func projectWebhook(c *gin.Context, min gitlab.AccessLevelValue) (models.Webhook, bool) {
+	hook, err := database.GetWebhook(c.Request.Context(), c.Param("webhookId"))
+	if err != nil {
+		webhookError(c, err)
+		return hook, false
+	}
+	return hook, RequireProjectAccess(c, hook.ProjectID, min)
+}
+
+// applyWebhookRequest copies the fields set in a request onto a webhook
+func applyWebhookRequest(hook *models.Webhook, req models.SaveWebhookRequest) {
+	if name := strings.TrimSpace(req.Name); name != "" {
+		hook.Name = name
+	}
+	if req.URL != "" {
+		hook.URL = strings.TrimSpace(req.URL)
+	}
+	if req.Events != nil {
+		hook.Events = req.Events
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+}
+
+// ListProjectWebhooks lists a project's outgoing webhooks and the events they
+// can subscribe to
+func ListProjectWebhooks(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.GuestPermissions) {
+		return
+	}
+	hooks, err := database.ListProjectWebhooks(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": hooks, "events": models.WebhookEvents})
+}
+
+// CreateProjectWebhook subscribes a URL to a project's QA events. The
+// response holds the signing secret, which is only shown this once.
+func CreateProjectWebhook(c *gin.Context) {
+	if !RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	var req models.SaveWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook := models.Webhook{
+		ProjectID: c.Param("id"),
+		Name:      "Webhook",
+		Active:    true,
+		CreatedBy: currentAuthorID(c),
+	}
+	applyWebhookRequest(&hook, req)
+	if err := services.ValidateWebhook(c.Request.Context(), &hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := database.NewWebhookSecret()
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if err := database.SaveWebhook(c.Request.Context(), &hook, secret); err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, webhookWithSecret{Webhook: hook, Secret: secret})
+}
+
+// GetWebhook returns a webhook without its secret
+func GetWebhook(c *gin.Context) {
+	hook, ok := projectWebhook(c, gitlab.GuestPermissions)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// UpdateWebhook changes a webhook's URL, name, events or active flag, and
+// rotates its secret when rotateSecret is set
+func UpdateWebhook(c *gin.Context) {
+	hook, ok := projectWebhook(c, gitlab.MaintainerPermissions)
+	if !ok {
+		return
+	}
+	var req models.SaveWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	applyWebhookRequest(&hook, req)
+	if err := services.ValidateWebhook(c.Request.Context(), &hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var secret string
+	if req.RotateSecret {
+		var err error
+		if secret, err = database.NewWebhookSecret(); err != nil {
+			webhookError(c, err)
+			return
+		}
+	}
+	if err := database.SaveWebhook(ctx, &hook, secret); err != nil {
+		webhookError(c, err)
+		return
+	}
+	if secret != "" {
+		c.JSON(http.StatusOK, webhookWithSecret{Webhook: hook, Secret: secret})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(c *gin.Context) {
+	hook, ok := projectWebhook(c, gitlab.MaintainerPermissions)
+	if !ok {
+		return
+	}
+	if err := database.DeleteWebhook(c.Request.Context(), hook.ID); err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// ListWebhookDeliveries returns a webhook's delivery log, newest first.
+// ?limit= caps the number of entries (default 50, max 200).
+func ListWebhookDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	hook, ok := projectWebhook(c, gitlab.MaintainerPermissions)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+	deliveries, err := database.ListWebhookDeliveries(ctx, hook.ID, limit)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+// TestWebhook sends a signed ping to a webhook and returns the delivery,
+// including the endpoint's response status
+func TestWebhook(c *gin.Context) {
+	hook, ok := projectWebhook(c, gitlab.MaintainerPermissions)
+	if !ok {
+		return
+	}
+	delivery, err := services.TestFireWebhook(c.Request.Context(), hook)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
@@ -12,6 +12,10 @@ import (
 
 // GetCurrentUserID fetches the current user's GitLab ID from the context
 func GetCurrentUserID(ginContext *gin.Context) (int, error) {
+	// API token requests already know who they act as
+	if userID := ginContext.GetInt("user_id"); userID != 0 {
+		return userID, nil
+	}
 	token, ok := ginContext.MustGet("token").(*oauth2.Token)
 	if !ok {
 		return 0, fmt.Errorf("missing token")
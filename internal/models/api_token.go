@@ -0,0 +1,94 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// API tokens
+// ─────────────────────────────────────────────
+
+// API token scopes. Admin includes every other scope.
+const (
+	APITokenScopeRead     = "read"
+	APITokenScopeRun      = "run"
+	APITokenScopeGenerate = "generate"
+	APITokenScopeAdmin    = "admin"
+)
+
+// APITokenScopes lists the scopes a token can be given
+var APITokenScopes = []string{
+	APITokenScopeRead,
+	APITokenScopeRun,
+	APITokenScopeGenerate,
+	APITokenScopeAdmin,
+}
+
+// API token kinds
+const (
+	// APITokenPersonal belongs to the user who created it
+	APITokenPersonal = "personal"
+	// APITokenProject belongs to a project and only reaches that project
+	APITokenProject = "project"
+)
+
+// GitLab credentials an API token acts with
+const (
+	// APITokenGitLabAccessToken is a GitLab personal, project or group access
+	// token given when the API token was created
+	APITokenGitLabAccessToken = "gitlab_access_token"
+	// APITokenGitLabGrant is the creator's stored OAuth authorization
+	APITokenGitLabGrant = "oauth_grant"
+)
+
+// APIToken is a long-lived credential for CI jobs, bots and scripts. The
+// secret is only returned when the token is created; it is stored hashed.
+type APIToken struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Prefix is the start of the secret, to recognise the token in lists
+	Prefix string `json:"prefix"`
+	// UserID is the GitLab user the token acts as
+	UserID int `json:"userId"`
+	// GitLabCredentials says which GitLab credentials the token uses
+	GitLabCredentials string `json:"gitlabCredentials"`
+	// ProjectIDs limits the token to these projects; empty means every
+	// project the user can reach
+	ProjectIDs []string   `json:"projectIds"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  int        `json:"createdBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	LastUsedIP string     `json:"lastUsedIp,omitempty"`
+}
+
+// HasScope reports whether the token was given a scope
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, APITokenScopeAdmin) || slices.Contains(t.Scopes, scope)
+}
+
+// AllowsProject reports whether the token may act on a project
+func (t *APIToken) AllowsProject(projectID string) bool {
+	return len(t.ProjectIDs) == 0 || slices.Contains(t.ProjectIDs, projectID)
+}
+
+// Expired reports whether the token can no longer be used
+func (t *APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// CreateAPITokenRequest creates a personal or project API token
+type CreateAPITokenRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ProjectIDs limits a personal token; project tokens always get their project
+	ProjectIDs []string `json:"projectIds"`
+	// ExpiresInDays sets the lifetime; 0 means the token does not expire
+	ExpiresInDays int `json:"expiresInDays"`
+	// GitLabToken is a GitLab access token the API token acts with. When
+	// empty the token uses the creator's own GitLab authorization.
+	GitLabToken string `json:"gitlabToken"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Test account credentials
+// ─────────────────────────────────────────────
+
+// Credential fields that can be referenced from steps and prompts
+const (
+	CredentialUsername = "username"
+	CredentialPassword = "password"
+)
+
+// Credential is a test account kept in the encrypted credential store. Only
+// metadata is ever returned; Username is masked.
+type Credential struct {
+	ID        string    `json:"id"`
+	ProjectID string    `json:"projectId,omitempty"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	CreatedBy int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// CredentialSecret is the decrypted account. It is only materialised by the
+// test runner and must never be serialised into a response, prompt or log.
+type CredentialSecret struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SaveCredentialRequest creates or rotates a stored credential
+type SaveCredentialRequest struct {
+	Name     string `json:"name"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CredentialPlaceholderPattern matches {{credential:<id>:<field>}}
+var CredentialPlaceholderPattern = regexp.MustCompile(`\{\{credential:([A-Za-z0-9-]+):(username|password)\}\}`)
+
+// CredentialPlaceholder is the stand-in written into steps and prompts; the
+// runner swaps it for the real value just before typing it
+func CredentialPlaceholder(id, field string) string {
+	return "{{credential:" + id + ":" + field + "}}"
+}
+
+// MaskUsername keeps enough of a username to recognise the account
+func MaskUsername(username string) string {
+	local, domain, isEmail := strings.Cut(username, "@")
+	if len(local) > 2 {
+		local = local[:2] + strings.Repeat("*", len(local)-2)
+	} else {
+		local = strings.Repeat("*", len(local))
+	}
+	if isEmail {
+		return local + "@" + domain
+	}
+	return local
+}
@@ -0,0 +1,63 @@
+package models
+
+// ─────────────────────────────────────────────
+// Duplicate detection types
+// ─────────────────────────────────────────────
+
+// DuplicateMember is one test case inside a duplicate cluster, with enough
+// context to tell the copies apart and the run history used to rank them
+type DuplicateMember struct {
+	ScenarioID       string              `json:"scenarioId"`
+	ScenarioTitle    string              `json:"scenarioTitle"`
+	SectionID        string              `json:"sectionId"`
+	SectionTitle     string              `json:"sectionTitle"`
+	TestCaseID       string              `json:"testCaseId"`
+	Code             string              `json:"code"`
+	Title            string              `json:"title"`
+	StepCount        int                 `json:"stepCount"`
+	Automated        bool                `json:"automated"`
+	AutomationStatus AutomationRunStatus `json:"automationStatus,omitempty"`
+	Runs             int                 `json:"runs"`
+	PassRate         float64             `json:"passRate"`
+	LastRunAt        string              `json:"lastRunAt,omitempty"`
+}
+
+// DuplicatePair is the similarity between two members of a cluster
+type DuplicatePair struct {
+	A          string  `json:"a"` // test case IDs
+	B          string  `json:"b"`
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicateCluster is a group of test cases that are likely copies of each other
+type DuplicateCluster struct {
+	ID              string            `json:"id"`
+	MaxSimilarity   float64           `json:"maxSimilarity"`
+	MinSimilarity   float64           `json:"minSimilarity"`
+	Members         []DuplicateMember `json:"members"`
+	Pairs           []DuplicatePair   `json:"pairs"`
+	SuggestedKeepID string            `json:"suggestedKeepId"` // best-performing member
+}
+
+// DuplicateRef identifies a test case across scenarios
+type DuplicateRef struct {
+	ScenarioID string `json:"scenarioId"`
+	TestCaseID string `json:"testCaseId"`
+}
+
+// MergeDuplicatesRequest merges test cases into one. KeepTestCaseID chooses
+// which test case survives; the automation always comes from the
+// best-performing member unless KeepOwnAutomation is set.
+type MergeDuplicatesRequest struct {
+	Members           []DuplicateRef `json:"members"`
+	KeepTestCaseID    string         `json:"keepTestCaseId,omitempty"`
+	KeepOwnAutomation bool           `json:"keepOwnAutomation,omitempty"`
+}
+
+// MergeDuplicatesResult describes the outcome of a merge
+type MergeDuplicatesResult struct {
+	Kept               DuplicateRef   `json:"kept"`
+	Removed            []DuplicateRef `json:"removed"`
+	AutomationFrom     string         `json:"automationFrom,omitempty"` // test case ID whose automation was kept
+	UpdatedScenarioIDs []string       `json:"updatedScenarioIds"`
+}
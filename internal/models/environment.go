@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Project environments
+// ─────────────────────────────────────────────
+
+// Environment is a named deployment of a project (dev, staging, a preview
+// build) that scenarios and recordings can be run against without editing
+// their steps.
+type Environment struct {
+	ID          string `json:"id"`
+	ProjectID   string `json:"projectId"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	BaseURL     string `json:"baseUrl"`
+	ApiBaseURL  string `json:"apiBaseUrl,omitempty"`
+	LoginURL    string `json:"loginUrl,omitempty"`
+	// CredentialID is the test account used in this environment. Steps that
+	// reference the scenario's own account log in with this one instead.
+	CredentialID string `json:"credentialId,omitempty"`
+	// Variables fill {{env.NAME}} placeholders in step values and URLs
+	Variables map[string]string `json:"variables,omitempty"`
+	// SecretVariables fill {{env.NAME}} placeholders too, but their values are
+	// kept in the encrypted credential store. Each name maps to a credential
+	// ID and, like other credentials, only resolves in typed values and headers.
+	SecretVariables map[string]string `json:"secretVariables,omitempty"`
+	// Headers are sent with browser requests to the environment's own
+	// origins. Values may hold variables and credential placeholders, which
+	// are resolved by the runner.
+	Headers   map[string]string `json:"headers,omitempty"`
+	IsDefault bool              `json:"isDefault"`
+	CreatedBy int               `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// EnvironmentRef identifies the environment a result was produced against
+type EnvironmentRef struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	BaseURL string `json:"baseUrl"`
+}
+
+// Ref returns the reference recorded on results
+func (e *Environment) Ref() *EnvironmentRef {
+	return &EnvironmentRef{ID: e.ID, Name: e.Name, BaseURL: e.BaseURL}
+}
+
+// RunEnvironment is what the runner needs from an environment once steps have
+// been rewritten for it
+type RunEnvironment struct {
+	EnvironmentRef
+	Headers map[string]string `json:"headers,omitempty"`
+	// HeaderOrigins are the only origins Headers are sent to
+	HeaderOrigins []string `json:"headerOrigins,omitempty"`
+}
+
+// SaveEnvironmentRequest creates or replaces an environment
+type SaveEnvironmentRequest struct {
+	Name         string            `json:"name" binding:"required"`
+	Description  string            `json:"description,omitempty"`
+	BaseURL      string            `json:"baseUrl" binding:"required"`
+	ApiBaseURL   string            `json:"apiBaseUrl,omitempty"`
+	LoginURL     string            `json:"loginUrl,omitempty"`
+	CredentialID string            `json:"credentialId,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	// Secrets set secret variables and are never returned. An empty value
+	// keeps the stored secret; names left out are removed.
+	Secrets   map[string]string `json:"secrets,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	IsDefault bool              `json:"isDefault"`
+}
+
+// EnvVariablePattern matches {{env.NAME}}
+var EnvVariablePattern = regexp.MustCompile(`\{\{env\.([A-Za-z_][A-Za-z0-9_]*)\}\}`)
+
+// EnvVariableNamePattern is the allowed form of variable names
+var EnvVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func NewEnvironmentID() string {
+	return fmt.Sprintf("env-%d", time.Now().UnixNano()%1000000000)
+}
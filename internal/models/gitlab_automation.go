@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// ─────────────────────────────────────────────
+// GitLab webhook automation
+// ─────────────────────────────────────────────
+
+// GitLab events a rule can trigger on
+const (
+	TriggerPush               = "push"
+	TriggerMergeRequestOpened = "merge_request.opened"
+	TriggerMergeRequestMerged = "merge_request.merged"
+	TriggerMergeRequestLabel  = "merge_request.labeled"
+	TriggerPipelineSuccess    = "pipeline.success"
+	TriggerPipelineFailed     = "pipeline.failed"
+	TriggerIssueLabel         = "issue.labeled"
+)
+
+// Actions a rule can take
+const (
+	ActionRebuildKnowledgeGraph = "rebuild_knowledge_graph"
+	ActionRunSuite              = "run_suite"
+	ActionStartFixAgent         = "start_fix_agent"
+)
+
+// GitLabTriggers and GitLabActions list the valid values, for validation and
+// for clients building the rule editor
+var (
+	GitLabTriggers = []string{
+		TriggerPush,
+		TriggerMergeRequestOpened,
+		TriggerMergeRequestMerged,
+		TriggerMergeRequestLabel,
+		TriggerPipelineSuccess,
+		TriggerPipelineFailed,
+		TriggerIssueLabel,
+	}
+	GitLabActions = []string{
+		ActionRebuildKnowledgeGraph,
+		ActionRunSuite,
+		ActionStartFixAgent,
+	}
+)
+
+// GitLabAutomation is a project's configuration for its GitLab webhook: the
+// rules that map incoming events to QA actions. Actions run as ActorUserID,
+// the user who last saved the configuration. The webhook's secret token is
+// stored separately and only returned when it is created or rotated.
+type GitLabAutomation struct {
+	ProjectID   string                 `json:"projectId"`
+	Enabled     bool                   `json:"enabled"`
+	Rules       []GitLabAutomationRule `json:"rules"`
+	ActorUserID int                    `json:"actorUserId"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+}
+
+// GitLabAutomationRule runs one action when a GitLab event matches
+type GitLabAutomationRule struct {
+	ID      string `json:"id"`
+	Trigger string `json:"trigger"`
+	Action  string `json:"action"`
+	Enabled bool   `json:"enabled"`
+	// Branches limits push and pipeline events to these refs, and merge
+	// request events to these target branches. Globs such as "release/*" are
+	// allowed; empty matches every branch.
+	Branches []string `json:"branches,omitempty"`
+	// Label is the label whose addition triggers the *.labeled triggers
+	Label string `json:"label,omitempty"`
+
+	// run_suite: which cases to run and where
+	Tags          []string `json:"tags,omitempty"`
+	ScenarioIDs   []string `json:"scenarioIds,omitempty"`
+	EnvironmentID string   `json:"environmentId,omitempty"`
+
+	// start_fix_agent: "claude" (default) or "pi", and the branch the merge
+	// request targets (the project's default branch when empty)
+	Runner       string `json:"runner,omitempty"`
+	TargetBranch string `json:"targetBranch,omitempty"`
+}
+
+// SaveGitLabAutomationRequest replaces a project's automation configuration
+type SaveGitLabAutomationRequest struct {
+	Enabled      *bool                  `json:"enabled"`
+	Rules        []GitLabAutomationRule `json:"rules"`
+	RotateSecret bool                   `json:"rotateSecret"`
+}
+
+// GitLabAutomationRun records what an incoming event triggered
+type GitLabAutomationRun struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	Trigger   string `json:"trigger"`
+	RuleID    string `json:"ruleId"`
+	Action    string `json:"action"`
+	Status    string `json:"status"` // "started" | "failed"
+	Message   string `json:"message"`
+	// ResourceID is the suite run or fix session started, if any
+	ResourceID string    `json:"resourceId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -117,12 +117,17 @@ type ManualRecording struct {
 	ProjectDetails *ProjectDetails  `json:"projectDetails,omitempty"`
 	IssueID        string           `json:"issue_id,omitempty"`
 	TestCaseID     string           `json:"test_case_id,omitempty"`
+	// CredentialID is the stored test account the recorded steps log in with
+	CredentialID   string           `json:"credential_id,omitempty"`
 	CreatorID      int              `json:"creator_id,omitempty"`
 	VideoURL       string           `json:"video_url,omitempty"`
 	Steps          []RecordingStep  `json:"steps"`
 	Parameters     []any            `json:"parameters"`
 	Telemetry      *SessionTelemetry `json:"telemetry,omitempty"`
+	// Redaction records what was scrubbed from the telemetry when it was stored
+	Redaction      *RedactionReport `json:"redaction,omitempty"`
 	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
 }
 
 // ManualRecordingSummary is a lightweight version of ManualRecording for list responses.
@@ -156,14 +161,23 @@ type TestResult struct {
 	Log           string           `json:"log,omitempty"`
 	VideoURL      string           `json:"videoUrl,omitempty"`
 	RunDurationMs int64            `json:"runDurationMs,omitempty"`
+	// Browser diagnostics captured during the run, used for bug reports
+	ConsoleErrors  []ConsoleLogEntry     `json:"consoleErrors,omitempty"`
+	FailedRequests []NetworkRequestEntry `json:"failedRequests,omitempty"`
+	// Environment is the project environment the test ran against, if one was chosen
+	Environment *EnvironmentRef `json:"environment,omitempty"`
+	// RanAt is when the result was stored; empty for results stored before it was recorded
+	RanAt *time.Time `json:"ranAt,omitempty"`
 }
 
 // TestRun is a runtime execution unit used by the Playwright runner.
 // Both ManualRecording and AutomationTest can be converted to a TestRun.
 type TestRun struct {
-	ID    string          `json:"id"`
-	Name  string          `json:"name"`
-	Steps []RecordingStep `json:"steps"`
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Steps       []RecordingStep `json:"steps"`
+	ProjectID   string          `json:"projectId,omitempty"` // only this project's credentials resolve
+	Environment *RunEnvironment `json:"environment,omitempty"`
 }
 
 // GeneratedAutomation holds the result of AI-generated automation steps
@@ -0,0 +1,26 @@
+package models
+
+// ─────────────────────────────────────────────
+// Recording ⇄ test case conversion
+// ─────────────────────────────────────────────
+
+// RecordingToTestCaseRequest documents a manual recording as a test case in a
+// scenario section. The recording becomes the test case's automation.
+type RecordingToTestCaseRequest struct {
+	ScenarioID string   `json:"scenarioId" binding:"required"`
+	SectionID  string   `json:"sectionId" binding:"required"`
+	Title      string   `json:"title,omitempty"` // overrides the generated title
+	Priority   Priority `json:"priority,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	// SkipAI describes the steps directly instead of asking the model
+	SkipAI bool `json:"skipAi,omitempty"`
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// AutomationToRecordingRequest creates an editable recording from a test
+// case's automation. All fields are optional.
+type AutomationToRecordingRequest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	IssueID     string `json:"issueId,omitempty"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// ─────────────────────────────────────────────
+// Telemetry redaction
+// ─────────────────────────────────────────────
+
+// RedactionPattern is a named regular expression whose matches are replaced
+// with [REDACTED:<name>]. Validate "luhn" keeps only matches that pass the
+// card number checksum.
+type RedactionPattern struct {
+	Name     string `json:"name"`
+	Regex    string `json:"regex"`
+	Validate string `json:"validate,omitempty"`
+}
+
+// RedactionPolicy controls what is scrubbed from recording telemetry before it
+// is stored. A project without its own policy uses DefaultRedactionPolicy.
+//
+// JSON paths are dotted: "password" matches the key at any depth, "$.user.ssn"
+// is anchored at the document root, "*" matches any key and "[*]" any array
+// element. Cookie and storage key entries accept * globs.
+type RedactionPolicy struct {
+	ProjectID   string             `json:"projectId,omitempty"`
+	Disabled    bool               `json:"disabled,omitempty"`
+	Headers     []string           `json:"headers"`
+	JSONPaths   []string           `json:"jsonPaths"`
+	Patterns    []RedactionPattern `json:"patterns"`
+	Cookies     []string           `json:"cookies"`
+	StorageKeys []string           `json:"storageKeys"`
+	UpdatedBy   int                `json:"updatedBy,omitempty"`
+	UpdatedAt   time.Time          `json:"updatedAt,omitempty"`
+}
+
+// RedactionReport counts what a redaction pass replaced
+type RedactionReport struct {
+	Headers     int            `json:"headers"`
+	JSONFields  int            `json:"jsonFields"`
+	Cookies     int            `json:"cookies"`
+	StorageKeys int            `json:"storageKeys"`
+	Patterns    map[string]int `json:"patterns,omitempty"`
+	RedactedAt  time.Time      `json:"redactedAt"`
+}
+
+// Total is the number of values replaced
+func (r RedactionReport) Total() int {
+	n := r.Headers + r.JSONFields + r.Cookies + r.StorageKeys
+	for _, c := range r.Patterns {
+		n += c
+	}
+	return n
+}
+
+// DefaultRedactionPolicy covers credentials, session cookies and common
+// personal data
+func DefaultRedactionPolicy() RedactionPolicy {
+	return RedactionPolicy{
+		Headers: []string{
+			"authorization", "proxy-authorization", "x-api-key", "x-auth-token",
+			"x-access-token", "x-csrf-token", "x-xsrf-token", "x-session-id", "private-token",
+		},
+		JSONPaths: []string{
+			"password", "passwd", "newPassword", "currentPassword", "confirmPassword",
+			"secret", "clientSecret", "client_secret", "token", "accessToken", "access_token",
+			"refreshToken", "refresh_token", "idToken", "id_token", "apiKey", "api_key",
+			"otp", "pin", "cvv", "cardNumber",
+		},
+		Patterns: []RedactionPattern{
+			{Name: "jwt", Regex: `eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`},
+			{Name: "bearer", Regex: `(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`},
+			{Name: "gitlab_token", Regex: `\bgl(pat|oas|rt)-[A-Za-z0-9_\-]{20,}`},
+			{Name: "email", Regex: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`},
+			{Name: "card", Regex: `\b(?:\d[ -]?){12,18}\d\b`, Validate: "luhn"},
+			{Name: "national_id", Regex: `\b\d{16}\b`},
+		},
+		Cookies:     []string{"*session*", "*sess", "*sid", "*token*", "*auth*", "jwt", "remember*"},
+		StorageKeys: []string{"*token*", "*auth*", "*session*", "*secret*", "*password*", "*credential*"},
+	}
+}
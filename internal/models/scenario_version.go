@@ -0,0 +1,110 @@
+package models
+
+import "time"
+
+// ─────────────────────────────────────────────
+// Version history
+// ─────────────────────────────────────────────
+
+// Version actions recorded with each snapshot
+const (
+	VersionActionBaseline         = "baseline"
+	VersionActionCreated          = "created"
+	VersionActionUpdateScenario   = "update_scenario"
+	VersionActionUpdateTestCase   = "update_test_case"
+	VersionActionAddTestCase      = "add_test_case"
+	VersionActionDeleteTestCase   = "delete_test_case"
+	VersionActionReorderTestCases = "reorder_test_cases"
+	VersionActionCreateSection    = "create_section"
+	VersionActionUpdateSection    = "update_section"
+	VersionActionDeleteSection    = "delete_section"
+	VersionActionReorderSections  = "reorder_sections"
+	VersionActionMoveTestCases    = "move_test_cases"
+	VersionActionCopyTestCases    = "copy_test_cases"
+	VersionActionBulkUpdate       = "bulk_update"
+	VersionActionAcceptProposal   = "accept_proposal"
+	VersionActionMergeDuplicates  = "merge_duplicates"
+	VersionActionFromRecording    = "from_recording"
+	VersionActionGeneration       = "generation"
+	VersionActionRestore          = "restore"
+)
+
+// ScenarioVersion is an immutable snapshot of a scenario taken after a mutation
+type ScenarioVersion struct {
+	Version    int            `json:"version"`
+	ScenarioID string         `json:"scenarioId"`
+	Action     string         `json:"action"`
+	Summary    string         `json:"summary,omitempty"`
+	AuthorID   int            `json:"authorId,omitempty"`
+	Author     string         `json:"author"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Stats      *ScenarioStats `json:"stats,omitempty"`
+	Snapshot   *TestScenario  `json:"snapshot,omitempty"`
+}
+
+// ─────────────────────────────────────────────
+// Diff types
+// ─────────────────────────────────────────────
+
+// Change kinds used throughout a ScenarioDiff
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+	ChangeMoved    = "moved"
+)
+
+// FieldChange is a single field that differs between two versions
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// StepDiff describes a changed step within a test case
+type StepDiff struct {
+	StepID string        `json:"stepId,omitempty"`
+	Order  int           `json:"order"`
+	Change string        `json:"change"`
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// TestCaseDiff describes a changed test case
+type TestCaseDiff struct {
+	TestCaseID string        `json:"testCaseId"`
+	Code       string        `json:"code,omitempty"`
+	Title      string        `json:"title"`
+	Change     string        `json:"change"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+	Steps      []StepDiff    `json:"steps,omitempty"`
+}
+
+// SectionDiff describes a changed section and the test cases that changed inside it
+type SectionDiff struct {
+	SectionID string         `json:"sectionId"`
+	Title     string         `json:"title"`
+	Change    string         `json:"change"`
+	Fields    []FieldChange  `json:"fields,omitempty"`
+	TestCases []TestCaseDiff `json:"testCases,omitempty"`
+}
+
+// DiffSummary provides aggregate counts for a diff
+type DiffSummary struct {
+	SectionsAdded     int `json:"sectionsAdded"`
+	SectionsRemoved   int `json:"sectionsRemoved"`
+	SectionsModified  int `json:"sectionsModified"`
+	TestCasesAdded    int `json:"testCasesAdded"`
+	TestCasesRemoved  int `json:"testCasesRemoved"`
+	TestCasesModified int `json:"testCasesModified"`
+	TestCasesMoved    int `json:"testCasesMoved"`
+	StepsChanged      int `json:"stepsChanged"`
+}
+
+// ScenarioDiff is the structured difference between two scenario versions
+type ScenarioDiff struct {
+	From     int           `json:"from"`
+	To       int           `json:"to"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+	Sections []SectionDiff `json:"sections"`
+	Summary  DiffSummary   `json:"summary"`
+}
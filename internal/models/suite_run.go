@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Suite runs
+// ─────────────────────────────────────────────
+
+// SuiteRunStatus is the state of a suite run or of one case in it
+type SuiteRunStatus string
+
+const (
+	SuiteRunQueued  SuiteRunStatus = "queued"
+	SuiteRunRunning SuiteRunStatus = "running"
+	SuiteRunPassed  SuiteRunStatus = "passed"
+	SuiteRunFailed  SuiteRunStatus = "failed"
+)
+
+// What started a suite run
+const (
+	SuiteTriggerManual = "manual"
+	SuiteTriggerGitLab = "gitlab"
+)
+
+// SuiteRun runs the automations of a selection of test cases across a
+// project's scenarios, such as every case tagged "smoke", one after another
+type SuiteRun struct {
+	ID          string          `json:"id"`
+	ProjectID   string          `json:"projectId"`
+	Name        string          `json:"name"`
+	ScenarioIDs []string        `json:"scenarioIds,omitempty"`
+	Tags        []string        `json:"tags,omitempty"`
+	Environment *EnvironmentRef `json:"environment,omitempty"`
+	Trigger     string          `json:"trigger"`
+	// Ref is the GitLab change the run was triggered for, if any
+	Ref        *SuiteRunRef      `json:"ref,omitempty"`
+	Status     SuiteRunStatus    `json:"status"`
+	Results    []SuiteCaseResult `json:"results"`
+	Total      int               `json:"total"`
+	Passed     int               `json:"passed"`
+	Failed     int               `json:"failed"`
+	StartedBy  int               `json:"startedBy,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// SuiteRunRef identifies the branch, commit, merge request or pipeline a run
+// was made for
+type SuiteRunRef struct {
+	Branch          string `json:"branch,omitempty"`
+	CommitSHA       string `json:"commitSha,omitempty"`
+	MergeRequestIID int64  `json:"mergeRequestIid,omitempty"`
+	PipelineID      int64  `json:"pipelineId,omitempty"`
+}
+
+// SuiteCaseResult is the outcome of one test case's automation in a suite run
+type SuiteCaseResult struct {
+	ScenarioID      string         `json:"scenarioId"`
+	ScenarioTitle   string         `json:"scenarioTitle"`
+	SectionID       string         `json:"sectionId"`
+	SectionTitle    string         `json:"sectionTitle,omitempty"`
+	TestCaseID      string         `json:"testCaseId"`
+	Code            string         `json:"code"`
+	Title           string         `json:"title"`
+	Status          SuiteRunStatus `json:"status"`
+	DurationMs      int64          `json:"durationMs,omitempty"`
+	Error           string         `json:"error,omitempty"`
+	FailedStepIndex *int           `json:"failedStepIndex,omitempty"`
+	// FailedStep describes the step at FailedStepIndex
+	FailedStep    string `json:"failedStep,omitempty"`
+	VideoURL      string `json:"videoUrl,omitempty"`
+	ScreenshotURL string `json:"screenshotUrl,omitempty"`
+}
+
+// StartSuiteRunRequest selects the test cases of a suite run. Cases need an
+// automation and, when tags are given, at least one of the tags.
+type StartSuiteRunRequest struct {
+	Name          string   `json:"name"`
+	ScenarioIDs   []string `json:"scenarioIds"`
+	Tags          []string `json:"tags"`
+	EnvironmentID string   `json:"environmentId"`
+	// Ref ties the run to a commit or merge request; its results are then
+	// reported there as a commit status and a merge request note
+	Ref *SuiteRunRef `json:"ref"`
+}
+
+// Tally recomputes the counts and the overall status from the case results
+func (r *SuiteRun) Tally() {
+	r.Total, r.Passed, r.Failed = len(r.Results), 0, 0
+	finished := true
+	for _, res := range r.Results {
+		switch res.Status {
+		case SuiteRunPassed:
+			r.Passed++
+		case SuiteRunFailed:
+			r.Failed++
+		default:
+			finished = false
+		}
+	}
+	switch {
+	case !finished:
+		if r.Passed+r.Failed > 0 {
+			r.Status = SuiteRunRunning
+		}
+	case r.Failed > 0:
+		r.Status = SuiteRunFailed
+	default:
+		r.Status = SuiteRunPassed
+	}
+}
+
+// NewSuiteRunID generates a suite run ID
+func NewSuiteRunID() string {
+	return fmt.Sprintf("suite-%d", time.Now().UnixNano())
+}
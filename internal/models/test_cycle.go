@@ -0,0 +1,247 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Test cycle enums
+// ─────────────────────────────────────────────
+
+type CycleStatus string
+
+const (
+	CycleStatusActive    CycleStatus = "active"
+	CycleStatusCompleted CycleStatus = "completed"
+)
+
+// ExecutionStatus is the outcome of executing a test case within a cycle
+type ExecutionStatus string
+
+const (
+	ExecutionNotRun     ExecutionStatus = "not_run"
+	ExecutionInProgress ExecutionStatus = "in_progress"
+	ExecutionPassed     ExecutionStatus = "passed"
+	ExecutionFailed     ExecutionStatus = "failed"
+	ExecutionBlocked    ExecutionStatus = "blocked"
+	ExecutionSkipped    ExecutionStatus = "skipped"
+)
+
+// IsValid reports whether s is a known execution status
+func (s ExecutionStatus) IsValid() bool {
+	switch s {
+	case ExecutionNotRun, ExecutionInProgress, ExecutionPassed, ExecutionFailed, ExecutionBlocked, ExecutionSkipped:
+		return true
+	}
+	return false
+}
+
+// Execution sources
+const (
+	ExecutionSourceManual     = "manual"
+	ExecutionSourceAutomation = "automation"
+)
+
+// Sign-off decisions
+const (
+	SignOffApproved = "approved"
+	SignOffRejected = "rejected"
+)
+
+// ─────────────────────────────────────────────
+// Test cycle types
+// ─────────────────────────────────────────────
+
+// ExecutionEvidence is an attachment backing an execution result
+type ExecutionEvidence struct {
+	Type string `json:"type"` // screenshot, video, log, link
+	URL  string `json:"url"`
+	Name string `json:"name,omitempty"`
+}
+
+// ExecutionRecord is one entry in the result history of an execution
+type ExecutionRecord struct {
+	Status     ExecutionStatus     `json:"status"`
+	Source     string              `json:"source"`
+	Comment    string              `json:"comment,omitempty"`
+	Evidence   []ExecutionEvidence `json:"evidence,omitempty"`
+	ExecutedBy int                 `json:"executedBy,omitempty"`
+	ExecutedAt time.Time           `json:"executedAt"`
+	// Environment names the project environment an automated run used
+	Environment string `json:"environment,omitempty"`
+}
+
+// TestExecution tracks one test case within a cycle
+type TestExecution struct {
+	TestCaseID   string              `json:"testCaseId"`
+	Code         string              `json:"code"`
+	Title        string              `json:"title"`
+	SectionID    string              `json:"sectionId"`
+	SectionTitle string              `json:"sectionTitle"`
+	Priority     Priority            `json:"priority"`
+	Automated    bool                `json:"automated"`
+	AssigneeID   int                 `json:"assigneeId,omitempty"`
+	AssigneeName string              `json:"assigneeName,omitempty"`
+	Status       ExecutionStatus     `json:"status"`
+	Source       string              `json:"source,omitempty"`
+	Comment      string              `json:"comment,omitempty"`
+	Evidence     []ExecutionEvidence `json:"evidence,omitempty"`
+	ExecutedBy   int                 `json:"executedBy,omitempty"`
+	ExecutedAt   *time.Time          `json:"executedAt,omitempty"`
+	History      []ExecutionRecord   `json:"history,omitempty"`
+}
+
+// CycleProgress provides aggregate execution counts
+type CycleProgress struct {
+	Total           int     `json:"total"`
+	NotRun          int     `json:"notRun"`
+	InProgress      int     `json:"inProgress"`
+	Passed          int     `json:"passed"`
+	Failed          int     `json:"failed"`
+	Blocked         int     `json:"blocked"`
+	Skipped         int     `json:"skipped"`
+	Executed        int     `json:"executed"`
+	PercentComplete float64 `json:"percentComplete"`
+	PassRate        float64 `json:"passRate"`
+}
+
+// CycleSignOff records the release decision taken at the end of a cycle
+type CycleSignOff struct {
+	Decision string        `json:"decision"`
+	Comment  string        `json:"comment,omitempty"`
+	SignedBy int           `json:"signedBy"`
+	SignedAt time.Time     `json:"signedAt"`
+	Progress CycleProgress `json:"progress"`
+}
+
+// TestCycle is a round of execution of a scenario's test cases against one build
+type TestCycle struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description,omitempty"`
+	ScenarioID    string          `json:"scenarioId"`
+	ScenarioTitle string          `json:"scenarioTitle"`
+	ProjectID     string          `json:"projectId,omitempty"`
+	Build         string          `json:"build,omitempty"`
+	Environment   string          `json:"environment,omitempty"`
+	Status        CycleStatus     `json:"status"`
+	Executions    []TestExecution `json:"executions"`
+	Progress      *CycleProgress  `json:"progress,omitempty"`
+	SignOff       *CycleSignOff   `json:"signOff,omitempty"`
+	CreatorID     int             `json:"creatorId,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+// CycleGroupProgress is progress for one slice of a cycle (assignee, section)
+type CycleGroupProgress struct {
+	Key      string        `json:"key"`
+	Label    string        `json:"label"`
+	Progress CycleProgress `json:"progress"`
+}
+
+// CycleSummary is the sign-off view of a cycle
+type CycleSummary struct {
+	CycleID     string               `json:"cycleId"`
+	Name        string               `json:"name"`
+	Build       string               `json:"build,omitempty"`
+	Environment string               `json:"environment,omitempty"`
+	Status      CycleStatus          `json:"status"`
+	Progress    CycleProgress        `json:"progress"`
+	ByAssignee  []CycleGroupProgress `json:"byAssignee"`
+	BySection   []CycleGroupProgress `json:"bySection"`
+	Failed      []TestExecution      `json:"failed"`
+	Blocked     []TestExecution      `json:"blocked"`
+	ReadyToSign bool                 `json:"readyToSign"`
+	SignOff     *CycleSignOff        `json:"signOff,omitempty"`
+}
+
+// ─────────────────────────────────────────────
+// API request types
+// ─────────────────────────────────────────────
+
+type CreateTestCycleRequest struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	ScenarioID   string   `json:"scenarioId"`
+	Build        string   `json:"build,omitempty"`
+	Environment  string   `json:"environment,omitempty"`
+	SectionIDs   []string `json:"sectionIds,omitempty"`
+	TestCaseIDs  []string `json:"testCaseIds,omitempty"`
+	AssigneeID   int      `json:"assigneeId,omitempty"`
+	AssigneeName string   `json:"assigneeName,omitempty"`
+}
+
+type UpdateTestCycleRequest struct {
+	Name        *string      `json:"name,omitempty"`
+	Description *string      `json:"description,omitempty"`
+	Build       *string      `json:"build,omitempty"`
+	Environment *string      `json:"environment,omitempty"`
+	Status      *CycleStatus `json:"status,omitempty"`
+}
+
+type UpdateExecutionRequest struct {
+	Status       *ExecutionStatus    `json:"status,omitempty"`
+	Comment      *string             `json:"comment,omitempty"`
+	Evidence     []ExecutionEvidence `json:"evidence,omitempty"`
+	AssigneeID   *int                `json:"assigneeId,omitempty"`
+	AssigneeName *string             `json:"assigneeName,omitempty"`
+}
+
+type AssignExecutionsRequest struct {
+	TestCaseIDs  []string `json:"testCaseIds"`
+	AssigneeID   int      `json:"assigneeId"`
+	AssigneeName string   `json:"assigneeName,omitempty"`
+}
+
+type SignOffTestCycleRequest struct {
+	Decision string `json:"decision"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// ─────────────────────────────────────────────
+// Progress computation
+// ─────────────────────────────────────────────
+
+// ComputeCycleProgress counts execution outcomes
+func ComputeCycleProgress(executions []TestExecution) CycleProgress {
+	p := CycleProgress{Total: len(executions)}
+	for _, e := range executions {
+		switch e.Status {
+		case ExecutionPassed:
+			p.Passed++
+		case ExecutionFailed:
+			p.Failed++
+		case ExecutionBlocked:
+			p.Blocked++
+		case ExecutionSkipped:
+			p.Skipped++
+		case ExecutionInProgress:
+			p.InProgress++
+		default:
+			p.NotRun++
+		}
+	}
+	p.Executed = p.Passed + p.Failed + p.Blocked + p.Skipped
+	if p.Total > 0 {
+		p.PercentComplete = roundPercent(float64(p.Executed) / float64(p.Total))
+	}
+	if p.Passed+p.Failed > 0 {
+		p.PassRate = roundPercent(float64(p.Passed) / float64(p.Passed+p.Failed))
+	}
+	return p
+}
+
+func (c *TestCycle) ComputeProgress() {
+	p := ComputeCycleProgress(c.Executions)
+	c.Progress = &p
+}
+
+func roundPercent(ratio float64) float64 {
+	return float64(int(ratio*1000+0.5)) / 10
+}
+
+func NewTestCycleID() string {
+	return fmt.Sprintf("cyc-%d", time.Now().UnixNano()%1000000000)
+}
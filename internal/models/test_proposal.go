@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Gap analysis enums
+// ─────────────────────────────────────────────
+
+// GapCategory is the kind of coverage gap a proposal fills
+type GapCategory string
+
+const (
+	GapValidation GapCategory = "validation"
+	GapPermission GapCategory = "permission"
+	GapEmptyState GapCategory = "empty_state"
+	GapBoundary   GapCategory = "boundary"
+	GapNegative   GapCategory = "negative"
+)
+
+// AllGapCategories is the default set analysed when a request names none
+var AllGapCategories = []GapCategory{GapValidation, GapPermission, GapEmptyState, GapBoundary, GapNegative}
+
+// IsValid reports whether c is a known gap category
+func (c GapCategory) IsValid() bool {
+	for _, known := range AllGapCategories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+type ProposalStatus string
+
+const (
+	ProposalPending  ProposalStatus = "pending"
+	ProposalAccepted ProposalStatus = "accepted"
+	ProposalRejected ProposalStatus = "rejected"
+)
+
+type GapAnalysisStatus string
+
+const (
+	GapAnalysisRunning   GapAnalysisStatus = "running"
+	GapAnalysisCompleted GapAnalysisStatus = "completed"
+	GapAnalysisFailed    GapAnalysisStatus = "failed"
+)
+
+// ─────────────────────────────────────────────
+// Gap analysis types
+// ─────────────────────────────────────────────
+
+// TestCaseProposal is a suggested test case waiting for review. It only
+// becomes a TestCase when accepted into a section.
+type TestCaseProposal struct {
+	ID                 string         `json:"id"`
+	ScenarioID         string         `json:"scenarioId"`
+	JobID              string         `json:"jobId"`
+	Category           GapCategory    `json:"category"`
+	Title              string         `json:"title"`
+	Description        string         `json:"description,omitempty"`
+	PreCondition       string         `json:"preCondition,omitempty"`
+	Steps              []TestStepV2   `json:"steps"`
+	Priority           Priority       `json:"priority"`
+	Type               string         `json:"type"`
+	Tags               []string       `json:"tags,omitempty"`
+	Rationale          string         `json:"rationale,omitempty"` // why the existing cases don't cover this
+	Route              string         `json:"route,omitempty"`
+	SuggestedSectionID string         `json:"suggestedSectionId,omitempty"`
+	BasedOnTestCaseID  string         `json:"basedOnTestCaseId,omitempty"`
+	Status             ProposalStatus `json:"status"`
+	TestCaseID         string         `json:"testCaseId,omitempty"` // set once accepted
+	ReviewedBy         int            `json:"reviewedBy,omitempty"`
+	ReviewedAt         *time.Time     `json:"reviewedAt,omitempty"`
+	RejectReason       string         `json:"rejectReason,omitempty"`
+	CreatedAt          time.Time      `json:"createdAt"`
+}
+
+// GapAnalysisJob tracks one gap analysis run for a scenario
+type GapAnalysisJob struct {
+	ID            string            `json:"id"`
+	ScenarioID    string            `json:"scenarioId"`
+	Status        GapAnalysisStatus `json:"status"`
+	Categories    []GapCategory     `json:"categories"`
+	Branch        string            `json:"branch,omitempty"`
+	UsedKnowledge bool              `json:"usedKnowledgeGraph"`
+	ProposalCount int               `json:"proposalCount"`
+	Error         string            `json:"error,omitempty"`
+	RequestedBy   int               `json:"requestedBy,omitempty"`
+	StartedAt     time.Time         `json:"startedAt"`
+	FinishedAt    *time.Time        `json:"finishedAt,omitempty"`
+}
+
+// ─────────────────────────────────────────────
+// API request types
+// ─────────────────────────────────────────────
+
+type StartGapAnalysisRequest struct {
+	Categories   []GapCategory `json:"categories,omitempty"`
+	SectionIDs   []string      `json:"sectionIds,omitempty"`
+	Branch       string        `json:"branch,omitempty"`
+	MaxProposals int           `json:"maxProposals,omitempty"`
+}
+
+// AcceptProposalRequest accepts a proposal into a section, optionally with edits
+type AcceptProposalRequest struct {
+	SectionID    string        `json:"sectionId"`
+	Title        *string       `json:"title,omitempty"`
+	Description  *string       `json:"description,omitempty"`
+	PreCondition *string       `json:"preCondition,omitempty"`
+	Steps        *[]TestStepV2 `json:"steps,omitempty"`
+	Priority     *Priority     `json:"priority,omitempty"`
+	Tags         *[]string     `json:"tags,omitempty"`
+}
+
+type RejectProposalRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewProposalID numbers proposals within the job that produced them
+func NewProposalID(jobID string, n int) string {
+	return fmt.Sprintf("prop-%s-%d", strings.TrimPrefix(jobID, "gap-"), n)
+}
+
+func NewGapAnalysisJobID() string {
+	return fmt.Sprintf("gap-%d", time.Now().UnixNano()%1000000000)
+}
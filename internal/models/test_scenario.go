@@ -13,8 +13,43 @@ type AuthConfig struct {
 	BaseURL    string `json:"baseUrl"`
 	ApiBaseURL string `json:"apiBaseUrl,omitempty"`
 	LoginURL   string `json:"loginUrl"`
-	Username   string `json:"username"`
-	Password   string `json:"password"`
+	// CredentialID references the test account in the encrypted credential
+	// store. Username and Password are only accepted on input and are moved
+	// into the store when the scenario is saved.
+	CredentialID string `json:"credentialId,omitempty"`
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+// HasInlineSecret reports whether the config still carries plain credentials
+func (a AuthConfig) HasInlineSecret() bool {
+	return a.Username != "" || a.Password != ""
+}
+
+// UsernameValue is what steps and prompts use for the username: a placeholder
+// when the account is stored, otherwise the inline value
+func (a AuthConfig) UsernameValue() string {
+	if a.CredentialID != "" {
+		return CredentialPlaceholder(a.CredentialID, CredentialUsername)
+	}
+	return a.Username
+}
+
+// PasswordValue is what steps use for the password
+func (a AuthConfig) PasswordValue() string {
+	if a.CredentialID != "" {
+		return CredentialPlaceholder(a.CredentialID, CredentialPassword)
+	}
+	return a.Password
+}
+
+// PromptPassword is the password as shown to a model: the placeholder, or a
+// mask when the account has not been stored yet
+func (a AuthConfig) PromptPassword() string {
+	if a.CredentialID == "" && a.Password != "" {
+		return "********"
+	}
+	return a.PasswordValue()
 }
 
 // ─────────────────────────────────────────────
@@ -47,6 +82,58 @@ type TestScenarioSheet struct {
 	TestCases []ParsedTestCase `json:"testCases"`
 }
 
+// ─────────────────────────────────────────────
+// Gherkin parsing types (internal, not stored)
+// ─────────────────────────────────────────────
+
+// GherkinStep is a single Given/When/Then/And/But/* line
+type GherkinStep struct {
+	Keyword   string     `json:"keyword"`
+	Text      string     `json:"text"`
+	DocString string     `json:"docString,omitempty"`
+	DataTable [][]string `json:"dataTable,omitempty"`
+	Line      int        `json:"line"`
+}
+
+// GherkinExamples is an Examples/Scenarios table attached to a Scenario Outline
+type GherkinExamples struct {
+	Name   string     `json:"name,omitempty"`
+	Tags   []string   `json:"tags,omitempty"`
+	Header []string   `json:"header"`
+	Rows   [][]string `json:"rows"`
+}
+
+// GherkinScenario is a Scenario, Example or Scenario Outline
+type GherkinScenario struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Outline     bool              `json:"outline,omitempty"`
+	Steps       []GherkinStep     `json:"steps"`
+	Examples    []GherkinExamples `json:"examples,omitempty"`
+	Line        int               `json:"line"`
+}
+
+// GherkinRule groups scenarios under a business rule (Gherkin 6+)
+type GherkinRule struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Background  []GherkinStep     `json:"background,omitempty"`
+	Scenarios   []GherkinScenario `json:"scenarios"`
+}
+
+// GherkinFeature is the root of a parsed .feature file
+type GherkinFeature struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Background  []GherkinStep     `json:"background,omitempty"`
+	Scenarios   []GherkinScenario `json:"scenarios"`
+	Rules       []GherkinRule     `json:"rules,omitempty"`
+	SourcePath  string            `json:"sourcePath,omitempty"`
+}
+
 // ─────────────────────────────────────────────
 // Enums
 // ─────────────────────────────────────────────
@@ -60,6 +147,15 @@ const (
 	PriorityCritical Priority = "critical"
 )
 
+// IsValid reports whether p is a known priority
+func (p Priority) IsValid() bool {
+	switch p {
+	case PriorityLow, PriorityMedium, PriorityHigh, PriorityCritical:
+		return true
+	}
+	return false
+}
+
 type TestCaseStatus string
 
 const (
@@ -69,6 +165,15 @@ const (
 	TCStatusDeprecated TestCaseStatus = "deprecated"
 )
 
+// IsValid reports whether s is a known test case status
+func (s TestCaseStatus) IsValid() bool {
+	switch s {
+	case TCStatusDraft, TCStatusReady, TCStatusBlocked, TCStatusDeprecated:
+		return true
+	}
+	return false
+}
+
 type ScenarioStatus string
 
 const (
@@ -102,12 +207,14 @@ type AutomationTest struct {
 	LastRunAt       string              `json:"lastRunAt,omitempty"`
 	RunDurationMs   int64               `json:"runDurationMs,omitempty"`
 	Steps           []RecordingStep     `json:"steps,omitempty"`
+	Parameters      []any               `json:"parameters,omitempty"` // one run per row; <name> placeholders in the steps are filled from it
 	VideoURL        string              `json:"videoUrl,omitempty"`
 	ScreenshotURL   string              `json:"screenshotUrl,omitempty"`
 	StepResults     []TestStepResult    `json:"stepResults,omitempty"`
 	Log             string              `json:"log,omitempty"`
 	ErrorMessage    string              `json:"errorMessage,omitempty"`
 	FailedStepIndex *int                `json:"failedStepIndex,omitempty"`
+	Environment     *EnvironmentRef     `json:"environment,omitempty"`
 }
 
 // TestStepV2 is a single step within a test case
@@ -134,6 +241,8 @@ type TestCase struct {
 	Status         TestCaseStatus  `json:"status"`
 	AutomationTest *AutomationTest `json:"automationTest,omitempty"`
 	Note           string          `json:"note,omitempty"`
+	Parameters     []any           `json:"parameters,omitempty"` // data-driven rows, e.g. from Gherkin Examples tables
+	Issues         []IssueLink     `json:"issues,omitempty"`     // GitLab issues and epics this test case verifies
 	CreatedAt      string          `json:"createdAt"`
 	UpdatedAt      string          `json:"updatedAt"`
 }
@@ -160,23 +269,23 @@ type ScenarioStats struct {
 
 // TestScenario is the top-level entity stored in Redis
 type TestScenario struct {
-	ID             string         `json:"id"`
-	Title          string         `json:"title"`
-	Description    string         `json:"description,omitempty"`
-	Sections       []TestSection  `json:"sections"`
-	ProjectID      string         `json:"projectId,omitempty"`
-	ProjectName    string         `json:"projectName,omitempty"`
-	Status         ScenarioStatus `json:"status"`
-	Error          string         `json:"error,omitempty"`
-	Stats          *ScenarioStats `json:"stats,omitempty"`
-	AuthConfig     AuthConfig     `json:"authConfig"`
-	CreatorID      int            `json:"creatorId,omitempty"`
-	CreatedAt      time.Time      `json:"createdAt"`
-	UpdatedAt      time.Time      `json:"updatedAt"`
-	CreatedBy      string         `json:"createdBy,omitempty"`
+	ID          string         `json:"id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Sections    []TestSection  `json:"sections"`
+	ProjectID   string         `json:"projectId,omitempty"`
+	ProjectName string         `json:"projectName,omitempty"`
+	Status      ScenarioStatus `json:"status"`
+	Error       string         `json:"error,omitempty"`
+	Stats       *ScenarioStats `json:"stats,omitempty"`
+	AuthConfig  AuthConfig     `json:"authConfig"`
+	CreatorID   int            `json:"creatorId,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	CreatedBy   string         `json:"createdBy,omitempty"`
 
 	// Internal: parsed XLSX sheets (kept for generation, not exposed in API)
-	Sheets         []TestScenarioSheet `json:"sheets,omitempty"`
+	Sheets []TestScenarioSheet `json:"sheets,omitempty"`
 }
 
 // ─────────────────────────────────────────────
@@ -211,8 +320,9 @@ func (s *TestScenario) ComputeStats() {
 // ─────────────────────────────────────────────
 
 type UpdateScenarioRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Title       *string     `json:"title,omitempty"`
+	Description *string     `json:"description,omitempty"`
+	AuthConfig  *AuthConfig `json:"authConfig,omitempty"` // a new username/password rotates the stored credential
 }
 
 type UpdateTestCaseRequest struct {
@@ -228,13 +338,13 @@ type UpdateTestCaseRequest struct {
 }
 
 type CreateTestCaseRequest struct {
-	Title        string       `json:"title"`
-	Description  string       `json:"description,omitempty"`
-	PreCondition string       `json:"preCondition,omitempty"`
-	Steps        []TestStepV2 `json:"steps,omitempty"`
-	Tags         []string     `json:"tags,omitempty"`
-	Priority     Priority     `json:"priority,omitempty"`
-	Type         string       `json:"type,omitempty"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description,omitempty"`
+	PreCondition string         `json:"preCondition,omitempty"`
+	Steps        []TestStepV2   `json:"steps,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	Priority     Priority       `json:"priority,omitempty"`
+	Type         string         `json:"type,omitempty"`
 	Status       TestCaseStatus `json:"status,omitempty"`
 }
 
@@ -242,6 +352,47 @@ type ReorderTestCasesRequest struct {
 	OrderedIDs []string `json:"orderedIds"`
 }
 
+type CreateSectionRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+type UpdateSectionRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+type ReorderSectionsRequest struct {
+	OrderedIDs []string `json:"orderedIds"`
+}
+
+// MoveTestCasesRequest moves or copies test cases into a section of the same
+// or another scenario. Position is 1-based; 0 appends to the end.
+type MoveTestCasesRequest struct {
+	TestCaseIDs      []string `json:"testCaseIds"`
+	TargetScenarioID string   `json:"targetScenarioId,omitempty"`
+	TargetSectionID  string   `json:"targetSectionId"`
+	Mode             string   `json:"mode,omitempty"` // "move" (default) or "copy"
+	Position         int      `json:"position,omitempty"`
+}
+
+// TagUpdate describes a tag change applied to many test cases at once
+type TagUpdate struct {
+	Add    []string  `json:"add,omitempty"`
+	Remove []string  `json:"remove,omitempty"`
+	Set    *[]string `json:"set,omitempty"`
+}
+
+// BulkTestCaseRequest applies one operation to many test cases
+type BulkTestCaseRequest struct {
+	TestCaseIDs []string        `json:"testCaseIds"`
+	Action      string          `json:"action"` // "delete" or "update"
+	Tags        *TagUpdate      `json:"tags,omitempty"`
+	Priority    *Priority       `json:"priority,omitempty"`
+	Status      *TestCaseStatus `json:"status,omitempty"`
+	Type        *string         `json:"type,omitempty"`
+}
+
 type GenerateTestCaseRequest struct {
 	TestCaseIDs []string `json:"testCaseIds"`
 }
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// ─────────────────────────────────────────────
+// Requirement traceability
+// ─────────────────────────────────────────────
+
+// Issue link types
+const (
+	IssueLinkIssue = "issue"
+	IssueLinkEpic  = "epic"
+)
+
+// IssueLink ties a test case to a GitLab issue or epic it verifies.
+// Issues are addressed by ProjectID, epics by GroupID.
+type IssueLink struct {
+	Type      string    `json:"type"`
+	ProjectID string    `json:"projectId,omitempty"`
+	GroupID   string    `json:"groupId,omitempty"`
+	IID       int64     `json:"iid"`
+	Title     string    `json:"title,omitempty"`
+	State     string    `json:"state,omitempty"`
+	WebURL    string    `json:"webUrl,omitempty"`
+	LinkedBy  int       `json:"linkedBy,omitempty"`
+	LinkedAt  time.Time `json:"linkedAt"`
+}
+
+// Matches reports whether the link points at the given issue or epic
+func (l IssueLink) Matches(linkType, parentID string, iid int64) bool {
+	if l.Type != linkType || l.IID != iid {
+		return false
+	}
+	if linkType == IssueLinkEpic {
+		return l.GroupID == parentID
+	}
+	return l.ProjectID == parentID
+}
+
+// LinkIssueRequest is the body for linking a test case to an issue or epic
+type LinkIssueRequest struct {
+	Type      string `json:"type,omitempty"` // "issue" (default) or "epic"
+	ProjectID string `json:"projectId,omitempty"`
+	GroupID   string `json:"groupId,omitempty"`
+	IID       int64  `json:"iid"`
+}
+
+// LinkedTestCase is a test case found through a traceability lookup
+type LinkedTestCase struct {
+	ScenarioID       string              `json:"scenarioId"`
+	ScenarioTitle    string              `json:"scenarioTitle"`
+	SectionID        string              `json:"sectionId"`
+	SectionTitle     string              `json:"sectionTitle"`
+	TestCaseID       string              `json:"testCaseId"`
+	Code             string              `json:"code"`
+	Title            string              `json:"title"`
+	Status           TestCaseStatus      `json:"status"`
+	AutomationStatus AutomationRunStatus `json:"automationStatus,omitempty"`
+	LastRunAt        string              `json:"lastRunAt,omitempty"`
+}
+
+// IssueCoverage is the traceability view of a single issue or epic
+type IssueCoverage struct {
+	Type       string           `json:"type"`
+	ParentID   string           `json:"parentId"`
+	IID        int64            `json:"iid"`
+	Title      string           `json:"title,omitempty"`
+	State      string           `json:"state,omitempty"`
+	WebURL     string           `json:"webUrl,omitempty"`
+	Labels     []string         `json:"labels,omitempty"`
+	TestCases  []LinkedTestCase `json:"testCases"`
+	Recordings []string         `json:"recordings,omitempty"`
+	Covered    bool             `json:"covered"`
+	Passing    int              `json:"passing"`
+	Failing    int              `json:"failing"`
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ─────────────────────────────────────────────
+// Outgoing webhooks
+// ─────────────────────────────────────────────
+
+// Webhook events a subscription can filter on
+const (
+	WebhookGenerationDone   = "generation.done"
+	WebhookGenerationFailed = "generation.failed"
+	WebhookRunPassed        = "run.passed"
+	WebhookRunFailed        = "run.failed"
+	WebhookFixDone          = "fix.done"
+	WebhookFixFailed        = "fix.failed"
+	// WebhookPing is only sent by the test-fire endpoint
+	WebhookPing = "ping"
+)
+
+// WebhookEvents lists the events that can be subscribed to
+var WebhookEvents = []string{
+	WebhookGenerationDone,
+	WebhookGenerationFailed,
+	WebhookRunPassed,
+	WebhookRunFailed,
+	WebhookFixDone,
+	WebhookFixFailed,
+}
+
+// Webhook is a project's subscription to QA events. The signing secret is
+// stored separately and only returned when it is created or rotated.
+type Webhook struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"projectId"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+	// Events filters what is delivered; empty means every event
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedBy int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Subscribes reports whether the webhook wants an event
+func (w *Webhook) Subscribes(event string) bool {
+	if event == WebhookPing || len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveWebhookRequest creates or updates a webhook. On update, omitted fields
+// are left as they are.
+type SaveWebhookRequest struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Events       []string `json:"events"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotateSecret"`
+}
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryRetrying  = "retrying"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent to one webhook, with the outcome of its
+// latest attempt
+type WebhookDelivery struct {
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhookId"`
+	Event     string          `json:"event"`
+	EventID   string          `json:"eventId,omitempty"` // stream event the delivery was made for
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// ResponseCode is from the latest attempt; response bodies are not kept
+	ResponseCode  int        `json:"responseCode,omitempty"`
+	Error         string     `json:"error,omitempty"`
+	DurationMs    int64      `json:"durationMs,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"qa-extension-backend/handlers"
 	"qa-extension-backend/middleware"
 	"qa-extension-backend/routes"
+	"qa-extension-backend/services"
 	"syscall"
 
 	"github.com/gin-gonic/gin"
@@ -42,6 +44,14 @@ func main() {
 
 	fmt.Println("Redis connected successfully")
 
+	// Build the list/search indexes on first boot or after an index format change
+	go database.EnsureSearchIndexes(context.Background())
+	go database.SealLegacyCredentials(context.Background())
+	// Relay cancel and reply messages to the interactive agent runs of this instance
+	go agent.ListenForAgentControl(context.Background())
+	// Deliver run, generation and fix events to project webhooks
+	go services.RunWebhookDispatcher(context.Background())
+
 	// Cleanup Playwright on exit
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -61,6 +71,8 @@ func main() {
 	api.POST("/auth/login", routes.LoginEndpoint)
 	api.GET("/auth/gitlab/callback", routes.AuthCallbackEndpoint)
 	api.GET("/auth/session", routes.GetSessionEndpoint)
+	// GitLab project webhooks, verified by the project's secret token
+	api.POST("/webhooks/gitlab", routes.ReceiveGitLabWebhook)
 
 	protected := api.Group("/")
 	protected.Use(middleware.AuthMiddleware())
@@ -72,26 +84,74 @@ func main() {
 		protected.PATCH("/recordings/:id", handlers.UpdateRecording)
 		protected.DELETE("/recordings/:id", handlers.DeleteRecording)
 		protected.POST("/recordings/bulk-delete", handlers.BulkDeleteRecordings)
+		protected.POST("/recordings/import", handlers.ImportRecording)
+		protected.POST("/recordings/:id/test-case", handlers.ConvertRecordingToTestCase)
 
 		protected.POST("/test-scenarios/upload", handlers.UploadScenario)
+		protected.POST("/test-scenarios/import/gherkin", handlers.ImportGherkinScenario)
+		protected.POST("/test-scenarios/import/gherkin/repo", handlers.ImportGherkinFromRepo)
 		protected.GET("/test-scenarios", handlers.ListScenarios)
 		protected.GET("/test-scenarios/:id", handlers.GetScenario)
 		protected.PATCH("/test-scenarios/:id", handlers.UpdateScenario)
 		protected.DELETE("/test-scenarios/:id", handlers.DeleteScenario)
 		protected.POST("/test-scenarios/:id/generate", handlers.GenerateTests)
 		protected.GET("/test-scenarios/:id/stream", handlers.StreamEvents)
+		protected.GET("/stream/history/:resourceId", handlers.GetStreamHistory)
 		protected.POST("/test-scenarios/bulk-delete", handlers.BulkDeleteScenarios)
-		
+
 		// Test case CRUD endpoints
+		protected.POST("/test-scenarios/:id/sections", handlers.CreateSection)
+		protected.PATCH("/test-scenarios/:id/sections/reorder", handlers.ReorderSections)
+		protected.PATCH("/test-scenarios/:id/sections/:sectionId", handlers.UpdateSection)
+		protected.DELETE("/test-scenarios/:id/sections/:sectionId", handlers.DeleteSection)
+		protected.POST("/test-scenarios/:id/test-cases/move", handlers.MoveTestCases)
+		protected.POST("/test-scenarios/:id/test-cases/bulk", handlers.BulkTestCases)
+		protected.POST("/test-scenarios/:id/gap-analysis", handlers.StartGapAnalysis)
+		protected.GET("/test-scenarios/:id/gap-analysis", handlers.GetGapAnalysis)
+		protected.GET("/test-scenarios/:id/proposals", handlers.ListProposals)
+		protected.POST("/test-scenarios/:id/proposals/:proposalId/accept", handlers.AcceptProposal)
+		protected.POST("/test-scenarios/:id/proposals/:proposalId/reject", handlers.RejectProposal)
 		protected.POST("/test-scenarios/:id/sections/:sectionId/test-cases", handlers.AddTestCase)
 		protected.PATCH("/test-scenarios/:id/sections/:sectionId/test-cases/reorder", handlers.ReorderTestCases)
 		protected.PATCH("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId", handlers.UpdateTestCase)
+		protected.DELETE("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId", handlers.DeleteTestCase)
 		protected.POST("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId/run", handlers.RunScenarioTestCase)
+		protected.POST("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId/issues", handlers.LinkTestCaseIssue)
+		protected.DELETE("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId/issues", handlers.UnlinkTestCaseIssue)
+		protected.POST("/test-scenarios/:id/sections/:sectionId/test-cases/:tcId/recording", handlers.CreateRecordingFromAutomation)
+
+		// Version history
+		protected.GET("/test-scenarios/:id/versions", handlers.ListScenarioVersions)
+		protected.GET("/test-scenarios/:id/versions/diff", handlers.DiffScenarioVersions)
+		protected.GET("/test-scenarios/:id/versions/:version", handlers.GetScenarioVersion)
+		protected.POST("/test-scenarios/:id/versions/:version/restore", handlers.RestoreScenarioVersion)
+
+		// Playwright spec export
+		protected.GET("/test-scenarios/:id/export/playwright", handlers.ExportScenarioPlaywright)
+		protected.GET("/test-scenarios/:id/export/results", handlers.ExportScenarioResults)
+		protected.POST("/test-scenarios/:id/export/playwright/commit", handlers.CommitScenarioPlaywright)
+		protected.GET("/recordings/:id/export/playwright", handlers.ExportRecordingPlaywright)
+		protected.POST("/recordings/:id/export/playwright/commit", handlers.CommitRecordingPlaywright)
 
 		protected.POST("/recordings/:id/run", handlers.RunRecording)
 
-		// Public SSE stream - no auth required, the connection will be authenticated via session_id cookie
-		api.GET("/stream", handlers.StreamEvents)
+		// Test execution cycles
+		protected.POST("/test-cycles", handlers.CreateTestCycle)
+		protected.GET("/test-cycles", handlers.ListTestCycles)
+		protected.GET("/test-cycles/:id", handlers.GetTestCycle)
+		protected.PATCH("/test-cycles/:id", handlers.UpdateTestCycle)
+		protected.DELETE("/test-cycles/:id", handlers.DeleteTestCycle)
+		protected.GET("/test-cycles/:id/summary", handlers.GetTestCycleSummary)
+		protected.GET("/test-cycles/:id/export", handlers.ExportTestCycle)
+		protected.POST("/test-cycles/:id/assign", handlers.AssignExecutions)
+		protected.POST("/test-cycles/:id/run", handlers.RunTestCycleAutomation)
+		protected.POST("/test-cycles/:id/sign-off", handlers.SignOffTestCycle)
+		protected.PATCH("/test-cycles/:id/executions/:tcId", handlers.UpdateExecution)
+
+		// SSE stream - authenticated via the session_id cookie or X-Session-ID; only the
+		// CORS preflight is public
+		api.OPTIONS("/stream", handlers.StreamEvents)
+		protected.GET("/stream", handlers.StreamEvents)
 
 		protected.POST("/auth/logout", routes.LogoutEndpoint)
 		protected.GET("/current-user", routes.GetUser)
@@ -113,6 +173,45 @@ func main() {
 		protected.DELETE("/projects/:id/issues/:issue_id/links/:link_id", routes.DeleteIssueLink)
 		protected.POST("/projects/:id/issues/:issue_id/children", routes.CreateChildIssue)
 		protected.DELETE("/projects/:id/issues/:issue_id/children/:child_id", routes.UnlinkChildIssue)
+		protected.GET("/projects/:id/issues/:issue_id/test-cases", handlers.GetIssueTestCases)
+		protected.GET("/projects/:id/traceability", handlers.GetProjectTraceability)
+		protected.POST("/projects/:id/bugs/from-failure", handlers.CreateBugFromFailure)
+		protected.GET("/projects/:id/duplicates", handlers.GetProjectDuplicates)
+		protected.POST("/projects/:id/duplicates/merge", handlers.MergeDuplicates)
+		protected.GET("/projects/:id/redaction-policy", handlers.GetRedactionPolicy)
+		protected.PUT("/projects/:id/redaction-policy", handlers.UpdateRedactionPolicy)
+		protected.DELETE("/projects/:id/redaction-policy", handlers.ResetRedactionPolicy)
+		protected.GET("/projects/:id/credentials", handlers.ListProjectCredentials)
+		protected.POST("/projects/:id/credentials", handlers.CreateProjectCredential)
+		protected.PUT("/credentials/:credentialId", handlers.UpdateCredential)
+		protected.DELETE("/credentials/:credentialId", handlers.DeleteCredential)
+		protected.GET("/projects/:id/environments", handlers.ListEnvironments)
+		protected.POST("/projects/:id/environments", handlers.CreateEnvironment)
+		protected.GET("/environments/:envId", handlers.GetEnvironment)
+		protected.PUT("/environments/:envId", handlers.UpdateEnvironment)
+		protected.DELETE("/environments/:envId", handlers.DeleteEnvironment)
+		protected.GET("/projects/:id/webhooks", handlers.ListProjectWebhooks)
+		protected.POST("/projects/:id/webhooks", handlers.CreateProjectWebhook)
+		protected.GET("/webhooks/:webhookId", handlers.GetWebhook)
+		protected.PUT("/webhooks/:webhookId", handlers.UpdateWebhook)
+		protected.DELETE("/webhooks/:webhookId", handlers.DeleteWebhook)
+		protected.GET("/webhooks/:webhookId/deliveries", handlers.ListWebhookDeliveries)
+		protected.POST("/webhooks/:webhookId/test", handlers.TestWebhook)
+		protected.GET("/projects/:id/gitlab-automation", routes.GetGitLabAutomation)
+		protected.PUT("/projects/:id/gitlab-automation", routes.SaveGitLabAutomation)
+		protected.GET("/projects/:id/gitlab-automation/log", routes.ListGitLabAutomationRuns)
+		protected.GET("/projects/:id/api-tokens", handlers.ListProjectAPITokens)
+		protected.POST("/projects/:id/api-tokens", handlers.CreateProjectAPIToken)
+		protected.DELETE("/projects/:id/api-tokens/:tokenId", handlers.RevokeProjectAPIToken)
+		protected.GET("/api-tokens", handlers.ListAPITokens)
+		protected.POST("/api-tokens", handlers.CreateAPIToken)
+		protected.DELETE("/api-tokens/:tokenId", handlers.RevokeAPIToken)
+		protected.POST("/projects/:id/suite-runs", handlers.StartProjectSuiteRun)
+		protected.GET("/projects/:id/suite-runs", handlers.ListProjectSuiteRuns)
+		protected.GET("/suite-runs/:runId", handlers.GetSuiteRun)
+		protected.GET("/suite-runs/:runId/export", handlers.ExportSuiteRun)
+		protected.POST("/bugs/from-failure", handlers.CreateBugFromFailure)
+		protected.GET("/groups/:id/epics/:epic_iid/test-cases", handlers.GetEpicTestCases)
 		protected.GET("/projects/:id/members", routes.GetProjectMembers)
 		protected.GET("/projects/:id/branches", routes.GetProjectBranches)
 		protected.GET("/projects/:id/boards", routes.GetProjectBoards)
@@ -136,6 +235,12 @@ func main() {
 		protected.GET("/issues/:id", routes.GetIssue)
 		protected.GET("/issues/open-ai-test", routes.SmartAutoCompleteIssueDescription)
 		protected.POST("/agent/chat", routes.ChatWithAgent)
+		protected.GET("/agent/ws", routes.AgentWebSocket)
+		protected.GET("/agent/sessions", routes.ListAgentSessions)
+		protected.GET("/agent/sessions/:session_id", routes.GetAgentSession)
+		protected.PATCH("/agent/sessions/:session_id", routes.RenameAgentSession)
+		protected.DELETE("/agent/sessions/:session_id", routes.DeleteAgentSession)
+		protected.GET("/agent/sessions/:session_id/export", routes.ExportAgentSession)
 		protected.POST("/agent/fix-issue", routes.FixIssueWithAgent)
 		protected.GET("/agent/fix-sessions", routes.ListFixSessions)
 		protected.GET("/agent/fix-status/:session_id", routes.GetFixStatus)
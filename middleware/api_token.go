@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"qa-extension-backend/auth"
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authenticateAPIToken resolves an API token to the GitLab user and token it
+// acts with. Handlers see the same "token" and "session_id" values as for a
+// browser session, plus "api_token" and "user_id".
+func authenticateAPIToken(c *gin.Context, secret string) {
+	ctx := c.Request.Context()
+	tok, err := database.LookupAPIToken(ctx, secret)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid API token"})
+		return
+	}
+	if err := services.AuthorizeAPIToken(tok, c.Request.Method, c.FullPath(), c.Param("id"), time.Now()); err != nil {
+		status := http.StatusForbidden
+		if tok.Expired(time.Now()) {
+			status = http.StatusUnauthorized
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := database.APITokenGitLabToken(ctx, tok.ID)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, database.ErrCredentialKeyMissing) {
+			status = http.StatusServiceUnavailable
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": "API token has no usable GitLab credentials: " + err.Error()})
+		return
+	}
+
+	sessionID := ""
+	if tok.GitLabCredentials == models.APITokenGitLabGrant {
+		// The grant is shared with the creator's session, other tokens and
+		// automations; GrantToken refreshes it once for all of them
+		sessionID = auth.GrantSessionID(tok.UserID)
+		if !token.Valid() {
+			token, err = auth.GrantToken(ctx, tok.UserID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API token's GitLab authorization expired; sign in again and recreate the token"})
+				return
+			}
+		}
+	}
+
+	if err := database.TouchAPIToken(ctx, tok, c.ClientIP()); err != nil {
+		log.Printf("[APIToken] Failed to record use of token %s: %v", tok.ID, err)
+	}
+
+	c.Set("token", token)
+	c.Set("session_id", sessionID)
+	c.Set("api_token", tok)
+	c.Set("user_id", tok.UserID)
+	c.Next()
+}
@@ -2,18 +2,31 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
+
 	"qa-extension-backend/auth"
+	"qa-extension-backend/database"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Try cookie first, then fallback to X-Session-ID header (for web app)
+		// Try cookie first, then fallback to X-Session-ID header (for web app),
+		// then to an Authorization bearer credential (for the qa CLI and CI jobs)
 		sessionID, err := c.Cookie("session_id")
 		if err != nil || sessionID == "" {
 			sessionID = c.GetHeader("X-Session-ID")
 		}
+		if sessionID == "" {
+			if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+				sessionID = strings.TrimSpace(bearer)
+			}
+			if strings.HasPrefix(sessionID, database.APITokenPrefix) {
+				authenticateAPIToken(c, sessionID)
+				return
+			}
+		}
 
 		if sessionID == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: No session found"})
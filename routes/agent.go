@@ -9,10 +9,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"qa-extension-backend/agent"
+	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/oauth2"
@@ -23,13 +26,9 @@ import (
 
 func ChatWithAgent(c *gin.Context) {
 	var req struct {
-		SessionID   string `json:"session_id"`
-		Input       string `json:"input"`
-		Attachments []struct {
-			Name     string `json:"name"`
-			MimeType string `json:"mimeType"`
-			Data     string `json:"data"`
-		} `json:"attachments"`
+		SessionID   string           `json:"session_id"`
+		Input       string           `json:"input"`
+		Attachments []chatAttachment `json:"attachments"`
 	}
 	if err := c.BindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -52,117 +51,18 @@ func ChatWithAgent(c *gin.Context) {
 		return
 	}
 
-	// We use a fixed userID for now
-	userID := "user"
-
-	sessionService := agent.GetSessionService()
-
-	// Check if session exists, if not create it
-	_, err = sessionService.Get(ctx, &session.GetRequest{
-		AppName:   "qa_extension",
-		UserID:    userID,
-		SessionID: req.SessionID,
-	})
-
+	// Sessions belong to the signed-in GitLab user
+	currentUserID, err := identity.GetCurrentUserID(c)
 	if err != nil {
-		// Attempt to create
-		_, err = sessionService.Create(ctx, &session.CreateRequest{
-			AppName:   "qa_extension",
-			UserID:    userID,
-			SessionID: req.SessionID,
-		})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session: " + err.Error()})
-			return
-		}
-	}
-
-	// Process input - check for slash commands
-	input := req.Input
-	if agent.IsSlashCommand(input) {
-		// Try built-in slash commands first
-		if cmd, args, matched := agent.MatchSlashCommand(input); matched {
-			log.Printf("[ChatWithAgent] Matched built-in slash command: %s", cmd.ToolName)
-
-			// Execute the tool pre-emptively
-			if cmd.ToolName != "" && agent.HasToolExecutor(cmd.ToolName) {
-				toolResult, execErr := agent.ExecuteTool(cmd.ToolName, ctx, args)
-				if execErr != nil {
-					log.Printf("[ChatWithAgent] Tool execution error: %v", execErr)
-					// Continue to LLM with error context
-					input = fmt.Sprintf("%s\n\n[Tool execution error: %v]", input, execErr)
-				} else {
-					// Serialize tool result and inject into context
-					resultJSON, _ := json.MarshalIndent(toolResult, "", "  ")
-					log.Printf("[ChatWithAgent] Tool executed successfully, result length: %d", len(resultJSON))
-
-					// Prepend tool result to user input for LLM to format
-					input = fmt.Sprintf(`%s
-
-[PRE-EXECUTED TOOL RESULT]
-The slash command "%s" was pre-executed and returned:
-%s
-
-Please format this result nicely for the user, presenting the key information in a clear, readable format.`, input, input, string(resultJSON))
-				}
-			} else if cmd.ToolName == "" {
-				// /help command - special handling
-				commands := agent.GetAllSlashCommands(ctx)
-				var helpText strings.Builder
-				helpText.WriteString("Available slash commands:\n\n")
-				for _, c := range commands {
-					helpText.WriteString(fmt.Sprintf("- %s: %s\n", c.Pattern, c.Description))
-				}
-				helpText.WriteString("\nYou can also type naturally and I'll help you with GitLab issues and test automation.")
-				input = fmt.Sprintf(`The user invoked /help. Display the available commands:
-
-%s`, helpText.String())
-			}
-		} else if cmd, args, matched := agent.MatchCustomSlashCommand(ctx, input); matched {
-			log.Printf("[ChatWithAgent] Matched custom slash command: %s", cmd.Name)
-
-			// Execute the custom command's tool
-			if cmd.ToolName != "" && agent.HasToolExecutor(cmd.ToolName) {
-				toolResult, execErr := agent.ExecuteTool(cmd.ToolName, ctx, args)
-				if execErr != nil {
-					log.Printf("[ChatWithAgent] Custom tool execution error: %v", execErr)
-					input = fmt.Sprintf("%s\n\n[Tool execution error: %v]", input, execErr)
-				} else {
-					resultJSON, _ := json.MarshalIndent(toolResult, "", "  ")
-					log.Printf("[ChatWithAgent] Custom tool executed successfully, result length: %d", len(resultJSON))
-
-					// Prepend tool result to user input for LLM to format
-					input = fmt.Sprintf(`%s
-
-[PRE-EXECUTED TOOL RESULT - Custom Command: %s]
-The custom command "%s" was pre-executed and returned:
-%s
-
-Please format this result nicely for the user.`, input, cmd.Name, cmd.Name, string(resultJSON))
-				}
-			}
-		}
-	}
-
-	// Build content parts: text first, then any image attachments
-	parts := []*genai.Part{genai.NewPartFromText(input)}
-	for _, att := range req.Attachments {
-		decoded, err := base64.StdEncoding.DecodeString(att.Data)
-		if err != nil {
-			log.Printf("[ChatWithAgent] Failed to decode attachment %s: %v", att.Name, err)
-			continue
-		}
-		mimeType := att.MimeType
-		if mimeType == "" {
-			mimeType = "image/png" // default fallback
-		}
-		log.Printf("[ChatWithAgent] Adding attachment: %s (%s, %d bytes)", att.Name, mimeType, len(decoded))
-		parts = append(parts, genai.NewPartFromBytes(decoded, mimeType))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return
 	}
+	userID := strconv.Itoa(currentUserID)
 
-	content := &genai.Content{
-		Role:  genai.RoleUser,
-		Parts: parts,
+	content, err := prepareAgentChat(ctx, userID, req.SessionID, req.Input, req.Attachments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.Header("Content-Type", "text/event-stream")
@@ -182,6 +82,8 @@ Please format this result nicely for the user.`, input, cmd.Name, cmd.Name, stri
 	if val := ctx.Value("session_id"); val != nil {
 		agentCtx = context.WithValue(agentCtx, "session_id", val)
 	}
+	// Tool and progress events of this chat are only streamed back to its user
+	agentCtx = database.WithStreamOwner(agentCtx, database.StreamOwner{UserID: currentUserID})
 
 	// Create a wrapper to consume the iterator and send to a channel
 	type resultEvent struct {
@@ -270,4 +172,126 @@ Please format this result nicely for the user.`, input, cmd.Name, cmd.Name, stri
 			}
 		}
 	})
-}
\ No newline at end of file
+}
+
+// chatAttachment is an image sent along with a chat message
+type chatAttachment struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// prepareAgentChat makes sure the ADK session exists and turns the user's
+// input into model content: slash commands are pre-executed and their result
+// inlined, and image attachments are added as parts.
+func prepareAgentChat(ctx context.Context, userID, sessionID, input string, attachments []chatAttachment) (*genai.Content, error) {
+	sessionService := agent.GetSessionService()
+
+	// Check if session exists, if not create it
+	_, err := sessionService.Get(ctx, &session.GetRequest{
+		AppName:   "qa_extension",
+		UserID:    userID,
+		SessionID: sessionID,
+	})
+
+	if errors.Is(err, agent.ErrSessionNotFound) {
+		// Attempt to create
+		_, err = sessionService.Create(ctx, &session.CreateRequest{
+			AppName:   "qa_extension",
+			UserID:    userID,
+			SessionID: sessionID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create session: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to load session: %w", err)
+	}
+
+	// Process input - check for slash commands
+	if agent.IsSlashCommand(input) {
+		// Try built-in slash commands first
+		if cmd, args, matched := agent.MatchSlashCommand(input); matched {
+			log.Printf("[ChatWithAgent] Matched built-in slash command: %s", cmd.ToolName)
+
+			// Execute the tool pre-emptively
+			if cmd.ToolName != "" && agent.HasToolExecutor(cmd.ToolName) {
+				toolResult, execErr := agent.ExecuteTool(cmd.ToolName, ctx, args)
+				if execErr != nil {
+					log.Printf("[ChatWithAgent] Tool execution error: %v", execErr)
+					// Continue to LLM with error context
+					input = fmt.Sprintf("%s\n\n[Tool execution error: %v]", input, execErr)
+				} else {
+					// Serialize tool result and inject into context
+					resultJSON, _ := json.MarshalIndent(toolResult, "", "  ")
+					log.Printf("[ChatWithAgent] Tool executed successfully, result length: %d", len(resultJSON))
+
+					// Prepend tool result to user input for LLM to format
+					input = fmt.Sprintf(`%s
+
+[PRE-EXECUTED TOOL RESULT]
+The slash command "%s" was pre-executed and returned:
+%s
+
+Please format this result nicely for the user, presenting the key information in a clear, readable format.`, input, input, string(resultJSON))
+				}
+			} else if cmd.ToolName == "" {
+				// /help command - special handling
+				commands := agent.GetAllSlashCommands(ctx)
+				var helpText strings.Builder
+				helpText.WriteString("Available slash commands:\n\n")
+				for _, c := range commands {
+					helpText.WriteString(fmt.Sprintf("- %s: %s\n", c.Pattern, c.Description))
+				}
+				helpText.WriteString("\nYou can also type naturally and I'll help you with GitLab issues and test automation.")
+				input = fmt.Sprintf(`The user invoked /help. Display the available commands:
+
+%s`, helpText.String())
+			}
+		} else if cmd, args, matched := agent.MatchCustomSlashCommand(ctx, input); matched {
+			log.Printf("[ChatWithAgent] Matched custom slash command: %s", cmd.Name)
+
+			// Execute the custom command's tool
+			if cmd.ToolName != "" && agent.HasToolExecutor(cmd.ToolName) {
+				toolResult, execErr := agent.ExecuteTool(cmd.ToolName, ctx, args)
+				if execErr != nil {
+					log.Printf("[ChatWithAgent] Custom tool execution error: %v", execErr)
+					input = fmt.Sprintf("%s\n\n[Tool execution error: %v]", input, execErr)
+				} else {
+					resultJSON, _ := json.MarshalIndent(toolResult, "", "  ")
+					log.Printf("[ChatWithAgent] Custom tool executed successfully, result length: %d", len(resultJSON))
+
+					// Prepend tool result to user input for LLM to format
+					input = fmt.Sprintf(`%s
+
+[PRE-EXECUTED TOOL RESULT - Custom Command: %s]
+The custom command "%s" was pre-executed and returned:
+%s
+
+Please format this result nicely for the user.`, input, cmd.Name, cmd.Name, string(resultJSON))
+				}
+			}
+		}
+	}
+
+	// Build content parts: text first, then any image attachments
+	parts := []*genai.Part{genai.NewPartFromText(input)}
+	for _, att := range attachments {
+		decoded, err := base64.StdEncoding.DecodeString(att.Data)
+		if err != nil {
+			log.Printf("[ChatWithAgent] Failed to decode attachment %s: %v", att.Name, err)
+			continue
+		}
+		mimeType := att.MimeType
+		if mimeType == "" {
+			mimeType = "image/png" // default fallback
+		}
+		log.Printf("[ChatWithAgent] Adding attachment: %s (%s, %d bytes)", att.Name, mimeType, len(decoded))
+		parts = append(parts, genai.NewPartFromBytes(decoded, mimeType))
+	}
+
+	return &genai.Content{
+		Role:  genai.RoleUser,
+		Parts: parts,
+	}, nil
+}
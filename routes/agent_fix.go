@@ -10,6 +10,7 @@ import (
 
 	"qa-extension-backend/agent"
 	"qa-extension-backend/database"
+	"qa-extension-backend/identity"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -110,6 +111,45 @@ func FixIssueWithAgent(c *gin.Context) {
 		return
 	}
 
+	// Progress events go to the requesting user and members of the project
+	owner := database.StreamOwner{ProjectID: fmt.Sprintf("%d", req.ProjectID)}
+	if userID, err := identity.GetCurrentUserID(c); err == nil {
+		owner.UserID = userID
+	}
+
+	session := startFixSession(oauthToken, owner, fixRequest{
+		Runner:            runner,
+		ProjectID:         req.ProjectID,
+		IssueIID:          req.IssueIID,
+		RepoProjectID:     repoProjectID,
+		TargetBranch:      targetBranch,
+		AdditionalContext: req.AdditionalContext,
+	})
+
+	// Return immediately with session ID
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   fmt.Sprintf("%s fix agent started", runner),
+		"sessionId": session.SessionID,
+		"runner":    runner,
+		"session":   session,
+	})
+}
+
+// fixRequest is what a fix-agent run works on
+type fixRequest struct {
+	Runner            string
+	ProjectID         int
+	IssueIID          int
+	RepoProjectID     int
+	TargetBranch      string
+	AdditionalContext string
+}
+
+// startFixSession saves a new fix session and runs the fix agent for it in
+// the background, acting with token and reporting progress to owner
+func startFixSession(oauthToken *oauth2.Token, owner database.StreamOwner, req fixRequest) FixSession {
+	runner, repoProjectID, targetBranch := req.Runner, req.RepoProjectID, req.TargetBranch
+
 	// Generate unique session ID
 	sessionID := fmt.Sprintf("fix_%d_%d_%s", req.ProjectID, req.IssueIID, uuid.New().String()[:8])
 
@@ -141,18 +181,11 @@ func FixIssueWithAgent(c *gin.Context) {
 		UpdatedAt:         time.Now().Format(time.RFC3339),
 	}
 	saveFixSession(session)
-
-	// Return immediately with session ID
-	c.JSON(http.StatusAccepted, gin.H{
-		"message":   fmt.Sprintf("%s fix agent started", runner),
-		"sessionId": sessionID,
-		"runner":    runner,
-		"session":   session,
-	})
+	started := session
 
 	// Run fix agent in background
 	go func() {
-		bgCtx := context.WithValue(context.Background(), "token", oauthToken)
+		bgCtx := context.WithValue(database.WithStreamOwner(context.Background(), owner), "token", oauthToken)
 		events := agent.NewAgentEmitter(bgCtx, sessionID)
 
 		eventCh := make(chan agent.FixEvent, 64)
@@ -192,9 +225,9 @@ func FixIssueWithAgent(c *gin.Context) {
 				// Publish to Redis pub/sub for SSE
 				switch fixEvent.Stage {
 				case "done":
-					events.Done("%s | MR: %s", fixEvent.Message, fixEvent.MRURL)
+					events.WithOutcome("done").Done("%s | MR: %s", fixEvent.Message, fixEvent.MRURL)
 				case "error":
-					events.Error(fixEvent.Error)
+					events.WithOutcome("failed").Error(fixEvent.Error)
 				default:
 					events.Progress(fixEvent.Message)
 				}
@@ -210,6 +243,7 @@ func FixIssueWithAgent(c *gin.Context) {
 
 		log.Printf("[FixRoute] Fix agent completed: session=%s", sessionID)
 	}()
+	return started
 }
 
 // GetFixStatus handles GET /agent/fix-status/:session_id
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"qa-extension-backend/agent"
+	"qa-extension-backend/identity"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/adk/session"
+)
+
+// sessionTitleMaxLength caps titles set by renaming
+const sessionTitleMaxLength = 200
+
+// agentSessionUser returns the signed-in user, whose ID namespaces their sessions
+func agentSessionUser(c *gin.Context) (int, bool) {
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to identify user: " + err.Error()})
+		return 0, false
+	}
+	return userID, true
+}
+
+// agentSessionError maps session store errors to responses
+func agentSessionError(c *gin.Context, err error) {
+	if errors.Is(err, agent.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// ListAgentSessions handles GET /agent/sessions
+// Returns the caller's conversations, most recently updated first.
+func ListAgentSessions(c *gin.Context) {
+	userID, ok := agentSessionUser(c)
+	if !ok {
+		return
+	}
+	conversations, err := agent.GetSessionService().ListConversations(c.Request.Context(), strconv.Itoa(userID))
+	if err != nil {
+		agentSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": conversations})
+}
+
+// GetAgentSession handles GET /agent/sessions/:session_id
+// Returns the conversation with its messages.
+func GetAgentSession(c *gin.Context) {
+	userID, ok := agentSessionUser(c)
+	if !ok {
+		return
+	}
+	conv, messages, err := agent.GetSessionService().GetConversation(c.Request.Context(), strconv.Itoa(userID), c.Param("session_id"))
+	if err != nil {
+		agentSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"conversation": conv, "messages": messages})
+}
+
+// RenameAgentSession handles PATCH /agent/sessions/:session_id
+func RenameAgentSession(c *gin.Context) {
+	userID, ok := agentSessionUser(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Title string `json:"title" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len([]rune(title)) > sessionTitleMaxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("title must be 1 to %d characters", sessionTitleMaxLength)})
+		return
+	}
+	conv, err := agent.GetSessionService().RenameSession(c.Request.Context(), strconv.Itoa(userID), c.Param("session_id"), title)
+	if err != nil {
+		agentSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, conv)
+}
+
+// DeleteAgentSession handles DELETE /agent/sessions/:session_id
+func DeleteAgentSession(c *gin.Context) {
+	userID, ok := agentSessionUser(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	sessionID := c.Param("session_id")
+	// Stop an answer still being generated so it doesn't write to the session
+	if err := agent.CancelRun(ctx, sessionID, userID); err != nil {
+		log.Printf("[AgentSessions] Failed to cancel run of session %s: %v", sessionID, err)
+	}
+	err := agent.GetSessionService().Delete(ctx, &session.DeleteRequest{
+		AppName:   "qa_extension",
+		UserID:    strconv.Itoa(userID),
+		SessionID: sessionID,
+	})
+	if err != nil {
+		agentSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "session deleted"})
+}
+
+// ExportAgentSession handles GET /agent/sessions/:session_id/export
+// Query params: format (json or markdown, default markdown)
+func ExportAgentSession(c *gin.Context) {
+	userID, ok := agentSessionUser(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "markdown")
+	if format != "json" && format != "markdown" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or markdown"})
+		return
+	}
+	conv, messages, err := agent.GetSessionService().GetConversation(c.Request.Context(), strconv.Itoa(userID), c.Param("session_id"))
+	if err != nil {
+		agentSessionError(c, err)
+		return
+	}
+
+	fileName := "conversation-" + conv.ID
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".json"))
+		c.JSON(http.StatusOK, gin.H{"conversation": conv, "messages": messages})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".md"))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(conversationMarkdown(conv, messages)))
+}
+
+// conversationMarkdown renders a transcript as a Markdown document
+func conversationMarkdown(conv agent.Conversation, messages []agent.TranscriptMessage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", conv.Title)
+	fmt.Fprintf(&b, "_Started %s, last updated %s_\n", conv.CreatedAt.UTC().Format(time.RFC3339), conv.UpdatedAt.UTC().Format(time.RFC3339))
+	for _, msg := range messages {
+		speaker := "Assistant"
+		if msg.Role == "user" {
+			speaker = "You"
+		}
+		fmt.Fprintf(&b, "\n## %s · %s\n\n%s\n", speaker, msg.Timestamp.UTC().Format(time.RFC3339), msg.Text)
+	}
+	return b.String()
+}
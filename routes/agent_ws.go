@@ -0,0 +1,407 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"qa-extension-backend/agent"
+	"qa-extension-backend/database"
+	"qa-extension-backend/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2"
+	adkagent "google.golang.org/adk/agent"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 25 * time.Second
+	// wsMaxMessageSize leaves room for base64 image attachments
+	wsMaxMessageSize = 16 << 20
+	// wsSubscribeAll is the subscription key for events of every resource
+	wsSubscribeAll = "*"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		// Same rule as the SSE stream: the session cookie authorises the socket
+		origin := r.Header.Get("Origin")
+		return origin == "" || handlers.StreamOriginAllowed(origin)
+	},
+}
+
+// wsClientMessage is a frame sent by the client. Type selects the action:
+//   - subscribe / unsubscribe: resourceId (empty for all), eventType, lastEventId
+//   - chat: sessionId, input, attachments
+//   - cancel: sessionId
+//   - approve: promptId, approved, answer (optional comment)
+//   - answer: promptId, answer
+//   - ping
+type wsClientMessage struct {
+	Type        string           `json:"type"`
+	RequestID   string           `json:"requestId,omitempty"`
+	ResourceID  string           `json:"resourceId,omitempty"`
+	EventType   string           `json:"eventType,omitempty"`
+	LastEventID string           `json:"lastEventId,omitempty"`
+	SessionID   string           `json:"sessionId,omitempty"`
+	Input       string           `json:"input,omitempty"`
+	Attachments []chatAttachment `json:"attachments,omitempty"`
+	PromptID    string           `json:"promptId,omitempty"`
+	Approved    bool             `json:"approved,omitempty"`
+	Answer      string           `json:"answer,omitempty"`
+}
+
+// wsSystemFrame answers client messages, in the shape of the SSE stream's
+// system events
+type wsSystemFrame struct {
+	Type      string `json:"type"`
+	Stage     string `json:"stage"` // connected, ack, error, pong
+	Message   string `json:"message,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// agentSocket is one client connection. Stream events are sent as the same
+// JSON envelope the SSE stream uses; all writes go through out.
+type agentSocket struct {
+	conn   *websocket.Conn
+	out    chan any
+	closed chan struct{}
+	token  *oauth2.Token
+
+	mu       sync.Mutex
+	access   *handlers.StreamAccess
+	subs     map[string]string // resource ID or wsSubscribeAll → event type filter
+	lastSent map[string]string // resource ID → last event delivered
+}
+
+// AgentWebSocket handles GET /agent/ws
+// A bidirectional alternative to POST /agent/chat plus GET /stream: the client
+// subscribes to stream events, sends chat messages whose answer arrives token
+// by token, and can cancel a run, approve tool calls and answer the agent's
+// questions while it is running.
+func AgentWebSocket(c *gin.Context) {
+	access, err := handlers.NewStreamAccess(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+		return
+	}
+	token := c.MustGet("token").(*oauth2.Token)
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		log.Printf("[AgentWS] Upgrade failed: %v", err)
+		return
+	}
+
+	ws := &agentSocket{
+		conn:     conn,
+		out:      make(chan any, 256),
+		closed:   make(chan struct{}),
+		token:    token,
+		access:   access,
+		subs:     make(map[string]string),
+		lastSent: make(map[string]string),
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+	defer cancel()
+
+	go ws.writeLoop()
+	go ws.tail(ctx)
+
+	ws.send(wsSystemFrame{Type: "system", Stage: "connected", Message: "Connected to agent socket"})
+	ws.readLoop(ctx)
+	close(ws.closed)
+}
+
+// send queues a frame, dropping it once the connection is closed
+func (ws *agentSocket) send(frame any) {
+	select {
+	case ws.out <- frame:
+	case <-ws.closed:
+	}
+}
+
+func (ws *agentSocket) reply(msg wsClientMessage, stage, message string) {
+	ws.send(wsSystemFrame{Type: "system", Stage: stage, Message: message, RequestID: msg.RequestID})
+}
+
+func (ws *agentSocket) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer ws.conn.Close()
+
+	for {
+		select {
+		case <-ws.closed:
+			ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteTimeout))
+			return
+		case frame := <-ws.out:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := ws.conn.WriteJSON(frame); err != nil {
+				log.Printf("[AgentWS] Write failed: %v", err)
+				return
+			}
+		case <-ping.C:
+			if err := ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (ws *agentSocket) readLoop(ctx context.Context) {
+	ws.conn.SetReadLimit(wsMaxMessageSize)
+	ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	ws.conn.SetPongHandler(func(string) error {
+		return ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("[AgentWS] Connection closed: %v", err)
+			}
+			return
+		}
+		ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		var msg wsClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			ws.reply(msg, "error", "invalid message: "+err.Error())
+			continue
+		}
+		ws.handle(ctx, msg)
+	}
+}
+
+func (ws *agentSocket) handle(ctx context.Context, msg wsClientMessage) {
+	userID := ws.access.UserID()
+
+	switch msg.Type {
+	case "ping":
+		ws.reply(msg, "pong", "")
+	case "subscribe":
+		if msg.LastEventID != "" && !database.ValidStreamEventID(msg.LastEventID) {
+			ws.reply(msg, "error", "invalid lastEventId")
+			return
+		}
+		ws.subscribe(ctx, msg.ResourceID, msg.EventType, msg.LastEventID)
+		ws.reply(msg, "ack", "subscribed")
+	case "unsubscribe":
+		key := msg.ResourceID
+		if key == "" {
+			key = wsSubscribeAll
+		}
+		ws.mu.Lock()
+		delete(ws.subs, key)
+		ws.mu.Unlock()
+		ws.reply(msg, "ack", "unsubscribed")
+	case "chat":
+		if msg.SessionID == "" {
+			ws.reply(msg, "error", "sessionId is required")
+			return
+		}
+		ws.reply(msg, "ack", "chat started")
+		go ws.chat(ctx, msg)
+	case "cancel":
+		if msg.SessionID == "" {
+			ws.reply(msg, "error", "sessionId is required")
+			return
+		}
+		if err := agent.CancelRun(ctx, msg.SessionID, userID); err != nil {
+			ws.reply(msg, "error", err.Error())
+			return
+		}
+		ws.reply(msg, "ack", "cancel requested")
+	case "approve", "answer":
+		if msg.PromptID == "" {
+			ws.reply(msg, "error", "promptId is required")
+			return
+		}
+		reply := agent.ClientReply{Approved: msg.Approved, Answer: msg.Answer}
+		if msg.Type == "answer" {
+			reply.Approved = true
+		}
+		if err := agent.ReplyToPrompt(ctx, msg.PromptID, userID, reply); err != nil {
+			ws.reply(msg, "error", err.Error())
+			return
+		}
+		ws.reply(msg, "ack", "reply sent")
+	default:
+		ws.reply(msg, "error", "unknown message type: "+msg.Type)
+	}
+}
+
+// subscribe adds a subscription and replays the resource's logged events
+// after lastEventID, so nothing published before the subscription is lost
+func (ws *agentSocket) subscribe(ctx context.Context, resourceID, eventType, lastEventID string) {
+	key := resourceID
+	if key == "" {
+		key = wsSubscribeAll
+	}
+	ws.mu.Lock()
+	ws.subs[key] = eventType
+	ws.mu.Unlock()
+
+	if resourceID == "" {
+		return
+	}
+	events, err := database.ResourceStreamEvents(ctx, resourceID, lastEventID)
+	if err != nil {
+		log.Printf("[AgentWS] Failed to replay events for %s: %v", resourceID, err)
+		return
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return database.CompareStreamEventIDs(events[i].ID, events[j].ID) < 0
+	})
+	for _, ev := range events {
+		ws.deliver(ev)
+	}
+}
+
+// deliver sends an event if a subscription matches, the user may see it and
+// it has not been sent already
+func (ws *agentSocket) deliver(ev database.StreamEvent) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	eventType, ok := ws.subs[ev.ResourceID]
+	if !ok {
+		if eventType, ok = ws.subs[wsSubscribeAll]; !ok {
+			return
+		}
+	}
+	if eventType != "" && ev.Type != eventType {
+		return
+	}
+	if last := ws.lastSent[ev.ResourceID]; last != "" && database.CompareStreamEventIDs(ev.ID, last) <= 0 {
+		return
+	}
+	if !ws.access.Allows(ev) {
+		return
+	}
+	ws.lastSent[ev.ResourceID] = ev.ID
+	ws.send(ev)
+}
+
+// tail follows the global event log from the moment the socket connected
+func (ws *agentSocket) tail(ctx context.Context) {
+	cursor, err := database.LatestStreamEventID(ctx)
+	if err != nil {
+		log.Printf("[AgentWS] Failed to read event log tip: %v", err)
+		cursor = "$"
+	}
+
+	for {
+		events, err := database.ReadStreamEvents(ctx, cursor, 15*time.Second)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[AgentWS] Failed to read event log: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		for _, ev := range events {
+			cursor = ev.ID
+			ws.deliver(ev)
+		}
+	}
+}
+
+// chat runs the agent for one message. Progress, prompts and completion are
+// published on the session's stream as with POST /agent/chat; the answer text
+// is additionally sent to this socket as token events while it is generated.
+func (ws *agentSocket) chat(connCtx context.Context, msg wsClientMessage) {
+	userID := ws.access.UserID()
+
+	// The run outlives the socket, like the REST chat outlives its request
+	ctx := context.WithValue(context.WithoutCancel(connCtx), "token", ws.token)
+	ctx = context.WithValue(ctx, "session_id", msg.SessionID)
+	ctx = database.WithStreamOwner(ctx, database.StreamOwner{UserID: userID})
+
+	// The client always hears about its own session
+	ws.mu.Lock()
+	if _, ok := ws.subs[msg.SessionID]; !ok {
+		ws.subs[msg.SessionID] = ""
+	}
+	ws.mu.Unlock()
+
+	events := agent.NewAgentEmitter(ctx, msg.SessionID)
+
+	r, err := agent.GetQARunner(ctx)
+	if err != nil {
+		events.Error("Failed to initialize agent runner: " + err.Error())
+		return
+	}
+
+	// Sessions belong to the signed-in GitLab user, as with the REST chat
+	sessionUserID := strconv.Itoa(userID)
+	content, err := prepareAgentChat(ctx, sessionUserID, msg.SessionID, msg.Input, msg.Attachments)
+	if err != nil {
+		events.Error(err.Error())
+		return
+	}
+
+	runCtx, done := agent.StartInteractiveRun(ctx, msg.SessionID, userID)
+	defer done()
+	events = agent.NewAgentEmitter(runCtx, msg.SessionID)
+
+	// streamed is set once the current answer has been sent as tokens
+	streamed := false
+	for event, err := range r.Run(runCtx, sessionUserID, msg.SessionID, content, adkagent.RunConfig{StreamingMode: adkagent.StreamingModeSSE}) {
+		if err != nil {
+			if errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "context canceled") {
+				log.Printf("[AgentWS] Run for session %s cancelled", msg.SessionID)
+				agent.NewAgentEmitter(ctx, msg.SessionID).Error("Agent run cancelled")
+				return
+			}
+			log.Printf("[AgentWS] Agent execution error: %v", err)
+			events.Error(err.Error())
+			return
+		}
+		if event.Content == nil {
+			continue
+		}
+
+		var text string
+		for _, part := range event.Content.Parts {
+			text += part.Text
+		}
+		switch {
+		case event.Partial:
+			if text != "" {
+				ws.send(events.Token(text))
+				streamed = true
+			}
+		case event.IsFinalResponse():
+			if !streamed && text != "" {
+				// The model answered without streaming
+				ws.send(events.Token(text))
+			}
+			streamed = false
+			events.Done("Agent completed")
+		default:
+			if text == "" {
+				text = "Agent is processing..."
+			}
+			streamed = false
+			events.Progress(text)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package routes
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"qa-extension-backend/auth"
+	"qa-extension-backend/client"
+	"qa-extension-backend/database"
+	"qa-extension-backend/handlers"
+	"qa-extension-backend/identity"
+	"qa-extension-backend/internal/models"
+	"qa-extension-backend/services"
+
+	"github.com/gin-gonic/gin"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"golang.org/x/oauth2"
+)
+
+// maxGitLabWebhookBody caps the payloads read from GitLab; push events list
+// at most 20 commits, so real payloads are far smaller
+const maxGitLabWebhookBody = 5 << 20
+
+// ReceiveGitLabWebhook handles POST /webhooks/gitlab
+// GitLab calls this for push, merge request, pipeline and issue events of a
+// project with automation configured, sending the project's secret token in
+// X-Gitlab-Token. Matching rules run in the background; GitLab only waits for
+// the acknowledgement.
+func ReceiveGitLabWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGitLabWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read payload"})
+		return
+	}
+
+	ev, err := services.ParseGitLabEvent(gitlab.HookEventType(c.Request), body)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	secret, err := database.GetGitLabAutomationSecret(ctx, ev.ProjectID)
+	token := c.GetHeader("X-Gitlab-Token")
+	if err != nil || secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook token"})
+		return
+	}
+	cfg, err := database.GetGitLabAutomation(ctx, ev.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := services.MatchGitLabRules(cfg, ev)
+	if len(rules) == 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+	go runGitLabAutomation(cfg, *ev, rules)
+	c.JSON(http.StatusAccepted, gin.H{"status": "accepted", "rules": len(rules)})
+}
+
+// runGitLabAutomation runs the actions of the matched rules as the user who
+// configured the automation
+func runGitLabAutomation(cfg models.GitLabAutomation, ev services.GitLabEvent, rules []models.GitLabAutomationRule) {
+	ctx := context.Background()
+	record := func(rule models.GitLabAutomationRule, resourceID string, err error) {
+		run := models.GitLabAutomationRun{
+			ProjectID:  cfg.ProjectID,
+			Trigger:    rule.Trigger,
+			RuleID:     rule.ID,
+			Action:     rule.Action,
+			Status:     "started",
+			Message:    describeGitLabEvent(rule.Trigger, ev),
+			ResourceID: resourceID,
+		}
+		if err != nil {
+			run.Status = "failed"
+			run.Message = fmt.Sprintf("%s: %v", run.Message, err)
+			log.Printf("[GitLabAutomation] Rule %s (%s) failed for project %s: %v", rule.ID, rule.Action, cfg.ProjectID, err)
+		}
+		if err := database.AddGitLabAutomationRun(ctx, &run); err != nil {
+			log.Printf("[GitLabAutomation] Failed to record run for project %s: %v", cfg.ProjectID, err)
+		}
+	}
+
+	token, gl, err := gitLabAutomationClient(ctx, cfg.ActorUserID)
+	if err != nil {
+		for _, rule := range rules {
+			record(rule, "", err)
+		}
+		return
+	}
+
+	owner := database.StreamOwner{UserID: cfg.ActorUserID, ProjectID: cfg.ProjectID}
+	for _, rule := range rules {
+		switch rule.Action {
+		case models.ActionRebuildKnowledgeGraph:
+			record(rule, "", nil)
+			go rebuildKnowledgeGraph(ctx, gl, cfg.ProjectID, ev.Branch)
+
+		case models.ActionRunSuite:
+			run := models.SuiteRun{
+				ProjectID:   cfg.ProjectID,
+				Name:        describeGitLabEvent(rule.Trigger, ev),
+				ScenarioIDs: rule.ScenarioIDs,
+				Tags:        rule.Tags,
+				Trigger:     models.SuiteTriggerGitLab,
+				Ref: &models.SuiteRunRef{
+					Branch:          ev.Branch,
+					CommitSHA:       ev.CommitSHA,
+					MergeRequestIID: ev.MergeRequestIID,
+					PipelineID:      ev.PipelineID,
+				},
+				StartedBy: cfg.ActorUserID,
+			}
+			// A merge request's suite tests its changes, not the branch it targets
+			if ev.SourceBranch != "" {
+				run.Ref.Branch = ev.SourceBranch
+			}
+			suiteCtx := context.WithValue(ctx, "token", token)
+			err := handlers.StartSuiteRun(suiteCtx, &run, rule.EnvironmentID, gl)
+			record(rule, run.ID, err)
+
+		case models.ActionStartFixAgent:
+			session, err := startGitLabFix(gl, token, owner, rule, ev)
+			record(rule, session, err)
+		}
+	}
+}
+
+// gitLabAutomationClient opens the stored GitLab authorization of the
+// automation's user. The token is refreshed up front, under the grant's
+// refresh lock, so that the fix agent, which builds its own clients from the
+// token, starts with a fresh one.
+func gitLabAutomationClient(ctx context.Context, userID int) (*oauth2.Token, *gitlab.Client, error) {
+	if userID == 0 {
+		return nil, nil, database.ErrGitLabGrantNotFound
+	}
+	token, err := auth.GrantToken(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrGitLabGrantNotFound) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("stored GitLab authorization no longer works, save the automation again: %w", err)
+	}
+	tokenSaver := func(ctx context.Context, t *oauth2.Token) error {
+		return auth.UpdateSession(ctx, auth.GrantSessionID(userID), t)
+	}
+	gl, err := client.GetClient(ctx, token, tokenSaver)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, gl, nil
+}
+
+// rebuildKnowledgeGraph drops the cached knowledge graph of a branch and generates it again
+func rebuildKnowledgeGraph(ctx context.Context, gl *gitlab.Client, projectID, branch string) {
+	graphMapper := services.NewGraphMapper()
+	if err := graphMapper.InvalidateCatalog(ctx, projectID, branch); err != nil {
+		log.Printf("[GitLabAutomation] Failed to invalidate knowledge graph %s/%s: %v", projectID, branch, err)
+	}
+	if _, err := graphMapper.FetchAndEnrichCatalog(ctx, gl, projectID, branch); err != nil {
+		log.Printf("[GitLabAutomation] Failed to rebuild knowledge graph %s/%s: %v", projectID, branch, err)
+		return
+	}
+	log.Printf("[GitLabAutomation] Rebuilt knowledge graph %s/%s", projectID, branch)
+}
+
+// startGitLabFix starts the fix agent on the labeled issue and returns the session ID
+func startGitLabFix(gl *gitlab.Client, token *oauth2.Token, owner database.StreamOwner, rule models.GitLabAutomationRule, ev services.GitLabEvent) (string, error) {
+	projectID, err := strconv.Atoi(ev.ProjectID)
+	if err != nil {
+		return "", err
+	}
+	targetBranch := rule.TargetBranch
+	if targetBranch == "" {
+		project, _, err := gl.Projects.GetProject(projectID, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get project: %w", err)
+		}
+		targetBranch = project.DefaultBranch
+	}
+	runner := rule.Runner
+	if runner == "" {
+		runner = "claude"
+	}
+
+	session := startFixSession(token, owner, fixRequest{
+		Runner:            runner,
+		ProjectID:         projectID,
+		IssueIID:          int(ev.IssueIID),
+		RepoProjectID:     projectID,
+		TargetBranch:      targetBranch,
+		AdditionalContext: fmt.Sprintf("Started automatically because the %q label was added to the issue.", rule.Label),
+	})
+	return session.SessionID, nil
+}
+
+// describeGitLabEvent summarizes the event that fired a rule
+func describeGitLabEvent(trigger string, ev services.GitLabEvent) string {
+	switch trigger {
+	case models.TriggerPush:
+		return fmt.Sprintf("Push to %s", ev.Branch)
+	case models.TriggerMergeRequestOpened:
+		return fmt.Sprintf("Merge request !%d opened", ev.MergeRequestIID)
+	case models.TriggerMergeRequestMerged:
+		return fmt.Sprintf("Merge request !%d merged into %s", ev.MergeRequestIID, ev.Branch)
+	case models.TriggerMergeRequestLabel:
+		return fmt.Sprintf("Merge request !%d labeled", ev.MergeRequestIID)
+	case models.TriggerPipelineSuccess:
+		return fmt.Sprintf("Pipeline #%d succeeded on %s", ev.PipelineID, ev.Branch)
+	case models.TriggerPipelineFailed:
+		return fmt.Sprintf("Pipeline #%d failed on %s", ev.PipelineID, ev.Branch)
+	case models.TriggerIssueLabel:
+		return fmt.Sprintf("Issue #%d labeled", ev.IssueIID)
+	}
+	return trigger
+}
+
+// gitLabAutomationResponse is returned by the automation endpoints; the
+// secret token is only set when it was just generated
+type gitLabAutomationResponse struct {
+	models.GitLabAutomation
+	Secret   string   `json:"secret,omitempty"`
+	Triggers []string `json:"triggers"`
+	Actions  []string `json:"actions"`
+}
+
+// GetGitLabAutomation handles GET /projects/:id/gitlab-automation
+// Like saving, it needs Maintainer access to the GitLab project.
+func GetGitLabAutomation(c *gin.Context) {
+	if !handlers.RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	cfg, err := database.GetGitLabAutomation(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, database.ErrGitLabAutomationNotFound) {
+		cfg = models.GitLabAutomation{ProjectID: c.Param("id"), Rules: []models.GitLabAutomationRule{}}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gitLabAutomationResponse{
+		GitLabAutomation: cfg,
+		Triggers:         models.GitLabTriggers,
+		Actions:          models.GitLabActions,
+	})
+}
+
+// SaveGitLabAutomation handles PUT /projects/:id/gitlab-automation
+// Replaces the project's rules. Actions will run as the caller, whose GitLab
+// authorization is stored for that purpose. The secret token for the GitLab
+// webhook is generated on the first save or when rotateSecret is set, and is
+// only returned then. Callers need Maintainer access to the GitLab project, the
+// level GitLab asks for to manage the project's webhooks.
+func SaveGitLabAutomation(c *gin.Context) {
+	if !handlers.RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	var req models.SaveGitLabAutomationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for _, rule := range req.Rules {
+		if err := services.ValidateGitLabAutomationRule(rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	userID, err := identity.GetCurrentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Failed to identify user: " + err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	token := c.MustGet("token").(*oauth2.Token)
+	if err := auth.SaveSessionGrant(ctx, c.GetString("session_id"), userID, token); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, database.ErrCredentialKeyMissing) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": "Failed to store GitLab authorization: " + err.Error()})
+		return
+	}
+
+	cfg, err := database.GetGitLabAutomation(ctx, c.Param("id"))
+	isNew := errors.Is(err, database.ErrGitLabAutomationNotFound)
+	if err != nil && !isNew {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cfg.ProjectID = c.Param("id")
+	cfg.Rules = req.Rules
+	cfg.ActorUserID = userID
+	if req.Enabled != nil {
+		cfg.Enabled = *req.Enabled
+	} else if isNew {
+		cfg.Enabled = true
+	}
+
+	var secret string
+	if isNew || req.RotateSecret {
+		if secret, err = database.NewGitLabWebhookToken(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := database.SaveGitLabAutomation(ctx, &cfg, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gitLabAutomationResponse{
+		GitLabAutomation: cfg,
+		Secret:           secret,
+		Triggers:         models.GitLabTriggers,
+		Actions:          models.GitLabActions,
+	})
+}
+
+// ListGitLabAutomationRuns handles GET /projects/:id/gitlab-automation/log
+// Returns what recent GitLab events triggered, newest first. ?limit= defaults to 50.
+func ListGitLabAutomationRuns(c *gin.Context) {
+	if !handlers.RequireProjectAccess(c, c.Param("id"), gitlab.MaintainerPermissions) {
+		return
+	}
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+	runs, err := database.ListGitLabAutomationRuns(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": runs})
+}
@@ -1404,18 +1404,7 @@ func CreateIssueEvidence(ginContext *gin.Context) {
 		return
 	}
 
-	var formattedBody strings.Builder
-	formattedBody.WriteString("**Evidence:**\n")
-	formattedBody.WriteString(req.Evidence)
-	formattedBody.WriteString("\n\n")
-
-	formattedBody.WriteString(req.Comment)
-
-	opt := &gitlab.CreateIssueNoteOptions{
-		Body: gitlab.Ptr(formattedBody.String()),
-	}
-
-	note, _, err := gitlabClient.Notes.CreateIssueNote(projectID, issueID, opt)
+	note, err := client.CreateEvidenceNote(gitlabClient, projectID, issueID, req.Evidence, req.Comment)
 	if err != nil {
 		ginContext.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		ginContext.Abort()
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// API tokens are checked against the route they call: reads need the read
+// scope, starting runs the run scope, anything that drives the AI agent or
+// test generation the generate scope, and every other change admin. Tokens
+// limited to projects only reach routes under those projects.
+
+// ErrAPITokenForbidden is returned when a token may not call a route
+var ErrAPITokenForbidden = errors.New("API token not allowed")
+
+// apiTokenMaxLifetime caps ExpiresInDays
+const apiTokenMaxLifetime = 365
+
+// sessionOnlyRoutes need a browser session; tokens can't manage tokens or log out
+var sessionOnlyRoutes = []string{
+	"/api/api-tokens",
+	"/api/api-tokens/:tokenId",
+	"/api/projects/:id/api-tokens",
+	"/api/projects/:id/api-tokens/:tokenId",
+	"/api/auth/logout",
+}
+
+// projectFreeRoutes are reachable by project-limited tokens although they are
+// not under /projects/:id. Their handlers filter what they return by project.
+var projectFreeRoutes = []string{
+	"/api/current-user",
+	"/api/stream",
+	"/api/suite-runs/:runId",
+	"/api/suite-runs/:runId/export",
+}
+
+// APITokenScopeFor returns the scope a request to route needs. route is the
+// matched route pattern, e.g. /api/projects/:id/suite-runs.
+func APITokenScopeFor(method, route string) string {
+	switch {
+	case route == "/api/agent/ws" || route == "/api/issues/open-ai-test":
+		// Both drive the agent although they are GETs
+		return models.APITokenScopeGenerate
+	case method == http.MethodGet || method == http.MethodHead:
+		return models.APITokenScopeRead
+	case strings.HasPrefix(route, "/api/agent/"),
+		strings.HasSuffix(route, "/generate"),
+		strings.HasSuffix(route, "/gap-analysis"):
+		return models.APITokenScopeGenerate
+	case strings.HasSuffix(route, "/run"),
+		strings.HasSuffix(route, "/suite-runs"):
+		return models.APITokenScopeRun
+	}
+	return models.APITokenScopeAdmin
+}
+
+// AuthorizeAPIToken checks that a token may call a route. projectID is the
+// :id of /projects/:id routes.
+func AuthorizeAPIToken(tok models.APIToken, method, route, projectID string, now time.Time) error {
+	if tok.Expired(now) {
+		return fmt.Errorf("%w: the token expired", ErrAPITokenForbidden)
+	}
+	if route == "" || slices.Contains(sessionOnlyRoutes, route) {
+		return fmt.Errorf("%w: this endpoint needs a signed-in session", ErrAPITokenForbidden)
+	}
+	if scope := APITokenScopeFor(method, route); !tok.HasScope(scope) {
+		return fmt.Errorf("%w: the token lacks the %s scope", ErrAPITokenForbidden, scope)
+	}
+	if len(tok.ProjectIDs) == 0 {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(route, "/api/projects/:id/") || route == "/api/projects/:id":
+		if !tok.AllowsProject(projectID) {
+			return fmt.Errorf("%w: the token can't access project %s", ErrAPITokenForbidden, projectID)
+		}
+	case !slices.Contains(projectFreeRoutes, route):
+		return fmt.Errorf("%w: the token is limited to projects %s", ErrAPITokenForbidden, strings.Join(tok.ProjectIDs, ", "))
+	}
+	return nil
+}
+
+// ValidateAPITokenRequest checks the scopes and lifetime of a new token
+func ValidateAPITokenRequest(req models.CreateAPITokenRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.APITokenScopes, scope) {
+			return fmt.Errorf("unknown scope %q; use one of %s", scope, strings.Join(models.APITokenScopes, ", "))
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > apiTokenMaxLifetime {
+		return fmt.Errorf("expiresInDays must be between 0 and %d", apiTokenMaxLifetime)
+	}
+	return nil
+}
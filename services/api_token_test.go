@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITokenScopeFor(t *testing.T) {
+	assert.Equal(t, models.APITokenScopeRead, APITokenScopeFor("GET", "/api/projects/:id/suite-runs"))
+	assert.Equal(t, models.APITokenScopeRun, APITokenScopeFor("POST", "/api/projects/:id/suite-runs"))
+	assert.Equal(t, models.APITokenScopeRun, APITokenScopeFor("POST", "/api/test-cycles/:id/run"))
+	assert.Equal(t, models.APITokenScopeGenerate, APITokenScopeFor("POST", "/api/test-scenarios/:id/generate"))
+	assert.Equal(t, models.APITokenScopeGenerate, APITokenScopeFor("POST", "/api/agent/chat"))
+	assert.Equal(t, models.APITokenScopeGenerate, APITokenScopeFor("GET", "/api/agent/ws"))
+	assert.Equal(t, models.APITokenScopeAdmin, APITokenScopeFor("DELETE", "/api/test-scenarios/:id"))
+}
+
+func TestAuthorizeAPIToken(t *testing.T) {
+	now := time.Now()
+	runner := models.APIToken{Scopes: []string{models.APITokenScopeRead, models.APITokenScopeRun}, ProjectIDs: []string{"42"}}
+
+	assert.NoError(t, AuthorizeAPIToken(runner, "POST", "/api/projects/:id/suite-runs", "42", now))
+	assert.NoError(t, AuthorizeAPIToken(runner, "GET", "/api/suite-runs/:runId", "", now))
+	assert.ErrorIs(t, AuthorizeAPIToken(runner, "POST", "/api/projects/:id/suite-runs", "7", now), ErrAPITokenForbidden)
+	assert.ErrorIs(t, AuthorizeAPIToken(runner, "GET", "/api/test-scenarios", "", now), ErrAPITokenForbidden,
+		"project-limited tokens only reach project routes")
+	assert.ErrorIs(t, AuthorizeAPIToken(runner, "PUT", "/api/projects/:id/environments", "42", now), ErrAPITokenForbidden)
+
+	admin := models.APIToken{Scopes: []string{models.APITokenScopeAdmin}}
+	assert.NoError(t, AuthorizeAPIToken(admin, "DELETE", "/api/test-scenarios/:id", "", now))
+	assert.ErrorIs(t, AuthorizeAPIToken(admin, "POST", "/api/api-tokens", "", now), ErrAPITokenForbidden,
+		"tokens can't create tokens")
+
+	expired := now.Add(-time.Minute)
+	admin.ExpiresAt = &expired
+	assert.ErrorIs(t, AuthorizeAPIToken(admin, "GET", "/api/projects", "", now), ErrAPITokenForbidden)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// maxReportEntries caps how many console errors and network failures go into a report
+const maxReportEntries = 10
+
+// BugReportInput is everything known about a failed run
+type BugReportInput struct {
+	SourceID    string // test case or recording ID, part of the failure signature
+	Name        string
+	Context     string // e.g. "Checkout scenario / Payment section"
+	Environment string
+	Steps       []models.RecordingStep
+	ManualSteps []models.TestStepV2 // authored steps of the test case, for expected results
+	Result      models.TestResult
+	SourceURL   string
+}
+
+// BugReport is a ready-to-file GitLab issue built from a failed run
+type BugReport struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Signature   string `json:"signature"`
+	FailedStep  int    `json:"failedStep"` // 0-based, -1 when unknown
+	Error       string `json:"error"`
+	Screenshot  string `json:"-"` // base64 screenshot of the failing step, if any
+}
+
+var (
+	digitsPattern     = regexp.MustCompile(`\d+`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	sensitiveHint     = regexp.MustCompile(`(?i)pass(word)?|secret|token|otp|pin\b`)
+)
+
+// BuildBugReport turns a failed TestResult into an issue title and markdown body
+func BuildBugReport(in BugReportInput) BugReport {
+	report := BugReport{FailedStep: -1}
+
+	var failed *models.TestStepResult
+	for i := range in.Result.StepResults {
+		if in.Result.StepResults[i].Status == "failure" {
+			failed = &in.Result.StepResults[i]
+			break
+		}
+	}
+	if failed != nil {
+		report.FailedStep = failed.StepIndex
+		report.Error = failed.Error
+		report.Screenshot = failed.Screenshot
+	}
+	if report.Error == "" {
+		report.Error = in.Result.Log
+	}
+	if report.Error == "" {
+		report.Error = fmt.Sprintf("Run finished with status %q", in.Result.Status)
+	}
+
+	var failedStep *models.RecordingStep
+	if report.FailedStep >= 0 && report.FailedStep < len(in.Steps) {
+		failedStep = &in.Steps[report.FailedStep]
+	}
+
+	report.Signature = FailureSignature(in.SourceID, failedStep, report.Error)
+
+	summary := firstLine(report.Error)
+	if r := []rune(summary); len(r) > 80 {
+		summary = string(r[:77]) + "..."
+	}
+	if failedStep != nil {
+		report.Title = fmt.Sprintf("%s fails at step %d: %s", in.Name, report.FailedStep+1, summary)
+	} else {
+		report.Title = fmt.Sprintf("%s fails: %s", in.Name, summary)
+	}
+
+	var b strings.Builder
+	b.WriteString("## Summary\n\n")
+	fmt.Fprintf(&b, "Automated run of **%s** failed", in.Name)
+	if in.Context != "" {
+		fmt.Fprintf(&b, " (%s)", in.Context)
+	}
+	b.WriteString(".\n\n")
+	if in.Environment != "" {
+		fmt.Fprintf(&b, "- **Environment:** %s\n", in.Environment)
+	}
+	fmt.Fprintf(&b, "- **Status:** %s\n", in.Result.Status)
+	if in.Result.RunDurationMs > 0 {
+		fmt.Fprintf(&b, "- **Duration:** %.1fs\n", float64(in.Result.RunDurationMs)/1000)
+	}
+	if in.SourceURL != "" {
+		fmt.Fprintf(&b, "- **Test:** %s\n", in.SourceURL)
+	}
+
+	if failedStep != nil {
+		b.WriteString("\n## Failing step\n\n")
+		fmt.Fprintf(&b, "Step %d: %s\n", report.FailedStep+1, describeStep(*failedStep))
+	}
+
+	b.WriteString("\n## Expected vs actual\n\n")
+	fmt.Fprintf(&b, "**Expected:** %s\n\n", expectedOutcome(failedStep, in.ManualSteps))
+	fmt.Fprintf(&b, "**Actual:**\n\n```\n%s\n```\n", strings.TrimSpace(report.Error))
+
+	if len(in.Steps) > 0 {
+		b.WriteString("\n## Steps to reproduce\n\n")
+		last := len(in.Steps) - 1
+		if report.FailedStep >= 0 && report.FailedStep < len(in.Steps) {
+			last = report.FailedStep
+		}
+		for i := 0; i <= last; i++ {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, describeStep(in.Steps[i]))
+		}
+	}
+
+	if n := len(in.Result.ConsoleErrors); n > 0 {
+		b.WriteString("\n## Console errors\n\n```\n")
+		for i, e := range in.Result.ConsoleErrors {
+			if i == maxReportEntries {
+				fmt.Fprintf(&b, "... %d more\n", n-maxReportEntries)
+				break
+			}
+			b.WriteString(firstLine(e.Message) + "\n")
+		}
+		b.WriteString("```\n")
+	}
+
+	if n := len(in.Result.FailedRequests); n > 0 {
+		b.WriteString("\n## Failed network calls\n\n| Method | URL | Result |\n|---|---|---|\n")
+		for i, r := range in.Result.FailedRequests {
+			if i == maxReportEntries {
+				fmt.Fprintf(&b, "| | ... %d more | |\n", n-maxReportEntries)
+				break
+			}
+			outcome := r.Error
+			if r.Status > 0 {
+				outcome = strings.TrimSpace(fmt.Sprintf("%d %s", r.Status, r.StatusText))
+			}
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", r.Method, r.URL, outcome)
+		}
+	}
+
+	if in.Result.VideoURL != "" {
+		fmt.Fprintf(&b, "\n## Evidence\n\n- Video: %s\n", in.Result.VideoURL)
+	}
+
+	fmt.Fprintf(&b, "\n---\nFailure signature: `%s`\n", report.Signature)
+	report.Description = b.String()
+	return report
+}
+
+// FailureSignature identifies "the same failure" across runs: the same test
+// failing on the same step with the same error, ignoring numbers such as
+// timeouts, IDs and counts.
+func FailureSignature(sourceID string, step *models.RecordingStep, errMsg string) string {
+	normalized := strings.ToLower(firstLine(errMsg))
+	normalized = digitsPattern.ReplaceAllString(normalized, "#")
+	normalized = whitespacePattern.ReplaceAllString(strings.TrimSpace(normalized), " ")
+
+	parts := []string{sourceID, "", "", normalized}
+	if step != nil {
+		parts[1] = step.Action
+		parts[2] = step.Selector
+	}
+
+	sum := sha1.Sum([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func describeStep(step models.RecordingStep) string {
+	target := step.Selector
+	if target == "" {
+		target = step.ApiEndpoint
+	}
+	value := step.Value
+	if value != "" && sensitiveHint.MatchString(step.Selector+" "+step.Description) {
+		value = "••••••"
+	}
+
+	var action string
+	switch step.Action {
+	case "navigate":
+		action = fmt.Sprintf("Open `%s`", step.Value)
+	case "type", "fill":
+		action = fmt.Sprintf("Type \"%s\" into `%s`", value, target)
+	case "click":
+		action = fmt.Sprintf("Click `%s`", target)
+	case "press":
+		action = fmt.Sprintf("Press %s on `%s`", step.Value, target)
+	case "assert":
+		action = fmt.Sprintf("Check `%s` is %s", target, assertionPhrase(step))
+	case "api_request":
+		action = fmt.Sprintf("Send %s `%s`", step.ApiMethod, step.ApiEndpoint)
+	default:
+		action = fmt.Sprintf("%s `%s`", step.Action, target)
+	}
+
+	if step.Description != "" {
+		return fmt.Sprintf("%s — %s", step.Description, action)
+	}
+	return action
+}
+
+func assertionPhrase(step models.RecordingStep) string {
+	phrase := strings.ReplaceAll(step.AssertionType, "_", " ")
+	if phrase == "" {
+		phrase = "visible"
+	}
+	if step.ExpectedValue != "" {
+		phrase += fmt.Sprintf(" \"%s\"", step.ExpectedValue)
+	}
+	return phrase
+}
+
+func expectedOutcome(step *models.RecordingStep, manual []models.TestStepV2) string {
+	if step != nil && step.Action == "assert" {
+		return fmt.Sprintf("`%s` is %s", step.Selector, assertionPhrase(*step))
+	}
+
+	var expectations []string
+	for _, s := range manual {
+		if e := strings.TrimSpace(s.Expected); e != "" {
+			expectations = append(expectations, e)
+		}
+	}
+	if len(expectations) > 0 {
+		return strings.Join(expectations, "; ")
+	}
+
+	if step != nil {
+		return fmt.Sprintf("%s succeeds", describeStep(*step))
+	}
+	return "The test completes without errors"
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
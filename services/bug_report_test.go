@@ -0,0 +1,63 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func failedLoginInput(errMsg string) BugReportInput {
+	return BugReportInput{
+		SourceID: "tc-1",
+		Name:     "TC-001 Login",
+		Steps: []models.RecordingStep{
+			{Action: "navigate", Value: "https://app.example.com/login"},
+			{Action: "type", Selector: "#password", Value: "hunter2"},
+			{Action: "click", Selector: "button[type=submit]"},
+			{Action: "assert", Selector: ".welcome", AssertionType: "contains_text", ExpectedValue: "Welcome"},
+		},
+		Result: models.TestResult{
+			Status: "failed",
+			StepResults: []models.TestStepResult{
+				{StepIndex: 0, Status: "success"},
+				{StepIndex: 1, Status: "success"},
+				{StepIndex: 2, Status: "success"},
+				{StepIndex: 3, Status: "failure", Error: errMsg},
+			},
+			ConsoleErrors:  []models.ConsoleLogEntry{{Level: "error", Message: "Uncaught TypeError: x is undefined"}},
+			FailedRequests: []models.NetworkRequestEntry{{Method: "POST", URL: "https://app.example.com/api/session", Status: 500, StatusText: "Internal Server Error"}},
+		},
+	}
+}
+
+func TestBuildBugReport(t *testing.T) {
+	report := BuildBugReport(failedLoginInput("Timeout 5000ms exceeded waiting for .welcome"))
+
+	assert.Equal(t, 3, report.FailedStep)
+	assert.Contains(t, report.Title, "fails at step 4")
+	assert.Contains(t, report.Description, "`.welcome` is contains text \"Welcome\"")
+	assert.Contains(t, report.Description, "## Console errors")
+	assert.Contains(t, report.Description, "| POST | `https://app.example.com/api/session` | 500 Internal Server Error |")
+	assert.NotContains(t, report.Description, "hunter2")
+	assert.Contains(t, report.Description, report.Signature)
+}
+
+func TestFailureSignatureIgnoresNumbers(t *testing.T) {
+	a := BuildBugReport(failedLoginInput("Timeout 5000ms exceeded waiting for .welcome"))
+	b := BuildBugReport(failedLoginInput("Timeout 30000ms exceeded waiting for .welcome"))
+	c := BuildBugReport(failedLoginInput("Element .welcome is detached"))
+
+	assert.Equal(t, a.Signature, b.Signature)
+	assert.NotEqual(t, a.Signature, c.Signature)
+}
+
+func TestBuildBugReportTruncatesTitleOnRunes(t *testing.T) {
+	report := BuildBugReport(failedLoginInput(strings.Repeat("é", 100)))
+
+	assert.True(t, utf8.ValidString(report.Title))
+	assert.True(t, strings.HasSuffix(report.Title, strings.Repeat("é", 77)+"..."))
+}
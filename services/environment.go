@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// urlMapping moves URLs under one prefix to another
+type urlMapping struct {
+	from string
+	to   string
+}
+
+// ApplyEnvironment rewrites a run for a project environment. Page and API URLs
+// under the scenario's base URLs move to the environment's, relative URLs are
+// resolved against it, {{env.NAME}} variables are filled in and placeholders
+// for the scenario's test account point at the environment's. Secret variables
+// become credential placeholders, so their values are only resolved by the
+// runner. The run gets a copy of the steps, so the stored automation is never
+// changed.
+func ApplyEnvironment(run *models.TestRun, origin models.AuthConfig, env *models.Environment) error {
+	if origin.BaseURL == "" {
+		origin.BaseURL = firstPageOrigin(run.Steps)
+	}
+	pages, apis := environmentMappings(origin, env)
+	apiBase := env.ApiBaseURL
+	if apiBase == "" {
+		apiBase = env.BaseURL
+	}
+	vars := environmentVariables(env)
+
+	steps := make([]models.RecordingStep, len(run.Steps))
+	copy(steps, run.Steps)
+	for i := range steps {
+		s := &steps[i]
+		if s.Action == "navigate" {
+			if s.Value != "" {
+				s.Value = rewriteURL(s.Value, pages, env.BaseURL)
+			} else {
+				s.Selector = rewriteURL(s.Selector, pages, env.BaseURL)
+			}
+		}
+		if s.ApiEndpoint != "" {
+			s.ApiEndpoint = rewriteURL(s.ApiEndpoint, apis, apiBase)
+		}
+
+		for _, field := range []*string{&s.Value, &s.Selector, &s.ApiEndpoint, &s.ApiPayload, &s.ApiHeaders, &s.ExpectedValue} {
+			filled, err := fillEnvVariables(*field, vars)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+			*field = swapCredential(filled, origin.CredentialID, env.CredentialID)
+		}
+	}
+
+	var headers map[string]string
+	if len(env.Headers) > 0 {
+		headers = make(map[string]string, len(env.Headers))
+		for name, value := range env.Headers {
+			filled, err := fillEnvVariables(value, vars)
+			if err != nil {
+				return fmt.Errorf("header %s: %w", name, err)
+			}
+			headers[name] = filled
+		}
+	}
+
+	run.Steps = steps
+	run.Environment = &models.RunEnvironment{
+		EnvironmentRef: *env.Ref(),
+		Headers:        headers,
+		HeaderOrigins:  environmentOrigins(env),
+	}
+	return nil
+}
+
+// environmentVariables merges plain and secret variables. Secrets are filled
+// in as credential placeholders.
+func environmentVariables(env *models.Environment) map[string]string {
+	vars := make(map[string]string, len(env.Variables)+len(env.SecretVariables))
+	for name, value := range env.Variables {
+		vars[name] = value
+	}
+	for name, credentialID := range env.SecretVariables {
+		vars[name] = models.CredentialPlaceholder(credentialID, models.CredentialPassword)
+	}
+	return vars
+}
+
+// environmentOrigins are the origins of the environment's page and API base
+// URLs, the only hosts its headers are sent to
+func environmentOrigins(env *models.Environment) []string {
+	var origins []string
+	for _, raw := range []string{env.BaseURL, env.ApiBaseURL} {
+		if o := urlOrigin(raw); o != "" && !slices.Contains(origins, o) {
+			origins = append(origins, o)
+		}
+	}
+	return origins
+}
+
+// environmentMappings pairs the scenario's URLs with the environment's, most
+// specific first. The bare origin of the base URL is mapped last so pages
+// outside the base path still follow the environment's host.
+func environmentMappings(origin models.AuthConfig, env *models.Environment) (pages, apis []urlMapping) {
+	add := func(list []urlMapping, from, to string) []urlMapping {
+		if from == "" || to == "" {
+			return list
+		}
+		return append(list, urlMapping{from: strings.TrimRight(from, "/"), to: strings.TrimRight(to, "/")})
+	}
+
+	pages = add(pages, origin.LoginURL, env.LoginURL)
+	pages = add(pages, origin.BaseURL, env.BaseURL)
+	pages = add(pages, urlOrigin(origin.BaseURL), urlOrigin(env.BaseURL))
+
+	apiTarget := env.ApiBaseURL
+	if apiTarget == "" {
+		apiTarget = env.BaseURL
+	}
+	apis = add(apis, origin.ApiBaseURL, apiTarget)
+	apis = append(apis, pages...)
+	return pages, apis
+}
+
+// rewriteURL maps an absolute URL through the first matching prefix and
+// resolves a root-relative one against base. Other values are left alone.
+func rewriteURL(raw string, mappings []urlMapping, base string) string {
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") {
+		if base == "" {
+			return raw
+		}
+		return strings.TrimRight(base, "/") + raw
+	}
+	for _, m := range mappings {
+		if hasURLPrefix(raw, m.from) {
+			return m.to + raw[len(m.from):]
+		}
+	}
+	return raw
+}
+
+// hasURLPrefix reports whether raw is prefix itself or a path below it
+func hasURLPrefix(raw, prefix string) bool {
+	if !strings.HasPrefix(raw, prefix) {
+		return false
+	}
+	rest := raw[len(prefix):]
+	return rest == "" || strings.ContainsAny(rest[:1], "/?#")
+}
+
+func urlOrigin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// firstPageOrigin is the origin a recording started on, used when there is no
+// scenario base URL to rewrite from
+func firstPageOrigin(steps []models.RecordingStep) string {
+	for _, s := range steps {
+		if s.Action != "navigate" {
+			continue
+		}
+		target := s.Value
+		if target == "" {
+			target = s.Selector
+		}
+		if o := urlOrigin(target); o != "" {
+			return o
+		}
+	}
+	return ""
+}
+
+func fillEnvVariables(value string, vars map[string]string) (string, error) {
+	var missing string
+	filled := models.EnvVariablePattern.ReplaceAllStringFunc(value, func(p string) string {
+		name := models.EnvVariablePattern.FindStringSubmatch(p)[1]
+		v, ok := vars[name]
+		if !ok {
+			missing = name
+			return p
+		}
+		return v
+	})
+	if missing != "" {
+		return value, fmt.Errorf("environment variable %s is not defined", missing)
+	}
+	return filled, nil
+}
+
+func swapCredential(value, from, to string) string {
+	if from == "" || to == "" || from == to {
+		return value
+	}
+	return strings.ReplaceAll(value, "{{credential:"+from+":", "{{credential:"+to+":")
+}
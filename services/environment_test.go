@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	origin := models.AuthConfig{
+		BaseURL:      "https://dev.example.com/app",
+		ApiBaseURL:   "https://api.dev.example.com",
+		CredentialID: "cred-dev",
+	}
+	env := &models.Environment{
+		ID:              "env-1",
+		Name:            "staging",
+		BaseURL:         "https://staging.example.com/app/",
+		ApiBaseURL:      "https://api.staging.example.com",
+		CredentialID:    "cred-staging",
+		Variables:       map[string]string{"TENANT": "acme"},
+		Headers:         map[string]string{"X-Preview": "1", "X-Tenant": "{{env.TENANT}}"},
+		SecretVariables: map[string]string{"COUPON": "cred-coupon"},
+	}
+	stored := []models.RecordingStep{
+		{Action: "navigate", Value: "https://dev.example.com/app/login?next=/home"},
+		{Action: "navigate", Value: "/orders/{{env.TENANT}}"},
+		{Action: "navigate", Value: "https://dev.example.com/health"},
+		{Action: "navigate", Value: "https://dev.example.com/application"},
+		{Action: "type", Value: "{{credential:cred-dev:password}}"},
+		{Action: "api_request", ApiEndpoint: "https://api.dev.example.com/v1/users"},
+		{Action: "navigate", Value: "https://other.example.org/"},
+		{Action: "fill", Selector: "#coupon", Value: "{{env.COUPON}}"},
+	}
+	run := &models.TestRun{ID: "auto-1", Steps: stored}
+
+	require.NoError(t, ApplyEnvironment(run, origin, env))
+
+	assert.Equal(t, "https://staging.example.com/app/login?next=/home", run.Steps[0].Value)
+	assert.Equal(t, "https://staging.example.com/app/orders/acme", run.Steps[1].Value)
+	assert.Equal(t, "https://staging.example.com/health", run.Steps[2].Value)
+	assert.Equal(t, "https://staging.example.com/application", run.Steps[3].Value)
+	assert.Equal(t, "{{credential:cred-staging:password}}", run.Steps[4].Value)
+	assert.Equal(t, "https://api.staging.example.com/v1/users", run.Steps[5].ApiEndpoint)
+	assert.Equal(t, "https://other.example.org/", run.Steps[6].Value)
+	assert.Equal(t, "staging", run.Environment.Name)
+	assert.Equal(t, "{{credential:cred-coupon:password}}", run.Steps[7].Value)
+	assert.Equal(t, "1", run.Environment.Headers["X-Preview"])
+	assert.Equal(t, "acme", run.Environment.Headers["X-Tenant"])
+	assert.Equal(t, []string{"https://staging.example.com", "https://api.staging.example.com"}, run.Environment.HeaderOrigins)
+
+	// The stored steps and headers are untouched
+	assert.Equal(t, "/orders/{{env.TENANT}}", stored[1].Value)
+	assert.Equal(t, "{{env.TENANT}}", env.Headers["X-Tenant"])
+}
+
+func TestApplyEnvironmentMissingVariable(t *testing.T) {
+	run := &models.TestRun{Steps: []models.RecordingStep{{Action: "type", Value: "{{env.COUPON}}"}}}
+	err := ApplyEnvironment(run, models.AuthConfig{}, &models.Environment{Name: "dev", BaseURL: "https://dev.example.com"})
+	assert.ErrorContains(t, err, "COUPON")
+}
+
+func TestApplyEnvironmentRecordingOrigin(t *testing.T) {
+	run := &models.TestRun{Steps: []models.RecordingStep{
+		{Action: "navigate", Value: "http://localhost:3000/signup"},
+		{Action: "click", Selector: "#submit"},
+	}}
+	require.NoError(t, ApplyEnvironment(run, models.AuthConfig{}, &models.Environment{Name: "preview", BaseURL: "https://pr-42.preview.example.com"}))
+	assert.Equal(t, "https://pr-42.preview.example.com/signup", run.Steps[0].Value)
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+	"google.golang.org/genai"
+)
+
+// defaultMaxProposals caps a gap analysis run when the request sets no limit
+const defaultMaxProposals = 15
+
+// GapAnalysisInput is the scenario slice and project knowledge to analyse
+type GapAnalysisInput struct {
+	Scenario     *models.TestScenario
+	SectionIDs   []string // limit the analysis to these sections, all when empty
+	Catalog      *ModuleCatalog
+	Graph        *models.KnowledgeGraph // forms and API calls of the catalog's routes, optional
+	Categories   []models.GapCategory
+	MaxProposals int
+}
+
+// gapDraft is the shape the model returns for each proposal
+type gapDraft struct {
+	Category     string `json:"category"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	PreCondition string `json:"preCondition"`
+	Steps        []struct {
+		Action   string `json:"action"`
+		Data     string `json:"data"`
+		Expected string `json:"expected"`
+	} `json:"steps"`
+	Priority  string `json:"priority"`
+	Type      string `json:"type"`
+	Rationale string `json:"rationale"`
+	Route     string `json:"route"`
+	Section   string `json:"section"`
+	BasedOn   string `json:"basedOn"`
+}
+
+// ProposeMissingTestCases asks the model for test cases the scenario is missing
+// in the requested categories. Proposals that repeat an existing test case
+// title are dropped. IDs, job and status are left for the caller to assign.
+func ProposeMissingTestCases(ctx context.Context, in GapAnalysisInput) ([]models.TestCaseProposal, error) {
+	if len(in.Categories) == 0 {
+		in.Categories = models.AllGapCategories
+	}
+	if in.MaxProposals <= 0 {
+		in.MaxProposals = defaultMaxProposals
+	}
+
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	location := os.Getenv("VERTEX_LOCATION")
+	if location == "" {
+		location = "us-central1"
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		Backend:  genai.BackendVertexAI,
+		Project:  projectID,
+		Location: location,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create genai client: %w", err)
+	}
+
+	categories := make([]string, len(in.Categories))
+	for i, c := range in.Categories {
+		categories[i] = string(c)
+	}
+
+	proposalSchema := &genai.Schema{
+		Type: genai.TypeArray,
+		Items: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"category":     {Type: genai.TypeString, Enum: categories},
+				"title":        {Type: genai.TypeString},
+				"description":  {Type: genai.TypeString},
+				"preCondition": {Type: genai.TypeString},
+				"steps": {
+					Type: genai.TypeArray,
+					Items: &genai.Schema{
+						Type: genai.TypeObject,
+						Properties: map[string]*genai.Schema{
+							"action":   {Type: genai.TypeString},
+							"data":     {Type: genai.TypeString},
+							"expected": {Type: genai.TypeString},
+						},
+						Required: []string{"action", "expected"},
+					},
+				},
+				"priority":  {Type: genai.TypeString, Enum: []string{"low", "medium", "high", "critical"}},
+				"type":      {Type: genai.TypeString, Enum: []string{"positive", "negative"}},
+				"rationale": {Type: genai.TypeString},
+				"route":     {Type: genai.TypeString},
+				"section":   {Type: genai.TypeString},
+				"basedOn":   {Type: genai.TypeString},
+			},
+			Required: []string{"category", "title", "steps", "rationale"},
+		},
+	}
+
+	config := &genai.GenerateContentConfig{
+		Temperature:      genai.Ptr(float32(0.4)),
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   proposalSchema,
+	}
+
+	resp, err := client.Models.GenerateContent(ctx, LLMModel, genai.Text(BuildGapAnalysisPrompt(in)), config)
+	if err != nil {
+		return nil, fmt.Errorf("gemini API call failed: %w", err)
+	}
+
+	resStr := getResponseString(resp)
+	if resStr == "" {
+		return nil, fmt.Errorf("empty response from gemini")
+	}
+
+	var drafts []gapDraft
+	if err := json.Unmarshal([]byte(resStr), &drafts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gemini response: %w\nResponse was: %.500s", err, resStr)
+	}
+
+	return draftsToProposals(in, drafts), nil
+}
+
+// draftsToProposals validates model output against the scenario: unknown
+// categories and duplicate titles are dropped, sections and source test cases
+// are resolved to IDs.
+func draftsToProposals(in GapAnalysisInput, drafts []gapDraft) []models.TestCaseProposal {
+	allowed := make(map[models.GapCategory]bool)
+	for _, c := range in.Categories {
+		allowed[c] = true
+	}
+
+	seen := make(map[string]bool)
+	sectionByTitle := make(map[string]string)
+	caseByCode := make(map[string]string)
+	for _, sec := range in.Scenario.Sections {
+		sectionByTitle[strings.ToLower(strings.TrimSpace(sec.Title))] = sec.ID
+		for _, tc := range sec.TestCases {
+			seen[normalizeCaseTitle(tc.Title)] = true
+			caseByCode[strings.ToUpper(tc.Code)] = tc.ID
+		}
+	}
+
+	var proposals []models.TestCaseProposal
+	for _, d := range drafts {
+		category := models.GapCategory(d.Category)
+		key := normalizeCaseTitle(d.Title)
+		if !allowed[category] || key == "" || seen[key] || len(d.Steps) == 0 {
+			continue
+		}
+		seen[key] = true
+
+		p := models.TestCaseProposal{
+			Category:     category,
+			Title:        strings.TrimSpace(d.Title),
+			Description:  d.Description,
+			PreCondition: d.PreCondition,
+			Priority:     models.Priority(d.Priority),
+			Type:         d.Type,
+			Tags:         []string{string(category)},
+			Rationale:    d.Rationale,
+			Route:        d.Route,
+		}
+		switch p.Priority {
+		case models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityCritical:
+		default:
+			p.Priority = models.PriorityMedium
+		}
+		if p.Type != "positive" && p.Type != "negative" {
+			p.Type = "negative"
+		}
+		for i, s := range d.Steps {
+			p.Steps = append(p.Steps, models.TestStepV2{
+				ID:       models.NewTestStepID(),
+				Order:    i + 1,
+				Action:   s.Action,
+				Data:     s.Data,
+				Expected: s.Expected,
+			})
+		}
+		p.SuggestedSectionID = sectionByTitle[strings.ToLower(strings.TrimSpace(d.Section))]
+		p.BasedOnTestCaseID = caseByCode[strings.ToUpper(strings.TrimSpace(d.BasedOn))]
+
+		proposals = append(proposals, p)
+		if len(proposals) == in.MaxProposals {
+			break
+		}
+	}
+	return proposals
+}
+
+// BuildGapAnalysisPrompt describes the existing test cases and the relevant part
+// of the knowledge graph, and asks for missing cases in the given categories.
+func BuildGapAnalysisPrompt(in GapAnalysisInput) string {
+	var b strings.Builder
+	scenario := in.Scenario
+
+	b.WriteString("You are a senior QA engineer reviewing a test scenario for missing coverage.\n")
+	b.WriteString("Scenario authors tend to cover the happy path and forget negative paths and edge cases.\n\n")
+	fmt.Fprintf(&b, "Scenario: %s\n", scenario.Title)
+	if scenario.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", scenario.Description)
+	}
+
+	b.WriteString("\n## Existing test cases\n")
+	for _, sec := range gapSections(in) {
+		fmt.Fprintf(&b, "\n### Section: %s\n", sec.Title)
+		for _, tc := range sec.TestCases {
+			fmt.Fprintf(&b, "- %s [%s] %s\n", tc.Code, tc.Type, tc.Title)
+			for _, s := range tc.Steps {
+				fmt.Fprintf(&b, "  %d. %s", s.Order, s.Action)
+				if s.Expected != "" {
+					fmt.Fprintf(&b, " → %s", s.Expected)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	if routes := relevantRoutes(in); len(routes) > 0 {
+		b.WriteString("\n## Application knowledge (pages, elements, actions)\n")
+		for _, route := range routes {
+			entry := in.Catalog.GetRouteEntry(route)
+			if entry == nil {
+				continue
+			}
+			fmt.Fprintf(&b, "\n- Route %s: %s\n", route, entry.Description)
+			if len(entry.AvailableActions) > 0 {
+				fmt.Fprintf(&b, "  Actions: %s\n", strings.Join(entry.AvailableActions, ", "))
+			}
+			if len(entry.KeyElements) > 0 {
+				names := make([]string, 0, len(entry.KeyElements))
+				for name := range entry.KeyElements {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				fmt.Fprintf(&b, "  Elements: %s\n", strings.Join(names, ", "))
+			}
+			info := routeKnowledge(in, route)
+			for _, form := range info.Forms {
+				fmt.Fprintf(&b, "  Form %s: %s\n", form.SchemaName, formFieldList(form))
+			}
+			if len(info.APIs) > 0 {
+				calls := make([]string, len(info.APIs))
+				for i, api := range info.APIs {
+					calls[i] = api.HTTPMethod + " " + api.Endpoint
+				}
+				fmt.Fprintf(&b, "  API calls: %s\n", strings.Join(calls, ", "))
+			}
+		}
+
+		if hints := gapHints(in, routes); len(hints) > 0 {
+			b.WriteString("\n## Likely gaps to check\n")
+			for _, h := range hints {
+				fmt.Fprintf(&b, "- %s\n", h)
+			}
+		}
+	}
+
+	b.WriteString("\n## Task\n")
+	fmt.Fprintf(&b, "Propose up to %d NEW test cases that are missing from the scenario, only in these categories:\n", in.MaxProposals)
+	for _, c := range in.Categories {
+		fmt.Fprintf(&b, "- %s: %s\n", c, gapCategoryGuidance[c])
+	}
+	b.WriteString(`
+Rules:
+- Do not repeat or rephrase an existing test case.
+- Each proposal must be concrete: real field names, values and expected messages where known.
+- Steps are manual test steps: "action" is what the tester does, "data" the input, "expected" the observable result.
+- "type" is "positive" when the case checks behaviour that should succeed, "negative" when it checks a failure or rejection.
+- "rationale" explains in one sentence which gap this closes.
+- "section" is the title of the existing section the case belongs in; "basedOn" is the code of the closest existing case, if any.
+- "route" is the application route the case exercises, if known.
+Return ONLY a JSON array.`)
+
+	return b.String()
+}
+
+var gapCategoryGuidance = map[models.GapCategory]string{
+	models.GapValidation: "required fields left empty, invalid formats, server-side validation errors",
+	models.GapPermission: "users without the needed role or ownership trying to view or change data",
+	models.GapEmptyState: "lists, searches and dashboards with no data, and first-time use",
+	models.GapBoundary:   "minimum/maximum lengths and values, just inside and just outside limits",
+	models.GapNegative:   "failed API calls, cancelled flows, duplicates and conflicting actions",
+}
+
+func gapSections(in GapAnalysisInput) []models.TestSection {
+	if len(in.SectionIDs) == 0 {
+		return in.Scenario.Sections
+	}
+	wanted := make(map[string]bool)
+	for _, id := range in.SectionIDs {
+		wanted[id] = true
+	}
+	var sections []models.TestSection
+	for _, sec := range in.Scenario.Sections {
+		if wanted[sec.ID] {
+			sections = append(sections, sec)
+		}
+	}
+	return sections
+}
+
+// relevantRoutes picks the catalog routes that belong to the modules the
+// scenario's sections and title refer to.
+func relevantRoutes(in GapAnalysisInput) []string {
+	if in.Catalog == nil {
+		return nil
+	}
+
+	set := make(map[string]bool)
+	names := []string{in.Scenario.Title}
+	for _, sec := range gapSections(in) {
+		names = append(names, sec.Title)
+	}
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		for _, route := range in.Catalog.InferRoutesFromSheetName(name) {
+			set[route] = true
+		}
+	}
+
+	routes := make([]string, 0, len(set))
+	for route := range set {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	if len(routes) > 25 {
+		routes = routes[:25]
+	}
+	return routes
+}
+
+// gapHints derives deterministic hints from the knowledge graph so the model
+// looks at inputs, lists and destructive actions first.
+func gapHints(in GapAnalysisInput, routes []string) []string {
+	wanted := make(map[models.GapCategory]bool)
+	for _, c := range in.Categories {
+		wanted[c] = true
+	}
+
+	var hints []string
+	for _, route := range routes {
+		entry := in.Catalog.GetRouteEntry(route)
+		if entry == nil {
+			continue
+		}
+
+		var inputs []string
+		for name := range entry.KeyElements {
+			lower := strings.ToLower(name)
+			if strings.Contains(lower, "input") || strings.Contains(lower, "field") || strings.Contains(lower, "select") {
+				inputs = append(inputs, name)
+			}
+		}
+		sort.Strings(inputs)
+		if len(inputs) > 0 && (wanted[models.GapValidation] || wanted[models.GapBoundary]) {
+			hints = append(hints, fmt.Sprintf("%s has inputs (%s): check required, format and length limits", route, strings.Join(inputs, ", ")))
+		}
+
+		info := routeKnowledge(in, route)
+		for _, form := range info.Forms {
+			var required []string
+			for _, f := range form.Fields {
+				if f.Required {
+					required = append(required, fieldName(f))
+				}
+			}
+			if len(required) > 0 && wanted[models.GapValidation] {
+				hints = append(hints, fmt.Sprintf("%s form %s requires %s: leave each one empty", route, form.SchemaName, strings.Join(required, ", ")))
+			}
+			if len(form.Fields) > 0 && wanted[models.GapBoundary] {
+				hints = append(hints, fmt.Sprintf("%s form %s (%s): check the length and value limits of each field", route, form.SchemaName, formFieldList(form)))
+			}
+		}
+		for _, api := range info.APIs {
+			call := api.HTTPMethod + " " + api.Endpoint
+			switch api.HTTPMethod {
+			case "GET":
+				if wanted[models.GapEmptyState] {
+					hints = append(hints, fmt.Sprintf("%s loads %s: check an empty response", route, call))
+				}
+				if wanted[models.GapNegative] {
+					hints = append(hints, fmt.Sprintf("%s loads %s: check how a failed request is shown", route, call))
+				}
+			default:
+				if wanted[models.GapNegative] {
+					hints = append(hints, fmt.Sprintf("%s calls %s: check a failed or duplicate submission", route, call))
+				}
+				if wanted[models.GapPermission] {
+					hints = append(hints, fmt.Sprintf("%s calls %s: check users who may not make this change", route, call))
+				}
+			}
+		}
+
+		for _, action := range entry.AvailableActions {
+			switch strings.ToLower(action) {
+			case "list", "search", "filter":
+				if wanted[models.GapEmptyState] {
+					hints = append(hints, fmt.Sprintf("%s supports %s: check the no-results state", route, action))
+				}
+			case "delete", "edit", "update", "approve":
+				if wanted[models.GapPermission] {
+					hints = append(hints, fmt.Sprintf("%s supports %s: check users who may not %s", route, action, action))
+				}
+			}
+		}
+	}
+	if len(hints) > 30 {
+		hints = hints[:30]
+	}
+	return hints
+}
+
+// routeKnowledge is the graph's forms and API calls of a route, empty without a graph
+func routeKnowledge(in GapAnalysisInput, route string) models.RouteInfo {
+	if in.Graph == nil {
+		return models.RouteInfo{}
+	}
+	return in.Graph.RouteSummary[route]
+}
+
+// formFieldList names a form's fields, marking required ones with *
+func formFieldList(form models.FormEntry) string {
+	names := make([]string, len(form.Fields))
+	for i, f := range form.Fields {
+		names[i] = fieldName(f)
+		if f.Required {
+			names[i] += "*"
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func fieldName(f models.FieldInfo) string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+// GapAnalysisRoutes are the catalog routes a gap analysis looks at
+func GapAnalysisRoutes(in GapAnalysisInput) []string {
+	return relevantRoutes(in)
+}
+
+var (
+	// apiCallPattern finds fetch, axios and api client calls with a literal URL
+	apiCallPattern = regexp.MustCompile("\\b(?:fetch|axios|api|http|client)(?:\\.(get|post|put|patch|delete))?\\(\\s*['\"\x60]([^'\"\x60\\s]+)['\"\x60]")
+	// fetchMethodPattern finds the method option of a fetch call
+	fetchMethodPattern = regexp.MustCompile(`^[^)]*?method:\s*['"]([A-Za-z]+)['"]`)
+	// requiredFieldPattern finds form fields marked required by an HTML
+	// attribute or a validation rule
+	requiredFieldPattern = regexp.MustCompile(`name=['"{]+([A-Za-z0-9_.\[\]-]+)['"}]+[^>]*?(?:\brequired\b|required:\s*true)`)
+)
+
+// LoadGapKnowledge reads the forms and API calls of routes from their page
+// sources. Form fields come from the catalog's input selectors of the page and
+// are marked required when the source says so; fetch and axios calls with a
+// literal URL become API calls. Returns nil when nothing could be read.
+func LoadGapKnowledge(ctx context.Context, glClient *gitlab.Client, projectID, branch string, catalog *ModuleCatalog, routes []string) *models.KnowledgeGraph {
+	if catalog == nil || len(routes) == 0 {
+		return nil
+	}
+	codebase, graph, err := NewGraphMapper().FetchCodebaseWithCatalog(ctx, glClient, projectID, branch, catalog, routes)
+	if err != nil {
+		log.Printf("[GapAnalysis] Failed to read page sources of project %s: %v", projectID, err)
+		return nil
+	}
+	sources := make(map[string]string, len(codebase.Files))
+	for _, f := range codebase.Files {
+		sources[f.Path] = f.Content
+	}
+	for _, route := range routes {
+		entry := catalog.GetRouteEntry(route)
+		if entry == nil {
+			continue
+		}
+		info := graph.RouteSummary[route]
+		info.Forms = pageForms(catalog.Selectors[entry.FilePath], sources[entry.FilePath])
+		info.APIs = pageAPICalls(sources[entry.FilePath])
+		graph.RouteSummary[route] = info
+	}
+	return graph
+}
+
+// pageForms groups the input elements of a page into one form
+func pageForms(selectors []ExtractedSelector, source string) []models.FormEntry {
+	required := make(map[string]bool)
+	for _, m := range requiredFieldPattern.FindAllStringSubmatch(source, -1) {
+		required[m[1]] = true
+	}
+
+	form := models.FormEntry{SchemaName: "page form"}
+	seen := make(map[string]bool)
+	for _, s := range selectors {
+		switch strings.ToLower(s.ElementType) {
+		case "input", "select", "textarea", "checkbox", "radio", "inputnumber", "datepicker":
+		default:
+			continue
+		}
+		if s.Name == "" || seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+		label := s.AriaLabel
+		if label == "" {
+			label = s.Placeholder
+		}
+		form.Fields = append(form.Fields, models.FieldInfo{Name: s.Name, Label: label, Required: required[s.Name]})
+	}
+	if len(form.Fields) == 0 {
+		return nil
+	}
+	return []models.FormEntry{form}
+}
+
+// pageAPICalls lists the distinct API calls made from a page source
+func pageAPICalls(source string) []models.APIEntry {
+	var calls []models.APIEntry
+	seen := make(map[string]bool)
+	for _, loc := range apiCallPattern.FindAllStringSubmatchIndex(source, -1) {
+		endpoint := source[loc[4]:loc[5]]
+		if !strings.HasPrefix(endpoint, "/") && !strings.HasPrefix(endpoint, "http") {
+			continue
+		}
+		method := "GET"
+		if loc[2] >= 0 {
+			method = strings.ToUpper(source[loc[2]:loc[3]])
+		} else if m := fetchMethodPattern.FindStringSubmatch(source[loc[1]:]); m != nil {
+			method = strings.ToUpper(m[1])
+		}
+		key := method + " " + endpoint
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		calls = append(calls, models.APIEntry{HTTPMethod: method, Endpoint: endpoint})
+	}
+	return calls
+}
+
+func normalizeCaseTitle(title string) string {
+	return strings.Join(strings.Fields(strings.ToLower(title)), " ")
+}
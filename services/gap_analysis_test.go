@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraftsToProposals(t *testing.T) {
+	scenario := &models.TestScenario{
+		Sections: []models.TestSection{{
+			ID:    "sec-1",
+			Title: "Login",
+			TestCases: []models.TestCase{
+				{ID: "tc-1", Code: "TC-001", Title: "Login with valid credentials"},
+			},
+		}},
+	}
+
+	drafts := []gapDraft{
+		{Category: "validation", Title: "Login with empty password", Section: "login", BasedOn: "tc-001", Priority: "urgent", Type: "negative"},
+		{Category: "validation", Title: "  login WITH valid credentials "},
+		{Category: "permission", Title: "Login as suspended user"},
+		{Category: "validation", Title: "Login with a pasted password", Type: "positive"},
+		{Category: "unknown", Title: "Something else"},
+	}
+	for i := range drafts {
+		drafts[i].Steps = append(drafts[i].Steps, struct {
+			Action   string `json:"action"`
+			Data     string `json:"data"`
+			Expected string `json:"expected"`
+		}{Action: "Submit the form", Expected: "An error is shown"})
+	}
+
+	proposals := draftsToProposals(GapAnalysisInput{
+		Scenario:     scenario,
+		Categories:   []models.GapCategory{models.GapValidation},
+		MaxProposals: 10,
+	}, drafts)
+
+	if assert.Len(t, proposals, 2) {
+		p := proposals[0]
+		assert.Equal(t, "Login with empty password", p.Title)
+		assert.Equal(t, "sec-1", p.SuggestedSectionID)
+		assert.Equal(t, "tc-1", p.BasedOnTestCaseID)
+		assert.Equal(t, models.PriorityMedium, p.Priority)
+		assert.Equal(t, "negative", p.Type)
+		assert.Equal(t, 1, p.Steps[0].Order)
+		assert.Equal(t, "positive", proposals[1].Type)
+	}
+}
+
+func TestPageFormsAndAPICalls(t *testing.T) {
+	source := `
+export default function Register() {
+  const { data } = useQuery(() => api.get('/api/plans'));
+  const submit = (v) => fetch("/api/users", { method: 'POST', body: JSON.stringify(v) });
+  axios.delete(` + "`/api/users/${id}`" + `);
+  return (
+    <form onSubmit={submit}>
+      <input name="email" type="email" required />
+      <Form.Item name="company" rules={[{ required: true, message: 'Company is required' }]}><Input name="company" /></Form.Item>
+      <input name="nickname" />
+    </form>
+  );
+}`
+	selectors := []ExtractedSelector{
+		{ElementType: "input", Name: "email", Placeholder: "Email"},
+		{ElementType: "input", Name: "company"},
+		{ElementType: "input", Name: "nickname"},
+		{ElementType: "button", Text: "Sign up"},
+	}
+
+	forms := pageForms(selectors, source)
+	if assert.Len(t, forms, 1) {
+		assert.Equal(t, []models.FieldInfo{
+			{Name: "email", Label: "Email", Required: true},
+			{Name: "company", Required: true},
+			{Name: "nickname"},
+		}, forms[0].Fields)
+	}
+
+	assert.Equal(t, []models.APIEntry{
+		{HTTPMethod: "GET", Endpoint: "/api/plans"},
+		{HTTPMethod: "POST", Endpoint: "/api/users"},
+		{HTTPMethod: "DELETE", Endpoint: "/api/users/${id}"},
+	}, pageAPICalls(source))
+}
+
+func TestGapHintsFromFormsAndAPIs(t *testing.T) {
+	catalog := &ModuleCatalog{
+		Modules:    map[string]ModuleEntry{"users": {DisplayName: "Users", Routes: map[string]RouteEntry{"/register": {FilePath: "app/register/page.tsx"}}}},
+		RouteIndex: map[string]string{"/register": "users"},
+	}
+	graph := &models.KnowledgeGraph{RouteSummary: map[string]models.RouteInfo{
+		"/register": {
+			Forms: []models.FormEntry{{SchemaName: "page form", Fields: []models.FieldInfo{{Name: "email", Required: true}, {Name: "nickname"}}}},
+			APIs:  []models.APIEntry{{HTTPMethod: "POST", Endpoint: "/api/users"}},
+		},
+	}}
+	in := GapAnalysisInput{
+		Scenario:   &models.TestScenario{Title: "Users"},
+		Catalog:    catalog,
+		Graph:      graph,
+		Categories: []models.GapCategory{models.GapValidation, models.GapNegative},
+	}
+
+	hints := gapHints(in, []string{"/register"})
+	assert.Contains(t, hints, "/register form page form requires email: leave each one empty")
+	assert.Contains(t, hints, "/register calls POST /api/users: check a failed or duplicate submission")
+
+	prompt := BuildGapAnalysisPrompt(GapAnalysisInput{Scenario: in.Scenario, Catalog: catalog, Graph: graph, Categories: in.Categories, MaxProposals: 5})
+	assert.Contains(t, prompt, "Form page form: email*, nickname")
+	assert.Contains(t, prompt, "API calls: POST /api/users")
+}
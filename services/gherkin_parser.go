@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// Gherkin keywords recognised by the parser (English dialect only)
+var (
+	gherkinScenarioKeywords = []string{"Scenario Outline:", "Scenario Template:", "Scenario:", "Example:"}
+	gherkinExamplesKeywords = []string{"Examples:", "Scenarios:"}
+	gherkinStepKeywords     = []string{"Given ", "When ", "Then ", "And ", "But ", "* "}
+)
+
+// gherkinParser holds the state machine used while walking a .feature file
+type gherkinParser struct {
+	feature  *models.GherkinFeature
+	rule     *models.GherkinRule
+	scenario *models.GherkinScenario
+	examples *models.GherkinExamples
+
+	// background is set while collecting Background steps (feature or rule level)
+	background *[]models.GherkinStep
+	// lastStep points to the step that should receive doc strings / data tables
+	lastStep *models.GherkinStep
+	// description collects free text after a Feature/Rule/Scenario header
+	description *string
+
+	pendingTags []string
+}
+
+// ParseGherkin parses a single .feature file into a GherkinFeature.
+// It supports Feature, Rule, Background, Scenario/Example, Scenario Outline,
+// Examples tables, tags, doc strings and data tables.
+func ParseGherkin(reader io.Reader) (*models.GherkinFeature, error) {
+	p := &gherkinParser{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	lineNum := 0
+	var docDelimiter string
+	var docLines []string
+	docIndent := 0
+
+	for scanner.Scan() {
+		lineNum++
+		raw := strings.TrimRight(scanner.Text(), "\r")
+		line := strings.TrimSpace(raw)
+
+		// Doc strings: everything between delimiters is taken verbatim
+		if docDelimiter != "" {
+			if line == docDelimiter {
+				if p.lastStep != nil {
+					p.lastStep.DocString = strings.Join(docLines, "\n")
+				}
+				docDelimiter = ""
+				docLines = nil
+				continue
+			}
+			docLines = append(docLines, stripIndent(raw, docIndent))
+			continue
+		}
+		if strings.HasPrefix(line, `"""`) || strings.HasPrefix(line, "```") {
+			docDelimiter = line[:3]
+			docIndent = len(raw) - len(strings.TrimLeft(raw, " \t"))
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "@") {
+			p.pendingTags = append(p.pendingTags, parseGherkinTags(line)...)
+			continue
+		}
+
+		if strings.HasPrefix(line, "|") {
+			if err := p.addTableRow(parseGherkinTableRow(line), lineNum); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if name, ok := cutKeyword(line, "Feature:"); ok {
+			if p.feature != nil {
+				return nil, fmt.Errorf("line %d: only one Feature is allowed per file", lineNum)
+			}
+			p.feature = &models.GherkinFeature{Name: name, Tags: p.takeTags()}
+			p.resetBlock()
+			p.description = &p.feature.Description
+			continue
+		}
+
+		if p.feature == nil {
+			return nil, fmt.Errorf("line %d: expected 'Feature:' but found %q", lineNum, line)
+		}
+
+		if name, ok := cutKeyword(line, "Rule:"); ok {
+			p.closeRule()
+			p.rule = &models.GherkinRule{Name: name, Tags: p.takeTags()}
+			p.resetBlock()
+			p.description = &p.rule.Description
+			continue
+		}
+
+		if _, ok := cutKeyword(line, "Background:"); ok {
+			p.closeScenario()
+			p.resetBlock()
+			if p.rule != nil {
+				p.background = &p.rule.Background
+			} else {
+				p.background = &p.feature.Background
+			}
+			continue
+		}
+
+		if kw, name, ok := cutAnyKeyword(line, gherkinScenarioKeywords); ok {
+			p.closeScenario()
+			p.resetBlock()
+			p.scenario = &models.GherkinScenario{
+				Name:    name,
+				Tags:    p.takeTags(),
+				Outline: kw == "Scenario Outline:" || kw == "Scenario Template:",
+				Line:    lineNum,
+			}
+			p.description = &p.scenario.Description
+			continue
+		}
+
+		if _, name, ok := cutAnyKeyword(line, gherkinExamplesKeywords); ok {
+			if p.scenario == nil {
+				return nil, fmt.Errorf("line %d: Examples must belong to a Scenario Outline", lineNum)
+			}
+			p.scenario.Examples = append(p.scenario.Examples, models.GherkinExamples{Name: name, Tags: p.takeTags()})
+			p.examples = &p.scenario.Examples[len(p.scenario.Examples)-1]
+			p.lastStep = nil
+			p.description = nil
+			continue
+		}
+
+		if kw, text, ok := cutAnyKeyword(line, gherkinStepKeywords); ok {
+			step := models.GherkinStep{Keyword: strings.TrimSpace(kw), Text: text, Line: lineNum}
+			p.description = nil
+			switch {
+			case p.background != nil:
+				*p.background = append(*p.background, step)
+				p.lastStep = &(*p.background)[len(*p.background)-1]
+			case p.scenario != nil:
+				p.scenario.Steps = append(p.scenario.Steps, step)
+				p.lastStep = &p.scenario.Steps[len(p.scenario.Steps)-1]
+			default:
+				return nil, fmt.Errorf("line %d: step %q is outside of a Scenario or Background", lineNum, line)
+			}
+			continue
+		}
+
+		// Anything else is free-form description text
+		if p.description != nil {
+			if *p.description != "" {
+				*p.description += "\n"
+			}
+			*p.description += line
+			continue
+		}
+
+		return nil, fmt.Errorf("line %d: unexpected content %q", lineNum, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feature file: %w", err)
+	}
+	if docDelimiter != "" {
+		return nil, fmt.Errorf("unterminated doc string at end of file")
+	}
+	if p.feature == nil {
+		return nil, fmt.Errorf("no Feature found")
+	}
+
+	p.closeScenario()
+	p.closeRule()
+
+	return p.feature, nil
+}
+
+func (p *gherkinParser) addTableRow(cells []string, lineNum int) error {
+	if p.examples != nil {
+		if p.examples.Header == nil {
+			p.examples.Header = cells
+			return nil
+		}
+		if len(cells) != len(p.examples.Header) {
+			return fmt.Errorf("line %d: examples row has %d cells, header has %d", lineNum, len(cells), len(p.examples.Header))
+		}
+		p.examples.Rows = append(p.examples.Rows, cells)
+		return nil
+	}
+	if p.lastStep != nil {
+		p.lastStep.DataTable = append(p.lastStep.DataTable, cells)
+		return nil
+	}
+	return fmt.Errorf("line %d: table row without a preceding step or Examples", lineNum)
+}
+
+// resetBlock clears the per-block pointers when a new header is found
+func (p *gherkinParser) resetBlock() {
+	p.background = nil
+	p.examples = nil
+	p.lastStep = nil
+	p.description = nil
+}
+
+func (p *gherkinParser) takeTags() []string {
+	tags := p.pendingTags
+	p.pendingTags = nil
+	return tags
+}
+
+func (p *gherkinParser) closeScenario() {
+	if p.scenario == nil {
+		return
+	}
+	if p.rule != nil {
+		p.rule.Scenarios = append(p.rule.Scenarios, *p.scenario)
+	} else {
+		p.feature.Scenarios = append(p.feature.Scenarios, *p.scenario)
+	}
+	p.scenario = nil
+}
+
+func (p *gherkinParser) closeRule() {
+	p.closeScenario()
+	if p.rule == nil {
+		return
+	}
+	p.feature.Rules = append(p.feature.Rules, *p.rule)
+	p.rule = nil
+}
+
+func cutKeyword(line, keyword string) (string, bool) {
+	if !strings.HasPrefix(line, keyword) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, keyword)), true
+}
+
+func cutAnyKeyword(line string, keywords []string) (string, string, bool) {
+	for _, kw := range keywords {
+		if rest, ok := cutKeyword(line, kw); ok {
+			return kw, rest, true
+		}
+	}
+	return "", "", false
+}
+
+func parseGherkinTags(line string) []string {
+	// Trailing comments are allowed after tags
+	if idx := strings.Index(line, " #"); idx >= 0 {
+		line = line[:idx]
+	}
+	var tags []string
+	for _, t := range strings.Fields(line) {
+		t = strings.TrimPrefix(t, "@")
+		if t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func parseGherkinTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			if r == 'n' {
+				cell.WriteRune('\n')
+			} else {
+				cell.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteRune(r)
+		}
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+	return cells
+}
+
+// stripIndent removes up to n leading whitespace characters so doc strings
+// keep their relative indentation
+func stripIndent(s string, n int) string {
+	i := 0
+	for i < len(s) && i < n && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return s[i:]
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleFeature = `
+@checkout
+Feature: Checkout
+  Customers can pay for the items in their cart.
+
+  Background:
+    Given I am logged in as "buyer@example.com"
+
+  @smoke
+  Scenario: Pay with a saved card
+    Given I have 2 items in my cart
+    When I open the checkout page
+    And I pay with my saved card
+    Then I see the order confirmation
+    And I receive a receipt email
+
+  Scenario Outline: Invalid coupon codes
+    When I apply the coupon "<code>"
+    Then I see the error "<message>"
+
+    Examples:
+      | code    | message        |
+      | EXPIRED | Coupon expired |
+      | UNKNOWN | Invalid coupon |
+
+  Rule: Guests must register first
+    Scenario: Guest checkout is redirected
+      Given I am not logged in
+      When I open the checkout page with
+        """
+        {"cart": "guest"}
+        """
+      Then I am redirected to "/register"
+`
+
+func TestParseGherkin(t *testing.T) {
+	feature, err := ParseGherkin(strings.NewReader(sampleFeature))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Checkout", feature.Name)
+	assert.Equal(t, []string{"checkout"}, feature.Tags)
+	assert.Equal(t, "Customers can pay for the items in their cart.", feature.Description)
+	assert.Len(t, feature.Background, 1)
+	assert.Len(t, feature.Scenarios, 2)
+	assert.Len(t, feature.Rules, 1)
+
+	outline := feature.Scenarios[1]
+	assert.True(t, outline.Outline)
+	assert.Len(t, outline.Examples, 1)
+	assert.Equal(t, []string{"code", "message"}, outline.Examples[0].Header)
+	assert.Len(t, outline.Examples[0].Rows, 2)
+
+	rule := feature.Rules[0]
+	assert.Equal(t, "Guests must register first", rule.Name)
+	assert.Len(t, rule.Scenarios, 1)
+	assert.Equal(t, `{"cart": "guest"}`, rule.Scenarios[0].Steps[1].DocString)
+}
+
+func TestParseGherkinErrors(t *testing.T) {
+	_, err := ParseGherkin(strings.NewReader("Scenario: no feature"))
+	assert.Error(t, err)
+
+	_, err = ParseGherkin(strings.NewReader("Feature: x\n  Scenario Outline: y\n    Examples:\n      | a | b |\n      | 1 |"))
+	assert.Error(t, err)
+}
+
+func TestBuildScenarioFromGherkin(t *testing.T) {
+	feature, err := ParseGherkin(strings.NewReader(sampleFeature))
+	assert.NoError(t, err)
+
+	scenario := BuildScenarioFromGherkin("checkout.feature", []models.GherkinFeature{*feature}, "42", "", models.AuthConfig{}, 0)
+
+	assert.Equal(t, "checkout", scenario.Title)
+	assert.Len(t, scenario.Sections, 2)
+	assert.Equal(t, "Checkout / Guests must register first", scenario.Sections[1].Title)
+	assert.Len(t, scenario.Sheets, 2)
+
+	pay := scenario.Sections[0].TestCases[0]
+	assert.Equal(t, "TC-001", pay.Code)
+	assert.Equal(t, `Given I am logged in as "buyer@example.com"`, pay.PreCondition)
+	assert.Len(t, pay.Steps, 3)
+	assert.Equal(t, "I see the order confirmation\nI receive a receipt email", pay.Steps[2].Expected)
+	assert.Equal(t, models.PriorityHigh, pay.Priority)
+	assert.Contains(t, pay.Tags, "smoke")
+
+	coupon := scenario.Sections[0].TestCases[1]
+	assert.Len(t, coupon.Parameters, 2)
+	assert.Equal(t, map[string]string{"code": "EXPIRED", "message": "Coupon expired"}, coupon.Parameters[0])
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// ErrIgnoredGitLabEvent is returned for GitLab webhook events no rule can
+// trigger on, such as tag pushes or comments
+var ErrIgnoredGitLabEvent = errors.New("GitLab event is not used by automation rules")
+
+// GitLabEvent is the part of a GitLab webhook event automation rules match on
+type GitLabEvent struct {
+	ProjectID string
+	// Triggers are the rule triggers the event fires; a merge request update
+	// that adds a label fires only merge_request.labeled
+	Triggers []string
+	// Branch is the pushed branch, the pipeline's ref or the merge request's
+	// target branch
+	Branch          string
+	SourceBranch    string
+	CommitSHA       string
+	MergeRequestIID int64
+	PipelineID      int64
+	IssueIID        int64
+	// AddedLabels are the labels added by this event
+	AddedLabels []string
+}
+
+// ParseGitLabEvent reads a GitLab webhook payload of the type given in its
+// X-Gitlab-Event header
+func ParseGitLabEvent(eventType gitlab.EventType, payload []byte) (*GitLabEvent, error) {
+	raw, err := gitlab.ParseWebhook(eventType, payload)
+	if err != nil {
+		return nil, ErrIgnoredGitLabEvent
+	}
+
+	var ev GitLabEvent
+	switch e := raw.(type) {
+	case *gitlab.PushEvent:
+		branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+		// Tag pushes and branch deletions have nothing to test
+		if !ok || strings.Trim(e.After, "0") == "" {
+			return nil, ErrIgnoredGitLabEvent
+		}
+		ev = GitLabEvent{
+			ProjectID: strconv.FormatInt(e.ProjectID, 10),
+			Triggers:  []string{models.TriggerPush},
+			Branch:    branch,
+			CommitSHA: e.After,
+		}
+
+	case *gitlab.MergeEvent:
+		attrs := e.ObjectAttributes
+		ev = GitLabEvent{
+			ProjectID:       strconv.FormatInt(e.Project.ID, 10),
+			Branch:          attrs.TargetBranch,
+			SourceBranch:    attrs.SourceBranch,
+			CommitSHA:       attrs.LastCommit.ID,
+			MergeRequestIID: attrs.IID,
+			AddedLabels:     addedLabels(e.Changes.Labels),
+		}
+		switch attrs.Action {
+		case "open", "reopen":
+			ev.Triggers = append(ev.Triggers, models.TriggerMergeRequestOpened)
+		case "merge":
+			ev.Triggers = append(ev.Triggers, models.TriggerMergeRequestMerged)
+		}
+		if len(ev.AddedLabels) > 0 {
+			ev.Triggers = append(ev.Triggers, models.TriggerMergeRequestLabel)
+		}
+
+	case *gitlab.PipelineEvent:
+		attrs := e.ObjectAttributes
+		ev = GitLabEvent{
+			ProjectID:       strconv.FormatInt(e.Project.ID, 10),
+			Branch:          attrs.Ref,
+			CommitSHA:       attrs.SHA,
+			PipelineID:      attrs.ID,
+			MergeRequestIID: e.MergeRequest.IID,
+		}
+		switch attrs.Status {
+		case "success":
+			ev.Triggers = []string{models.TriggerPipelineSuccess}
+		case "failed":
+			ev.Triggers = []string{models.TriggerPipelineFailed}
+		}
+
+	case *gitlab.IssueEvent:
+		ev = GitLabEvent{
+			ProjectID:   strconv.FormatInt(e.Project.ID, 10),
+			IssueIID:    e.ObjectAttributes.IID,
+			AddedLabels: addedLabels(e.Changes.Labels),
+		}
+		if len(ev.AddedLabels) > 0 {
+			ev.Triggers = []string{models.TriggerIssueLabel}
+		}
+	}
+
+	if len(ev.Triggers) == 0 {
+		return nil, ErrIgnoredGitLabEvent
+	}
+	return &ev, nil
+}
+
+// addedLabels returns the titles in a label change that were not there before
+func addedLabels(change gitlab.EventChangesLabels) []string {
+	previous := make(map[string]bool, len(change.Previous))
+	for _, l := range change.Previous {
+		previous[l.Title] = true
+	}
+	var added []string
+	for _, l := range change.Current {
+		if !previous[l.Title] {
+			added = append(added, l.Title)
+		}
+	}
+	return added
+}
+
+// MatchGitLabRules returns the enabled rules of an automation the event triggers
+func MatchGitLabRules(cfg models.GitLabAutomation, ev *GitLabEvent) []models.GitLabAutomationRule {
+	if !cfg.Enabled {
+		return nil
+	}
+	var matched []models.GitLabAutomationRule
+	for _, rule := range cfg.Rules {
+		if !rule.Enabled || !slices.Contains(ev.Triggers, rule.Trigger) {
+			continue
+		}
+		if rule.Label != "" && !slices.Contains(ev.AddedLabels, rule.Label) {
+			continue
+		}
+		if ev.Branch != "" && !branchMatches(rule.Branches, ev.Branch) {
+			continue
+		}
+		matched = append(matched, rule)
+	}
+	return matched
+}
+
+func branchMatches(patterns []string, branch string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateGitLabAutomationRule checks that a rule's trigger and action exist
+// and go together
+func ValidateGitLabAutomationRule(rule models.GitLabAutomationRule) error {
+	if !slices.Contains(models.GitLabTriggers, rule.Trigger) {
+		return fmt.Errorf("unknown trigger %q", rule.Trigger)
+	}
+	if !slices.Contains(models.GitLabActions, rule.Action) {
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	labeled := rule.Trigger == models.TriggerMergeRequestLabel || rule.Trigger == models.TriggerIssueLabel
+	if labeled && rule.Label == "" {
+		return fmt.Errorf("trigger %s needs a label", rule.Trigger)
+	}
+	for _, p := range rule.Branches {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid branch pattern %q", p)
+		}
+	}
+
+	switch rule.Action {
+	case models.ActionRebuildKnowledgeGraph:
+		if rule.Trigger == models.TriggerIssueLabel {
+			return fmt.Errorf("%s needs a branch; issue events have none", rule.Action)
+		}
+	case models.ActionStartFixAgent:
+		if rule.Trigger != models.TriggerIssueLabel {
+			return fmt.Errorf("%s can only be triggered by %s", rule.Action, models.TriggerIssueLabel)
+		}
+		if rule.Runner != "" && rule.Runner != "claude" && rule.Runner != "pi" {
+			return fmt.Errorf("invalid runner value, must be 'claude' or 'pi'")
+		}
+	}
+	return nil
+}
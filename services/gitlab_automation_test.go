@@ -0,0 +1,125 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestParseGitLabPushEvent(t *testing.T) {
+	ev, err := ParseGitLabEvent(gitlab.EventTypePush, []byte(`{
+		"object_kind": "push", "ref": "refs/heads/release/1.2",
+		"after": "9f3c2a1", "project_id": 42
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "42", ev.ProjectID)
+	assert.Equal(t, []string{models.TriggerPush}, ev.Triggers)
+	assert.Equal(t, "release/1.2", ev.Branch)
+	assert.Equal(t, "9f3c2a1", ev.CommitSHA)
+
+	_, err = ParseGitLabEvent(gitlab.EventTypeTagPush, []byte(`{"object_kind": "tag_push", "ref": "refs/tags/v1"}`))
+	assert.ErrorIs(t, err, ErrIgnoredGitLabEvent)
+
+	_, err = ParseGitLabEvent(gitlab.EventTypePush, []byte(`{
+		"object_kind": "push", "ref": "refs/heads/old",
+		"after": "0000000000000000000000000000000000000000", "project_id": 42
+	}`))
+	assert.ErrorIs(t, err, ErrIgnoredGitLabEvent, "branch deletions are ignored")
+}
+
+func TestParseGitLabMergeRequestEvent(t *testing.T) {
+	ev, err := ParseGitLabEvent(gitlab.EventTypeMergeRequest, []byte(`{
+		"object_kind": "merge_request", "project": {"id": 42},
+		"object_attributes": {"iid": 7, "action": "open", "target_branch": "main",
+			"source_branch": "feature", "last_commit": {"id": "abc123"}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.TriggerMergeRequestOpened}, ev.Triggers)
+	assert.Equal(t, "main", ev.Branch)
+	assert.Equal(t, int64(7), ev.MergeRequestIID)
+	assert.Equal(t, "abc123", ev.CommitSHA)
+
+	ev, err = ParseGitLabEvent(gitlab.EventTypeMergeRequest, []byte(`{
+		"object_kind": "merge_request", "project": {"id": 42},
+		"object_attributes": {"iid": 7, "action": "update", "target_branch": "main"},
+		"changes": {"labels": {"previous": [{"title": "backend"}], "current": [{"title": "backend"}, {"title": "qa-smoke"}]}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.TriggerMergeRequestLabel}, ev.Triggers)
+	assert.Equal(t, []string{"qa-smoke"}, ev.AddedLabels)
+
+	_, err = ParseGitLabEvent(gitlab.EventTypeMergeRequest, []byte(`{
+		"object_kind": "merge_request", "project": {"id": 42},
+		"object_attributes": {"iid": 7, "action": "update"}
+	}`))
+	assert.ErrorIs(t, err, ErrIgnoredGitLabEvent)
+}
+
+func TestParseGitLabPipelineAndIssueEvents(t *testing.T) {
+	ev, err := ParseGitLabEvent(gitlab.EventTypePipeline, []byte(`{
+		"object_kind": "pipeline", "project": {"id": 42},
+		"object_attributes": {"id": 900, "ref": "main", "sha": "def456", "status": "failed"},
+		"merge_request": {"iid": 7}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.TriggerPipelineFailed}, ev.Triggers)
+	assert.Equal(t, int64(900), ev.PipelineID)
+	assert.Equal(t, int64(7), ev.MergeRequestIID)
+
+	_, err = ParseGitLabEvent(gitlab.EventTypePipeline, []byte(`{
+		"object_kind": "pipeline", "project": {"id": 42},
+		"object_attributes": {"id": 900, "status": "running"}
+	}`))
+	assert.ErrorIs(t, err, ErrIgnoredGitLabEvent)
+
+	ev, err = ParseGitLabEvent(gitlab.EventTypeIssue, []byte(`{
+		"object_kind": "issue", "project": {"id": 42},
+		"object_attributes": {"iid": 15, "action": "update"},
+		"changes": {"labels": {"previous": [], "current": [{"title": "ai-fix"}]}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.TriggerIssueLabel}, ev.Triggers)
+	assert.Equal(t, int64(15), ev.IssueIID)
+	assert.Equal(t, []string{"ai-fix"}, ev.AddedLabels)
+}
+
+func TestMatchGitLabRules(t *testing.T) {
+	cfg := models.GitLabAutomation{
+		Enabled: true,
+		Rules: []models.GitLabAutomationRule{
+			{ID: "kg", Trigger: models.TriggerPush, Action: models.ActionRebuildKnowledgeGraph, Enabled: true, Branches: []string{"main", "release/*"}},
+			{ID: "smoke", Trigger: models.TriggerMergeRequestOpened, Action: models.ActionRunSuite, Enabled: true, Tags: []string{"smoke"}},
+			{ID: "fix", Trigger: models.TriggerIssueLabel, Action: models.ActionStartFixAgent, Enabled: true, Label: "ai-fix"},
+			{ID: "off", Trigger: models.TriggerPush, Action: models.ActionRunSuite, Enabled: false},
+		},
+	}
+	ids := func(rules []models.GitLabAutomationRule) []string {
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"kg"}, ids(MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerPush}, Branch: "release/2.0"})))
+	assert.Empty(t, MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerPush}, Branch: "feature/x"}))
+	assert.Equal(t, []string{"smoke"}, ids(MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerMergeRequestOpened}, Branch: "develop"})))
+	assert.Equal(t, []string{"fix"}, ids(MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerIssueLabel}, AddedLabels: []string{"bug", "ai-fix"}})))
+	assert.Empty(t, MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerIssueLabel}, AddedLabels: []string{"bug"}}))
+
+	cfg.Enabled = false
+	assert.Empty(t, MatchGitLabRules(cfg, &GitLabEvent{Triggers: []string{models.TriggerPush}, Branch: "main"}))
+}
+
+func TestValidateGitLabAutomationRule(t *testing.T) {
+	assert.NoError(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: models.TriggerIssueLabel, Action: models.ActionStartFixAgent, Label: "ai-fix"}))
+	assert.NoError(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: models.TriggerPush, Action: models.ActionRebuildKnowledgeGraph, Branches: []string{"release/*"}}))
+	assert.Error(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: "tag_push", Action: models.ActionRunSuite}))
+	assert.Error(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: models.TriggerIssueLabel, Action: models.ActionStartFixAgent}), "labeled triggers need a label")
+	assert.Error(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: models.TriggerPush, Action: models.ActionStartFixAgent}), "the fix agent needs an issue")
+	assert.Error(t, ValidateGitLabAutomationRule(models.GitLabAutomationRule{Trigger: models.TriggerPush, Action: models.ActionRunSuite, Branches: []string{"[main"}}))
+}
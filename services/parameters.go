@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// RunTestFunc runs a single test run, like agent.RunTest
+type RunTestFunc func(ctx context.Context, run *models.TestRun) (*models.TestResult, error)
+
+// ParameterRows turns stored data-driven parameters into one map per row.
+// Rows decoded from JSON arrive as map[string]any; entries that are not
+// objects are skipped.
+func ParameterRows(params []any) []map[string]string {
+	var rows []map[string]string
+	for _, p := range params {
+		row := map[string]string{}
+		switch v := p.(type) {
+		case map[string]string:
+			for k, val := range v {
+				row[k] = val
+			}
+		case map[string]any:
+			for k, val := range v {
+				if val == nil {
+					row[k] = ""
+					continue
+				}
+				row[k] = fmt.Sprint(val)
+			}
+		default:
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ApplyParameters returns a copy of steps with the <name> placeholders of the
+// row's columns filled in, the same syntax Gherkin Scenario Outlines use.
+// Placeholders without a matching column are left as they are.
+func ApplyParameters(steps []models.RecordingStep, row map[string]string) []models.RecordingStep {
+	pairs := make([]string, 0, len(row)*2)
+	for k, v := range row {
+		pairs = append(pairs, "<"+k+">", v)
+	}
+	r := strings.NewReplacer(pairs...)
+
+	out := make([]models.RecordingStep, len(steps))
+	copy(out, steps)
+	for i := range out {
+		s := &out[i]
+		s.Value = r.Replace(s.Value)
+		s.Selector = r.Replace(s.Selector)
+		s.ApiEndpoint = r.Replace(s.ApiEndpoint)
+		s.ApiPayload = r.Replace(s.ApiPayload)
+		s.ApiHeaders = r.Replace(s.ApiHeaders)
+		s.ExpectedValue = r.Replace(s.ExpectedValue)
+	}
+	return out
+}
+
+// RunWithParameters runs a test once, or once per parameter row when it is
+// data-driven. The run fails when any row fails; step results, video and
+// browser diagnostics come from the first failing row (or the last row when
+// all pass), and the log starts with every row's outcome.
+func RunWithParameters(ctx context.Context, run *models.TestRun, params []any, runTest RunTestFunc) (*models.TestResult, error) {
+	rows := ParameterRows(params)
+	if len(rows) == 0 {
+		return runTest(ctx, run)
+	}
+
+	var reported *models.TestResult
+	var summary []string
+	var duration int64
+	for i, row := range rows {
+		rowRun := *run
+		rowRun.Steps = ApplyParameters(run.Steps, row)
+		result, err := runTest(ctx, &rowRun)
+		if err != nil {
+			return nil, fmt.Errorf("row %d (%s): %w", i+1, describeRow(row), err)
+		}
+		duration += result.RunDurationMs
+		summary = append(summary, fmt.Sprintf("Row %d (%s): %s", i+1, describeRow(row), result.Status))
+		if reported == nil || reported.Status != "failed" {
+			reported = result
+		}
+	}
+
+	combined := *reported
+	combined.RunDurationMs = duration
+	combined.Log = strings.Join(summary, "\n")
+	if reported.Log != "" {
+		combined.Log += "\n\n" + reported.Log
+	}
+	return &combined, nil
+}
+
+// describeRow formats a parameter row as "a=1, b=2" with sorted column names
+func describeRow(row map[string]string) string {
+	keys := make([]string, 0, len(row))
+	for k := range row {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + row[k]
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithParametersRunsEachExamplesRow(t *testing.T) {
+	feature, err := ParseGherkin(strings.NewReader(sampleFeature))
+	require.NoError(t, err)
+	scenario := BuildScenarioFromGherkin("checkout.feature", []models.GherkinFeature{*feature}, "42", "", models.AuthConfig{}, 0)
+	coupon := scenario.Sections[0].TestCases[1]
+	require.Len(t, coupon.Parameters, 2)
+
+	LinkAutomation(&scenario, &models.GeneratedAutomation{
+		ID:         "gen-1",
+		TestCaseID: coupon.ID,
+		Steps: []models.RecordingStep{
+			{Action: "type", Selector: "#coupon", Value: "<code>"},
+			{Action: "assert", Selector: ".error", ExpectedValue: "<message>"},
+		},
+	})
+	automation := scenario.Sections[0].TestCases[1].AutomationTest
+	require.NotNil(t, automation)
+
+	// Parameters survive being stored as JSON
+	raw, err := json.Marshal(automation)
+	require.NoError(t, err)
+	var stored models.AutomationTest
+	require.NoError(t, json.Unmarshal(raw, &stored))
+
+	var ran [][]models.RecordingStep
+	fake := func(_ context.Context, run *models.TestRun) (*models.TestResult, error) {
+		ran = append(ran, run.Steps)
+		status := "passed"
+		if run.Steps[0].Value == "UNKNOWN" {
+			status = "failed"
+		}
+		return &models.TestResult{TestID: run.ID, Status: status, RunDurationMs: 100, Log: "ran " + run.Steps[0].Value}, nil
+	}
+
+	run := &models.TestRun{ID: stored.ID, Steps: stored.Steps}
+	result, err := RunWithParameters(context.Background(), run, stored.Parameters, fake)
+	require.NoError(t, err)
+
+	require.Len(t, ran, 2)
+	assert.Equal(t, "EXPIRED", ran[0][0].Value)
+	assert.Equal(t, "Coupon expired", ran[0][1].ExpectedValue)
+	assert.Equal(t, "UNKNOWN", ran[1][0].Value)
+	assert.Equal(t, "<code>", stored.Steps[0].Value, "the stored steps are not changed")
+
+	assert.Equal(t, "failed", result.Status)
+	assert.Equal(t, int64(200), result.RunDurationMs)
+	assert.Equal(t, "Row 1 (code=EXPIRED, message=Coupon expired): passed\nRow 2 (code=UNKNOWN, message=Invalid coupon): failed\n\nran UNKNOWN", result.Log)
+}
+
+func TestRunWithParametersWithoutRows(t *testing.T) {
+	calls := 0
+	fake := func(_ context.Context, run *models.TestRun) (*models.TestResult, error) {
+		calls++
+		return &models.TestResult{Status: "passed", Log: "once"}, nil
+	}
+	result, err := RunWithParameters(context.Background(), &models.TestRun{}, nil, fake)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "once", result.Log)
+}
+
+func TestRunWithParametersStopsOnRunError(t *testing.T) {
+	fake := func(_ context.Context, run *models.TestRun) (*models.TestResult, error) {
+		return nil, errors.New("browser crashed")
+	}
+	params := []any{map[string]any{"n": 1.0}, map[string]any{"n": 2.0}}
+	_, err := RunWithParameters(context.Background(), &models.TestRun{}, params, fake)
+	assert.EqualError(t, err, "row 1 (n=1): browser crashed")
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// PlaywrightExporter renders RecordingStep arrays as @playwright/test TypeScript.
+// When a knowledge graph catalog is available, raw CSS/XPath selectors are
+// upgraded to Playwright's preferred locators (getByTestId, getByRole, ...).
+type PlaywrightExporter struct {
+	baseURL string
+	// locators maps a raw selector (as recorded) to the catalog element it targets
+	locators map[string]ExtractedSelector
+}
+
+// NewPlaywrightExporter creates an exporter. Both arguments are optional:
+// baseURL is stripped from navigate steps so specs work with Playwright's baseURL,
+// catalog provides locator preferences from the project's knowledge graph.
+func NewPlaywrightExporter(baseURL string, catalog *ModuleCatalog) *PlaywrightExporter {
+	e := &PlaywrightExporter{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		locators: make(map[string]ExtractedSelector),
+	}
+	if catalog != nil {
+		for _, selectors := range catalog.Selectors {
+			for _, s := range selectors {
+				for _, raw := range rawSelectorForms(s) {
+					if _, exists := e.locators[raw]; !exists {
+						e.locators[raw] = s
+					}
+				}
+			}
+		}
+	}
+	return e
+}
+
+// ExportRecording renders a single recording as a spec file
+func (e *PlaywrightExporter) ExportRecording(rec *models.ManualRecording) string {
+	var b strings.Builder
+	e.writeHeader(&b)
+	e.writeTests(&b, "", rec.Name, rec.Description, rec.Steps, rec.Parameters)
+	return b.String()
+}
+
+// ExportTestCase renders the automation attached to a test case as a spec file
+func (e *PlaywrightExporter) ExportTestCase(tc *models.TestCase) string {
+	var b strings.Builder
+	e.writeHeader(&b)
+	e.writeTestCase(&b, "", tc)
+	return b.String()
+}
+
+// ExportScenario renders a whole scenario: one describe per section and one
+// test per test case, or per parameter row of a data-driven test case. Test
+// cases without automation are emitted as test.fixme.
+func (e *PlaywrightExporter) ExportScenario(scenario *models.TestScenario) string {
+	var b strings.Builder
+	e.writeHeader(&b)
+	if scenario.Description != "" {
+		fmt.Fprintf(&b, "// %s\n\n", oneLine(scenario.Description))
+	}
+
+	fmt.Fprintf(&b, "test.describe(%s, () => {\n", tsString(scenario.Title))
+	for i, sec := range scenario.Sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "  test.describe(%s, () => {\n", tsString(sec.Title))
+		for j := range sec.TestCases {
+			tc := &sec.TestCases[j]
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			if tc.AutomationTest == nil || len(tc.AutomationTest.Steps) == 0 {
+				fmt.Fprintf(&b, "    test.fixme(%s, async () => {\n", tsString(testCaseTitle(tc)))
+				for _, st := range tc.Steps {
+					fmt.Fprintf(&b, "      // %d. %s\n", st.Order, oneLine(st.Action))
+				}
+				b.WriteString("    });\n")
+				continue
+			}
+			e.writeTestCase(&b, "    ", tc)
+		}
+		b.WriteString("  });\n")
+	}
+	b.WriteString("});\n")
+	return b.String()
+}
+
+// SpecFileName builds a kebab-case *.spec.ts file name from a title
+func SpecFileName(title string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if slug == "" {
+		slug = "exported"
+	}
+	return slug + ".spec.ts"
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func testCaseTitle(tc *models.TestCase) string {
+	if tc.Code != "" {
+		return fmt.Sprintf("%s %s", tc.Code, tc.Title)
+	}
+	return tc.Title
+}
+
+func (e *PlaywrightExporter) writeHeader(b *strings.Builder) {
+	b.WriteString("// Generated by QA Extension. Edits are safe; re-exporting overwrites this file.\n")
+	b.WriteString("import { test, expect } from '@playwright/test';\n\n")
+	if e.baseURL != "" {
+		fmt.Fprintf(b, "test.use({ baseURL: %s });\n\n", tsString(e.baseURL))
+	}
+}
+
+// writeTestCase writes a test case's automation, using the test case's own
+// Examples rows when the automation has none, the same way runs do
+func (e *PlaywrightExporter) writeTestCase(b *strings.Builder, indent string, tc *models.TestCase) {
+	var steps []models.RecordingStep
+	params := tc.Parameters
+	if tc.AutomationTest != nil {
+		steps = tc.AutomationTest.Steps
+		if len(tc.AutomationTest.Parameters) > 0 {
+			params = tc.AutomationTest.Parameters
+		}
+	}
+	e.writeTests(b, indent, testCaseTitle(tc), tc.PreCondition, steps, params)
+}
+
+// writeTests writes one test, or one test per parameter row with the row's
+// values filled into the steps and named in the title
+func (e *PlaywrightExporter) writeTests(b *strings.Builder, indent, title, description string, steps []models.RecordingStep, params []any) {
+	rows := ParameterRows(params)
+	if len(rows) == 0 {
+		e.writeTest(b, indent, title, description, steps)
+		return
+	}
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		e.writeTest(b, indent, fmt.Sprintf("%s [%s]", title, describeRow(row)), description, ApplyParameters(steps, row))
+	}
+}
+
+func (e *PlaywrightExporter) writeTest(b *strings.Builder, indent, title, description string, steps []models.RecordingStep) {
+	fixtures := "page"
+	for _, s := range steps {
+		if s.Action == "api_request" {
+			fixtures = "page, request"
+			break
+		}
+	}
+
+	fmt.Fprintf(b, "%stest(%s, async ({ %s }) => {\n", indent, tsString(title), fixtures)
+	if description != "" {
+		for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+			fmt.Fprintf(b, "%s  // %s\n", indent, strings.TrimSpace(line))
+		}
+	}
+	for _, s := range steps {
+		if s.Description != "" {
+			fmt.Fprintf(b, "%s  // %s\n", indent, oneLine(s.Description))
+		}
+		for _, line := range e.stepLines(s) {
+			fmt.Fprintf(b, "%s  %s\n", indent, line)
+		}
+	}
+	fmt.Fprintf(b, "%s});\n", indent)
+}
+
+// stepLines translates one RecordingStep into TypeScript statements,
+// mirroring the action semantics of agent.executeStep
+func (e *PlaywrightExporter) stepLines(s models.RecordingStep) []string {
+	switch s.Action {
+	case "navigate":
+		url := s.Value
+		if url == "" {
+			url = s.Selector
+		}
+		return []string{fmt.Sprintf("await page.goto(%s);", tsString(e.relativeURL(url)))}
+	case "type":
+		return []string{fmt.Sprintf("await %s.fill(%s);", e.locator(s), tsValue(s.Value))}
+	case "click":
+		return []string{fmt.Sprintf("await %s.click();", e.locator(s))}
+	case "press":
+		return []string{fmt.Sprintf("await %s.press(%s);", e.locator(s), tsString(s.Value))}
+	case "wait":
+		return []string{fmt.Sprintf("await %s.waitFor();", e.locator(s))}
+	case "assert":
+		loc := e.locator(s)
+		switch s.AssertionType {
+		case "not_exists":
+			return []string{fmt.Sprintf("await expect(%s).toHaveCount(0);", loc)}
+		case "hidden":
+			return []string{fmt.Sprintf("await expect(%s).toBeHidden();", loc)}
+		case "text", "contains":
+			return []string{fmt.Sprintf("await expect(%s).toContainText(%s);", loc, tsString(s.ExpectedValue))}
+		case "value":
+			return []string{fmt.Sprintf("await expect(%s).toHaveValue(%s);", loc, tsString(s.ExpectedValue))}
+		case "url":
+			return []string{fmt.Sprintf("await expect(page).toHaveURL(%s);", tsString(s.ExpectedValue))}
+		default:
+			return []string{fmt.Sprintf("await expect(%s).toBeVisible();", loc)}
+		}
+	case "api_request":
+		method := strings.ToUpper(s.ApiMethod)
+		if method == "" {
+			method = "GET"
+		}
+		var lines []string
+		opts := []string{fmt.Sprintf("method: %s", tsString(method))}
+		if s.ApiHeaders != "" {
+			if headers, ok := jsonHeaders(s.ApiHeaders); ok {
+				opts = append(opts, "headers: "+headers)
+			} else {
+				lines = append(lines, "// TODO: the recorded headers are not a JSON object of strings and were left out")
+			}
+		}
+		if s.ApiPayload != "" {
+			if payload, ok := jsonPayload(s.ApiPayload); ok {
+				opts = append(opts, "data: "+payload)
+			} else {
+				lines = append(lines, "// TODO: the recorded payload is not valid JSON and was left out")
+			}
+		}
+		return append(lines,
+			fmt.Sprintf("expect((await request.fetch(%s, { %s })).ok()).toBeTruthy();", tsString(s.ApiEndpoint), strings.Join(opts, ", ")),
+		)
+	default:
+		return []string{fmt.Sprintf("// TODO: unsupported action %q", s.Action)}
+	}
+}
+
+// locator picks the most idiomatic Playwright locator for a step.
+// Priority: knowledge graph match > recorded element hints > raw selector.
+func (e *PlaywrightExporter) locator(s models.RecordingStep) string {
+	candidates := append([]string{s.Selector}, s.SelectorCandidates...)
+	for _, raw := range candidates {
+		if sel, ok := e.locators[normalizeRawSelector(raw)]; ok {
+			if loc := preferredLocator(sel); loc != "" {
+				return loc
+			}
+		}
+	}
+
+	if attrs := s.ElementHints.Attributes; attrs != nil {
+		hint := ExtractedSelector{
+			ElementType: s.ElementHints.TagName,
+			Testid:      attrs["data-testid"],
+			AriaLabel:   attrs["aria-label"],
+			Placeholder: attrs["placeholder"],
+			Role:        attrs["role"],
+		}
+		if loc := preferredLocator(hint); loc != "" {
+			return loc
+		}
+	}
+
+	for _, raw := range candidates {
+		if raw != "" {
+			return fmt.Sprintf("page.locator(%s)", tsString(raw))
+		}
+	}
+	if s.XPath != "" {
+		return fmt.Sprintf("page.locator(%s)", tsString("xpath="+s.XPath))
+	}
+	return "page.locator('body')"
+}
+
+// preferredLocator follows Playwright's recommended priority:
+// test id > role with name > label > placeholder > text
+func preferredLocator(s ExtractedSelector) string {
+	if s.Testid != "" {
+		return fmt.Sprintf("page.getByTestId(%s)", tsString(s.Testid))
+	}
+	role := s.Role
+	if role == "" {
+		role = implicitRole(s.ElementType)
+	}
+	name := s.AriaLabel
+	if name == "" {
+		name = s.Text
+	}
+	if role != "" && name != "" {
+		return fmt.Sprintf("page.getByRole(%s, { name: %s })", tsString(role), tsString(name))
+	}
+	if s.AriaLabel != "" {
+		return fmt.Sprintf("page.getByLabel(%s)", tsString(s.AriaLabel))
+	}
+	if s.Placeholder != "" {
+		return fmt.Sprintf("page.getByPlaceholder(%s)", tsString(s.Placeholder))
+	}
+	if s.Text != "" {
+		return fmt.Sprintf("page.getByText(%s)", tsString(s.Text))
+	}
+	return ""
+}
+
+func implicitRole(elementType string) string {
+	switch strings.ToLower(elementType) {
+	case "button":
+		return "button"
+	case "a", "link":
+		return "link"
+	case "select":
+		return "combobox"
+	case "textarea":
+		return "textbox"
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return "heading"
+	}
+	return ""
+}
+
+// rawSelectorForms lists the selector strings a recorder or the generator
+// might have produced for a catalog element
+func rawSelectorForms(s ExtractedSelector) []string {
+	var forms []string
+	if s.Testid != "" {
+		forms = append(forms, fmt.Sprintf("[data-testid='%s']", s.Testid))
+	}
+	if s.ID != "" {
+		forms = append(forms, "#"+s.ID)
+	}
+	if s.AriaLabel != "" {
+		forms = append(forms, fmt.Sprintf("[aria-label='%s']", s.AriaLabel))
+	}
+	if s.Placeholder != "" {
+		forms = append(forms, fmt.Sprintf("[placeholder='%s']", s.Placeholder))
+	}
+	if s.Name != "" {
+		forms = append(forms, fmt.Sprintf("[name='%s']", s.Name))
+	}
+	forms = append(forms, s.GeneratePlaywrightSelector())
+	for i := range forms {
+		forms[i] = normalizeRawSelector(forms[i])
+	}
+	return forms
+}
+
+// normalizeRawSelector makes quoting differences irrelevant when matching selectors
+func normalizeRawSelector(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), `"`, `'`)
+}
+
+func (e *PlaywrightExporter) relativeURL(url string) string {
+	if e.baseURL != "" && strings.HasPrefix(url, e.baseURL) {
+		rel := strings.TrimPrefix(url, e.baseURL)
+		if rel == "" {
+			return "/"
+		}
+		return rel
+	}
+	return url
+}
+
+// tsValue is tsString for typed values; stored credentials are read from the
+// environment of the test run instead of being written into the spec
+func tsValue(s string) string {
+	if m := models.CredentialPlaceholderPattern.FindStringSubmatch(s); m != nil && m[0] == s {
+		return fmt.Sprintf("process.env.QA_%s ?? ''", strings.ToUpper(m[2]))
+	}
+	return tsString(s)
+}
+
+// tsString quotes a Go string as a single-quoted TypeScript literal
+func tsString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// jsonHeaders re-encodes recorded headers as an object literal. Only a JSON
+// object of strings is accepted, so nothing recorded is written as code.
+func jsonHeaders(raw string) (string, bool) {
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+		return "", false
+	}
+	out, err := json.Marshal(headers)
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// jsonPayload re-encodes a recorded JSON payload as a literal
+func jsonPayload(raw string) (string, bool) {
+	if !json.Valid([]byte(raw)) {
+		return "", false
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var payload any
+	if err := dec.Decode(&payload); err != nil {
+		return "", false
+	}
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
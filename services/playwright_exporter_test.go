@@ -0,0 +1,129 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaywrightExporterStepLines(t *testing.T) {
+	e := NewPlaywrightExporter("https://app.example.com/", nil)
+
+	tests := []struct {
+		name string
+		step models.RecordingStep
+		want []string
+	}{
+		{
+			name: "navigate under the base URL",
+			step: models.RecordingStep{Action: "navigate", Value: "https://app.example.com/login"},
+			want: []string{"await page.goto('/login');"},
+		},
+		{
+			name: "typed credential",
+			step: models.RecordingStep{Action: "type", Selector: "#password", Value: "{{credential:c-1:password}}"},
+			want: []string{"await page.locator('#password').fill(process.env.QA_PASSWORD ?? '');"},
+		},
+		{
+			name: "quotes in values",
+			step: models.RecordingStep{Action: "assert", AssertionType: "text", Selector: ".msg", ExpectedValue: "it's done"},
+			want: []string{`await expect(page.locator('.msg')).toContainText('it\'s done');`},
+		},
+		{
+			name: "element hints",
+			step: models.RecordingStep{Action: "click", Selector: "div > button:nth-child(2)", ElementHints: models.ElementHints{TagName: "button", Attributes: map[string]string{"data-testid": "save"}}},
+			want: []string{"await page.getByTestId('save').click();"},
+		},
+		{
+			name: "api request with JSON headers and payload",
+			step: models.RecordingStep{
+				Action:      "api_request",
+				ApiMethod:   "post",
+				ApiEndpoint: "https://api.example.com/orders",
+				ApiHeaders:  `{"X-Trace": "1", "Content-Type": "application/json"}`,
+				ApiPayload:  "{\n  \"qty\": 2.50,\n  \"note\": \"</script>\"\n}",
+			},
+			want: []string{`expect((await request.fetch('https://api.example.com/orders', { method: 'POST', headers: {"Content-Type":"application/json","X-Trace":"1"}, data: {"note":"\u003c/script\u003e","qty":2.50} })).ok()).toBeTruthy();`},
+		},
+		{
+			name: "api request with code in place of JSON",
+			step: models.RecordingStep{
+				Action:      "api_request",
+				ApiEndpoint: "/health",
+				ApiHeaders:  "Authorization: Bearer abc",
+				ApiPayload:  "{}); require('child_process').exec('id'); ({",
+			},
+			want: []string{
+				"// TODO: the recorded headers are not a JSON object of strings and were left out",
+				"// TODO: the recorded payload is not valid JSON and was left out",
+				"expect((await request.fetch('/health', { method: 'GET' })).ok()).toBeTruthy();",
+			},
+		},
+		{
+			name: "unsupported action",
+			step: models.RecordingStep{Action: "hover"},
+			want: []string{`// TODO: unsupported action "hover"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, e.stepLines(tt.step))
+		})
+	}
+}
+
+func TestPlaywrightExporterLocatorFromCatalog(t *testing.T) {
+	catalog := &ModuleCatalog{Selectors: map[string][]ExtractedSelector{
+		"src/Login.tsx": {{ElementType: "button", ID: "submit", Text: "Sign in"}},
+	}}
+	e := NewPlaywrightExporter("", catalog)
+
+	lines := e.stepLines(models.RecordingStep{Action: "click", Selector: "#submit"})
+	assert.Equal(t, []string{"await page.getByRole('button', { name: 'Sign in' }).click();"}, lines)
+}
+
+func TestPlaywrightExporterExportScenario(t *testing.T) {
+	scenario := &models.TestScenario{
+		Title: "Checkout",
+		Sections: []models.TestSection{{
+			Title: "Payment",
+			TestCases: []models.TestCase{
+				{
+					Code:  "TC-001",
+					Title: "Pay by card",
+					AutomationTest: &models.AutomationTest{Steps: []models.RecordingStep{
+						{Action: "api_request", ApiEndpoint: "/api/cart"},
+						{Action: "click", Selector: "#pay"},
+					}},
+				},
+				{Code: "TC-002", Title: "Pay by transfer", Steps: []models.TestStepV2{{Order: 1, Action: "Choose bank transfer"}}},
+			},
+		}},
+	}
+
+	spec := NewPlaywrightExporter("", nil).ExportScenario(scenario)
+
+	assert.Contains(t, spec, "test.describe('Checkout', () => {")
+	assert.Contains(t, spec, "    test('TC-001 Pay by card', async ({ page, request }) => {")
+	assert.Contains(t, spec, "    test.fixme('TC-002 Pay by transfer', async () => {\n      // 1. Choose bank transfer\n")
+	assert.Equal(t, "checkout-flow.spec.ts", SpecFileName("Checkout  flow!"))
+}
+
+func TestPlaywrightExporterParameterRows(t *testing.T) {
+	tc := &models.TestCase{
+		Code:       "TC-003",
+		Title:      "Invalid coupon",
+		Parameters: []any{map[string]any{"code": "EXPIRED"}, map[string]any{"code": "UNKNOWN"}},
+		AutomationTest: &models.AutomationTest{Steps: []models.RecordingStep{
+			{Action: "type", Selector: "#coupon", Value: "<code>"},
+		}},
+	}
+
+	spec := NewPlaywrightExporter("", nil).ExportTestCase(tc)
+
+	assert.Contains(t, spec, "test('TC-003 Invalid coupon [code=EXPIRED]', async ({ page }) => {\n  await page.locator('#coupon').fill('EXPIRED');\n});\n")
+	assert.Contains(t, spec, "test('TC-003 Invalid coupon [code=UNKNOWN]', async ({ page }) => {\n  await page.locator('#coupon').fill('UNKNOWN');\n});\n")
+	assert.NotContains(t, spec, "<code>")
+}
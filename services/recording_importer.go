@@ -0,0 +1,577 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// ImportWarning describes a construct that could not be converted faithfully
+type ImportWarning struct {
+	Line   int    `json:"line,omitempty"`
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// RecordingImport is the result of converting an external artefact into recording data
+type RecordingImport struct {
+	Steps           []models.RecordingStep       `json:"steps"`
+	NetworkRequests []models.NetworkRequestEntry `json:"networkRequests,omitempty"`
+	StartURL        string                       `json:"startUrl,omitempty"`
+	Warnings        []ImportWarning              `json:"warnings"`
+}
+
+// ─────────────────────────────────────────────
+// Playwright codegen (JS/TS)
+// ─────────────────────────────────────────────
+
+var (
+	// Boilerplate emitted by codegen that carries no test behaviour
+	codegenIgnoredLine = regexp.MustCompile(`^(import\s|const\s*\{.*\}\s*=\s*require|test\(|test\.describe\(|\}\);?$|\}\)\);?$|\(async\s*\(\)|const\s+(browser|context|page)\s*=|await\s+(browser|context|page)\.close\(\)|\}\)\(\);?$|//|/\*|\*)`)
+	codegenStatement   = regexp.MustCompile(`^await\s+(.*?);?$`)
+	codegenExpect      = regexp.MustCompile(`^expect\((page(?:\..+?)?)\)\.(not\.)?(\w+)\((.*)\)$`)
+	jsStringLiteral    = regexp.MustCompile(`^\s*(?:'((?:[^'\\]|\\.)*)'|"((?:[^"\\]|\\.)*)"|` + "`" + `((?:[^` + "`" + `\\]|\\.)*)` + "`" + `)`)
+	jsRegexLiteral     = regexp.MustCompile(`^\s*(/(?:[^/\\]|\\.)+/[a-z]*)`)
+)
+
+// codegenCall is one link in a locator chain, e.g. getByRole('button', { name: 'Save' })
+type codegenCall struct {
+	name string
+	args string
+}
+
+// ImportPlaywrightCodegen converts the output of `npx playwright codegen` (JS or TS)
+// into RecordingSteps. Lines that cannot be mapped to a runner action are
+// returned as warnings instead of being dropped silently.
+func ImportPlaywrightCodegen(source string) *RecordingImport {
+	result := &RecordingImport{Steps: []models.RecordingStep{}, Warnings: []ImportWarning{}}
+
+	for i, raw := range strings.Split(source, "\n") {
+		lineNum := i + 1
+		line := strings.TrimSpace(raw)
+		if line == "" || codegenIgnoredLine.MatchString(line) {
+			continue
+		}
+
+		m := codegenStatement.FindStringSubmatch(line)
+		if m == nil {
+			result.warn(lineNum, line, "not an awaited page action")
+			continue
+		}
+		stmt := strings.TrimSuffix(strings.TrimSpace(m[1]), ";")
+
+		var step *models.RecordingStep
+		var err error
+		if strings.HasPrefix(stmt, "expect(") {
+			step, err = codegenAssertion(stmt)
+		} else if strings.HasPrefix(stmt, "page.") {
+			step, err = codegenAction(stmt, result)
+		} else {
+			err = fmt.Errorf("only statements on the page object are supported")
+		}
+		if err != nil {
+			result.warn(lineNum, line, err.Error())
+			continue
+		}
+		if step != nil {
+			result.Steps = append(result.Steps, *step)
+		}
+	}
+
+	return result
+}
+
+func (r *RecordingImport) warn(line int, source, reason string) {
+	r.Warnings = append(r.Warnings, ImportWarning{Line: line, Source: source, Reason: reason})
+}
+
+func codegenAction(stmt string, result *RecordingImport) (*models.RecordingStep, error) {
+	calls, err := splitCallChain(strings.TrimPrefix(stmt, "page"))
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("empty statement")
+	}
+
+	action := calls[len(calls)-1]
+	locatorCalls := calls[:len(calls)-1]
+
+	if action.name == "goto" && len(locatorCalls) == 0 {
+		target, _, err := firstStringArg(action.args)
+		if err != nil {
+			return nil, err
+		}
+		if result.StartURL == "" {
+			result.StartURL = target
+		}
+		return &models.RecordingStep{
+			Action:      "navigate",
+			Description: fmt.Sprintf("Navigate to %s", target),
+			Value:       target,
+		}, nil
+	}
+
+	if len(locatorCalls) == 0 {
+		return nil, fmt.Errorf("page.%s() has no equivalent runner action", action.name)
+	}
+
+	selector, label, err := codegenSelector(locatorCalls)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &models.RecordingStep{Selector: selector}
+	switch action.name {
+	case "click", "tap":
+		step.Action = "click"
+		step.Description = fmt.Sprintf("Click %s", label)
+	case "fill", "type", "pressSequentially":
+		value, _, err := firstStringArg(action.args)
+		if err != nil {
+			return nil, err
+		}
+		step.Action = "type"
+		step.Value = value
+		step.Description = fmt.Sprintf("Type into %s", label)
+	case "press":
+		value, _, err := firstStringArg(action.args)
+		if err != nil {
+			return nil, err
+		}
+		step.Action = "press"
+		step.Value = value
+		step.Description = fmt.Sprintf("Press %s on %s", value, label)
+	case "waitFor":
+		step.Action = "wait"
+		step.Description = fmt.Sprintf("Wait for %s", label)
+	default:
+		return nil, fmt.Errorf("locator action %q is not supported by the runner", action.name)
+	}
+	return step, nil
+}
+
+func codegenAssertion(stmt string) (*models.RecordingStep, error) {
+	m := codegenExpect.FindStringSubmatch(stmt)
+	if m == nil {
+		return nil, fmt.Errorf("unrecognised expect() statement")
+	}
+	target, negated, matcher, args := m[1], m[2] != "", m[3], m[4]
+	if target == "page" {
+		return nil, fmt.Errorf("page-level assertion %s is not supported", matcher)
+	}
+
+	calls, err := splitCallChain(strings.TrimPrefix(target, "page"))
+	if err != nil {
+		return nil, err
+	}
+	selector, label, err := codegenSelector(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	step := &models.RecordingStep{Action: "assert", Selector: selector}
+	switch {
+	case matcher == "toBeVisible" && !negated, matcher == "toBeAttached" && !negated:
+		step.AssertionType = "visible"
+		step.Description = fmt.Sprintf("Verify %s is visible", label)
+	case matcher == "toBeHidden" && !negated, matcher == "toBeVisible" && negated, matcher == "toBeAttached" && negated:
+		step.AssertionType = "not_exists"
+		step.Description = fmt.Sprintf("Verify %s is not visible", label)
+	case matcher == "toHaveCount" && strings.TrimSpace(args) == "0" && !negated:
+		step.AssertionType = "not_exists"
+		step.Description = fmt.Sprintf("Verify %s does not exist", label)
+	case (matcher == "toHaveText" || matcher == "toContainText") && !negated:
+		expected, _, err := firstStringArg(args)
+		if err != nil {
+			return nil, err
+		}
+		step.AssertionType = "text"
+		step.ExpectedValue = expected
+		step.Description = fmt.Sprintf("Verify %s contains %q", label, expected)
+	case matcher == "toHaveValue" && !negated:
+		expected, _, err := firstStringArg(args)
+		if err != nil {
+			return nil, err
+		}
+		step.AssertionType = "value"
+		step.ExpectedValue = expected
+		step.Description = fmt.Sprintf("Verify %s has value %q", label, expected)
+	default:
+		prefix := ""
+		if negated {
+			prefix = "not."
+		}
+		return nil, fmt.Errorf("assertion %s%s is not supported", prefix, matcher)
+	}
+	return step, nil
+}
+
+// codegenSelector turns a locator chain into a Playwright selector string that
+// page.Locator understands, plus a short human label for the step description
+func codegenSelector(calls []codegenCall) (string, string, error) {
+	var parts []string
+	label := ""
+	for _, call := range calls {
+		arg, isRegex, err := firstStringArg(call.args)
+		opts := call.args
+		quoted := func(v string) string {
+			if isRegex {
+				return v
+			}
+			return strconv.Quote(v)
+		}
+
+		switch call.name {
+		case "getByRole":
+			if err != nil {
+				return "", "", err
+			}
+			sel := "role=" + arg
+			if name, nameRegex, ok := optionString(opts, "name"); ok {
+				if nameRegex {
+					sel += fmt.Sprintf("[name=%s]", name)
+				} else {
+					sel += fmt.Sprintf("[name=%s]", strconv.Quote(name))
+				}
+				label = fmt.Sprintf("%s %q", arg, name)
+			} else {
+				label = arg
+			}
+			parts = append(parts, sel)
+		case "getByText":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, "text="+quoted(arg))
+			label = fmt.Sprintf("text %q", arg)
+		case "getByLabel":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, "internal:label="+quoted(arg))
+			label = fmt.Sprintf("field %q", arg)
+		case "getByPlaceholder":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, fmt.Sprintf("[placeholder=%s]", strconv.Quote(arg)))
+			label = fmt.Sprintf("field %q", arg)
+		case "getByTestId":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, fmt.Sprintf("[data-testid=%s]", strconv.Quote(arg)))
+			label = fmt.Sprintf("%q", arg)
+		case "getByAltText":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, fmt.Sprintf("[alt=%s]", strconv.Quote(arg)))
+			label = fmt.Sprintf("image %q", arg)
+		case "getByTitle":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, fmt.Sprintf("[title=%s]", strconv.Quote(arg)))
+			label = fmt.Sprintf("%q", arg)
+		case "locator":
+			if err != nil {
+				return "", "", err
+			}
+			parts = append(parts, arg)
+			label = arg
+		case "first":
+			parts = append(parts, "nth=0")
+		case "last":
+			parts = append(parts, "nth=-1")
+		case "nth":
+			n, convErr := strconv.Atoi(strings.TrimSpace(call.args))
+			if convErr != nil {
+				return "", "", fmt.Errorf("nth() needs a numeric index")
+			}
+			parts = append(parts, fmt.Sprintf("nth=%d", n))
+		case "filter":
+			text, textRegex, ok := optionString(opts, "hasText")
+			if !ok {
+				return "", "", fmt.Errorf("filter() is only supported with hasText")
+			}
+			if !textRegex {
+				text = strconv.Quote(text)
+			}
+			parts = append(parts, "internal:has-text="+text)
+		default:
+			return "", "", fmt.Errorf("locator method %s() is not supported", call.name)
+		}
+	}
+	if len(parts) == 0 {
+		return "", "", fmt.Errorf("no locator found")
+	}
+	return strings.Join(parts, " >> "), label, nil
+}
+
+// splitCallChain splits ".a(x).b(y, { z: 1 })" into calls, respecting quotes and nesting
+func splitCallChain(chain string) ([]codegenCall, error) {
+	var calls []codegenCall
+	i := 0
+	for i < len(chain) {
+		if chain[i] != '.' {
+			return nil, fmt.Errorf("unexpected %q in call chain", chain[i:])
+		}
+		i++
+		start := i
+		for i < len(chain) && (isIdentChar(chain[i])) {
+			i++
+		}
+		name := chain[start:i]
+		if name == "" || i >= len(chain) || chain[i] != '(' {
+			return nil, fmt.Errorf("property access %q is not supported", name)
+		}
+		end, err := matchingParen(chain, i)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, codegenCall{name: name, args: chain[i+1 : end]})
+		i = end + 1
+	}
+	return calls, nil
+}
+
+func isIdentChar(b byte) bool {
+	return b == '_' || b == '$' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open
+func matchingParen(s string, open int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unbalanced parentheses")
+}
+
+// firstStringArg returns the leading string (or regex) literal of an argument list
+func firstStringArg(args string) (string, bool, error) {
+	if m := jsStringLiteral.FindStringSubmatch(args); m != nil {
+		return unescapeJS(m[1] + m[2] + m[3]), false, nil
+	}
+	if m := jsRegexLiteral.FindStringSubmatch(args); m != nil {
+		return m[1], true, nil
+	}
+	return "", false, fmt.Errorf("expected a string argument")
+}
+
+// optionString reads a string or regex option such as { name: 'Save' }
+func optionString(args, key string) (string, bool, bool) {
+	idx := regexp.MustCompile(`[{,]\s*` + regexp.QuoteMeta(key) + `\s*:`).FindStringIndex(args)
+	if idx == nil {
+		return "", false, false
+	}
+	value, isRegex, err := firstStringArg(args[idx[1]:])
+	if err != nil {
+		return "", false, false
+	}
+	return value, isRegex, true
+}
+
+func unescapeJS(s string) string {
+	r := strings.NewReplacer(`\'`, `'`, `\"`, `"`, "\\`", "`", `\n`, "\n", `\t`, "\t", `\\`, `\`)
+	return r.Replace(s)
+}
+
+// ─────────────────────────────────────────────
+// HAR (HTTP Archive 1.2)
+// ─────────────────────────────────────────────
+
+type harFile struct {
+	Log struct {
+		Pages []struct {
+			StartedDateTime string `json:"startedDateTime"`
+			Title           string `json:"title"`
+		} `json:"pages"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime string  `json:"startedDateTime"`
+	Time            float64 `json:"time"`
+	ResourceType    string  `json:"_resourceType"`
+	Request         struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []harNameValue `json:"headers"`
+		PostData *struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status     int            `json:"status"`
+		StatusText string         `json:"statusText"`
+		Headers    []harNameValue `json:"headers"`
+		Content    struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ImportHAR converts a HAR file into network telemetry. When asSteps is true,
+// XHR/fetch entries also become api_request steps; other resources (documents,
+// scripts, images, ...) are kept as telemetry only.
+func ImportHAR(data []byte, asSteps bool) (*RecordingImport, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR file contains no entries")
+	}
+
+	result := &RecordingImport{Steps: []models.RecordingStep{}, Warnings: []ImportWarning{}}
+	if len(har.Log.Pages) > 0 && strings.HasPrefix(har.Log.Pages[0].Title, "http") {
+		result.StartURL = har.Log.Pages[0].Title
+	}
+
+	skipped := 0
+	for i, e := range har.Log.Entries {
+		entry := models.NetworkRequestEntry{
+			RequestID:       fmt.Sprintf("har-%d", i),
+			URL:             e.Request.URL,
+			Method:          e.Request.Method,
+			Status:          e.Response.Status,
+			StatusText:      e.Response.StatusText,
+			RequestHeaders:  harHeaders(e.Request.Headers),
+			ResponseHeaders: harHeaders(e.Response.Headers),
+			ResponsePayload: e.Response.Content.Text,
+			DurationMs:      int64(e.Time),
+		}
+		if e.Request.PostData != nil {
+			entry.RequestPayload = e.Request.PostData.Text
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, e.StartedDateTime); err == nil {
+			entry.Timestamp = ts.UnixMilli()
+		}
+		if e.Response.Status == 0 {
+			entry.Error = "request did not complete"
+		}
+		result.NetworkRequests = append(result.NetworkRequests, entry)
+
+		if result.StartURL == "" && harIsDocument(e) {
+			result.StartURL = e.Request.URL
+		}
+
+		if !asSteps {
+			continue
+		}
+		if !harIsAPICall(e) {
+			skipped++
+			continue
+		}
+		headers := ""
+		if h := harAPIHeaders(e.Request.Headers); len(h) > 0 {
+			if b, err := json.Marshal(h); err == nil {
+				headers = string(b)
+			}
+		}
+		result.Steps = append(result.Steps, models.RecordingStep{
+			Action:      "api_request",
+			Description: fmt.Sprintf("%s %s", e.Request.Method, harPath(e.Request.URL)),
+			ApiMethod:   e.Request.Method,
+			ApiEndpoint: e.Request.URL,
+			ApiPayload:  entry.RequestPayload,
+			ApiHeaders:  headers,
+		})
+	}
+
+	if asSteps && skipped > 0 {
+		result.Warnings = append(result.Warnings, ImportWarning{
+			Source: fmt.Sprintf("%d entries", skipped),
+			Reason: "non-API resources (documents, scripts, styles, images) were kept as telemetry only",
+		})
+	}
+
+	return result, nil
+}
+
+func harHeaders(list []harNameValue) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	headers := make(map[string]string, len(list))
+	for _, h := range list {
+		headers[h.Name] = h.Value
+	}
+	return headers
+}
+
+// harAPIHeaders keeps only headers that matter for replaying an API call
+func harAPIHeaders(list []harNameValue) map[string]string {
+	headers := make(map[string]string)
+	for _, h := range list {
+		name := strings.ToLower(h.Name)
+		if strings.HasPrefix(name, ":") || name == "cookie" || name == "authorization" ||
+			name == "content-length" || name == "host" || strings.HasPrefix(name, "sec-") {
+			continue
+		}
+		if name == "content-type" || name == "accept" || strings.HasPrefix(name, "x-") {
+			headers[h.Name] = h.Value
+		}
+	}
+	return headers
+}
+
+func harIsDocument(e harEntry) bool {
+	if e.ResourceType != "" {
+		return e.ResourceType == "document"
+	}
+	return strings.Contains(e.Response.Content.MimeType, "text/html")
+}
+
+func harIsAPICall(e harEntry) bool {
+	if e.ResourceType != "" {
+		return e.ResourceType == "xhr" || e.ResourceType == "fetch"
+	}
+	mime := e.Response.Content.MimeType
+	return strings.Contains(mime, "json") || strings.Contains(mime, "xml") && !strings.Contains(mime, "html")
+}
+
+func harPath(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Path == "" {
+		return raw
+	}
+	return u.Path
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportPlaywrightCodegen(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		want     *models.RecordingStep
+		warnings []string
+	}{
+		{
+			name: "goto",
+			line: "await page.goto('https://app.example.com/login');",
+			want: &models.RecordingStep{Action: "navigate", Description: "Navigate to https://app.example.com/login", Value: "https://app.example.com/login"},
+		},
+		{
+			name: "click by role and name",
+			line: "await page.getByRole('button', { name: 'Sign in' }).click();",
+			want: &models.RecordingStep{Action: "click", Selector: `role=button[name="Sign in"]`, Description: `Click button "Sign in"`},
+		},
+		{
+			name: "fill by label",
+			line: "await page.getByLabel('Email').fill('ann@example.com');",
+			want: &models.RecordingStep{Action: "type", Selector: `internal:label="Email"`, Value: "ann@example.com", Description: `Type into field "Email"`},
+		},
+		{
+			name: "press on a css locator",
+			line: `await page.locator("#search").press("Enter");`,
+			want: &models.RecordingStep{Action: "press", Selector: "#search", Value: "Enter", Description: "Press Enter on #search"},
+		},
+		{
+			name: "chained locator with nth and filter",
+			line: "await page.getByTestId('row').filter({ hasText: 'Paid' }).nth(2).click();",
+			want: &models.RecordingStep{Action: "click", Selector: `[data-testid="row"] >> internal:has-text="Paid" >> nth=2`, Description: `Click "row"`},
+		},
+		{
+			name: "visible assertion",
+			line: "await expect(page.getByText('Welcome')).toBeVisible();",
+			want: &models.RecordingStep{Action: "assert", AssertionType: "visible", Selector: `text="Welcome"`, Description: `Verify text "Welcome" is visible`},
+		},
+		{
+			name: "negated visibility",
+			line: "await expect(page.locator('.spinner')).not.toBeVisible();",
+			want: &models.RecordingStep{Action: "assert", AssertionType: "not_exists", Selector: ".spinner", Description: "Verify .spinner is not visible"},
+		},
+		{
+			name: "value assertion",
+			line: "await expect(page.getByPlaceholder('Qty')).toHaveValue('2');",
+			want: &models.RecordingStep{Action: "assert", AssertionType: "value", Selector: `[placeholder="Qty"]`, ExpectedValue: "2", Description: `Verify field "Qty" has value "2"`},
+		},
+		{
+			name:     "check is not a click",
+			line:     "await page.getByLabel('Remember me').check();",
+			warnings: []string{`locator action "check" is not supported by the runner`},
+		},
+		{
+			name:     "uncheck is not a click",
+			line:     "await page.getByLabel('Newsletter').uncheck();",
+			warnings: []string{`locator action "uncheck" is not supported by the runner`},
+		},
+		{
+			name:     "page-level assertion",
+			line:     "await expect(page).toHaveURL('/dashboard');",
+			warnings: []string{"page-level assertion toHaveURL is not supported"},
+		},
+		{
+			name:     "not awaited",
+			line:     "page.on('dialog', d => d.accept());",
+			warnings: []string{"not an awaited page action"},
+		},
+		{
+			name: "boilerplate",
+			line: "import { test, expect } from '@playwright/test';",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ImportPlaywrightCodegen(tt.line)
+			if tt.want == nil {
+				assert.Empty(t, result.Steps)
+			} else {
+				require.Len(t, result.Steps, 1)
+				assert.Equal(t, *tt.want, result.Steps[0])
+			}
+			var reasons []string
+			for _, w := range result.Warnings {
+				reasons = append(reasons, w.Reason)
+				assert.Equal(t, 1, w.Line)
+			}
+			assert.Equal(t, tt.warnings, reasons)
+		})
+	}
+}
+
+func TestImportPlaywrightCodegenScript(t *testing.T) {
+	source := `import { test, expect } from '@playwright/test';
+
+test('test', async ({ page }) => {
+  await page.goto('https://app.example.com/');
+  await page.getByRole('link', { name: 'Settings' }).click();
+  await page.getByLabel('Dark mode').check();
+});
+`
+	result := ImportPlaywrightCodegen(source)
+
+	assert.Equal(t, "https://app.example.com/", result.StartURL)
+	require.Len(t, result.Steps, 2)
+	assert.Equal(t, "navigate", result.Steps[0].Action)
+	assert.Equal(t, "click", result.Steps[1].Action)
+	require.Len(t, result.Warnings, 1)
+	assert.Equal(t, 6, result.Warnings[0].Line)
+	assert.Equal(t, "await page.getByLabel('Dark mode').check();", result.Warnings[0].Source)
+}
+
+const sampleHAR = `{
+  "log": {
+    "pages": [{"startedDateTime": "2026-01-05T10:00:00.000Z", "title": "https://app.example.com/orders"}],
+    "entries": [
+      {
+        "startedDateTime": "2026-01-05T10:00:00.000Z",
+        "time": 120.4,
+        "_resourceType": "document",
+        "request": {"method": "GET", "url": "https://app.example.com/orders", "headers": []},
+        "response": {"status": 200, "statusText": "OK", "headers": [], "content": {"mimeType": "text/html", "text": "<html></html>"}}
+      },
+      {
+        "startedDateTime": "2026-01-05T10:00:01.000Z",
+        "time": 45,
+        "_resourceType": "fetch",
+        "request": {
+          "method": "POST",
+          "url": "https://api.example.com/v1/orders?draft=1",
+          "headers": [
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Authorization", "value": "Bearer secret"},
+            {"name": "Cookie", "value": "sid=1"},
+            {"name": "X-Request-Id", "value": "abc"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"qty\":2}"}
+        },
+        "response": {"status": 201, "statusText": "Created", "headers": [], "content": {"mimeType": "application/json", "text": "{\"id\":7}"}}
+      },
+      {
+        "startedDateTime": "2026-01-05T10:00:02.000Z",
+        "time": 0,
+        "request": {"method": "GET", "url": "https://app.example.com/logo.png", "headers": []},
+        "response": {"status": 0, "statusText": "", "headers": [], "content": {"mimeType": "image/png"}}
+      }
+    ]
+  }
+}`
+
+func TestImportHAR(t *testing.T) {
+	tests := []struct {
+		name      string
+		asSteps   bool
+		wantSteps []models.RecordingStep
+		warnings  []string
+	}{
+		{
+			name:      "telemetry only",
+			wantSteps: []models.RecordingStep{},
+		},
+		{
+			name:    "api calls as steps",
+			asSteps: true,
+			wantSteps: []models.RecordingStep{{
+				Action:      "api_request",
+				Description: "POST /v1/orders",
+				ApiMethod:   "POST",
+				ApiEndpoint: "https://api.example.com/v1/orders?draft=1",
+				ApiPayload:  `{"qty":2}`,
+				ApiHeaders:  `{"Content-Type":"application/json","X-Request-Id":"abc"}`,
+			}},
+			warnings: []string{
+				"non-API resources (documents, scripts, styles, images) were kept as telemetry only",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ImportHAR([]byte(sampleHAR), tt.asSteps)
+			require.NoError(t, err)
+
+			assert.Equal(t, "https://app.example.com/orders", result.StartURL)
+			assert.Equal(t, tt.wantSteps, result.Steps)
+			var reasons []string
+			for _, w := range result.Warnings {
+				reasons = append(reasons, w.Reason)
+			}
+			assert.Equal(t, tt.warnings, reasons)
+
+			require.Len(t, result.NetworkRequests, 3)
+			api := result.NetworkRequests[1]
+			assert.Equal(t, "har-1", api.RequestID)
+			assert.Equal(t, 201, api.Status)
+			assert.Equal(t, `{"qty":2}`, api.RequestPayload)
+			assert.Equal(t, int64(45), api.DurationMs)
+			assert.Equal(t, int64(1767607201000), api.Timestamp)
+			assert.Equal(t, "request did not complete", result.NetworkRequests[2].Error)
+		})
+	}
+}
+
+func TestImportHARErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "not JSON", data: "GET /", want: "invalid HAR file"},
+		{name: "no entries", data: `{"log": {"entries": []}}`, want: "HAR file contains no entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ImportHAR([]byte(tt.data), true)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
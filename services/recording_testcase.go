@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+
+	"google.golang.org/genai"
+)
+
+// RecordingCaseDraft is the documented form of a recording: what a manual
+// tester would read, with expected results for each step
+type RecordingCaseDraft struct {
+	Title        string              `json:"title"`
+	Description  string              `json:"description"`
+	PreCondition string              `json:"preCondition"`
+	Steps        []models.TestStepV2 `json:"steps"`
+}
+
+// maxPromptRequests caps the network calls listed per step in the prompt
+const maxPromptRequests = 5
+
+// GenerateTestCaseFromRecording asks the model to document a recording as a
+// manual test case, using its telemetry to describe what each step caused.
+func GenerateTestCaseFromRecording(ctx context.Context, rec *models.ManualRecording) (RecordingCaseDraft, error) {
+	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	location := os.Getenv("VERTEX_LOCATION")
+	if location == "" {
+		location = "us-central1"
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		Backend:  genai.BackendVertexAI,
+		Project:  projectID,
+		Location: location,
+	})
+	if err != nil {
+		return RecordingCaseDraft{}, fmt.Errorf("failed to create genai client: %w", err)
+	}
+
+	caseSchema := &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"title":        {Type: genai.TypeString},
+			"description":  {Type: genai.TypeString},
+			"preCondition": {Type: genai.TypeString},
+			"steps": {
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"action":   {Type: genai.TypeString},
+						"data":     {Type: genai.TypeString},
+						"expected": {Type: genai.TypeString},
+					},
+					Required: []string{"action", "expected"},
+				},
+			},
+		},
+		Required: []string{"title", "steps"},
+	}
+
+	config := &genai.GenerateContentConfig{
+		Temperature:      genai.Ptr(float32(0.2)),
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   caseSchema,
+	}
+
+	resp, err := client.Models.GenerateContent(ctx, LLMModel, genai.Text(BuildRecordingCasePrompt(rec)), config)
+	if err != nil {
+		return RecordingCaseDraft{}, fmt.Errorf("gemini API call failed: %w", err)
+	}
+
+	resStr := getResponseString(resp)
+	if resStr == "" {
+		return RecordingCaseDraft{}, fmt.Errorf("empty response from gemini")
+	}
+
+	var draft RecordingCaseDraft
+	if err := json.Unmarshal([]byte(resStr), &draft); err != nil {
+		return RecordingCaseDraft{}, fmt.Errorf("failed to unmarshal gemini response: %w\nResponse was: %.500s", err, resStr)
+	}
+	if len(draft.Steps) == 0 {
+		return RecordingCaseDraft{}, fmt.Errorf("model returned no steps")
+	}
+
+	if strings.TrimSpace(draft.Title) == "" {
+		draft.Title = rec.Name
+	}
+	numberSteps(draft.Steps)
+	return draft, nil
+}
+
+// BuildRecordingCasePrompt lists the recorded steps with the network calls,
+// console errors and DOM changes observed around each one.
+func BuildRecordingCasePrompt(rec *models.ManualRecording) string {
+	var b strings.Builder
+
+	b.WriteString("You are a senior QA engineer documenting a recorded browser session as a manual test case.\n\n")
+	fmt.Fprintf(&b, "Recording: %s\n", rec.Name)
+	if rec.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", rec.Description)
+	}
+	if t := rec.Telemetry; t != nil && t.StartUrl != "" {
+		fmt.Fprintf(&b, "Start URL: %s\n", t.StartUrl)
+	}
+
+	contexts := make(map[int]models.StepContext)
+	if rec.Telemetry != nil {
+		for _, sc := range rec.Telemetry.StepsWithContext {
+			contexts[sc.StepIndex] = sc
+		}
+	}
+
+	b.WriteString("\n## Recorded steps\n")
+	for i, step := range rec.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, describeStep(step))
+		sc, ok := contexts[i]
+		if !ok {
+			continue
+		}
+		for j, r := range sc.SurroundingRequests {
+			if j == maxPromptRequests {
+				break
+			}
+			fmt.Fprintf(&b, "   - %s %s → %d\n", r.Method, r.URL, r.Status)
+		}
+		for _, e := range sc.SurroundingErrors {
+			fmt.Fprintf(&b, "   - JS error: %s\n", e.Message)
+		}
+		for _, l := range sc.SurroundingLogs {
+			if l.Level == "error" {
+				fmt.Fprintf(&b, "   - Console error: %s\n", l.Message)
+			}
+		}
+		if sc.DomMutationCount > 0 {
+			fmt.Fprintf(&b, "   - %d DOM changes\n", sc.DomMutationCount)
+		}
+	}
+
+	b.WriteString(`
+## Task
+Write the manual test case a tester would follow to repeat this session.
+Rules:
+- Merge low-level steps into meaningful user actions (e.g. typing email and password, then clicking "Sign in" is one "Log in" step when natural).
+- "action" is what the tester does in plain language, naming fields and buttons by their visible labels rather than CSS selectors.
+- "data" is the input value, if any. Never include passwords, tokens or other secrets; write "<password>" instead.
+- "expected" is the observable result, based on assertions, navigation, responses and DOM changes. Recorded assertions must become expected results.
+- "preCondition" states the starting page and any state the session relied on (e.g. logged in).
+- "title" is a short test case title; "description" one sentence on what is verified.
+Return ONLY a JSON object.`)
+
+	return b.String()
+}
+
+// DescribeRecording documents a recording without the model: each user action
+// becomes a step, and recorded assertions become the expected result of the
+// action before them.
+func DescribeRecording(rec *models.ManualRecording) RecordingCaseDraft {
+	draft := RecordingCaseDraft{Title: rec.Name, Description: rec.Description}
+	if t := rec.Telemetry; t != nil && t.StartUrl != "" {
+		draft.PreCondition = fmt.Sprintf("Start at %s", t.StartUrl)
+	}
+
+	// placeholder is true while the last step still has a generic expected result
+	placeholder := false
+	for _, step := range rec.Steps {
+		if step.Action == "assert" {
+			expected := fmt.Sprintf("%s is %s", stepTarget(step), assertionPhrase(step))
+			n := len(draft.Steps)
+			switch {
+			case n == 0:
+				draft.Steps = append(draft.Steps, models.TestStepV2{Action: "Verify " + stepTarget(step), Expected: expected})
+			case placeholder:
+				draft.Steps[n-1].Expected = expected
+			default:
+				draft.Steps[n-1].Expected += "; " + expected
+			}
+			placeholder = false
+			continue
+		}
+
+		s := models.TestStepV2{Expected: "The action is performed"}
+		switch step.Action {
+		case "navigate":
+			url := step.Value
+			if url == "" {
+				url = step.Selector
+			}
+			s.Action = fmt.Sprintf("Open %s", url)
+			s.Expected = "The page loads"
+		case "type", "fill":
+			s.Action = fmt.Sprintf("Enter %s", stepTarget(step))
+			s.Data = step.Value
+			if sensitiveHint.MatchString(step.Selector + " " + stepTarget(step)) {
+				s.Data = "<secret>"
+			}
+			s.Expected = "The value is accepted"
+		case "click":
+			s.Action = fmt.Sprintf("Click %s", stepTarget(step))
+		case "press":
+			s.Action = fmt.Sprintf("Press %s on %s", step.Value, stepTarget(step))
+		case "api_request":
+			s.Action = fmt.Sprintf("Send %s %s", step.ApiMethod, step.ApiEndpoint)
+			s.Data = step.ApiPayload
+			s.Expected = "The request succeeds"
+		default:
+			s.Action = fmt.Sprintf("%s %s", step.Action, stepTarget(step))
+		}
+		draft.Steps = append(draft.Steps, s)
+		placeholder = true
+	}
+
+	numberSteps(draft.Steps)
+	return draft
+}
+
+// stepTarget names the element a step acts on, preferring what a person
+// would see over the selector
+func stepTarget(step models.RecordingStep) string {
+	if d := strings.TrimSpace(step.Description); d != "" {
+		return fmt.Sprintf("\"%s\"", d)
+	}
+	for _, attr := range []string{"aria-label", "placeholder", "name", "title", "id"} {
+		if v := strings.TrimSpace(step.ElementHints.Attributes[attr]); v != "" {
+			return fmt.Sprintf("\"%s\"", v)
+		}
+	}
+	if step.Selector != "" {
+		return fmt.Sprintf("`%s`", step.Selector)
+	}
+	return step.ApiEndpoint
+}
+
+func numberSteps(steps []models.TestStepV2) {
+	for i := range steps {
+		steps[i].Order = i + 1
+		if steps[i].ID == "" {
+			steps[i].ID = models.NewTestStepID()
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeRecording(t *testing.T) {
+	rec := &models.ManualRecording{
+		Name: "Login with valid credentials",
+		Steps: []models.RecordingStep{
+			{Action: "navigate", Value: "https://app.test/login"},
+			{Action: "type", Selector: "#email", Value: "qa@example.com", ElementHints: models.ElementHints{Attributes: map[string]string{"placeholder": "Email"}}},
+			{Action: "type", Selector: "#password", Value: "hunter2"},
+			{Action: "click", Description: "Sign in"},
+			{Action: "assert", Selector: ".dashboard", AssertionType: "visible"},
+			{Action: "assert", Selector: ".welcome", AssertionType: "contains_text", ExpectedValue: "Hi"},
+		},
+		Telemetry: &models.SessionTelemetry{StartUrl: "https://app.test/login"},
+	}
+
+	draft := DescribeRecording(rec)
+	assert.Equal(t, "Start at https://app.test/login", draft.PreCondition)
+	if assert.Len(t, draft.Steps, 4) {
+		assert.Equal(t, "Enter \"Email\"", draft.Steps[1].Action)
+		assert.Equal(t, "qa@example.com", draft.Steps[1].Data)
+		assert.Equal(t, "<secret>", draft.Steps[2].Data)
+		assert.Equal(t, "Click \"Sign in\"", draft.Steps[3].Action)
+		assert.Equal(t, "`.dashboard` is visible; `.welcome` is contains text \"Hi\"", draft.Steps[3].Expected)
+		assert.Equal(t, 4, draft.Steps[3].Order)
+	}
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+const redactedValue = "[REDACTED]"
+
+type compiledPattern struct {
+	name string
+	re   *regexp.Regexp
+	luhn bool
+}
+
+// Redactor applies a redaction policy to recording telemetry. A Redactor is
+// not safe for concurrent use; it accumulates a report across calls.
+type Redactor struct {
+	headers     map[string]bool
+	anchored    [][]string // JSON paths starting at the document root
+	floating    [][]string // JSON paths matched at any depth
+	keys        map[string]bool
+	cookies     []string
+	storageKeys []string
+	patterns    []compiledPattern
+	report      models.RedactionReport
+}
+
+// NewRedactor compiles a policy. It fails on invalid regular expressions or
+// unknown validators, so it doubles as policy validation.
+func NewRedactor(policy models.RedactionPolicy) (*Redactor, error) {
+	r := &Redactor{
+		headers: make(map[string]bool),
+		keys:    make(map[string]bool),
+		report:  models.RedactionReport{Patterns: make(map[string]int)},
+	}
+	for _, h := range policy.Headers {
+		r.headers[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	for _, p := range policy.JSONPaths {
+		segs, anchored := parseJSONPath(p)
+		if len(segs) == 0 {
+			continue
+		}
+		if anchored {
+			r.anchored = append(r.anchored, segs)
+			continue
+		}
+		r.floating = append(r.floating, segs)
+		if len(segs) == 1 && segs[0] != "*" {
+			// Single keys also apply to query strings and form bodies
+			r.keys[segs[0]] = true
+		}
+	}
+	for _, c := range policy.Cookies {
+		r.cookies = append(r.cookies, strings.ToLower(c))
+	}
+	for _, k := range policy.StorageKeys {
+		r.storageKeys = append(r.storageKeys, strings.ToLower(k))
+	}
+	for _, p := range policy.Patterns {
+		if p.Name == "" {
+			return nil, fmt.Errorf("pattern name is required")
+		}
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %w", p.Name, err)
+		}
+		switch p.Validate {
+		case "", "luhn":
+		default:
+			return nil, fmt.Errorf("pattern %s: unknown validator %q", p.Name, p.Validate)
+		}
+		r.patterns = append(r.patterns, compiledPattern{name: p.Name, re: re, luhn: p.Validate == "luhn"})
+	}
+	return r, nil
+}
+
+// fresh returns a redactor for the same policy with an empty report
+func (r *Redactor) fresh() *Redactor {
+	c := *r
+	c.report = models.RedactionReport{Patterns: make(map[string]int)}
+	return &c
+}
+
+// Report returns what has been redacted so far
+func (r *Redactor) Report() models.RedactionReport {
+	report := r.report
+	report.RedactedAt = time.Now()
+	return report
+}
+
+// RedactRecording scrubs a recording's telemetry in place. Recorded steps keep
+// the values the runner replays, except for the headers and payloads of API
+// steps, which carry the same tokens as the captured requests. Credential
+// placeholders are kept, since they hold no secret themselves.
+func (r *Redactor) RedactRecording(rec *models.ManualRecording) models.RedactionReport {
+	r.redactSteps(rec.Steps)
+	t := rec.Telemetry
+	if t == nil {
+		return r.Report()
+	}
+
+	t.StartUrl = r.redactURL(t.StartUrl)
+	t.BrowserContext.URL = r.redactURL(t.BrowserContext.URL)
+	r.redactLogs(t.ConsoleLogs)
+	r.redactRequests(t.NetworkRequests)
+	r.redactErrors(t.JSErrors)
+	for i := range t.DOMMutations {
+		t.DOMMutations[i].Summary = r.redactText(t.DOMMutations[i].Summary)
+	}
+	for i := range t.StorageSnapshots {
+		r.redactStorage(&t.StorageSnapshots[i])
+	}
+	for i := range t.StepsWithContext {
+		sc := &t.StepsWithContext[i]
+		r.redactLogs(sc.SurroundingLogs)
+		r.redactRequests(sc.SurroundingRequests)
+		r.redactErrors(sc.SurroundingErrors)
+	}
+	return r.Report()
+}
+
+func (r *Redactor) redactSteps(steps []models.RecordingStep) {
+	for i := range steps {
+		steps[i].ApiHeaders = r.redactStepHeaders(steps[i].ApiHeaders)
+		steps[i].ApiPayload = r.redactPayload(steps[i].ApiPayload)
+	}
+}
+
+// redactStepHeaders redacts an api_request step's headers, stored as a JSON
+// object of strings. Anything else is treated as a payload.
+func (r *Redactor) redactStepHeaders(raw string) string {
+	var headers map[string]string
+	if strings.TrimSpace(raw) == "" || json.Unmarshal([]byte(raw), &headers) != nil {
+		return r.redactPayload(raw)
+	}
+	original := maps.Clone(headers)
+	r.redactHeaders(headers)
+	if maps.Equal(original, headers) {
+		return raw
+	}
+	b, err := json.Marshal(headers)
+	if err != nil {
+		return raw
+	}
+	return string(b)
+}
+
+func (r *Redactor) redactLogs(logs []models.ConsoleLogEntry) {
+	for i := range logs {
+		logs[i].Message = r.redactText(logs[i].Message)
+	}
+}
+
+func (r *Redactor) redactErrors(errs []models.JSErrorEntry) {
+	for i := range errs {
+		errs[i].Message = r.redactText(errs[i].Message)
+		errs[i].Stack = r.redactText(errs[i].Stack)
+	}
+}
+
+func (r *Redactor) redactRequests(reqs []models.NetworkRequestEntry) {
+	for i := range reqs {
+		req := &reqs[i]
+		req.URL = r.redactURL(req.URL)
+		r.redactHeaders(req.RequestHeaders)
+		r.redactHeaders(req.ResponseHeaders)
+		req.RequestPayload = r.redactPayload(req.RequestPayload)
+		req.ResponsePayload = r.redactPayload(req.ResponsePayload)
+		req.Error = r.redactText(req.Error)
+	}
+}
+
+func (r *Redactor) redactHeaders(headers map[string]string) {
+	for k, v := range headers {
+		switch name := strings.ToLower(k); {
+		case r.headers[name]:
+			if v != redactedValue && !isCredentialPlaceholder(v) {
+				headers[k] = redactedValue
+				r.report.Headers++
+			}
+		case name == "cookie":
+			headers[k] = r.redactCookiePairs(v, "; ")
+		case name == "set-cookie":
+			lines := strings.Split(v, "\n")
+			for i, line := range lines {
+				// Only the leading name=value pair carries data; the rest are attributes
+				pair, attrs, _ := strings.Cut(line, ";")
+				lines[i] = r.redactCookiePairs(pair, "; ")
+				if attrs != "" {
+					lines[i] += ";" + attrs
+				}
+			}
+			headers[k] = strings.Join(lines, "\n")
+		default:
+			headers[k] = r.redactText(v)
+		}
+	}
+}
+
+func (r *Redactor) redactCookiePairs(header, sep string) string {
+	pairs := strings.Split(header, ";")
+	for i, p := range pairs {
+		name, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok {
+			pairs[i] = strings.TrimSpace(p)
+			continue
+		}
+		if globMatch(r.cookies, name) {
+			if value != redactedValue {
+				r.report.Cookies++
+			}
+			value = redactedValue
+		} else {
+			value = r.redactText(value)
+		}
+		pairs[i] = name + "=" + value
+	}
+	return strings.Join(pairs, sep)
+}
+
+func (r *Redactor) redactStorage(snap *models.StorageSnapshot) {
+	globs := r.storageKeys
+	if snap.Type == "cookies" {
+		globs = r.cookies
+	}
+	for k, v := range snap.Data {
+		if globMatch(globs, k) {
+			if v != redactedValue {
+				snap.Data[k] = redactedValue
+				if snap.Type == "cookies" {
+					r.report.Cookies++
+				} else {
+					r.report.StorageKeys++
+				}
+			}
+			continue
+		}
+		snap.Data[k] = r.redactPayload(v)
+	}
+}
+
+// redactURL redacts query parameters named by single-key JSON paths, then
+// applies the patterns to the rest of the URL
+func (r *Redactor) redactURL(raw string) string {
+	base, query, ok := strings.Cut(raw, "?")
+	if !ok {
+		return r.redactText(raw)
+	}
+	fragment := ""
+	if q, f, ok := strings.Cut(query, "#"); ok {
+		query, fragment = q, "#"+f
+	}
+	return r.redactText(base) + "?" + r.redactForm(query) + fragment
+}
+
+// redactForm redacts a URL-encoded key/value string, keeping parameter order
+func (r *Redactor) redactForm(query string) string {
+	pairs := strings.Split(query, "&")
+	for i, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			continue
+		}
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		if r.keys[strings.ToLower(key)] {
+			if v != redactedValue {
+				r.report.JSONFields++
+			}
+			pairs[i] = k + "=" + redactedValue
+			continue
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			continue
+		}
+		if redacted := r.redactText(value); redacted != value {
+			pairs[i] = k + "=" + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactPayload handles JSON documents and form bodies structurally, and any
+// other text with the patterns only
+func (r *Redactor) redactPayload(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+
+	if trimmed[0] == '{' || trimmed[0] == '[' {
+		dec := json.NewDecoder(strings.NewReader(trimmed))
+		dec.UseNumber()
+		var doc any
+		if dec.Decode(&doc) == nil {
+			redacted, changed := r.walkJSON(doc, nil)
+			if !changed {
+				return s
+			}
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if enc.Encode(redacted) == nil {
+				return strings.TrimSuffix(buf.String(), "\n")
+			}
+			return s
+		}
+	}
+
+	if !strings.ContainsAny(trimmed, " \n") && strings.Contains(trimmed, "=") {
+		return r.redactForm(trimmed)
+	}
+	return r.redactText(s)
+}
+
+func (r *Redactor) walkJSON(v any, jsonPath []string) (any, bool) {
+	if len(jsonPath) > 0 && r.matchesPath(jsonPath) {
+		if v == redactedValue || isCredentialPlaceholder(v) {
+			return v, false
+		}
+		r.report.JSONFields++
+		return redactedValue, true
+	}
+
+	changed := false
+	switch node := v.(type) {
+	case map[string]any:
+		for k, child := range node {
+			next, c := r.walkJSON(child, append(jsonPath[:len(jsonPath):len(jsonPath)], strings.ToLower(k)))
+			if c {
+				node[k] = next
+				changed = true
+			}
+		}
+	case []any:
+		for i, child := range node {
+			next, c := r.walkJSON(child, append(jsonPath[:len(jsonPath):len(jsonPath)], "[]"))
+			if c {
+				node[i] = next
+				changed = true
+			}
+		}
+	case string:
+		if redacted := r.redactText(node); redacted != node {
+			return redacted, true
+		}
+	}
+	return v, changed
+}
+
+func (r *Redactor) matchesPath(jsonPath []string) bool {
+	for _, segs := range r.anchored {
+		if len(segs) == len(jsonPath) && segmentsMatch(segs, jsonPath) {
+			return true
+		}
+	}
+	for _, segs := range r.floating {
+		if len(segs) <= len(jsonPath) && segmentsMatch(segs, jsonPath[len(jsonPath)-len(segs):]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCredentialPlaceholder reports whether v refers to a stored credential
+// instead of holding the secret
+func isCredentialPlaceholder(v any) bool {
+	str, ok := v.(string)
+	return ok && models.CredentialPlaceholderPattern.MatchString(str)
+}
+
+func segmentsMatch(pattern, actual []string) bool {
+	for i, p := range pattern {
+		if p != "*" && p != actual[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Redactor) redactText(s string) string {
+	if s == "" {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.luhn && !luhnValid(m) {
+				return m
+			}
+			r.report.Patterns[p.name]++
+			return "[REDACTED:" + p.name + "]"
+		})
+	}
+	return s
+}
+
+// parseJSONPath splits "$.items[*].card" into ["items", "*", "card"]
+func parseJSONPath(p string) ([]string, bool) {
+	p = strings.TrimSpace(p)
+	anchored := strings.HasPrefix(p, "$")
+	p = strings.TrimPrefix(p, "$")
+	p = strings.NewReplacer("[*]", ".*", "[]", ".*").Replace(p)
+
+	var segs []string
+	for _, s := range strings.Split(p, ".") {
+		if s = strings.TrimSpace(s); s != "" {
+			segs = append(segs, strings.ToLower(s))
+		}
+	}
+	return segs, anchored
+}
+
+func globMatch(globs []string, name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, g := range globs {
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+)
+
+// RedactRecordingForProject scrubs a recording's telemetry and API steps with
+// its project's policy and records the outcome on the recording. Nothing is
+// changed when the policy is disabled.
+func RedactRecordingForProject(ctx context.Context, rec *models.ManualRecording) (models.RedactionReport, error) {
+	policy, _, err := database.GetRedactionPolicy(ctx, rec.ProjectID)
+	if err != nil {
+		return models.RedactionReport{}, err
+	}
+	if policy.Disabled {
+		return models.RedactionReport{}, nil
+	}
+	redactor, err := NewRedactor(policy)
+	if err != nil {
+		return models.RedactionReport{}, err
+	}
+
+	report := redactor.RedactRecording(rec)
+	if rec.Redaction != nil {
+		report = mergeRedactionReports(*rec.Redaction, report)
+	}
+	rec.Redaction = &report
+	return report, nil
+}
+
+// RedactionBackfillOptions selects the stored recordings to scrub
+type RedactionBackfillOptions struct {
+	ProjectID string // all recordings when empty
+	DryRun    bool
+	// Progress is called after each recording that had something redacted
+	Progress func(rec *models.ManualRecording, report models.RedactionReport)
+}
+
+// RedactionBackfillResult summarises a backfill run
+type RedactionBackfillResult struct {
+	Scanned  int `json:"scanned"`
+	Redacted int `json:"redacted"` // recordings with at least one value replaced
+	Values   int `json:"values"`
+	Failed   int `json:"failed"`
+}
+
+// BackfillRedaction applies the current policies to recordings stored before
+// redaction existed, or before a policy was tightened
+func BackfillRedaction(ctx context.Context, opts RedactionBackfillOptions) (RedactionBackfillResult, error) {
+	var result RedactionBackfillResult
+
+	setKey := "recordings"
+	if opts.ProjectID != "" {
+		setKey = fmt.Sprintf("recordings:project:%s", opts.ProjectID)
+	}
+	ids, err := database.RedisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return result, err
+	}
+
+	redactors := make(map[string]*Redactor)
+	for _, id := range ids {
+		key := fmt.Sprintf("recording:%s", id)
+		val, err := database.RedisClient.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		var rec models.ManualRecording
+		if err := json.Unmarshal([]byte(val), &rec); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Scanned++
+		if rec.Telemetry == nil && len(rec.Steps) == 0 {
+			continue
+		}
+
+		// Policies are compiled once per project; a fresh redactor per recording
+		// keeps the reports separate
+		if _, ok := redactors[rec.ProjectID]; !ok {
+			policy, _, err := database.GetRedactionPolicy(ctx, rec.ProjectID)
+			if err != nil {
+				return result, err
+			}
+			if policy.Disabled {
+				redactors[rec.ProjectID] = nil
+			} else if redactors[rec.ProjectID], err = NewRedactor(policy); err != nil {
+				return result, fmt.Errorf("project %s: %w", rec.ProjectID, err)
+			}
+		}
+		if redactors[rec.ProjectID] == nil {
+			continue
+		}
+		redactor := redactors[rec.ProjectID].fresh()
+
+		report := redactor.RedactRecording(&rec)
+		if report.Total() == 0 {
+			continue
+		}
+		result.Redacted++
+		result.Values += report.Total()
+		if opts.Progress != nil {
+			opts.Progress(&rec, report)
+		}
+		if opts.DryRun {
+			continue
+		}
+
+		if rec.Redaction != nil {
+			report = mergeRedactionReports(*rec.Redaction, report)
+		}
+		rec.Redaction = &report
+		data, err := json.Marshal(rec)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if err := database.RedisClient.Set(ctx, key, data, 0).Err(); err != nil {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+func mergeRedactionReports(prev, next models.RedactionReport) models.RedactionReport {
+	next.Headers += prev.Headers
+	next.JSONFields += prev.JSONFields
+	next.Cookies += prev.Cookies
+	next.StorageKeys += prev.StorageKeys
+	for name, n := range prev.Patterns {
+		next.Patterns[name] += n
+	}
+	return next
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactRecording(t *testing.T) {
+	rec := &models.ManualRecording{Telemetry: &models.SessionTelemetry{
+		StartUrl: "https://app.test/reset?token=abc123&lang=en",
+		NetworkRequests: []models.NetworkRequestEntry{{
+			URL:    "https://api.test/login",
+			Method: "POST",
+			RequestHeaders: map[string]string{
+				"Authorization": "Bearer abc.def",
+				"Cookie":        "session_id=s3cr3t; theme=dark",
+			},
+			RequestPayload:  `{"email":"qa@example.com","password":"hunter2","card":{"number":"4111 1111 1111 1111"},"ref":"1234 5678 9012 3456"}`,
+			ResponsePayload: `{"user":{"id":7,"accessToken":"xyz"}}`,
+		}},
+		StorageSnapshots: []models.StorageSnapshot{
+			{Type: "localStorage", Data: map[string]string{"auth_token": "t", "theme": "dark"}},
+		},
+		ConsoleLogs: []models.ConsoleLogEntry{{Level: "info", Message: "logged in as qa@example.com"}},
+	}}
+
+	policy := models.DefaultRedactionPolicy()
+	policy.JSONPaths = append(policy.JSONPaths, "$.user.id")
+	r, err := NewRedactor(policy)
+	require.NoError(t, err)
+	report := r.RedactRecording(rec)
+
+	tel := rec.Telemetry
+	req := tel.NetworkRequests[0]
+	assert.Equal(t, "https://app.test/reset?token=[REDACTED]&lang=en", tel.StartUrl)
+	assert.Equal(t, "[REDACTED]", req.RequestHeaders["Authorization"])
+	assert.Equal(t, "session_id=[REDACTED]; theme=dark", req.RequestHeaders["Cookie"])
+	assert.Equal(t, `{"card":{"number":"[REDACTED:card]"},"email":"[REDACTED:email]","password":"[REDACTED]","ref":"1234 5678 9012 3456"}`, req.RequestPayload)
+	assert.Equal(t, `{"user":{"accessToken":"[REDACTED]","id":"[REDACTED]"}}`, req.ResponsePayload)
+	assert.Equal(t, map[string]string{"auth_token": "[REDACTED]", "theme": "dark"}, tel.StorageSnapshots[0].Data)
+	assert.Equal(t, "logged in as [REDACTED:email]", tel.ConsoleLogs[0].Message)
+
+	assert.Equal(t, 1, report.Headers)
+	assert.Equal(t, 4, report.JSONFields)
+	assert.Equal(t, 1, report.Cookies)
+	assert.Equal(t, 1, report.StorageKeys)
+	assert.Equal(t, map[string]int{"email": 2, "card": 1}, report.Patterns)
+
+	// A second pass finds nothing new
+	again, err := NewRedactor(policy)
+	require.NoError(t, err)
+	assert.Zero(t, again.RedactRecording(rec).Total())
+
+	_, err = NewRedactor(models.RedactionPolicy{Patterns: []models.RedactionPattern{{Name: "bad", Regex: "("}}})
+	assert.Error(t, err)
+}
+
+func TestRedactRecordingAPISteps(t *testing.T) {
+	rec := &models.ManualRecording{Steps: []models.RecordingStep{
+		{Action: "type", Selector: "#email", Value: "qa@example.com"},
+		{
+			Action:      "api_request",
+			ApiEndpoint: "https://api.test/orders",
+			ApiHeaders:  `{"Authorization": "Bearer abc.def", "X-Api-Key": "{{credential:c-1:password}}", "Accept": "application/json"}`,
+			ApiPayload:  `{"password":"hunter2","token":"{{credential:c-1:password}}","qty":2}`,
+		},
+		{Action: "api_request", ApiHeaders: "Authorization: Bearer abc.def", ApiPayload: "contact qa@example.com"},
+		{Action: "api_request", ApiHeaders: `{"Accept": "application/json"}`},
+	}}
+
+	r, err := NewRedactor(models.DefaultRedactionPolicy())
+	require.NoError(t, err)
+	report := r.RedactRecording(rec)
+
+	assert.Equal(t, "qa@example.com", rec.Steps[0].Value, "replayed values are kept")
+	assert.Equal(t, `{"Accept":"application/json","Authorization":"[REDACTED]","X-Api-Key":"{{credential:c-1:password}}"}`, rec.Steps[1].ApiHeaders)
+	assert.Equal(t, `{"password":"[REDACTED]","qty":2,"token":"{{credential:c-1:password}}"}`, rec.Steps[1].ApiPayload)
+	assert.Equal(t, "contact [REDACTED:email]", rec.Steps[2].ApiPayload)
+	assert.Equal(t, `{"Accept": "application/json"}`, rec.Steps[3].ApiHeaders, "unchanged headers keep their formatting")
+	assert.Equal(t, 1, report.Headers)
+	assert.Equal(t, 1, report.JSONFields)
+}
@@ -183,6 +183,15 @@ func mapTCStatus(status string) models.TestCaseStatus {
 	}
 }
 
+// automationParameters returns the generated automation's data-driven rows,
+// falling back to the test case's own Examples rows.
+func automationParameters(auto *models.GeneratedAutomation, tc *models.TestCase) []any {
+	if len(auto.Parameters) > 0 {
+		return auto.Parameters
+	}
+	return tc.Parameters
+}
+
 // LinkAutomation links a generated automation to a matching test case using TestCaseID, then name fallback.
 func LinkAutomation(scenario *models.TestScenario, auto *models.GeneratedAutomation) bool {
 	// First try exact ID match
@@ -192,10 +201,11 @@ func LinkAutomation(scenario *models.TestScenario, auto *models.GeneratedAutomat
 				tc := &scenario.Sections[i].TestCases[j]
 				if tc.ID == auto.TestCaseID || strings.HasPrefix(auto.TestCaseID, tc.ID) || strings.Contains(auto.TestCaseID, tc.ID) || strings.Contains(tc.ID, auto.TestCaseID) {
 					tc.AutomationTest = &models.AutomationTest{
-						ID:     fmt.Sprintf("auto-%s", auto.ID),
-						Name:   auto.Name,
-						Status: models.AutomationStatusIdle,
-						Steps:  auto.Steps,
+						ID:         fmt.Sprintf("auto-%s", auto.ID),
+						Name:       auto.Name,
+						Status:     models.AutomationStatusIdle,
+						Steps:      auto.Steps,
+						Parameters: automationParameters(auto, tc),
 					}
 					return true
 				}
@@ -216,10 +226,11 @@ func LinkAutomation(scenario *models.TestScenario, auto *models.GeneratedAutomat
 			if strings.Contains(autoLower, codeLower) ||
 				strings.Contains(autoLower, strings.ReplaceAll(tcLower, " ", "_")) {
 				tc.AutomationTest = &models.AutomationTest{
-					ID:     fmt.Sprintf("auto-%s", auto.ID),
-					Name:   auto.Name,
-					Status: models.AutomationStatusIdle,
-					Steps:  auto.Steps,
+					ID:         fmt.Sprintf("auto-%s", auto.ID),
+					Name:       auto.Name,
+					Status:     models.AutomationStatusIdle,
+					Steps:      auto.Steps,
+					Parameters: automationParameters(auto, tc),
 				}
 				return true
 			}
@@ -231,10 +242,11 @@ func LinkAutomation(scenario *models.TestScenario, auto *models.GeneratedAutomat
 			tc := &scenario.Sections[i].TestCases[j]
 			if tc.AutomationTest == nil || len(tc.AutomationTest.Steps) == 0 {
 				tc.AutomationTest = &models.AutomationTest{
-					ID:     fmt.Sprintf("auto-%s", auto.ID),
-					Name:   auto.Name,
-					Status: models.AutomationStatusIdle,
-					Steps:  auto.Steps,
+					ID:         fmt.Sprintf("auto-%s", auto.ID),
+					Name:       auto.Name,
+					Status:     models.AutomationStatusIdle,
+					Steps:      auto.Steps,
+					Parameters: automationParameters(auto, tc),
 				}
 				return true
 			}
@@ -273,3 +285,250 @@ func GetTestCasesInSection(scenario *models.TestScenario, sectionID string) []*m
 	}
 	return nil
 }
+
+// BuildScenarioFromGherkin converts parsed .feature files into a TestScenario.
+// Each Feature (and each Rule inside it) becomes a section, each Scenario a
+// test case. Given/When lines become steps and Then lines become the expected
+// result of the preceding step. Examples tables are kept as test case parameters.
+func BuildScenarioFromGherkin(
+	title string,
+	features []models.GherkinFeature,
+	projectID string,
+	projectName string,
+	authConfig models.AuthConfig,
+	creatorID int,
+) models.TestScenario {
+	now := time.Now()
+
+	if idx := strings.LastIndex(title, "."); idx > 0 && strings.HasSuffix(strings.ToLower(title), ".feature") {
+		title = title[:idx]
+	}
+
+	createdBy := ""
+	if creatorID != 0 {
+		createdBy = fmt.Sprintf("User %d", creatorID)
+	}
+
+	var sources []string
+	for _, f := range features {
+		if f.SourcePath != "" {
+			sources = append(sources, f.SourcePath)
+		}
+	}
+	description := "Imported from Gherkin feature files"
+	if len(sources) > 0 {
+		description = fmt.Sprintf("Imported from %s", strings.Join(sources, ", "))
+	}
+
+	scenario := models.TestScenario{
+		Title:       title,
+		Description: description,
+		ProjectID:   projectID,
+		ProjectName: projectName,
+		Status:      models.ScenarioStatusDraft,
+		AuthConfig:  authConfig,
+		CreatorID:   creatorID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		CreatedBy:   createdBy,
+	}
+
+	// gherkinBlock is a feature or rule flattened into a single section
+	type gherkinBlock struct {
+		title       string
+		description string
+		tags        []string
+		background  []models.GherkinStep
+		scenarios   []models.GherkinScenario
+	}
+
+	var blocks []gherkinBlock
+	for _, f := range features {
+		if len(f.Scenarios) > 0 || len(f.Rules) == 0 {
+			blocks = append(blocks, gherkinBlock{
+				title:       f.Name,
+				description: f.Description,
+				tags:        f.Tags,
+				background:  f.Background,
+				scenarios:   f.Scenarios,
+			})
+		}
+		for _, r := range f.Rules {
+			blocks = append(blocks, gherkinBlock{
+				title:       fmt.Sprintf("%s / %s", f.Name, r.Name),
+				description: r.Description,
+				tags:        append(append([]string{}, f.Tags...), r.Tags...),
+				background:  append(append([]models.GherkinStep{}, f.Background...), r.Background...),
+				scenarios:   r.Scenarios,
+			})
+		}
+	}
+
+	globalTCIndex := 0
+	for blockIdx, block := range blocks {
+		section := models.TestSection{
+			ID:          fmt.Sprintf("sec-%d-%d", now.UnixMilli()%100000, blockIdx),
+			Order:       blockIdx + 1,
+			Title:       block.title,
+			Description: block.description,
+		}
+		sheet := models.TestScenarioSheet{Name: block.title}
+
+		preCondition := gherkinStepsToText(block.background)
+
+		for tcIdx, gs := range block.scenarios {
+			globalTCIndex++
+			parsed := models.ParsedTestCase{
+				ID:           fmt.Sprintf("TC-%03d", globalTCIndex),
+				UserStory:    gs.Description,
+				Name:         gs.Name,
+				PreCondition: preCondition,
+				Steps:        gherkinToParsedSteps(gs.Steps),
+			}
+			sheet.TestCases = append(sheet.TestCases, parsed)
+
+			tc := convertParsedTestCase(parsed, globalTCIndex, blockIdx, tcIdx, now)
+			tags := gherkinTags(block.tags, gs)
+			tc.Tags = tags
+			tc.Priority = gherkinPriority(tags, parsed)
+			tc.Parameters = gherkinParameters(gs.Examples)
+			section.TestCases = append(section.TestCases, tc)
+		}
+
+		scenario.Sections = append(scenario.Sections, section)
+		scenario.Sheets = append(scenario.Sheets, sheet)
+	}
+
+	scenario.ComputeStats()
+	return scenario
+}
+
+// gherkinToParsedSteps folds Then/And/But lines into the expected result of
+// the preceding action step.
+func gherkinToParsedSteps(steps []models.GherkinStep) []models.ParsedStep {
+	var result []models.ParsedStep
+	inThen := false
+	for _, s := range steps {
+		switch s.Keyword {
+		case "Then":
+			inThen = true
+		case "Given", "When":
+			inThen = false
+		}
+
+		data := gherkinStepData(s)
+		if inThen {
+			if len(result) == 0 {
+				result = append(result, models.ParsedStep{})
+			}
+			last := &result[len(result)-1]
+			last.ExpectedResult = joinNonEmpty(last.ExpectedResult, s.Text)
+			if data != "" {
+				last.ExpectedResult = joinNonEmpty(last.ExpectedResult, data)
+			}
+			continue
+		}
+		result = append(result, models.ParsedStep{
+			Action:    s.Text,
+			InputData: data,
+		})
+	}
+	if result == nil {
+		result = []models.ParsedStep{}
+	}
+	return result
+}
+
+func gherkinStepsToText(steps []models.GherkinStep) string {
+	lines := make([]string, 0, len(steps))
+	for _, s := range steps {
+		line := s.Keyword + " " + s.Text
+		if data := gherkinStepData(s); data != "" {
+			line += "\n" + data
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gherkinStepData renders a doc string or data table as plain text
+func gherkinStepData(s models.GherkinStep) string {
+	if s.DocString != "" {
+		return s.DocString
+	}
+	if len(s.DataTable) == 0 {
+		return ""
+	}
+	rows := make([]string, 0, len(s.DataTable))
+	for _, row := range s.DataTable {
+		rows = append(rows, "| "+strings.Join(row, " | ")+" |")
+	}
+	return strings.Join(rows, "\n")
+}
+
+func gherkinTags(inherited []string, gs models.GherkinScenario) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	add := func(list []string) {
+		for _, t := range list {
+			t = strings.ToLower(t)
+			if t != "" && !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	add(inherited)
+	add(gs.Tags)
+	for _, ex := range gs.Examples {
+		add(ex.Tags)
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags
+}
+
+// gherkinPriority honours explicit priority tags before falling back to name heuristics
+func gherkinPriority(tags []string, tc models.ParsedTestCase) models.Priority {
+	for _, t := range tags {
+		switch t {
+		case "critical", "blocker", "p0":
+			return models.PriorityCritical
+		case "high", "smoke", "p1":
+			return models.PriorityHigh
+		case "medium", "p2":
+			return models.PriorityMedium
+		case "low", "p3":
+			return models.PriorityLow
+		}
+	}
+	return inferPriority(tc)
+}
+
+// gherkinParameters flattens Examples tables into one map per row
+func gherkinParameters(examples []models.GherkinExamples) []any {
+	var params []any
+	for _, ex := range examples {
+		for _, row := range ex.Rows {
+			values := make(map[string]string, len(ex.Header))
+			for i, h := range ex.Header {
+				if i < len(row) {
+					values[h] = row[i]
+				}
+			}
+			params = append(params, values)
+		}
+	}
+	return params
+}
+
+func joinNonEmpty(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "\n" + b
+}
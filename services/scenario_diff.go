@@ -0,0 +1,257 @@
+package services
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"qa-extension-backend/internal/models"
+)
+
+// DiffScenarios compares two scenario snapshots at the scenario, section,
+// test case and step level. Sections, test cases and steps are matched by ID;
+// a test case that changes section is reported as moved.
+func DiffScenarios(from, to *models.TestScenario) models.ScenarioDiff {
+	diff := models.ScenarioDiff{Sections: []models.SectionDiff{}}
+
+	addField(&diff.Fields, "title", from.Title, to.Title)
+	addField(&diff.Fields, "description", from.Description, to.Description)
+	addField(&diff.Fields, "status", from.Status, to.Status)
+	addField(&diff.Fields, "authConfig.baseUrl", from.AuthConfig.BaseURL, to.AuthConfig.BaseURL)
+	addField(&diff.Fields, "authConfig.loginUrl", from.AuthConfig.LoginURL, to.AuthConfig.LoginURL)
+	addField(&diff.Fields, "authConfig.credentialId", from.AuthConfig.CredentialID, to.AuthConfig.CredentialID)
+
+	type located struct {
+		sectionID string
+		tc        models.TestCase
+	}
+	oldCases := make(map[string]located)
+	for _, sec := range from.Sections {
+		for _, tc := range sec.TestCases {
+			oldCases[tc.ID] = located{sectionID: sec.ID, tc: tc}
+		}
+	}
+	seenCases := make(map[string]bool)
+
+	oldSections := make(map[string]models.TestSection, len(from.Sections))
+	for _, sec := range from.Sections {
+		oldSections[sec.ID] = sec
+	}
+
+	sectionDiffs := make(map[string]*models.SectionDiff)
+	var order []string
+	sectionDiff := func(id, title, change string) *models.SectionDiff {
+		if d, ok := sectionDiffs[id]; ok {
+			return d
+		}
+		d := &models.SectionDiff{SectionID: id, Title: title, Change: change}
+		sectionDiffs[id] = d
+		order = append(order, id)
+		return d
+	}
+
+	for _, sec := range to.Sections {
+		old, existed := oldSections[sec.ID]
+		if !existed {
+			d := sectionDiff(sec.ID, sec.Title, models.ChangeAdded)
+			for _, tc := range sec.TestCases {
+				seenCases[tc.ID] = true
+				if prev, ok := oldCases[tc.ID]; ok {
+					d.TestCases = append(d.TestCases, movedCase(prev.tc, tc, prev.sectionID, sec.ID))
+				} else {
+					d.TestCases = append(d.TestCases, models.TestCaseDiff{TestCaseID: tc.ID, Code: tc.Code, Title: tc.Title, Change: models.ChangeAdded})
+				}
+			}
+			continue
+		}
+
+		var fields []models.FieldChange
+		addField(&fields, "title", old.Title, sec.Title)
+		addField(&fields, "description", old.Description, sec.Description)
+		addField(&fields, "order", old.Order, sec.Order)
+
+		var cases []models.TestCaseDiff
+		for _, tc := range sec.TestCases {
+			seenCases[tc.ID] = true
+			prev, ok := oldCases[tc.ID]
+			switch {
+			case !ok:
+				cases = append(cases, models.TestCaseDiff{TestCaseID: tc.ID, Code: tc.Code, Title: tc.Title, Change: models.ChangeAdded})
+			case prev.sectionID != sec.ID:
+				cases = append(cases, movedCase(prev.tc, tc, prev.sectionID, sec.ID))
+			default:
+				if d, changed := diffTestCase(prev.tc, tc); changed {
+					cases = append(cases, d)
+				}
+			}
+		}
+
+		if len(fields) > 0 || len(cases) > 0 {
+			d := sectionDiff(sec.ID, sec.Title, models.ChangeModified)
+			d.Fields = fields
+			d.TestCases = append(d.TestCases, cases...)
+		}
+	}
+
+	// Removed sections, and test cases that disappeared from surviving sections
+	newSections := make(map[string]bool, len(to.Sections))
+	for _, sec := range to.Sections {
+		newSections[sec.ID] = true
+	}
+	for _, sec := range from.Sections {
+		if !newSections[sec.ID] {
+			d := sectionDiff(sec.ID, sec.Title, models.ChangeRemoved)
+			for _, tc := range sec.TestCases {
+				if !seenCases[tc.ID] {
+					d.TestCases = append(d.TestCases, models.TestCaseDiff{TestCaseID: tc.ID, Code: tc.Code, Title: tc.Title, Change: models.ChangeRemoved})
+				}
+			}
+			continue
+		}
+		for _, tc := range sec.TestCases {
+			if !seenCases[tc.ID] {
+				d := sectionDiff(sec.ID, sec.Title, models.ChangeModified)
+				d.TestCases = append(d.TestCases, models.TestCaseDiff{TestCaseID: tc.ID, Code: tc.Code, Title: tc.Title, Change: models.ChangeRemoved})
+			}
+		}
+	}
+
+	for _, id := range order {
+		d := sectionDiffs[id]
+		switch d.Change {
+		case models.ChangeAdded:
+			diff.Summary.SectionsAdded++
+		case models.ChangeRemoved:
+			diff.Summary.SectionsRemoved++
+		default:
+			diff.Summary.SectionsModified++
+		}
+		for _, tc := range d.TestCases {
+			switch tc.Change {
+			case models.ChangeAdded:
+				diff.Summary.TestCasesAdded++
+			case models.ChangeRemoved:
+				diff.Summary.TestCasesRemoved++
+			case models.ChangeMoved:
+				diff.Summary.TestCasesMoved++
+			default:
+				diff.Summary.TestCasesModified++
+			}
+			diff.Summary.StepsChanged += len(tc.Steps)
+		}
+		diff.Sections = append(diff.Sections, *d)
+	}
+
+	return diff
+}
+
+func movedCase(old, tc models.TestCase, fromSection, toSection string) models.TestCaseDiff {
+	d, _ := diffTestCase(old, tc)
+	d.Change = models.ChangeMoved
+	d.Fields = append([]models.FieldChange{{Field: "section", Old: fromSection, New: toSection}}, d.Fields...)
+	return d
+}
+
+func diffTestCase(old, tc models.TestCase) (models.TestCaseDiff, bool) {
+	d := models.TestCaseDiff{TestCaseID: tc.ID, Code: tc.Code, Title: tc.Title, Change: models.ChangeModified}
+
+	addField(&d.Fields, "title", old.Title, tc.Title)
+	addField(&d.Fields, "description", old.Description, tc.Description)
+	addField(&d.Fields, "preCondition", old.PreCondition, tc.PreCondition)
+	addField(&d.Fields, "priority", old.Priority, tc.Priority)
+	addField(&d.Fields, "type", old.Type, tc.Type)
+	addField(&d.Fields, "status", old.Status, tc.Status)
+	addField(&d.Fields, "note", old.Note, tc.Note)
+	addField(&d.Fields, "order", old.Order, tc.Order)
+	if !sameStrings(old.Tags, tc.Tags) {
+		d.Fields = append(d.Fields, models.FieldChange{Field: "tags", Old: old.Tags, New: tc.Tags})
+	}
+	if !reflect.DeepEqual(old.Parameters, tc.Parameters) {
+		d.Fields = append(d.Fields, models.FieldChange{Field: "parameters", Old: old.Parameters, New: tc.Parameters})
+	}
+
+	if automationSignature(old.AutomationTest) != automationSignature(tc.AutomationTest) {
+		d.Fields = append(d.Fields, models.FieldChange{Field: "automationSteps", Old: automationStepCount(old.AutomationTest), New: automationStepCount(tc.AutomationTest)})
+	}
+
+	d.Steps = diffSteps(old.Steps, tc.Steps)
+
+	return d, len(d.Fields) > 0 || len(d.Steps) > 0
+}
+
+func diffSteps(old, steps []models.TestStepV2) []models.StepDiff {
+	key := func(s models.TestStepV2, idx int) string {
+		if s.ID != "" {
+			return s.ID
+		}
+		return "#" + strconv.Itoa(idx)
+	}
+
+	oldByKey := make(map[string]models.TestStepV2, len(old))
+	for i, s := range old {
+		oldByKey[key(s, i)] = s
+	}
+
+	var diffs []models.StepDiff
+	seen := make(map[string]bool)
+	for i, s := range steps {
+		k := key(s, i)
+		seen[k] = true
+		prev, ok := oldByKey[k]
+		if !ok {
+			diffs = append(diffs, models.StepDiff{StepID: s.ID, Order: s.Order, Change: models.ChangeAdded})
+			continue
+		}
+		var fields []models.FieldChange
+		addField(&fields, "action", prev.Action, s.Action)
+		addField(&fields, "data", prev.Data, s.Data)
+		addField(&fields, "expected", prev.Expected, s.Expected)
+		addField(&fields, "order", prev.Order, s.Order)
+		if len(fields) > 0 {
+			diffs = append(diffs, models.StepDiff{StepID: s.ID, Order: s.Order, Change: models.ChangeModified, Fields: fields})
+		}
+	}
+	for i, s := range old {
+		if !seen[key(s, i)] {
+			diffs = append(diffs, models.StepDiff{StepID: s.ID, Order: s.Order, Change: models.ChangeRemoved})
+		}
+	}
+	return diffs
+}
+
+// automationSignature summarises the authored part of an automation (not run results)
+func automationSignature(a *models.AutomationTest) string {
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	for _, s := range a.Steps {
+		parts = append(parts, s.Action+":"+s.Selector+":"+s.Value)
+	}
+	return a.ID + "|" + strings.Join(parts, ";")
+}
+
+func automationStepCount(a *models.AutomationTest) int {
+	if a == nil {
+		return 0
+	}
+	return len(a.Steps)
+}
+
+func addField[T comparable](fields *[]models.FieldChange, name string, before, after T) {
+	if before != after {
+		*fields = append(*fields, models.FieldChange{Field: name, Old: before, New: after})
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"testing"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffFixture() *models.TestScenario {
+	return &models.TestScenario{
+		ID:    "s1",
+		Title: "Checkout",
+		Sections: []models.TestSection{
+			{
+				ID:    "sec-pay",
+				Title: "Payment",
+				Order: 1,
+				TestCases: []models.TestCase{
+					{
+						ID: "tc-card", Code: "TC-001", Title: "Pay by card", Priority: models.PriorityHigh, Tags: []string{"smoke"},
+						Steps: []models.TestStepV2{
+							{ID: "st-1", Order: 1, Action: "Open checkout"},
+							{ID: "st-2", Order: 2, Action: "Enter card", Data: "4111"},
+							{ID: "st-3", Order: 3, Action: "Pay", Expected: "Order placed"},
+						},
+					},
+					{ID: "tc-transfer", Code: "TC-002", Title: "Pay by transfer"},
+					{ID: "tc-voucher", Code: "TC-003", Title: "Pay with voucher"},
+				},
+			},
+			{
+				ID:    "sec-legacy",
+				Title: "Legacy",
+				Order: 2,
+				TestCases: []models.TestCase{
+					{ID: "tc-old", Code: "TC-004", Title: "Old flow"},
+					{ID: "tc-keep", Code: "TC-005", Title: "Refund"},
+				},
+			},
+		},
+	}
+}
+
+func TestDiffScenariosIdentical(t *testing.T) {
+	diff := DiffScenarios(diffFixture(), diffFixture())
+
+	assert.Empty(t, diff.Fields)
+	assert.Empty(t, diff.Sections)
+	assert.Equal(t, models.DiffSummary{}, diff.Summary)
+}
+
+func TestDiffScenarios(t *testing.T) {
+	from := diffFixture()
+	to := diffFixture()
+
+	to.Title = "Checkout flow"
+
+	pay := &to.Sections[0]
+	pay.Title = "Payments"
+	card := &pay.TestCases[0]
+	card.Priority = models.PriorityCritical
+	card.Tags = []string{"smoke", "regression"}
+	card.Steps = []models.TestStepV2{
+		{ID: "st-1", Order: 1, Action: "Open checkout"},
+		{ID: "st-2", Order: 2, Action: "Enter card", Data: "5555"},
+		{ID: "st-4", Order: 3, Action: "Confirm 3-D Secure"},
+	}
+	// tc-voucher is deleted, tc-transfer is unchanged
+	pay.TestCases = pay.TestCases[:2]
+	pay.TestCases = append(pay.TestCases, models.TestCase{ID: "tc-wallet", Code: "TC-006", Title: "Pay with wallet"})
+
+	// The legacy section goes away; its refund case moves to a new section
+	refund := to.Sections[1].TestCases[1]
+	to.Sections = []models.TestSection{*pay, {
+		ID:        "sec-refund",
+		Title:     "Refunds",
+		Order:     2,
+		TestCases: []models.TestCase{refund},
+	}}
+
+	diff := DiffScenarios(from, to)
+
+	assert.Equal(t, []models.FieldChange{{Field: "title", Old: "Checkout", New: "Checkout flow"}}, diff.Fields)
+	require.Len(t, diff.Sections, 3)
+
+	modified := diff.Sections[0]
+	assert.Equal(t, "sec-pay", modified.SectionID)
+	assert.Equal(t, models.ChangeModified, modified.Change)
+	assert.Equal(t, []models.FieldChange{{Field: "title", Old: "Payment", New: "Payments"}}, modified.Fields)
+	require.Len(t, modified.TestCases, 3)
+
+	cardDiff := modified.TestCases[0]
+	assert.Equal(t, "tc-card", cardDiff.TestCaseID)
+	assert.Equal(t, models.ChangeModified, cardDiff.Change)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "priority", Old: models.PriorityHigh, New: models.PriorityCritical},
+		{Field: "tags", Old: []string{"smoke"}, New: []string{"smoke", "regression"}},
+	}, cardDiff.Fields)
+	assert.Equal(t, []models.StepDiff{
+		{StepID: "st-2", Order: 2, Change: models.ChangeModified, Fields: []models.FieldChange{{Field: "data", Old: "4111", New: "5555"}}},
+		{StepID: "st-4", Order: 3, Change: models.ChangeAdded},
+		{StepID: "st-3", Order: 3, Change: models.ChangeRemoved},
+	}, cardDiff.Steps)
+
+	assert.Equal(t, models.TestCaseDiff{TestCaseID: "tc-wallet", Code: "TC-006", Title: "Pay with wallet", Change: models.ChangeAdded}, modified.TestCases[1])
+	assert.Equal(t, models.TestCaseDiff{TestCaseID: "tc-voucher", Code: "TC-003", Title: "Pay with voucher", Change: models.ChangeRemoved}, modified.TestCases[2])
+
+	added := diff.Sections[1]
+	assert.Equal(t, "sec-refund", added.SectionID)
+	assert.Equal(t, models.ChangeAdded, added.Change)
+	require.Len(t, added.TestCases, 1)
+	assert.Equal(t, models.ChangeMoved, added.TestCases[0].Change)
+	assert.Equal(t, []models.FieldChange{{Field: "section", Old: "sec-legacy", New: "sec-refund"}}, added.TestCases[0].Fields)
+
+	removed := diff.Sections[2]
+	assert.Equal(t, "sec-legacy", removed.SectionID)
+	assert.Equal(t, models.ChangeRemoved, removed.Change)
+	assert.Equal(t, []models.TestCaseDiff{{TestCaseID: "tc-old", Code: "TC-004", Title: "Old flow", Change: models.ChangeRemoved}}, removed.TestCases, "the moved case is not reported as removed")
+
+	assert.Equal(t, models.DiffSummary{
+		SectionsAdded:     1,
+		SectionsRemoved:   1,
+		SectionsModified:  1,
+		TestCasesAdded:    1,
+		TestCasesRemoved:  2,
+		TestCasesModified: 1,
+		TestCasesMoved:    1,
+		StepsChanged:      3,
+	}, diff.Summary)
+}
+
+func TestDiffStepsWithoutIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []models.TestStepV2
+		new  []models.TestStepV2
+		want []models.StepDiff
+	}{
+		{
+			name: "appended step",
+			old:  []models.TestStepV2{{Order: 1, Action: "Open"}},
+			new:  []models.TestStepV2{{Order: 1, Action: "Open"}, {Order: 2, Action: "Save"}},
+			want: []models.StepDiff{{Order: 2, Change: models.ChangeAdded}},
+		},
+		{
+			name: "dropped last step",
+			old:  []models.TestStepV2{{Order: 1, Action: "Open"}, {Order: 2, Action: "Save"}},
+			new:  []models.TestStepV2{{Order: 1, Action: "Open"}},
+			want: []models.StepDiff{{Order: 2, Change: models.ChangeRemoved}},
+		},
+		{
+			name: "changed expectation",
+			old:  []models.TestStepV2{{Order: 1, Action: "Save", Expected: "Saved"}},
+			new:  []models.TestStepV2{{Order: 1, Action: "Save", Expected: "Saved!"}},
+			want: []models.StepDiff{{Order: 1, Change: models.ChangeModified, Fields: []models.FieldChange{{Field: "expected", Old: "Saved", New: "Saved!"}}}},
+		},
+		{
+			name: "unchanged",
+			old:  []models.TestStepV2{{Order: 1, Action: "Open"}},
+			new:  []models.TestStepV2{{Order: 1, Action: "Open"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, diffSteps(tt.old, tt.new))
+		})
+	}
+}
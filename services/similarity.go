@@ -0,0 +1,190 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"qa-extension-backend/internal/models"
+)
+
+// SimilarityDoc is the text of one test case as seen by the similarity index
+type SimilarityDoc struct {
+	ID           string
+	Title        string
+	PreCondition string
+	Steps        []string
+}
+
+// SimilarPair is two documents whose cosine similarity passed the threshold
+type SimilarPair struct {
+	A, B       string
+	Similarity float64
+}
+
+// SimilarityIndex is an offline TF-IDF index over test cases. Vectors are L2
+// normalised so the dot product of two documents is their cosine similarity.
+type SimilarityIndex struct {
+	ids      []string
+	vectors  []map[string]float64
+	postings map[string][]posting
+}
+
+type posting struct {
+	doc    int
+	weight float64
+}
+
+// titleWeight counts title terms more than step text, since copied test cases
+// usually keep the title and drift in the steps
+const titleWeight = 2
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "to": true, "of": true, "in": true,
+	"on": true, "for": true, "with": true, "is": true, "be": true, "as": true, "at": true,
+	"by": true, "it": true, "or": true, "that": true, "this": true, "from": true, "should": true,
+	"then": true, "when": true, "user": true, "yang": true, "dan": true, "di": true, "ke": true,
+}
+
+// TestCaseDoc builds the similarity document for a test case
+func TestCaseDoc(id string, tc models.TestCase) SimilarityDoc {
+	doc := SimilarityDoc{ID: id, Title: tc.Title, PreCondition: tc.PreCondition}
+	for _, s := range tc.Steps {
+		doc.Steps = append(doc.Steps, s.Action+" "+s.Data+" "+s.Expected)
+	}
+	return doc
+}
+
+// NewSimilarityIndex builds the index. Documents are compared on title,
+// precondition and steps.
+func NewSimilarityIndex(docs []SimilarityDoc) *SimilarityIndex {
+	ix := &SimilarityIndex{postings: make(map[string][]posting)}
+
+	termFreqs := make([]map[string]float64, len(docs))
+	docFreq := make(map[string]int)
+	for i, d := range docs {
+		tf := make(map[string]float64)
+		for _, t := range similarityTokens(d.Title) {
+			tf[t] += titleWeight
+		}
+		for _, t := range similarityTokens(d.PreCondition) {
+			tf[t]++
+		}
+		for _, s := range d.Steps {
+			for _, t := range similarityTokens(s) {
+				tf[t]++
+			}
+		}
+		for t := range tf {
+			docFreq[t]++
+		}
+		termFreqs[i] = tf
+		ix.ids = append(ix.ids, d.ID)
+	}
+
+	n := float64(len(docs))
+	for i, tf := range termFreqs {
+		vec := make(map[string]float64, len(tf))
+		var norm float64
+		for t, f := range tf {
+			w := (1 + math.Log(f)) * (math.Log((n+1)/float64(docFreq[t]+1)) + 1)
+			vec[t] = w
+			norm += w * w
+		}
+		norm = math.Sqrt(norm)
+		for t := range vec {
+			if norm > 0 {
+				vec[t] /= norm
+			}
+			ix.postings[t] = append(ix.postings[t], posting{doc: i, weight: vec[t]})
+		}
+		ix.vectors = append(ix.vectors, vec)
+	}
+	return ix
+}
+
+// Pairs returns every pair of documents with similarity >= threshold, most
+// similar first. Scores are accumulated over shared terms only, so documents
+// with nothing in common are never compared.
+func (ix *SimilarityIndex) Pairs(threshold float64) []SimilarPair {
+	var pairs []SimilarPair
+	for i, vec := range ix.vectors {
+		scores := make(map[int]float64)
+		for t, w := range vec {
+			for _, p := range ix.postings[t] {
+				if p.doc > i {
+					scores[p.doc] += w * p.weight
+				}
+			}
+		}
+		for j, s := range scores {
+			if s >= threshold {
+				pairs = append(pairs, SimilarPair{A: ix.ids[i], B: ix.ids[j], Similarity: math.Min(s, 1)})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		return pairs[i].A+pairs[i].B < pairs[j].A+pairs[j].B
+	})
+	return pairs
+}
+
+// ClusterPairs groups documents connected by similar pairs. Clusters are
+// returned largest first, with members in index order.
+func ClusterPairs(pairs []SimilarPair) [][]string {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(x string) string {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for _, p := range pairs {
+		for _, id := range []string{p.A, p.B} {
+			if _, ok := parent[id]; !ok {
+				parent[id] = id
+			}
+		}
+		if ra, rb := find(p.A), find(p.B); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	groups := make(map[string][]string)
+	for id := range parent {
+		root := find(id)
+		groups[root] = append(groups[root], id)
+	}
+
+	clusters := make([][]string, 0, len(groups))
+	for _, members := range groups {
+		sort.Strings(members)
+		clusters = append(clusters, members)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i]) != len(clusters[j]) {
+			return len(clusters[i]) > len(clusters[j])
+		}
+		return clusters[i][0] < clusters[j][0]
+	})
+	return clusters
+}
+
+func similarityTokens(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, f := range fields {
+		if len(f) < 2 || stopwords[f] {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
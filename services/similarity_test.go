@@ -0,0 +1,25 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarityClusters(t *testing.T) {
+	docs := []SimilarityDoc{
+		{ID: "a", Title: "Login with valid credentials", Steps: []string{"Open login page", "Enter email and password", "Dashboard is shown"}},
+		{ID: "b", Title: "Login with valid credential", Steps: []string{"Open the login page", "Enter email and password", "Dashboard is displayed"}},
+		{ID: "c", Title: "Export invoices to CSV", Steps: []string{"Open invoices", "Click export", "CSV file is downloaded"}},
+		{ID: "d", Title: "Delete an invoice", Steps: []string{"Open invoices", "Click delete", "Invoice is removed"}},
+	}
+
+	pairs := NewSimilarityIndex(docs).Pairs(0.6)
+	if assert.Len(t, pairs, 1) {
+		assert.Equal(t, "a", pairs[0].A)
+		assert.Equal(t, "b", pairs[0].B)
+	}
+
+	clusters := ClusterPairs([]SimilarPair{{A: "a", B: "b"}, {A: "b", B: "e"}, {A: "c", B: "d"}})
+	assert.Equal(t, [][]string{{"a", "b", "e"}, {"c", "d"}}, clusters)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// SuiteStatusName is the commit status name of a suite, stable across runs so
+// that each run replaces the previous result: "qa/smoke" for a suite of the
+// "smoke" tag, "qa/suite" when it is not selected by tags
+func SuiteStatusName(run models.SuiteRun) string {
+	if len(run.Tags) == 0 {
+		return "qa/suite"
+	}
+	return "qa/" + strings.Join(run.Tags, "+")
+}
+
+// SuiteNoteMarker identifies the merge request note of a suite, which is
+// updated in place by later runs
+func SuiteNoteMarker(run models.SuiteRun) string {
+	return fmt.Sprintf("<!-- qa-extension:suite-run %s -->", SuiteStatusName(run))
+}
+
+// SuiteCommitState maps a suite run status to a GitLab commit status
+func SuiteCommitState(status models.SuiteRunStatus) gitlab.BuildStateValue {
+	switch status {
+	case models.SuiteRunRunning:
+		return gitlab.Running
+	case models.SuiteRunPassed:
+		return gitlab.Success
+	case models.SuiteRunFailed:
+		return gitlab.Failed
+	}
+	return gitlab.Pending
+}
+
+// SuiteStatusDescription is the short text shown next to a commit status
+func SuiteStatusDescription(run models.SuiteRun) string {
+	switch run.Status {
+	case models.SuiteRunPassed, models.SuiteRunFailed:
+		return fmt.Sprintf("%d passed, %d failed", run.Passed, run.Failed)
+	case models.SuiteRunRunning:
+		return fmt.Sprintf("%d of %d test cases done", run.Passed+run.Failed, run.Total)
+	}
+	return fmt.Sprintf("%d test cases queued", run.Total)
+}
+
+// BuildSuiteRunNote renders a suite run as a merge request note: a summary,
+// a results table, the failed steps and links to the recordings
+func BuildSuiteRunNote(run models.SuiteRun) string {
+	var b strings.Builder
+	b.WriteString(SuiteNoteMarker(run) + "\n")
+
+	icon := map[models.SuiteRunStatus]string{
+		models.SuiteRunPassed:  "✅",
+		models.SuiteRunFailed:  "❌",
+		models.SuiteRunRunning: "⏳",
+		models.SuiteRunQueued:  "⏳",
+	}
+	fmt.Fprintf(&b, "### %s QA suite `%s` %s\n\n", icon[run.Status], SuiteStatusName(run), run.Status)
+	fmt.Fprintf(&b, "**%d passed, %d failed** of %d test case%s", run.Passed, run.Failed, run.Total, plural(run.Total))
+	if run.Ref != nil && run.Ref.CommitSHA != "" {
+		fmt.Fprintf(&b, " · commit %s", shortSHA(run.Ref.CommitSHA))
+	}
+	if run.Environment != nil {
+		fmt.Fprintf(&b, " · %s", run.Environment.Name)
+	}
+	if run.FinishedAt != nil {
+		fmt.Fprintf(&b, " · %s", run.FinishedAt.Sub(run.CreatedAt).Round(time.Second))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("| | Test case | Scenario | Duration | Artifacts |\n|---|---|---|---|---|\n")
+	for _, res := range run.Results {
+		duration := ""
+		if res.DurationMs > 0 {
+			duration = fmt.Sprintf("%.1fs", float64(res.DurationMs)/1000)
+		}
+		fmt.Fprintf(&b, "| %s | %s %s | %s | %s | %s |\n",
+			icon[res.Status], res.Code, tableCell(res.Title), tableCell(res.ScenarioTitle), duration, artifactLinks(res))
+	}
+
+	var failed []models.SuiteCaseResult
+	for _, res := range run.Results {
+		if res.Status == models.SuiteRunFailed {
+			failed = append(failed, res)
+		}
+	}
+	if len(failed) > 0 {
+		b.WriteString("\n#### Failed steps\n\n")
+		for _, res := range failed {
+			fmt.Fprintf(&b, "- **%s %s**", res.Code, res.Title)
+			if res.FailedStepIndex != nil {
+				fmt.Fprintf(&b, " at step %d", *res.FailedStepIndex+1)
+				if res.FailedStep != "" {
+					fmt.Fprintf(&b, " (%s)", res.FailedStep)
+				}
+			}
+			if res.Error != "" {
+				fmt.Fprintf(&b, ": `%s`", strings.ReplaceAll(firstLine(res.Error), "`", "'"))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func artifactLinks(res models.SuiteCaseResult) string {
+	var links []string
+	if isLink(res.VideoURL) {
+		links = append(links, fmt.Sprintf("[video](%s)", res.VideoURL))
+	}
+	if isLink(res.ScreenshotURL) {
+		links = append(links, fmt.Sprintf("[screenshot](%s)", res.ScreenshotURL))
+	}
+	return strings.Join(links, " · ")
+}
+
+func isLink(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// tableCell keeps text from breaking a markdown table row
+func tableCell(s string) string {
+	return strings.ReplaceAll(firstLine(s), "|", "\\|")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestSuiteStatusName(t *testing.T) {
+	assert.Equal(t, "qa/smoke", SuiteStatusName(models.SuiteRun{Tags: []string{"smoke"}}))
+	assert.Equal(t, "qa/suite", SuiteStatusName(models.SuiteRun{ScenarioIDs: []string{"s1"}}))
+	assert.Equal(t, gitlab.Success, SuiteCommitState(models.SuiteRunPassed))
+	assert.Equal(t, gitlab.Pending, SuiteCommitState(models.SuiteRunQueued))
+}
+
+func TestBuildSuiteRunNote(t *testing.T) {
+	step := 2
+	created := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	finished := created.Add(95 * time.Second)
+	run := models.SuiteRun{
+		Tags:        []string{"smoke"},
+		Ref:         &models.SuiteRunRef{CommitSHA: "9f3c2a1b7e0d", MergeRequestIID: 7},
+		Environment: &models.EnvironmentRef{Name: "staging"},
+		Results: []models.SuiteCaseResult{
+			{Code: "TC-001", Title: "Log in", ScenarioTitle: "Auth", Status: models.SuiteRunPassed, DurationMs: 4200, VideoURL: "https://cdn.example.com/v1.webm"},
+			{Code: "TC-002", Title: "Pay | refund", ScenarioTitle: "Checkout", Status: models.SuiteRunFailed,
+				FailedStepIndex: &step, FailedStep: "Click pay", Error: "timeout waiting for `#pay`\nstack", ScreenshotURL: "data:image/png;base64,AAA"},
+		},
+		CreatedAt:  created,
+		FinishedAt: &finished,
+	}
+	run.Tally()
+
+	note := BuildSuiteRunNote(run)
+	assert.Contains(t, note, SuiteNoteMarker(run))
+	assert.Contains(t, note, "QA suite `qa/smoke` failed")
+	assert.Contains(t, note, "**1 passed, 1 failed** of 2 test cases · commit 9f3c2a1b · staging · 1m35s")
+	assert.Contains(t, note, "| ✅ | TC-001 Log in | Auth | 4.2s | [video](https://cdn.example.com/v1.webm) |")
+	assert.Contains(t, note, "TC-002 Pay \\| refund", "pipes are escaped inside the table")
+	assert.NotContains(t, note, "[screenshot]", "inline screenshots are not linked")
+	assert.Contains(t, note, "- **TC-002 Pay | refund** at step 3 (Click pay): `timeout waiting for '#pay'`")
+}
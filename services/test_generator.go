@@ -464,6 +464,7 @@ All Available Selectors in this file:
    - Login URL: %s
    - Username: %s
    - Password: %s
+   - Credentials in {{credential:...}} form are placeholders: type them exactly as written, the runner substitutes the real values.
 3. SELECTOR RULES (CRITICAL):
    - Use ONLY selectors from the "Available Selectors" or "All Available Selectors" sections
    - Pick the BEST selector based on:
@@ -497,7 +498,7 @@ All Available Selectors in this file:
 
 ### RESPONSE FORMAT:
 Return ONLY a JSON array of automation test objects.`, 
-		authConfig.BaseURL, authConfig.LoginURL, authConfig.Username, authConfig.Password,
+		authConfig.BaseURL, authConfig.LoginURL, authConfig.UsernameValue(), authConfig.PromptPassword(),
 		strings.Join(routeContexts, "\n\n"),
 		selectorSummary,
 		formatCodebaseContext(codebaseCtx),
@@ -0,0 +1,425 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"qa-extension-backend/internal/models"
+)
+
+// A TestReport is a suite run, a scenario's run history or a test cycle in
+// the shape the export formats share: sections become test suites, test cases
+// become cases, failed steps become the failure, and videos and screenshots
+// become attachments. The JUnit, Allure and CTRF writers render it.
+
+// ReportStatus is the outcome of a case or step in a report
+type ReportStatus string
+
+const (
+	ReportPassed  ReportStatus = "passed"
+	ReportFailed  ReportStatus = "failed"
+	ReportSkipped ReportStatus = "skipped"
+	// ReportPending cases have not finished, e.g. in a suite run still running
+	ReportPending ReportStatus = "pending"
+)
+
+// Report export formats
+const (
+	ReportFormatJUnit  = "junit"
+	ReportFormatAllure = "allure"
+	ReportFormatCTRF   = "ctrf"
+)
+
+// ReportFormats lists the formats a report can be exported in
+var ReportFormats = []string{ReportFormatJUnit, ReportFormatAllure, ReportFormatCTRF}
+
+// TestReport is one exported run
+type TestReport struct {
+	Name        string
+	Environment string
+	Build       string
+	Branch      string
+	Commit      string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	// Properties are further key/value pairs describing the run
+	Properties []ReportProperty
+	Suites     []ReportSuite
+}
+
+// ReportProperty is a key/value pair describing a run
+type ReportProperty struct {
+	Name  string
+	Value string
+}
+
+// ReportSuite groups the cases of one section
+type ReportSuite struct {
+	Name  string
+	Cases []ReportCase
+}
+
+// ReportCase is one test case run
+type ReportCase struct {
+	// ID identifies the test case across runs
+	ID     string
+	Name   string
+	Status ReportStatus
+	// Message says why the case failed or was skipped; Trace has the details
+	Message     string
+	Trace       string
+	StartedAt   time.Time
+	DurationMs  int64
+	Tags        []string
+	Steps       []ReportStep
+	Attachments []ReportAttachment
+}
+
+// ReportStep is the result of one automation step
+type ReportStep struct {
+	Name        string
+	Status      ReportStatus
+	Message     string
+	Attachments []ReportAttachment
+}
+
+// Attachment kinds, as used by test cycle evidence
+const (
+	AttachmentVideo      = "video"
+	AttachmentScreenshot = "screenshot"
+	AttachmentLog        = "log"
+	AttachmentLink       = "link"
+)
+
+// ReportAttachment is evidence of a case or step. URL is a link or, for
+// screenshots taken inline, a data URL.
+type ReportAttachment struct {
+	Name string
+	Kind string
+	URL  string
+}
+
+// ContentType guesses the MIME type of an attachment
+func (a ReportAttachment) ContentType() string {
+	if rest, ok := strings.CutPrefix(a.URL, "data:"); ok {
+		if mediaType, _, ok := strings.Cut(rest, ";"); ok && mediaType != "" {
+			return mediaType
+		}
+	}
+	if ext := path.Ext(strings.SplitN(a.URL, "?", 2)[0]); ext != "" {
+		if t := mime.TypeByExtension(ext); t != "" {
+			return strings.SplitN(t, ";", 2)[0]
+		}
+	}
+	switch a.Kind {
+	case AttachmentVideo:
+		return "video/webm"
+	case AttachmentScreenshot:
+		return "image/png"
+	case AttachmentLog:
+		return "text/plain"
+	}
+	return "text/uri-list"
+}
+
+// suiteBuilder groups cases into suites in first-seen order
+type suiteBuilder struct {
+	suites []ReportSuite
+	index  map[string]int
+}
+
+func (b *suiteBuilder) add(key, name string, c ReportCase) {
+	if b.index == nil {
+		b.index = map[string]int{}
+	}
+	i, ok := b.index[key]
+	if !ok {
+		i = len(b.suites)
+		b.index[key] = i
+		b.suites = append(b.suites, ReportSuite{Name: name})
+	}
+	b.suites[i].Cases = append(b.suites[i].Cases, c)
+}
+
+func caseName(code, title string) string {
+	return strings.TrimSpace(code + " " + title)
+}
+
+func suiteName(scenario, section string) string {
+	switch {
+	case section == "":
+		return scenario
+	case scenario == "":
+		return section
+	}
+	return scenario + " › " + section
+}
+
+// SuiteRunReport builds the report of a suite run, one suite per scenario
+// section. Cases that did not finish are pending.
+func SuiteRunReport(run models.SuiteRun) TestReport {
+	report := TestReport{
+		Name:       run.Name,
+		StartedAt:  run.CreatedAt,
+		Properties: []ReportProperty{{Name: "suiteRunId", Value: run.ID}, {Name: "projectId", Value: run.ProjectID}},
+	}
+	if report.Name == "" {
+		report.Name = "Suite run " + run.ID
+	}
+	if run.FinishedAt != nil {
+		report.FinishedAt = *run.FinishedAt
+	}
+	if run.Environment != nil {
+		report.Environment = run.Environment.Name
+	}
+	if ref := run.Ref; ref != nil {
+		report.Branch, report.Commit = ref.Branch, ref.CommitSHA
+		if ref.MergeRequestIID > 0 {
+			report.Properties = append(report.Properties, ReportProperty{Name: "mergeRequestIid", Value: fmt.Sprint(ref.MergeRequestIID)})
+		}
+		if ref.PipelineID > 0 {
+			report.Build = fmt.Sprint(ref.PipelineID)
+		}
+	}
+	if len(run.Tags) > 0 {
+		report.Properties = append(report.Properties, ReportProperty{Name: "tags", Value: strings.Join(run.Tags, ",")})
+	}
+
+	var suites suiteBuilder
+	for _, res := range run.Results {
+		c := ReportCase{
+			ID:         res.TestCaseID,
+			Name:       caseName(res.Code, res.Title),
+			StartedAt:  run.CreatedAt,
+			DurationMs: res.DurationMs,
+		}
+		switch res.Status {
+		case models.SuiteRunPassed:
+			c.Status = ReportPassed
+		case models.SuiteRunFailed:
+			c.Status = ReportFailed
+			c.Message = firstLine(res.Error)
+			c.Trace = res.Error
+			if res.FailedStepIndex != nil {
+				c.Trace = fmt.Sprintf("Failed at step %d", *res.FailedStepIndex+1)
+				if res.FailedStep != "" {
+					c.Trace += " (" + res.FailedStep + ")"
+				}
+				c.Trace += ":\n" + res.Error
+			}
+		default:
+			c.Status = ReportPending
+			c.Message = "did not run to completion"
+		}
+		if res.VideoURL != "" {
+			c.Attachments = append(c.Attachments, ReportAttachment{Name: "Video", Kind: AttachmentVideo, URL: res.VideoURL})
+		}
+		if res.ScreenshotURL != "" {
+			c.Attachments = append(c.Attachments, ReportAttachment{Name: "Screenshot", Kind: AttachmentScreenshot, URL: res.ScreenshotURL})
+		}
+		suites.add(res.ScenarioID+"|"+res.SectionID, suiteName(res.ScenarioTitle, res.SectionTitle), c)
+	}
+	report.Suites = suites.suites
+	return report
+}
+
+// ScenarioReport builds the report of a scenario's automation runs, one suite
+// per section. history holds the stored results of each automation, newest
+// first, keyed by automation ID; a case without stored results is reported
+// from the last run kept on its automation. Cases never run are skipped.
+func ScenarioReport(scenario models.TestScenario, history map[string][]models.TestResult) TestReport {
+	report := TestReport{
+		Name:       scenario.Title,
+		Properties: []ReportProperty{{Name: "scenarioId", Value: scenario.ID}, {Name: "projectId", Value: scenario.ProjectID}},
+	}
+
+	var suites suiteBuilder
+	for _, sec := range scenario.Sections {
+		for _, tc := range sec.TestCases {
+			name := caseName(tc.Code, tc.Title)
+			auto := tc.AutomationTest
+			results := []models.TestResult(nil)
+			if auto != nil {
+				results = history[auto.ID]
+			}
+
+			var cases []ReportCase
+			switch {
+			case len(results) > 0:
+				for _, res := range results {
+					c := automationCase(tc, auto.Steps, res.Status == "passed", res.StepResults, "", res.RunDurationMs)
+					c.Attachments = append(c.Attachments, videoAttachment(res.VideoURL)...)
+					if res.RanAt != nil {
+						c.StartedAt = *res.RanAt
+						if len(results) > 1 {
+							c.Name = fmt.Sprintf("%s @ %s", name, res.RanAt.UTC().Format(time.RFC3339))
+						}
+					}
+					if res.Environment != nil && report.Environment == "" {
+						report.Environment = res.Environment.Name
+					}
+					cases = append(cases, c)
+				}
+			case auto != nil && (auto.Status == models.AutomationStatusPass || auto.Status == models.AutomationStatusFail):
+				c := automationCase(tc, auto.Steps, auto.Status == models.AutomationStatusPass, auto.StepResults, auto.ErrorMessage, auto.RunDurationMs)
+				c.Attachments = append(c.Attachments, videoAttachment(auto.VideoURL)...)
+				if auto.ScreenshotURL != "" {
+					c.Attachments = append(c.Attachments, ReportAttachment{Name: "Screenshot", Kind: AttachmentScreenshot, URL: auto.ScreenshotURL})
+				}
+				if t, err := time.Parse(time.RFC3339, auto.LastRunAt); err == nil {
+					c.StartedAt = t
+				}
+				if auto.Environment != nil && report.Environment == "" {
+					report.Environment = auto.Environment.Name
+				}
+				cases = append(cases, c)
+			default:
+				c := ReportCase{ID: tc.ID, Name: name, Tags: tc.Tags, Status: ReportSkipped, Message: "not automated"}
+				if auto != nil && len(auto.Steps) > 0 {
+					c.Message = "never run"
+				}
+				cases = append(cases, c)
+			}
+
+			for _, c := range cases {
+				suites.add(sec.ID, suiteName(scenario.Title, sec.Title), c)
+				if !c.StartedAt.IsZero() {
+					if report.StartedAt.IsZero() || c.StartedAt.Before(report.StartedAt) {
+						report.StartedAt = c.StartedAt
+					}
+					if end := c.StartedAt.Add(time.Duration(c.DurationMs) * time.Millisecond); end.After(report.FinishedAt) {
+						report.FinishedAt = end
+					}
+				}
+			}
+		}
+	}
+	report.Suites = suites.suites
+	return report
+}
+
+// automationCase reports one automation run. Every failed step result is
+// listed in the trace; the first one is the failure message.
+func automationCase(tc models.TestCase, steps []models.RecordingStep, passed bool, results []models.TestStepResult, errorMessage string, durationMs int64) ReportCase {
+	c := ReportCase{ID: tc.ID, Name: caseName(tc.Code, tc.Title), Tags: tc.Tags, DurationMs: durationMs, Status: ReportPassed}
+	if !passed {
+		c.Status = ReportFailed
+	}
+
+	var failures []string
+	for _, sr := range results {
+		step := ReportStep{Name: reportStepName(steps, sr.StepIndex), Status: ReportPassed}
+		if sr.Screenshot != "" {
+			step.Attachments = []ReportAttachment{{Name: fmt.Sprintf("Step %d screenshot", sr.StepIndex+1), Kind: AttachmentScreenshot, URL: sr.Screenshot}}
+		}
+		if sr.Status == "failure" {
+			step.Status = ReportFailed
+			step.Message = sr.Error
+			failures = append(failures, fmt.Sprintf("Step %d (%s): %s", sr.StepIndex+1, step.Name, sr.Error))
+			if c.Message == "" {
+				c.Message = firstLine(sr.Error)
+			}
+			c.Attachments = append(c.Attachments, step.Attachments...)
+		}
+		c.Steps = append(c.Steps, step)
+	}
+	if !passed {
+		if c.Message == "" {
+			c.Message = firstLine(errorMessage)
+		}
+		if c.Message == "" {
+			c.Message = "failed"
+		}
+		c.Trace = strings.Join(failures, "\n")
+		if c.Trace == "" {
+			c.Trace = errorMessage
+		}
+	}
+	return c
+}
+
+func reportStepName(steps []models.RecordingStep, i int) string {
+	if i < 0 || i >= len(steps) {
+		return fmt.Sprintf("Step %d", i+1)
+	}
+	if steps[i].Description != "" {
+		return steps[i].Description
+	}
+	return steps[i].Action
+}
+
+func videoAttachment(url string) []ReportAttachment {
+	if url == "" {
+		return nil
+	}
+	return []ReportAttachment{{Name: "Video", Kind: AttachmentVideo, URL: url}}
+}
+
+// TestCycleReport builds the report of a test cycle, one suite per section.
+// Blocked cases are skipped and cases not run yet are pending.
+func TestCycleReport(cycle models.TestCycle) TestReport {
+	report := TestReport{
+		Name:        cycle.Name,
+		Environment: cycle.Environment,
+		Build:       cycle.Build,
+		StartedAt:   cycle.CreatedAt,
+		Properties: []ReportProperty{
+			{Name: "testCycleId", Value: cycle.ID},
+			{Name: "projectId", Value: cycle.ProjectID},
+			{Name: "scenario", Value: cycle.ScenarioTitle},
+		},
+	}
+	if cycle.SignOff != nil {
+		report.FinishedAt = cycle.SignOff.SignedAt
+		report.Properties = append(report.Properties, ReportProperty{Name: "signOff", Value: cycle.SignOff.Decision})
+	}
+
+	var suites suiteBuilder
+	for _, e := range cycle.Executions {
+		c := ReportCase{ID: e.TestCaseID, Name: caseName(e.Code, e.Title), Message: e.Comment}
+		switch e.Status {
+		case models.ExecutionPassed:
+			c.Status = ReportPassed
+		case models.ExecutionFailed:
+			c.Status = ReportFailed
+			c.Trace = e.Comment
+			c.Message = firstLine(e.Comment)
+			if c.Message == "" {
+				c.Message = "marked as failed"
+			}
+		case models.ExecutionBlocked:
+			c.Status = ReportSkipped
+			c.Message = strings.TrimSpace("blocked " + e.Comment)
+		case models.ExecutionSkipped:
+			c.Status = ReportSkipped
+		default:
+			c.Status = ReportPending
+			if c.Message == "" {
+				c.Message = "not run"
+			}
+		}
+		if e.ExecutedAt != nil {
+			c.StartedAt = *e.ExecutedAt
+			if report.FinishedAt.Before(c.StartedAt) && cycle.SignOff == nil {
+				report.FinishedAt = c.StartedAt
+			}
+		}
+		for _, ev := range e.Evidence {
+			name := ev.Name
+			if name == "" {
+				name = ev.Type
+			}
+			c.Attachments = append(c.Attachments, ReportAttachment{Name: name, Kind: ev.Type, URL: ev.URL})
+		}
+		section := e.SectionTitle
+		if section == "" {
+			section = cycle.ScenarioTitle
+		}
+		suites.add(e.SectionID, section, c)
+	}
+	report.Suites = suites.suites
+	return report
+}
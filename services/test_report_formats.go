@@ -0,0 +1,550 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// reportTool names this backend in exported reports
+const reportTool = "qa-extension"
+
+// RenderTestReport renders a report in one of ReportFormats and returns the
+// file name and content type to serve it with
+func RenderTestReport(r TestReport, format string) (data []byte, fileName, contentType string, err error) {
+	baseName := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(r.Name), "-"), "-")
+	if baseName == "" {
+		baseName = "report"
+	}
+	switch format {
+	case ReportFormatJUnit, "":
+		data, err = WriteJUnitReport(r)
+		return data, baseName + "-junit.xml", "application/xml", err
+	case ReportFormatAllure:
+		data, err = WriteAllureResults(r)
+		return data, baseName + "-allure-results.zip", "application/zip", err
+	case ReportFormatCTRF:
+		data, err = WriteCTRFReport(r)
+		return data, baseName + "-ctrf.json", "application/json", err
+	}
+	return nil, "", "", fmt.Errorf("unknown format %q; use one of %s", format, strings.Join(ReportFormats, ", "))
+}
+
+func seconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+// ─────────────────────────────────────────────
+// JUnit XML
+// ─────────────────────────────────────────────
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Cases      []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnitReport renders a report as JUnit XML in the subset GitLab's unit
+// test report reads. Pending cases are skipped. Linked attachments are listed
+// in system-out with the [[ATTACHMENT|...]] convention; inline screenshots
+// are left out.
+func WriteJUnitReport(r TestReport) ([]byte, error) {
+	out := junitTestSuites{Name: r.Name}
+	var props *junitProperties
+	if list := reportProperties(r); len(list) > 0 {
+		props = &junitProperties{}
+		for _, p := range list {
+			props.Properties = append(props.Properties, junitProperty{Name: p.Name, Value: p.Value})
+		}
+	}
+
+	var totalMs int64
+	for _, suite := range r.Suites {
+		js := junitTestSuite{Name: suite.Name, Properties: props}
+		if !r.StartedAt.IsZero() {
+			js.Timestamp = r.StartedAt.UTC().Format("2006-01-02T15:04:05")
+		}
+		var suiteMs int64
+		for _, c := range suite.Cases {
+			tc := junitTestCase{ClassName: suite.Name, Name: c.Name, Time: seconds(c.DurationMs)}
+			var lines []string
+			for _, a := range c.Attachments {
+				if isLink(a.URL) {
+					lines = append(lines, fmt.Sprintf("%s: [[ATTACHMENT|%s]]", a.Name, a.URL))
+				}
+			}
+			tc.SystemOut = strings.Join(lines, "\n")
+			switch c.Status {
+			case ReportFailed:
+				tc.Failure = &junitFailure{Message: c.Message, Type: "AssertionError", Body: c.Trace}
+				js.Failures++
+			case ReportSkipped, ReportPending:
+				tc.Skipped = &junitSkipped{Message: c.Message}
+				js.Skipped++
+			}
+			js.Cases = append(js.Cases, tc)
+			js.Tests++
+			suiteMs += c.DurationMs
+		}
+		js.Time = seconds(suiteMs)
+		totalMs += suiteMs
+		out.Tests += js.Tests
+		out.Failures += js.Failures
+		out.Skipped += js.Skipped
+		out.Suites = append(out.Suites, js)
+	}
+	out.Time = seconds(totalMs)
+
+	data, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// reportProperties lists the report's metadata as name/value pairs
+func reportProperties(r TestReport) []ReportProperty {
+	var list []ReportProperty
+	for _, p := range []ReportProperty{
+		{Name: "environment", Value: r.Environment},
+		{Name: "build", Value: r.Build},
+		{Name: "branch", Value: r.Branch},
+		{Name: "commit", Value: r.Commit},
+	} {
+		if p.Value != "" {
+			list = append(list, p)
+		}
+	}
+	for _, p := range r.Properties {
+		if p.Value != "" {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// ─────────────────────────────────────────────
+// Allure results
+// ─────────────────────────────────────────────
+
+type allureResult struct {
+	UUID          string             `json:"uuid"`
+	HistoryID     string             `json:"historyId"`
+	TestCaseID    string             `json:"testCaseId"`
+	FullName      string             `json:"fullName"`
+	Name          string             `json:"name"`
+	Status        string             `json:"status"`
+	StatusDetails *allureDetails     `json:"statusDetails,omitempty"`
+	Stage         string             `json:"stage"`
+	Start         int64              `json:"start,omitempty"`
+	Stop          int64              `json:"stop,omitempty"`
+	Labels        []allureLabel      `json:"labels"`
+	Links         []allureLink       `json:"links,omitempty"`
+	Steps         []allureStep       `json:"steps,omitempty"`
+	Attachments   []allureAttachment `json:"attachments,omitempty"`
+}
+
+type allureDetails struct {
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+}
+
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Type string `json:"type,omitempty"`
+}
+
+type allureStep struct {
+	Name          string             `json:"name"`
+	Status        string             `json:"status"`
+	StatusDetails *allureDetails     `json:"statusDetails,omitempty"`
+	Stage         string             `json:"stage"`
+	Attachments   []allureAttachment `json:"attachments,omitempty"`
+}
+
+type allureAttachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// allureStatus maps a report status; Allure has no pending, unknown is closest
+func allureStatus(s ReportStatus) string {
+	if s == ReportPending {
+		return "unknown"
+	}
+	return string(s)
+}
+
+// allureFiles collects the files of an Allure results directory
+type allureFiles struct {
+	names []string
+	files map[string][]byte
+}
+
+func (f *allureFiles) add(name string, data []byte) {
+	if f.files == nil {
+		f.files = map[string][]byte{}
+	}
+	f.names = append(f.names, name)
+	f.files[name] = data
+}
+
+// attach stores inline attachments as files and returns them; linked
+// attachments are returned as links because Allure only shows local files
+func (f *allureFiles) attach(list []ReportAttachment) ([]allureAttachment, []allureLink) {
+	var attachments []allureAttachment
+	var links []allureLink
+	for _, a := range list {
+		if isLink(a.URL) {
+			links = append(links, allureLink{Name: a.Name, URL: a.URL})
+			continue
+		}
+		data, contentType, ok := decodeDataURL(a.URL)
+		if !ok {
+			continue
+		}
+		ext := ".bin"
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			ext = exts[0]
+		}
+		source := uuid.NewString() + "-attachment" + ext
+		f.add(source, data)
+		attachments = append(attachments, allureAttachment{Name: a.Name, Source: source, Type: contentType})
+	}
+	return attachments, links
+}
+
+// decodeDataURL decodes a base64 data URL, such as an inline screenshot
+func decodeDataURL(s string) ([]byte, string, bool) {
+	rest, ok := strings.CutPrefix(s, "data:")
+	if !ok {
+		return nil, "", false
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return nil, "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", false
+	}
+	return data, strings.TrimSuffix(meta, ";base64"), true
+}
+
+// WriteAllureResults renders a report as a zipped Allure results directory,
+// one result file per case, for `allure generate`
+func WriteAllureResults(r TestReport) ([]byte, error) {
+	var files allureFiles
+	for _, suite := range r.Suites {
+		for _, c := range suite.Cases {
+			sum := sha256.Sum256([]byte(suite.Name + "|" + c.ID + "|" + c.Name))
+			res := allureResult{
+				UUID:       uuid.NewString(),
+				HistoryID:  hex.EncodeToString(sum[:16]),
+				TestCaseID: c.ID,
+				FullName:   suite.Name + ": " + c.Name,
+				Name:       c.Name,
+				Status:     allureStatus(c.Status),
+				Stage:      "finished",
+				Labels: []allureLabel{
+					{Name: "suite", Value: suite.Name},
+					{Name: "parentSuite", Value: r.Name},
+					{Name: "framework", Value: reportTool},
+				},
+			}
+			if c.Message != "" || c.Trace != "" {
+				res.StatusDetails = &allureDetails{Message: c.Message, Trace: c.Trace}
+			}
+			if !c.StartedAt.IsZero() {
+				res.Start = c.StartedAt.UnixMilli()
+				res.Stop = c.StartedAt.Add(time.Duration(c.DurationMs) * time.Millisecond).UnixMilli()
+			}
+			for _, tag := range c.Tags {
+				res.Labels = append(res.Labels, allureLabel{Name: "tag", Value: tag})
+			}
+			for _, step := range c.Steps {
+				as := allureStep{Name: step.Name, Status: allureStatus(step.Status), Stage: "finished"}
+				if step.Message != "" {
+					as.StatusDetails = &allureDetails{Message: step.Message}
+				}
+				as.Attachments, _ = files.attach(step.Attachments)
+				res.Steps = append(res.Steps, as)
+			}
+			// Step screenshots are already attached to their steps
+			var caseAttachments []ReportAttachment
+			for _, a := range c.Attachments {
+				if isLink(a.URL) || len(c.Steps) == 0 || a.Kind != AttachmentScreenshot {
+					caseAttachments = append(caseAttachments, a)
+				}
+			}
+			res.Attachments, res.Links = files.attach(caseAttachments)
+
+			data, err := json.MarshalIndent(res, "", "  ")
+			if err != nil {
+				return nil, err
+			}
+			files.add(res.UUID+"-result.json", data)
+		}
+	}
+
+	var env strings.Builder
+	for _, p := range reportProperties(r) {
+		fmt.Fprintf(&env, "%s=%s\n", p.Name, strings.ReplaceAll(p.Value, "\n", " "))
+	}
+	if env.Len() > 0 {
+		files.add("environment.properties", []byte(env.String()))
+	}
+	executor, err := json.Marshal(map[string]string{"name": reportTool, "type": reportTool, "buildName": r.Name})
+	if err != nil {
+		return nil, err
+	}
+	files.add("executor.json", executor)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range files.names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files.files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ─────────────────────────────────────────────
+// CTRF JSON
+// ─────────────────────────────────────────────
+
+type ctrfReport struct {
+	ReportFormat string      `json:"reportFormat"`
+	SpecVersion  string      `json:"specVersion"`
+	Results      ctrfResults `json:"results"`
+}
+
+type ctrfResults struct {
+	Tool        ctrfTool          `json:"tool"`
+	Summary     ctrfSummary       `json:"summary"`
+	Tests       []ctrfTest        `json:"tests"`
+	Environment map[string]string `json:"environment,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type ctrfTool struct {
+	Name string `json:"name"`
+}
+
+type ctrfSummary struct {
+	Tests   int   `json:"tests"`
+	Passed  int   `json:"passed"`
+	Failed  int   `json:"failed"`
+	Pending int   `json:"pending"`
+	Skipped int   `json:"skipped"`
+	Other   int   `json:"other"`
+	Start   int64 `json:"start"`
+	Stop    int64 `json:"stop"`
+}
+
+type ctrfTest struct {
+	Name        string           `json:"name"`
+	Status      string           `json:"status"`
+	Duration    int64            `json:"duration"`
+	Start       int64            `json:"start,omitempty"`
+	Stop        int64            `json:"stop,omitempty"`
+	Suite       string           `json:"suite,omitempty"`
+	Message     string           `json:"message,omitempty"`
+	Trace       string           `json:"trace,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	Steps       []ctrfStep       `json:"steps,omitempty"`
+	Attachments []ctrfAttachment `json:"attachments,omitempty"`
+	Extra       map[string]any   `json:"extra,omitempty"`
+}
+
+type ctrfStep struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type ctrfAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Path        string `json:"path"`
+}
+
+// WriteCTRFReport renders a report as CTRF JSON. Attachments are referenced
+// by URL; inline screenshots are left out.
+func WriteCTRFReport(r TestReport) ([]byte, error) {
+	out := ctrfReport{
+		ReportFormat: "CTRF",
+		SpecVersion:  "0.0.0",
+		Results: ctrfResults{
+			Tool:  ctrfTool{Name: reportTool},
+			Tests: []ctrfTest{},
+		},
+	}
+
+	env := map[string]string{}
+	for key, v := range map[string]string{
+		"reportName":      r.Name,
+		"testEnvironment": r.Environment,
+		"buildName":       r.Build,
+		"branchName":      r.Branch,
+		"commit":          r.Commit,
+	} {
+		if v != "" {
+			env[key] = v
+		}
+	}
+	if len(env) > 0 {
+		out.Results.Environment = env
+	}
+	if len(r.Properties) > 0 {
+		out.Results.Extra = map[string]string{}
+		for _, p := range r.Properties {
+			if p.Value != "" {
+				out.Results.Extra[p.Name] = p.Value
+			}
+		}
+	}
+
+	summary := &out.Results.Summary
+	for _, suite := range r.Suites {
+		for _, c := range suite.Cases {
+			t := ctrfTest{
+				Name:     c.Name,
+				Status:   string(c.Status),
+				Duration: c.DurationMs,
+				Suite:    suite.Name,
+				Message:  c.Message,
+				Trace:    c.Trace,
+				Tags:     c.Tags,
+				Extra:    map[string]any{"testCaseId": c.ID},
+			}
+			if !c.StartedAt.IsZero() {
+				t.Start = c.StartedAt.UnixMilli()
+				t.Stop = t.Start + c.DurationMs
+			}
+			for _, step := range c.Steps {
+				t.Steps = append(t.Steps, ctrfStep{Name: step.Name, Status: string(step.Status)})
+			}
+			for _, a := range c.Attachments {
+				if isLink(a.URL) {
+					t.Attachments = append(t.Attachments, ctrfAttachment{Name: a.Name, ContentType: a.ContentType(), Path: a.URL})
+				}
+			}
+			out.Results.Tests = append(out.Results.Tests, t)
+
+			summary.Tests++
+			switch c.Status {
+			case ReportPassed:
+				summary.Passed++
+			case ReportFailed:
+				summary.Failed++
+			case ReportSkipped:
+				summary.Skipped++
+			case ReportPending:
+				summary.Pending++
+			default:
+				summary.Other++
+			}
+		}
+	}
+	summary.Start, summary.Stop = reportSpan(r, out.Results.Tests)
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// reportSpan is the start and end of a report in unix milliseconds, taken
+// from the cases when the report has no times of its own
+func reportSpan(r TestReport, tests []ctrfTest) (int64, int64) {
+	var start, stop int64
+	if !r.StartedAt.IsZero() {
+		start = r.StartedAt.UnixMilli()
+	}
+	if !r.FinishedAt.IsZero() {
+		stop = r.FinishedAt.UnixMilli()
+	}
+	starts := make([]int64, 0, len(tests))
+	for _, t := range tests {
+		if t.Start > 0 {
+			starts = append(starts, t.Start)
+		}
+		if t.Stop > stop {
+			stop = t.Stop
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	if start == 0 && len(starts) > 0 {
+		start = starts[0]
+	}
+	if stop < start {
+		stop = start
+	}
+	return start, stop
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestScenario() models.TestScenario {
+	failed := 1
+	return models.TestScenario{
+		ID:    "s1",
+		Title: "Checkout",
+		Sections: []models.TestSection{
+			{ID: "sec1", Title: "Payment", TestCases: []models.TestCase{
+				{ID: "tc1", Code: "TC-001", Title: "Pay by card", Tags: []string{"smoke"}, AutomationTest: &models.AutomationTest{
+					ID:     "auto1",
+					Status: models.AutomationStatusFail,
+					Steps:  []models.RecordingStep{{Action: "navigate"}, {Action: "click", Description: "Click pay"}},
+					StepResults: []models.TestStepResult{
+						{StepIndex: 0, Status: "success"},
+						{StepIndex: 1, Status: "failure", Error: "element #pay not found\nat click", Screenshot: "data:image/png;base64,iVBORw0KGgo="},
+					},
+					FailedStepIndex: &failed,
+					ErrorMessage:    "element #pay not found",
+					VideoURL:        "https://cdn.example.com/run.webm",
+					RunDurationMs:   3200,
+					LastRunAt:       "2026-05-01T10:00:00Z",
+				}},
+				{ID: "tc2", Code: "TC-002", Title: "Refund"},
+			}},
+		},
+	}
+}
+
+func TestScenarioReport(t *testing.T) {
+	report := ScenarioReport(exportTestScenario(), nil)
+	require.Len(t, report.Suites, 1)
+	assert.Equal(t, "Checkout › Payment", report.Suites[0].Name)
+
+	cases := report.Suites[0].Cases
+	require.Len(t, cases, 2)
+	assert.Equal(t, ReportFailed, cases[0].Status)
+	assert.Equal(t, "element #pay not found", cases[0].Message)
+	assert.Equal(t, "Step 2 (Click pay): element #pay not found\nat click", cases[0].Trace)
+	assert.Len(t, cases[0].Steps, 2)
+	assert.Len(t, cases[0].Attachments, 2, "the failed step's screenshot and the video")
+	assert.Equal(t, ReportSkipped, cases[1].Status)
+	assert.Equal(t, "not automated", cases[1].Message)
+
+	ran := time.Date(2026, 5, 2, 9, 0, 0, 0, time.UTC)
+	history := map[string][]models.TestResult{"auto1": {
+		{Status: "passed", RunDurationMs: 1000, RanAt: &ran},
+		{Status: "failed", StepResults: []models.TestStepResult{{StepIndex: 1, Status: "failure", Error: "timeout"}}},
+	}}
+	report = ScenarioReport(exportTestScenario(), history)
+	cases = report.Suites[0].Cases
+	require.Len(t, cases, 3)
+	assert.Equal(t, "TC-001 Pay by card @ 2026-05-02T09:00:00Z", cases[0].Name)
+	assert.Equal(t, ReportPassed, cases[0].Status)
+	assert.Equal(t, "timeout", cases[1].Message)
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	data, err := WriteJUnitReport(ScenarioReport(exportTestScenario(), nil))
+	require.NoError(t, err)
+	xml := string(data)
+	assert.Contains(t, xml, `<testsuites name="Checkout" tests="2" failures="1" skipped="1" time="3.200">`)
+	assert.Contains(t, xml, `<testsuite name="Checkout › Payment"`)
+	assert.Contains(t, xml, `<failure message="element #pay not found" type="AssertionError">Step 2 (Click pay)`)
+	assert.Contains(t, xml, "Video: [[ATTACHMENT|https://cdn.example.com/run.webm]]")
+	assert.NotContains(t, xml, "data:image", "inline screenshots are left out")
+}
+
+func TestWriteCTRFReport(t *testing.T) {
+	run := models.SuiteRun{
+		ID:        "suite-1",
+		Name:      "Nightly",
+		CreatedAt: time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC),
+		Ref:       &models.SuiteRunRef{Branch: "main", CommitSHA: "abc123"},
+		Results: []models.SuiteCaseResult{
+			{ScenarioID: "s1", ScenarioTitle: "Auth", SectionID: "a", SectionTitle: "Login", TestCaseID: "tc1", Code: "TC-001", Title: "Log in", Status: models.SuiteRunPassed, DurationMs: 1500},
+			{ScenarioID: "s1", ScenarioTitle: "Auth", SectionID: "a", SectionTitle: "Login", TestCaseID: "tc2", Code: "TC-002", Title: "Log out", Status: models.SuiteRunRunning},
+		},
+	}
+	data, err := WriteCTRFReport(SuiteRunReport(run))
+	require.NoError(t, err)
+
+	var out ctrfReport
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "CTRF", out.ReportFormat)
+	assert.Equal(t, ctrfSummary{Tests: 2, Passed: 1, Pending: 1, Start: run.CreatedAt.UnixMilli(), Stop: run.CreatedAt.UnixMilli() + 1500}, out.Results.Summary)
+	assert.Equal(t, "Auth › Login", out.Results.Tests[0].Suite)
+	assert.Equal(t, "main", out.Results.Environment["branchName"])
+	assert.Equal(t, "suite-1", out.Results.Extra["suiteRunId"])
+}
+
+func TestWriteAllureResults(t *testing.T) {
+	data, err := WriteAllureResults(ScenarioReport(exportTestScenario(), nil))
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var results []allureResult
+	attachments := 0
+	for _, f := range zr.File {
+		switch {
+		case strings.HasSuffix(f.Name, "-result.json"):
+			rc, err := f.Open()
+			require.NoError(t, err)
+			body, _ := io.ReadAll(rc)
+			rc.Close()
+			var res allureResult
+			require.NoError(t, json.Unmarshal(body, &res))
+			results = append(results, res)
+		case strings.Contains(f.Name, "-attachment"):
+			attachments++
+		}
+	}
+	require.Len(t, results, 2)
+	assert.Equal(t, 1, attachments, "the inline screenshot is written as a file")
+
+	var failed allureResult
+	for _, res := range results {
+		if res.Status == "failed" {
+			failed = res
+		}
+	}
+	assert.Equal(t, "element #pay not found", failed.StatusDetails.Message)
+	require.Len(t, failed.Steps, 2)
+	assert.Len(t, failed.Steps[1].Attachments, 1)
+	assert.Equal(t, []allureLink{{Name: "Video", URL: "https://cdn.example.com/run.webm"}}, failed.Links)
+}
+
+func TestTestCycleReport(t *testing.T) {
+	cycle := models.TestCycle{
+		Name: "Release 1.2",
+		Executions: []models.TestExecution{
+			{TestCaseID: "tc1", Code: "TC-001", Title: "Log in", SectionID: "a", SectionTitle: "Login", Status: models.ExecutionFailed, Comment: "500 on submit",
+				Evidence: []models.ExecutionEvidence{{Type: "screenshot", URL: "https://cdn.example.com/shot.png"}}},
+			{TestCaseID: "tc2", Code: "TC-002", Title: "Log out", SectionID: "a", SectionTitle: "Login", Status: models.ExecutionBlocked, Comment: "by #12"},
+			{TestCaseID: "tc3", Code: "TC-003", Title: "Reset", SectionID: "b", SectionTitle: "Password", Status: models.ExecutionNotRun},
+		},
+	}
+	report := TestCycleReport(cycle)
+	require.Len(t, report.Suites, 2)
+	cases := report.Suites[0].Cases
+	assert.Equal(t, ReportFailed, cases[0].Status)
+	assert.Equal(t, "image/png", cases[0].Attachments[0].ContentType())
+	assert.Equal(t, ReportSkipped, cases[1].Status)
+	assert.Equal(t, "blocked by #12", cases[1].Message)
+	assert.Equal(t, ReportPending, report.Suites[1].Cases[0].Status)
+}
@@ -0,0 +1,542 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Deliveries are signed Stripe-style: X-QA-Signature is "t=<unix>,v1=<hex>",
+// where the hex is the HMAC-SHA256 of "<t>.<body>" under the webhook secret.
+// Receivers should recompute it and reject stale timestamps.
+const (
+	WebhookSignatureHeader = "X-QA-Signature"
+	WebhookEventHeader     = "X-QA-Event"
+	WebhookDeliveryHeader  = "X-QA-Delivery"
+)
+
+const (
+	webhookConsumerGroup = "webhooks"
+	webhookTimeout       = 10 * time.Second
+	webhookPollInterval  = 2 * time.Second
+	webhookWorkers       = 8
+	// webhookDrainLimit caps the response body read to reuse the connection
+	webhookDrainLimit = 4096
+	// webhookEventRetryMax caps the wait before an event whose deliveries
+	// could not be queued is tried again
+	webhookEventRetryMax = 5 * time.Minute
+)
+
+// webhookBackoff is the wait before each retry; a delivery is attempted at
+// most len(webhookBackoff)+1 times
+var webhookBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+}
+
+// webhookAllowPrivate lets webhooks reach loopback and private networks, for
+// deployments whose chat or CI servers are internal
+var webhookAllowPrivate = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+// lookupWebhookHost resolves a webhook host when it is validated
+var lookupWebhookHost = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		// A proxy would hide the address actually dialled
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			// Checked on the resolved address of every connection, so a host
+			// that re-resolves to an internal address after validation is
+			// still refused
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				addr, err := netip.ParseAddr(host)
+				if err != nil {
+					return err
+				}
+				if !webhookAddrAllowed(addr) {
+					return fmt.Errorf("webhook target %s is a private or loopback address", addr)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConnsPerHost: 2,
+	},
+	// A redirect would turn the POST into a GET; report it instead
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookAddrAllowed reports whether webhooks may be sent to an address:
+// loopback, private, link-local (including cloud metadata endpoints),
+// carrier-grade NAT and unspecified addresses are refused
+func webhookAddrAllowed(addr netip.Addr) bool {
+	if webhookAllowPrivate {
+		return true
+	}
+	addr = addr.Unmap()
+	switch {
+	case !addr.IsValid(),
+		addr.IsUnspecified(),
+		addr.IsLoopback(),
+		addr.IsPrivate(),
+		addr.IsLinkLocalUnicast(),
+		addr.IsLinkLocalMulticast(),
+		addr.IsInterfaceLocalMulticast(),
+		addr.IsMulticast(),
+		sharedAddressSpace.Contains(addr):
+		return false
+	}
+	return true
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598)
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// WebhookPayload is the JSON body of a delivery. Data is the stream event, in
+// the same envelope the SSE stream sends.
+type WebhookPayload struct {
+	DeliveryID string               `json:"deliveryId"`
+	Event      string               `json:"event"`
+	ProjectID  string               `json:"projectId"`
+	Timestamp  string               `json:"timestamp"`
+	Data       database.StreamEvent `json:"data"`
+}
+
+// WebhookEventFor names the webhook event a stream event triggers, or returns
+// "" for events that are not delivered (progress, steps, chat replies)
+func WebhookEventFor(ev database.StreamEvent) string {
+	switch ev.Type {
+	case "generation":
+		switch ev.Stage {
+		case "done":
+			return models.WebhookGenerationDone
+		case "error":
+			return models.WebhookGenerationFailed
+		}
+	case "execution":
+		// Only a run's final event carries its outcome
+		switch ev.Outcome {
+		case "":
+			return ""
+		case "passed":
+			return models.WebhookRunPassed
+		default:
+			return models.WebhookRunFailed
+		}
+	case "agent":
+		switch ev.Outcome {
+		case "done":
+			return models.WebhookFixDone
+		case "failed":
+			return models.WebhookFixFailed
+		}
+	}
+	return ""
+}
+
+// SignWebhookPayload computes the X-QA-Signature header value for a body
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// WebhookRetryDelay returns the wait before the next attempt after the given
+// number of failed attempts, and false once the delivery should give up
+func WebhookRetryDelay(attempts int) (time.Duration, bool) {
+	if attempts < 1 || attempts > len(webhookBackoff) {
+		return 0, false
+	}
+	return webhookBackoff[attempts-1], true
+}
+
+// ValidateWebhook checks a webhook's URL and event filter. The URL's host
+// must resolve to public addresses only.
+func ValidateWebhook(ctx context.Context, hook *models.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = lookupWebhookHost(ctx, u.Hostname()); err != nil || len(addrs) == 0 {
+		return fmt.Errorf("url host %s could not be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !webhookAddrAllowed(addr) {
+			return fmt.Errorf("url must not point to a private or loopback address")
+		}
+	}
+	for _, e := range hook.Events {
+		known := false
+		for _, k := range models.WebhookEvents {
+			if e == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+// NewWebhookDelivery builds the delivery of an event to a webhook
+func NewWebhookDelivery(hook models.Webhook, event string, ev database.StreamEvent) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{
+		ID:        uuid.NewString(),
+		WebhookID: hook.ID,
+		Event:     event,
+		EventID:   ev.ID,
+		Status:    models.DeliveryPending,
+		CreatedAt: time.Now(),
+	}
+	payload, err := json.Marshal(WebhookPayload{
+		DeliveryID: d.ID,
+		Event:      event,
+		ProjectID:  hook.ProjectID,
+		Timestamp:  d.CreatedAt.Format(time.RFC3339),
+		Data:       ev,
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return d, nil
+}
+
+// sendWebhook makes one delivery attempt and records its response status on
+// d. The response body is discarded: the delivery log is readable by project
+// members and must not relay what the endpoint returns.
+func sendWebhook(ctx context.Context, hook models.Webhook, secret string, d *models.WebhookDelivery) bool {
+	start := time.Now()
+	d.Attempts++
+	d.ResponseCode = 0
+	d.Error = ""
+	defer func() { d.DurationMs = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		d.Error = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "qa-extension-webhooks/1")
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, start.Unix(), d.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		d.Error = err.Error()
+		return false
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookDrainLimit))
+	d.ResponseCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		d.Error = fmt.Sprintf("endpoint responded %s", resp.Status)
+		return false
+	}
+	return true
+}
+
+// attemptWebhookDelivery sends a queued delivery and either completes it or
+// schedules the next retry
+func attemptWebhookDelivery(ctx context.Context, deliveryID string) {
+	d, err := database.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		// Expired from the log; nothing left to send
+		return
+	}
+	hook, err := database.GetWebhook(ctx, d.WebhookID)
+	if err != nil {
+		// The webhook was deleted along with its log
+		return
+	}
+	secret, err := database.GetWebhookSecret(ctx, hook.ID)
+	if err != nil {
+		return
+	}
+
+	d.NextAttemptAt = nil
+	switch {
+	case !hook.Active:
+		d.Status = models.DeliveryFailed
+		d.Error = "webhook is disabled"
+	case sendWebhook(ctx, hook, secret, &d):
+		d.Status = models.DeliverySucceeded
+	default:
+		delay, retry := WebhookRetryDelay(d.Attempts)
+		if !retry {
+			d.Status = models.DeliveryFailed
+			log.Printf("[Webhook] Giving up on delivery %s to %s after %d attempts: %s", d.ID, hook.URL, d.Attempts, d.Error)
+			break
+		}
+		next := time.Now().Add(delay)
+		d.Status = models.DeliveryRetrying
+		d.NextAttemptAt = &next
+		if err := database.ScheduleWebhookDelivery(ctx, d.ID, next); err != nil {
+			log.Printf("[Webhook] Failed to schedule retry of %s: %v", d.ID, err)
+		}
+	}
+	if err := database.SaveWebhookDelivery(ctx, &d); err != nil {
+		log.Printf("[Webhook] Failed to log delivery %s: %v", d.ID, err)
+	}
+}
+
+// TestFireWebhook sends a ping to a webhook straight away, without retries,
+// and logs the result like any other delivery
+func TestFireWebhook(ctx context.Context, hook models.Webhook) (*models.WebhookDelivery, error) {
+	secret, err := database.GetWebhookSecret(ctx, hook.ID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := NewWebhookDelivery(hook, models.WebhookPing, database.StreamEvent{
+		Type:      "system",
+		ProjectID: hook.ProjectID,
+		Stage:     "ping",
+		Message:   fmt.Sprintf("Test delivery for webhook '%s'", hook.Name),
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sendWebhook(ctx, hook, secret, d) {
+		d.Status = models.DeliverySucceeded
+	} else {
+		d.Status = models.DeliveryFailed
+	}
+	if err := database.SaveWebhookDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// enqueueWebhookDeliveries queues a delivery of the event to each active
+// webhook of its project that subscribes to it
+func enqueueWebhookDeliveries(ctx context.Context, ev database.StreamEvent) error {
+	event := WebhookEventFor(ev)
+	if event == "" || ev.ProjectID == "" {
+		return nil
+	}
+	hooks, err := database.ListProjectWebhooks(ctx, ev.ProjectID)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if !hook.Active || !hook.Subscribes(event) {
+			continue
+		}
+		d, err := NewWebhookDelivery(hook, event, ev)
+		if err != nil {
+			return err
+		}
+		if err := database.SaveWebhookDelivery(ctx, d); err != nil {
+			return err
+		}
+		if err := database.ScheduleWebhookDelivery(ctx, d.ID, d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunWebhookDispatcher turns stream events into webhook deliveries and sends
+// queued deliveries until ctx is done. Every instance runs it: the consumer
+// group hands each event to one instance, and claiming a queued delivery
+// removes it from the queue, so each attempt is made once.
+func RunWebhookDispatcher(ctx context.Context) {
+	consumer, _ := os.Hostname()
+	consumer += "-" + strconv.Itoa(os.Getpid())
+
+	for {
+		err := database.EnsureStreamGroup(ctx, webhookConsumerGroup)
+		if err == nil {
+			break
+		}
+		log.Printf("[Webhook] Failed to create consumer group: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+
+	go sendQueuedWebhooks(ctx)
+
+	// Events handed to this consumer before a restart come first. Events whose
+	// deliveries could not be queued stay pending too, and the pending list is
+	// read again once one of them is due.
+	retries := newEventRetries()
+	pending := true
+	for {
+		if !pending && retries.due(time.Now()) {
+			pending = true
+		}
+		events, err := database.ReadStreamGroup(ctx, webhookConsumerGroup, consumer, pending, 5*time.Second)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[Webhook] Failed to read event log: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		if pending && len(events) == 0 {
+			pending = false
+			retries.reset()
+			continue
+		}
+
+		progress := false
+		now := time.Now()
+		for _, ev := range events {
+			if !retries.ready(ev.ID, now) {
+				continue
+			}
+			if err := enqueueWebhookDeliveries(ctx, ev); err != nil {
+				delay := retries.failed(ev.ID, now)
+				log.Printf("[Webhook] Failed to queue deliveries for event %s, retrying in %s: %v", ev.ID, delay, err)
+				continue
+			}
+			retries.done(ev.ID)
+			progress = true
+			if err := database.AckStreamEvents(ctx, webhookConsumerGroup, ev.ID); err != nil {
+				log.Printf("[Webhook] Failed to acknowledge event %s: %v", ev.ID, err)
+			}
+		}
+		if pending && !progress {
+			// Every pending event is failing or waiting; go back to new
+			// events and only reread the pending ones once a retry is due
+			pending = false
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(webhookPollInterval):
+			}
+		}
+	}
+}
+
+// eventRetries backs off events whose deliveries could not be queued, by the
+// number of failed attempts
+type eventRetries struct {
+	attempts map[string]int
+	next     map[string]time.Time
+}
+
+func newEventRetries() *eventRetries {
+	return &eventRetries{attempts: make(map[string]int), next: make(map[string]time.Time)}
+}
+
+// ready reports whether an event may be tried now
+func (r *eventRetries) ready(id string, now time.Time) bool {
+	next, ok := r.next[id]
+	return !ok || !now.Before(next)
+}
+
+// due reports whether any backed-off event may be tried now
+func (r *eventRetries) due(now time.Time) bool {
+	for _, next := range r.next {
+		if !now.Before(next) {
+			return true
+		}
+	}
+	return false
+}
+
+// failed records a failed attempt and returns the wait before the next one:
+// one second, doubling with each attempt up to webhookEventRetryMax
+func (r *eventRetries) failed(id string, now time.Time) time.Duration {
+	r.attempts[id]++
+	delay := webhookEventRetryMax
+	if n := r.attempts[id] - 1; n < 16 {
+		delay = min(time.Second<<n, webhookEventRetryMax)
+	}
+	r.next[id] = now.Add(delay)
+	return delay
+}
+
+func (r *eventRetries) done(id string) {
+	delete(r.attempts, id)
+	delete(r.next, id)
+}
+
+// reset forgets every event, once none is pending any more
+func (r *eventRetries) reset() {
+	clear(r.attempts)
+	clear(r.next)
+}
+
+// sendQueuedWebhooks attempts due deliveries with a bounded number of workers
+func sendQueuedWebhooks(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	workers := make(chan struct{}, webhookWorkers)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := database.DueWebhookDeliveries(ctx, time.Now())
+		if err != nil {
+			log.Printf("[Webhook] Failed to read delivery queue: %v", err)
+			continue
+		}
+		for _, id := range ids {
+			claimed, err := database.UnscheduleWebhookDelivery(ctx, id)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("[Webhook] Failed to claim delivery %s: %v", id, err)
+			}
+			if !claimed {
+				continue
+			}
+			workers <- struct{}{}
+			go func(id string) {
+				defer func() { <-workers }()
+				attemptWebhookDelivery(ctx, id)
+			}(id)
+		}
+	}
+}
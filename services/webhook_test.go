@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"qa-extension-backend/database"
+	"qa-extension-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookEventFor(t *testing.T) {
+	cases := []struct {
+		ev   database.StreamEvent
+		want string
+	}{
+		{database.StreamEvent{Type: "generation", Stage: "done"}, models.WebhookGenerationDone},
+		{database.StreamEvent{Type: "generation", Stage: "error"}, models.WebhookGenerationFailed},
+		{database.StreamEvent{Type: "generation", Stage: "progress"}, ""},
+		{database.StreamEvent{Type: "execution", Stage: "done", Outcome: "passed"}, models.WebhookRunPassed},
+		{database.StreamEvent{Type: "execution", Stage: "error", Outcome: "timeout"}, models.WebhookRunFailed},
+		{database.StreamEvent{Type: "execution", Stage: "done"}, ""},
+		{database.StreamEvent{Type: "agent", Stage: "done", Outcome: "done"}, models.WebhookFixDone},
+		{database.StreamEvent{Type: "agent", Stage: "done"}, ""},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, WebhookEventFor(tc.ev), "%s/%s/%s", tc.ev.Type, tc.ev.Stage, tc.ev.Outcome)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	delay, ok := WebhookRetryDelay(1)
+	assert.True(t, ok)
+	assert.Equal(t, webhookBackoff[0], delay)
+
+	_, ok = WebhookRetryDelay(len(webhookBackoff) + 1)
+	assert.False(t, ok, "gives up once the backoff schedule is exhausted")
+}
+
+// allowLocalWebhooks lets a test deliver to an httptest server on loopback
+func allowLocalWebhooks(t *testing.T) {
+	webhookAllowPrivate = true
+	t.Cleanup(func() { webhookAllowPrivate = false })
+}
+
+func TestValidateWebhook(t *testing.T) {
+	hosts := map[string][]netip.Addr{
+		"chat.example.com":     {netip.MustParseAddr("93.184.215.14")},
+		"internal.example.com": {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.0.0.5")},
+	}
+	lookup := lookupWebhookHost
+	lookupWebhookHost = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if addrs, ok := hosts[host]; ok {
+			return addrs, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	t.Cleanup(func() { lookupWebhookHost = lookup })
+
+	ctx := context.Background()
+	assert.NoError(t, ValidateWebhook(ctx, &models.Webhook{URL: "https://chat.example.com/hook", Events: []string{models.WebhookRunFailed}}))
+	assert.Error(t, ValidateWebhook(ctx, &models.Webhook{URL: "ftp://example.com"}))
+	assert.Error(t, ValidateWebhook(ctx, &models.Webhook{URL: "/relative"}))
+	assert.Error(t, ValidateWebhook(ctx, &models.Webhook{URL: "https://chat.example.com", Events: []string{"run.maybe"}}))
+	assert.Error(t, ValidateWebhook(ctx, &models.Webhook{URL: "https://unknown.example.com/hook"}))
+
+	for _, target := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://192.168.1.10/hook",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://100.64.0.1/hook",
+		"https://internal.example.com/hook",
+	} {
+		assert.Error(t, ValidateWebhook(ctx, &models.Webhook{URL: target}), target)
+	}
+}
+
+func TestSendWebhookRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request should not have been sent")
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{ID: "hook-1", URL: srv.URL}
+	d, err := NewWebhookDelivery(hook, models.WebhookPing, database.StreamEvent{})
+	require.NoError(t, err)
+	assert.False(t, sendWebhook(context.Background(), hook, "s", d))
+	assert.Contains(t, d.Error, "private or loopback")
+}
+
+func TestEventRetriesBackOff(t *testing.T) {
+	r := newEventRetries()
+	now := time.Now()
+	assert.True(t, r.ready("1-0", now))
+
+	assert.Equal(t, time.Second, r.failed("1-0", now))
+	assert.False(t, r.ready("1-0", now))
+	assert.False(t, r.due(now))
+	assert.True(t, r.ready("1-0", now.Add(time.Second)))
+	assert.Equal(t, 2*time.Second, r.failed("1-0", now))
+	for i := 0; i < 20; i++ {
+		r.failed("1-0", now)
+	}
+	assert.Equal(t, webhookEventRetryMax, r.failed("1-0", now))
+	assert.True(t, r.due(now.Add(webhookEventRetryMax)))
+
+	r.done("1-0")
+	assert.True(t, r.ready("1-0", now))
+	assert.False(t, r.due(now.Add(time.Hour)))
+}
+
+func TestSendWebhookSignsPayload(t *testing.T) {
+	allowLocalWebhooks(t)
+	const secret = "whsec_test"
+	var gotEvent, gotSignature string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEvent = r.Header.Get(WebhookEventHeader)
+		gotSignature = r.Header.Get(WebhookSignatureHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{ID: "hook-1", ProjectID: "42", URL: srv.URL}
+	d, err := NewWebhookDelivery(hook, models.WebhookRunFailed, database.StreamEvent{ID: "1-0", Type: "execution", Outcome: "failed"})
+	require.NoError(t, err)
+
+	assert.True(t, sendWebhook(context.Background(), hook, secret, d))
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusNoContent, d.ResponseCode)
+	assert.Equal(t, models.WebhookRunFailed, gotEvent)
+	assert.Equal(t, string(d.Payload), string(gotBody))
+
+	// The receiver can verify the body with the shared secret
+	var ts int64
+	var sig string
+	_, err = fmt.Sscanf(gotSignature, "t=%d,v1=%s", &ts, &sig)
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "."))
+	mac.Write(gotBody)
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), sig)
+}
+
+func TestSendWebhookReportsFailures(t *testing.T) {
+	allowLocalWebhooks(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	hook := models.Webhook{ID: "hook-1", URL: srv.URL}
+	d, err := NewWebhookDelivery(hook, models.WebhookPing, database.StreamEvent{})
+	require.NoError(t, err)
+
+	assert.False(t, sendWebhook(context.Background(), hook, "s", d))
+	assert.Equal(t, http.StatusServiceUnavailable, d.ResponseCode)
+	assert.Contains(t, d.Error, "503")
+	assert.NotContains(t, d.Error, "down for maintenance", "the response body is not kept")
+}